@@ -0,0 +1,309 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"github.com/urfave/cli"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/core/equity"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/core/hand_processor"
+)
+
+/*
+
+牌型计算的命令行工具，结果都以json输出到stdout，方便脚本调用
+退出码：0成功，1校验有不一致的结果，2参数或输入有误
+
+*/
+
+const (
+	VariantFName = "variant"
+	BoardFName = "board"
+	DeadFName = "dead"
+	SamplesFName = "samples"
+	MaxEnumerationsFName = "max_enumerations"
+	SeedFName = "seed"
+)
+
+const (
+	exitMismatch = 1
+	exitInvalid = 2
+)
+
+const (
+	variantHoldem = "holdem"
+	variantShortDeck = "short"
+	variantOmaha = "omaha"
+	// 高低牌玩法，结果里才有低牌
+	variantOmahaHiLo = "omaha_hilo"
+	// 七张梭哈高低牌，七张牌都写在HAND里
+	variantStudHiLo = "stud_hilo"
+)
+
+func main() {
+	if err := newApp(os.Stdout).Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitInvalid)
+	}
+}
+
+// 结果写到stdout，测试时换成buffer
+func newApp(stdout io.Writer) *cli.App {
+	app := cli.NewApp()
+	app.Name = "hand_eval"
+	app.Usage = "evaluate, compare and rank poker hands"
+	app.Writer = stdout
+	// 不设置时cli的退出码是3
+	app.CommandNotFound = func(c *cli.Context, command string) {
+		fmt.Fprintf(cli.ErrWriter, "unknown command %v\n", command)
+		cli.OsExiter(exitInvalid)
+	}
+	variantFlag := cli.StringFlag{ Name: VariantFName, Value: variantHoldem, Usage: "holdem, short, omaha, omaha_hilo or stud_hilo" }
+	boardFlag := cli.StringFlag{ Name: BoardFName, Usage: "common pokers, e.g. Kd7c2h" }
+	app.Commands = []cli.Command{
+		{
+			Name: "eval",
+			Usage: "evaluate a hand",
+			ArgsUsage: "HAND",
+			Flags: []cli.Flag{ variantFlag, boardFlag },
+			Action: evalHand,
+		},
+		{
+			Name: "compare",
+			Usage: "compare hands, rank 1 is the best",
+			ArgsUsage: "HAND HAND [HAND...]",
+			Flags: []cli.Flag{ variantFlag, boardFlag },
+			Action: compareHands,
+		},
+		{
+			Name: "rank",
+			Usage: "sort the hands of a json file like {\"hands\":[{\"hand\":\"AsKsQsJsTs\"}]}",
+			ArgsUsage: "FILE",
+			Action: rankFile,
+		},
+		{
+			Name: "match",
+			Usage: "validate a json file like {\"matches\":[{\"alice\":\"...\",\"bob\":\"...\",\"result\":1}]}",
+			ArgsUsage: "FILE",
+			Action: matchFile,
+		},
+		{
+			Name: "equity",
+			Usage: "compute equity of ranges, e.g. AsAh \"KK+,AKs\"",
+			ArgsUsage: "RANGE RANGE [RANGE...]",
+			Flags: []cli.Flag{
+				boardFlag,
+				cli.StringFlag{ Name: DeadFName, Usage: "pokers not in the deck" },
+				// 为0时用默认值
+				cli.IntFlag{ Name: SamplesFName },
+				cli.IntFlag{ Name: MaxEnumerationsFName },
+				cli.Int64Flag{ Name: SeedFName },
+			},
+			Action: calcEquity,
+		},
+	}
+	return app
+}
+
+func invalid(format string, a ...interface{}) error {
+	return cli.NewExitError(fmt.Sprintf(format, a...), exitInvalid)
+}
+
+func output(c *cli.Context, v interface{}) error {
+	if err := json.NewEncoder(c.App.Writer).Encode(v); err != nil {
+		return cli.NewExitError(err.Error(), exitInvalid)
+	}
+	return nil
+}
+
+type handResult struct {
+	Hand string `json:"hand"`
+	HandType string `json:"hand_type"`
+	Name string `json:"name"`
+	CnName string `json:"cn_name"`
+	BestFive string `json:"best_five"`
+	// 赖子当成了哪张牌
+	LzAs string `json:"lz_as,omitempty"`
+	// 高低牌玩法中8或更小的低牌，没有时为空
+	Low string `json:"low,omitempty"`
+}
+
+// 按玩法算手牌和公共牌组成的牌型，奥马哈必须有公共牌
+func handOf(variant string, handStr string, board string) (*hand_processor.Hand, error) {
+	switch variant {
+	case variantHoldem, variantStudHiLo:
+		return hand_processor.HandStrToHand(handStr + board)
+	case variantShortDeck:
+		return hand_processor.ShortDeckHandStrToHand(handStr + board)
+	case variantOmaha, variantOmahaHiLo:
+		return hand_processor.OmahaHandStrToHand(handStr, board)
+	}
+	return nil, fmt.Errorf("unknown variant %v", variant)
+}
+
+// 只有高低牌玩法算低牌
+func lowOf(variant string, handStr string, board string) (hand_processor.LowRank, bool) {
+	var low hand_processor.LowRank
+	var ok bool
+	var err error
+	switch variant {
+	case variantStudHiLo:
+		low, ok, err = hand_processor.LowHandStrToLow(handStr + board)
+	case variantOmahaHiLo:
+		low, ok, err = hand_processor.OmahaLowHandStrToLow(handStr, board)
+	}
+	return low, ok && err == nil
+}
+
+func toHandResult(c *cli.Context, handStr string) (*handResult, *hand_processor.Hand, error) {
+	variant, board := c.String(VariantFName), c.String(BoardFName)
+	hand, err := handOf(variant, handStr, board)
+	if err != nil {
+		return nil, nil, invalid("%v: %v", handStr, err)
+	}
+	result := &handResult{
+		Hand: handStr,
+		HandType: hand.GetHandType().EnString(),
+		Name: hand.Description(),
+		CnName: hand.CnDescription(),
+		BestFive: hand_processor.PokersToString(hand.GetSortedPokers()),
+	}
+	if lzAs := hand.GetLzAs(); lzAs != nil {
+		result.LzAs = lzAs.GetWhole()
+	}
+	if low, ok := lowOf(variant, handStr, board); ok {
+		result.Low = low.String()
+	}
+	return result, hand, nil
+}
+
+func evalHand(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return invalid("eval needs exactly one hand")
+	}
+	result, _, err := toHandResult(c, c.Args().First())
+	if err != nil {
+		return err
+	}
+	return output(c, result)
+}
+
+type compareResult struct {
+	Hands []*handResult `json:"hands"`
+	// 每手牌的名次，一样大的名次一样
+	Ranks []int `json:"ranks"`
+	// 最大的几手牌的下标
+	Winners []int `json:"winners"`
+}
+
+func compareHands(c *cli.Context) error {
+	if c.NArg() < 2 {
+		return invalid("compare needs at least two hands")
+	}
+	result := &compareResult{ Ranks: make([]int, c.NArg()) }
+	var hands []*hand_processor.Hand
+	for _, handStr := range c.Args() {
+		hr, hand, err := toHandResult(c, handStr)
+		if err != nil {
+			return err
+		}
+		result.Hands = append(result.Hands, hr)
+		hands = append(hands, hand)
+	}
+
+	order := make([]int, len(hands))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return hands[order[i]].Match(hands[order[j]]) == 1
+	})
+	for i, idx := range order {
+		result.Ranks[idx] = i + 1
+		if i > 0 && hands[idx].Match(hands[order[i - 1]]) == 0 {
+			result.Ranks[idx] = result.Ranks[order[i - 1]]
+		}
+		if result.Ranks[idx] == 1 {
+			result.Winners = append(result.Winners, idx)
+		}
+	}
+	sort.Ints(result.Winners)
+	return output(c, result)
+}
+
+func rankFile(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return invalid("rank needs a json file")
+	}
+	var rHands hand_processor.RHands
+	if err := hand_processor.ReadJsonFromFile(c.Args().First(), &rHands); err != nil {
+		return invalid("%v", err)
+	}
+	sorted, err := rHands.Sort()
+	if err != nil {
+		return invalid("%v", err)
+	}
+	return output(c, sorted)
+}
+
+type matchResult struct {
+	Total int `json:"total"`
+	Failed []*hand_processor.Match `json:"failed"`
+}
+
+// 有不一致的结果或者不合格的牌时退出码是1
+func matchFile(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return invalid("match needs a json file")
+	}
+	var matches hand_processor.Matches
+	if err := hand_processor.ReadJsonFromFile(c.Args().First(), &matches); err != nil {
+		return invalid("%v", err)
+	}
+	result := &matchResult{ Total: len(matches.Matches), Failed: matches.Validate() }
+	if err := output(c, result); err != nil {
+		return err
+	}
+	if len(result.Failed) > 0 {
+		return cli.NewExitError("", exitMismatch)
+	}
+	return nil
+}
+
+type equityResult struct {
+	Ranges []string `json:"ranges"`
+	*equity.Result
+}
+
+func calcEquity(c *cli.Context) error {
+	if c.NArg() < 2 {
+		return invalid("equity needs at least two ranges")
+	}
+	req := &equity.Request{
+		Samples: c.Int(SamplesFName),
+		MaxEnumerations: c.Int(MaxEnumerationsFName),
+		Seed: c.Int64(SeedFName),
+	}
+	var err error
+	if req.Board, err = hand_processor.CardsOf(c.String(BoardFName)); err != nil {
+		return invalid("board: %v", err)
+	}
+	if req.Dead, err = hand_processor.CardsOf(c.String(DeadFName)); err != nil {
+		return invalid("dead: %v", err)
+	}
+	result := &equityResult{}
+	for _, str := range c.Args() {
+		r, err := equity.ParseRange(str)
+		if err != nil {
+			return invalid("%v: %v", str, err)
+		}
+		req.Players = append(req.Players, r)
+		result.Ranges = append(result.Ranges, r.String())
+	}
+	if result.Result, err = equity.Calculate(req); err != nil {
+		return invalid("%v", err)
+	}
+	return output(c, result)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"github.com/urfave/cli"
+	"github.com/stretchr/testify/assert"
+)
+
+// 跑一次命令，返回退出码和stdout，cli的退出换成记下退出码
+func runApp(t *testing.T, args ...string) (int, []byte) {
+	code := 0
+	oldExiter, oldErrWriter := cli.OsExiter, cli.ErrWriter
+	cli.OsExiter = func(c int) {
+		if code == 0 {
+			code = c
+		}
+	}
+	cli.ErrWriter = ioutil.Discard
+	defer func() {
+		cli.OsExiter, cli.ErrWriter = oldExiter, oldErrWriter
+	}()
+	var out bytes.Buffer
+	// 和main一样，不是cli.ExitCoder的错误都是参数有误
+	if err := newApp(&out).Run(append([]string{ "hand_eval" }, args...)); err != nil && code == 0 {
+		code = exitInvalid
+	}
+	return code, out.Bytes()
+}
+
+func TestEval(t *testing.T) {
+	code, out := runApp(t, "eval", "--board", "Kd7c2h", "AsKs")
+	assert.Equal(t, 0, code)
+	var result handResult
+	assert.NoError(t, json.Unmarshal(out, &result))
+	assert.Equal(t, "AsKs", result.Hand)
+	assert.Equal(t, "One pair", result.HandType)
+	assert.Equal(t, "Pair of Kings", result.Name)
+	assert.Len(t, result.BestFive, 10)
+	// 不是高低牌玩法没有低牌
+	assert.Empty(t, result.Low)
+
+	code, out = runApp(t, "eval", "--variant", "omaha_hilo", "--board", "3s4d5cQhJh", "As2hKdKc")
+	assert.Equal(t, 0, code)
+	assert.NoError(t, json.Unmarshal(out, &result))
+	assert.Equal(t, "5-4-3-2-A", result.Low)
+}
+
+func TestCompare(t *testing.T) {
+	code, out := runApp(t, "compare", "--board", "AhKdQc2s3s", "JdTd", "JcTc", "4h5h")
+	assert.Equal(t, 0, code)
+	var result compareResult
+	assert.NoError(t, json.Unmarshal(out, &result))
+	// 两个一样的顺子并列第一
+	assert.Equal(t, []int{ 1, 1, 3 }, result.Ranks)
+	assert.Equal(t, []int{ 0, 1 }, result.Winners)
+}
+
+func TestMatchExitCode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hand_eval")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	write := func(name string, content string) string {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+
+	code, out := runApp(t, "match", write("ok.json", `{"matches":[{"alice":"AsAhKdQc2s","bob":"KsKhQdJc2d","result":1}]}`))
+	assert.Equal(t, 0, code)
+	var result matchResult
+	assert.NoError(t, json.Unmarshal(out, &result))
+	assert.Equal(t, 1, result.Total)
+	assert.Empty(t, result.Failed)
+
+	// 期望的结果不对
+	code, out = runApp(t, "match", write("failed.json", `{"matches":[{"alice":"AsAhKdQc2s","bob":"KsKhQdJc2d","result":2}]}`))
+	assert.Equal(t, exitMismatch, code)
+	assert.NoError(t, json.Unmarshal(out, &result))
+	assert.Len(t, result.Failed, 1)
+}
+
+func TestInvalidInput(t *testing.T) {
+	for _, args := range [][]string{
+		{ "eval", "AsXx" },
+		{ "eval", "--variant", "razz", "AsKs" },
+		{ "compare", "AsKsQsJsTs" },
+		{ "match", "not_exist.json" },
+		{ "unknown" },
+	} {
+		code, out := runApp(t, args...)
+		assert.Equal(t, exitInvalid, code, "%v", args)
+		assert.Empty(t, out, "%v", args)
+	}
+}
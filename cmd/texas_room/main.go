@@ -6,7 +6,9 @@ import (
 	"syscall"
 	"os/signal"
 	"time"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/common/msg_server"
 	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/core"
 )
 
 const (
@@ -14,6 +16,16 @@ const (
 	TableSeatCountFName = "ts_count"
 	TableLevelFName = "t_level"
 	PortFName = "port"
+	DrainTimeoutFName = "drain_timeout"
+	SnapshotDirFName = "snapshot_dir"
+	SnapshotIntervalFName = "snapshot_interval"
+	ResumeGamesFName = "resume_games"
+	SignKeyFName = "sign_key"
+	RequireEncryptionFName = "require_encryption"
+	TLSCertFName = "tls_cert"
+	TLSKeyFName = "tls_key"
+	AllowedOriginsFName = "allowed_origins"
+	MaxPeersFName = "max_peers"
 )
 
 func main() {
@@ -23,6 +35,24 @@ func main() {
 		cli.IntFlag{ Name: TableSeatCountFName, Value: 5 },
 		cli.IntFlag{ Name: TableLevelFName, Value: 1 },
 		cli.IntFlag{ Name: PortFName, Value: 3030 },
+		// 停服时等待正在打的局结束的秒数
+		cli.IntFlag{ Name: DrainTimeoutFName, Value: 30 },
+		// 桌子快照的目录，为空则不做崩溃恢复
+		cli.StringFlag{ Name: SnapshotDirFName },
+		// 存快照的间隔毫秒数
+		cli.IntFlag{ Name: SnapshotIntervalFName, Value: 500 },
+		// 重启时正在打的局接着打，为false则作废并退回下注
+		cli.BoolTFlag{ Name: ResumeGamesFName },
+		// hex编码的ed25519 seed文件，为空则不支持加密会话
+		cli.StringFlag{ Name: SignKeyFName },
+		// 不加密的客户端握手失败
+		cli.BoolFlag{ Name: RequireEncryptionFName },
+		// 证书和私钥的pem文件，都不为空时用wss
+		cli.StringFlag{ Name: TLSCertFName },
+		cli.StringFlag{ Name: TLSKeyFName },
+		// 允许的浏览器Origin，可以传多个，"*"允许所有，不传时只允许同源
+		cli.StringSliceFlag{ Name: AllowedOriginsFName },
+		cli.IntFlag{ Name: MaxPeersFName, Value: 1000 },
 	}
 	app.Action = run
 
@@ -32,7 +62,22 @@ func main() {
 }
 
 func run(c *cli.Context) {
-	room := texas.NewRoomServer(c.Int(TableCountFName), c.Int(TableSeatCountFName), c.Int(TableLevelFName), c.Int(PortFName))
+	opts := msg_server.DefaultWsOptions(c.Int(PortFName))
+	opts.CertFile, opts.KeyFile = c.String(TLSCertFName), c.String(TLSKeyFName)
+	opts.AllowedOrigins = c.StringSlice(AllowedOriginsFName)
+	opts.MaxPeers = int64(c.Int(MaxPeersFName))
+	room := texas.NewRoomServerWithOptions(c.Int(TableCountFName), c.Int(TableSeatCountFName), c.Int(TableLevelFName), opts)
+	room.SetDrainTimeout(time.Duration(c.Int(DrainTimeoutFName)) * time.Second)
+	if dir := c.String(SnapshotDirFName); dir != "" {
+		room.EnableRecovery(core.NewFileSnapshotStore(dir), time.Duration(c.Int(SnapshotIntervalFName)) * time.Millisecond, c.BoolT(ResumeGamesFName))
+	}
+	if path := c.String(SignKeyFName); path != "" {
+		signKey, err := msg_server.ReadSignKey(path)
+		if err != nil {
+			panic(err)
+		}
+		room.EnableEncryption(signKey, c.Bool(RequireEncryptionFName))
+	}
 	if err := room.Start(); err != nil {
 		panic(err)
 	}
@@ -40,14 +85,13 @@ func run(c *cli.Context) {
 		if err := room.Stop(); err != nil {
 			panic(err)
 		}
-		time.Sleep(1 * time.Second)
 	})
 }
 
 // listen stop signal
 func signalListen(stopFunc func()) {
-	c := make(chan os.Signal)
-	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM, syscall.SIGKILL)
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
 	<-c
 
 	stopFunc()
@@ -0,0 +1,97 @@
+package msg_server
+
+import (
+	"compress/flate"
+	"context"
+	"math/big"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/common/util"
+)
+
+func TestWrapBatch(t *testing.T) {
+	msgs := [][]byte{ WrapMsg(1, 2, []byte("alice")), WrapMsg(3, 4, nil) }
+	msgType, mID, body := UnWrapMsg(WrapBatch(msgs))
+	assert.Equal(t, MsgTypeBatch, msgType)
+	assert.Equal(t, 0, int(mID))
+	result, err := UnWrapBatch(body)
+	assert.NoError(t, err)
+	assert.Equal(t, msgs, result)
+
+	_, err = UnWrapBatch(body[:len(body) - 1])
+	assert.Error(t, err)
+	_, err = UnWrapBatch([]byte{ 0, 0 })
+	assert.Error(t, err)
+}
+
+func dialCompressed(t *testing.T, host string, req HandShakeReq) *websocket.Conn {
+	u := url.URL{ Scheme: "ws", Host: host, Path: "/msg" }
+	dialer := &websocket.Dialer{ EnableCompression: true }
+	conn, _, err := dialer.Dial(u.String(), nil)
+	if err != nil {
+		panic(err)
+	}
+	assert.NoError(t, conn.WriteMessage(websocket.BinaryMessage, WrapMsg(MsgTypeHandShake, 1, util.StringifyJsonToBytes(req))))
+	time.Sleep(20 * time.Millisecond)
+	return conn
+}
+
+// 握手时要求批量的连接，窗口内的消息合成一个frame，压缩后比原来小
+func TestBatchAndCompression(t *testing.T) {
+	server := NewWsServer(3337, &fakeUserGetter{}, &fakeMsgHandler{})
+	server.EnableCompression(flate.BestSpeed)
+	server.EnableBatching(50 * time.Millisecond)
+	go server.Run()
+	defer server.Shutdown(context.Background())
+	time.Sleep(10 * time.Millisecond)
+
+	batched := dialCompressed(t, "localhost:3337", HandShakeReq{ Token: "1", Batch: true })
+	defer batched.Close()
+	balance, _ := new(big.Int).SetString(strings.Repeat("9", 300), 10)
+	for i := 0; i < 5; i++ {
+		server.Send("1", playRespMsg, int64(i), playResp{ Balance: balance })
+	}
+	_, mb, err := batched.ReadMessage()
+	assert.NoError(t, err)
+	msgType, _, body := UnWrapMsg(mb)
+	assert.Equal(t, MsgTypeBatch, msgType)
+	msgs, err := UnWrapBatch(body)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, len(msgs))
+	for i, m := range msgs {
+		msgType, mID, content := UnWrapMsg(m)
+		assert.Equal(t, playRespMsg, msgType)
+		assert.Equal(t, i, int(mID))
+		var resp playResp
+		assert.NoError(t, util.ParseJsonFromBytes(content, &resp))
+		assert.Equal(t, balance, resp.Balance)
+	}
+
+	// 写完才统计
+	time.Sleep(10 * time.Millisecond)
+	stats := server.Stats()
+	assert.Equal(t, 5, int(stats.Msgs))
+	assert.Equal(t, 1, int(stats.Frames))
+	assert.True(t, stats.SavedBytes() > stats.PayloadBytes / 2, "%+v", stats)
+
+	// 没要求批量的连接还是一条消息一个frame
+	single := dialCompressed(t, "localhost:3337", HandShakeReq{ Token: "2" })
+	defer single.Close()
+	server.Send("2", playRespMsg, 7, playResp{ Balance: big.NewInt(1) })
+	server.Send("2", playRespMsg, 8, playResp{ Balance: big.NewInt(2) })
+	for _, id := range []int{ 7, 8 } {
+		_, mb, err := single.ReadMessage()
+		assert.NoError(t, err)
+		msgType, mID, _ := UnWrapMsg(mb)
+		assert.Equal(t, playRespMsg, msgType)
+		assert.Equal(t, id, int(mID))
+	}
+	time.Sleep(10 * time.Millisecond)
+	stats = server.Stats()
+	assert.Equal(t, 7, int(stats.Msgs))
+	assert.Equal(t, 3, int(stats.Frames))
+}
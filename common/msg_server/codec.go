@@ -0,0 +1,98 @@
+package msg_server
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/common/util"
+)
+
+/*
+
+消息体的编解码，握手时客户端在HandShakeReq.Codec中选择，之后这个连接收发的消息都用它
+握手消息本身总是json
+客户端不选时用json，兼容老客户端
+
+*/
+type Codec interface {
+	// 握手时用的名字
+	Name() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+const (
+	CodecJson = "json"
+	CodecProtobuf = "protobuf"
+)
+
+var JsonCodec Codec = jsonCodec{}
+
+type jsonCodec struct {}
+
+func (jsonCodec) Name() string { return CodecJson }
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return util.StringifyJsonToBytesWithErr(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return util.ParseJsonFromBytes(data, v)
+}
+
+/*
+
+业务里的结构体和proto消息的互相转换
+业务里的结构体字段多是int、uint，不能直接用proto库编码
+
+*/
+type ProtoConverter interface {
+	// 把要发的消息转成proto消息
+	ToProto(v interface{}) (proto.Message, error)
+	// 和v对应的空proto消息，收到的消息先解码到它上边
+	NewProto(v interface{}) (proto.Message, error)
+	// 把解码后的proto消息赋值给v
+	FromProto(m proto.Message, v interface{}) error
+}
+
+// converter为nil时只能收发proto.Message
+func NewProtoCodec(converter ProtoConverter) Codec {
+	return &protoCodec{ converter: converter }
+}
+
+type protoCodec struct {
+	converter ProtoConverter
+}
+
+func (c *protoCodec) Name() string { return CodecProtobuf }
+
+func (c *protoCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		if c.converter == nil {
+			return nil, errors.New(fmt.Sprintf("%T isn't a proto message", v))
+		}
+		var err error
+		if m, err = c.converter.ToProto(v); err != nil {
+			return nil, err
+		}
+	}
+	return proto.Marshal(m)
+}
+
+func (c *protoCodec) Unmarshal(data []byte, v interface{}) error {
+	if m, ok := v.(proto.Message); ok {
+		return proto.Unmarshal(data, m)
+	}
+	if c.converter == nil {
+		return errors.New(fmt.Sprintf("%T isn't a proto message", v))
+	}
+	m, err := c.converter.NewProto(v)
+	if err != nil {
+		return err
+	}
+	if err = proto.Unmarshal(data, m); err != nil {
+		return err
+	}
+	return c.converter.FromProto(m, v)
+}
@@ -0,0 +1,99 @@
+package msg_server
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+	"github.com/golang/protobuf/proto"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/common/util"
+)
+
+type echoMsg struct {
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name"`
+	Count int64 `protobuf:"varint,2,opt,name=count,proto3" json:"count"`
+}
+
+func (m *echoMsg) Reset() { *m = echoMsg{} }
+func (m *echoMsg) String() string { return proto.CompactTextString(m) }
+func (*echoMsg) ProtoMessage() {}
+
+// 收到的消息count加1后原样发回去
+type echoHandler struct {
+	server *WsServer
+}
+
+func (h *echoHandler) Handle(uID string, msgType int, mID int64, msg []byte, codec Codec) error {
+	var req echoMsg
+	if err := codec.Unmarshal(msg, &req); err != nil {
+		return err
+	}
+	req.Count++
+	h.server.Send(uID, msgType, mID, &req)
+	return nil
+}
+
+func TestCodec(t *testing.T) {
+	msg := &echoMsg{ Name: "alice", Count: 300 }
+	for _, codec := range []Codec{ JsonCodec, NewProtoCodec(nil) } {
+		b, err := codec.Marshal(msg)
+		assert.NoError(t, err)
+		var result echoMsg
+		assert.NoError(t, codec.Unmarshal(b, &result))
+		assert.Equal(t, *msg, result, codec.Name())
+	}
+	// name：tag、长度、5byte，count：tag、2byte的varint
+	b, _ := NewProtoCodec(nil).Marshal(msg)
+	assert.Equal(t, 10, len(b))
+
+	// 没有converter时只能编码proto消息
+	_, err := NewProtoCodec(nil).Marshal(playReq{ Name: "alice" })
+	assert.Error(t, err)
+}
+
+func dialWithCodec(t *testing.T, host string, token string, codec string) *websocket.Conn {
+	u := url.URL{ Scheme: "ws", Host: host, Path: "/msg" }
+	var dialer *websocket.Dialer
+	conn, _, err := dialer.Dial(u.String(), nil)
+	if err != nil {
+		panic(err)
+	}
+	err = conn.WriteMessage(websocket.BinaryMessage, WrapMsg(MsgTypeHandShake, 1, util.StringifyJsonToBytes(HandShakeReq{ Token: token, Codec: codec })))
+	assert.NoError(t, err)
+	return conn
+}
+
+// 握手时选的codec用于这个连接之后收发的所有消息，不支持的codec握手失败
+func TestHandShakeCodec(t *testing.T) {
+	h := &echoHandler{}
+	server := NewWsServer(3336, &fakeUserGetter{}, h)
+	server.AddCodec(NewProtoCodec(nil))
+	h.server = server
+	go server.Run()
+	defer server.Shutdown(context.Background())
+	time.Sleep(10 * time.Millisecond)
+
+	for token, codec := range map[string]Codec{ "3": NewProtoCodec(nil), "4": JsonCodec } {
+		conn := dialWithCodec(t, "localhost:3336", token, codec.Name())
+		b, err := codec.Marshal(&echoMsg{ Name: "bob", Count: 1 })
+		assert.NoError(t, err)
+		assert.NoError(t, conn.WriteMessage(websocket.BinaryMessage, WrapMsg(playReqMsg, 7, b)))
+
+		_, mb, err := conn.ReadMessage()
+		assert.NoError(t, err)
+		msgType, mID, msgB := UnWrapMsg(mb)
+		assert.Equal(t, playReqMsg, msgType)
+		assert.Equal(t, 7, int(mID))
+		var resp echoMsg
+		assert.NoError(t, codec.Unmarshal(msgB, &resp), codec.Name())
+		assert.Equal(t, echoMsg{ Name: "bob", Count: 2 }, resp)
+		conn.Close()
+	}
+
+	conn := dialWithCodec(t, "localhost:3336", "5", "msgpack")
+	_, _, err := conn.ReadMessage()
+	assert.Error(t, err)
+	conn.Close()
+}
@@ -0,0 +1,352 @@
+package msg_server
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/common/util"
+)
+
+/*
+
+收到的消息交给msgHandler之前的检查
+1. 限流：每个用户一个令牌桶，每种消息还可以再配一个，超了就拒绝
+1. 校验：配置了MsgSchema后只接受配置过的消息，消息体先用连接的codec解码校验
+1. 被拒绝的消息和msgHandler返回错误的消息都回复一条MsgTypeReject，不断开连接
+1. 在StrikeWindow内被拒绝KickAfter次就踢掉，在BanWindow内被踢BanAfter次就封禁BanDuration，封禁期间握手失败
+
+限流和违规次数按用户记，重连不会清零
+
+*/
+
+const (
+	// 被拒绝的消息的回复，见RejectResp
+	MsgTypeReject = 0xFFFC
+)
+
+// RejectResp.Code
+const (
+	RejectCodeRateLimited = 1
+	// 没配置MsgSchema的消息
+	RejectCodeUnknownMsg = 2
+	// 消息体太大、解码失败或者没通过校验
+	RejectCodeInvalidMsg = 3
+	// msgHandler返回了错误
+	RejectCodeHandleFailed = 4
+)
+
+const (
+	CloseReasonAbuse = "too many rejected msgs"
+	CloseReasonBanned = "banned"
+)
+
+var rejectReasons = map[int]string{
+	RejectCodeRateLimited: "rate_limited",
+	RejectCodeUnknownMsg: "unknown_msg",
+	RejectCodeInvalidMsg: "invalid_msg",
+	RejectCodeHandleFailed: "handle_failed",
+}
+
+var (
+	rejectedMsgsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "msg_server_rejected_msgs_total",
+		Help: "received msgs rejected before or by the msg handler",
+	}, []string{ "reason" })
+	abuseKicksCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "msg_server_abuse_kicks_total",
+		Help: "peers kicked for too many rejected msgs",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(rejectedMsgsCounter, abuseKicksCounter)
+}
+
+var errBanned = errors.New("user banned")
+
+// 被拒绝的消息的回复，总是json编码，msg id和被拒绝的消息一样
+type RejectResp struct {
+	Code int `json:"code"`
+	MsgType int `json:"msg_type"`
+	Info string `json:"info"`
+}
+
+// 令牌桶的参数
+type RateLimit struct {
+	// 每秒补充的令牌数
+	Rate float64
+	// 桶的大小，也就是最多能连着发多少条
+	Burst int
+}
+
+/*
+
+收到的消息的格式
+New为nil时不解码消息体，只检查大小
+
+*/
+type MsgSchema struct {
+	// 消息体最多多少byte，为0时不限制
+	MaxSize int
+	// 返回用来解码的空结构体的指针
+	New func() interface{}
+	// 解码后的检查，可以为nil
+	Validate func(v interface{}) error
+}
+
+func (schema *MsgSchema) check(msg []byte, codec Codec) error {
+	if schema.MaxSize > 0 && len(msg) > schema.MaxSize {
+		return errors.New(fmt.Sprintf("msg too large, %v > %v", len(msg), schema.MaxSize))
+	}
+	if schema.New == nil {
+		return nil
+	}
+	v := schema.New()
+	if err := codec.Unmarshal(msg, v); err != nil {
+		return err
+	}
+	if schema.Validate != nil {
+		return schema.Validate(v)
+	}
+	return nil
+}
+
+// 违规的处理，次数为0时不踢或不封
+type AbuseLimit struct {
+	KickAfter int
+	StrikeWindow time.Duration
+	BanAfter int
+	BanWindow time.Duration
+	BanDuration time.Duration
+}
+
+var defaultAbuseLimit = AbuseLimit{
+	KickAfter: 20,
+	StrikeWindow: 10 * time.Second,
+	BanAfter: 3,
+	BanWindow: 10 * time.Minute,
+	BanDuration: 10 * time.Minute,
+}
+
+// 多久没动静的用户不用再记，这期间的违规都过期了
+func (l AbuseLimit) keep() time.Duration {
+	if l.StrikeWindow > l.BanWindow {
+		return l.StrikeWindow
+	}
+	return l.BanWindow
+}
+
+func newTokenBucket(limit RateLimit, now time.Time) *tokenBucket {
+	return &tokenBucket{ limit: limit, tokens: float64(limit.Burst), last: now }
+}
+
+type tokenBucket struct {
+	limit RateLimit
+	tokens float64
+	last time.Time
+}
+
+func (b *tokenBucket) take(now time.Time) bool {
+	b.tokens = math.Min(float64(b.limit.Burst), b.tokens + now.Sub(b.last).Seconds() * b.limit.Rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+func newUserGuard() *userGuard {
+	return &userGuard{ msgBuckets: map[int]*tokenBucket{} }
+}
+
+// 一个用户的限流和违规记录，跨连接保留
+type userGuard struct {
+	lock sync.Mutex
+	// 所有消息共用的桶，没配置时为nil
+	bucket *tokenBucket
+	// key msg type
+	msgBuckets map[int]*tokenBucket
+	// 最近被拒绝的时间
+	strikes []time.Time
+	// 最近被踢的时间
+	kicks []time.Time
+	bannedUntil time.Time
+	lastSeen time.Time
+}
+
+// 拿一个令牌，先拿用户的再拿这种消息的
+func (g *userGuard) allow(userLimit *RateLimit, msgLimit *RateLimit, msgType int, now time.Time) bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.lastSeen = now
+	if userLimit != nil {
+		if g.bucket == nil {
+			g.bucket = newTokenBucket(*userLimit, now)
+		}
+		if !g.bucket.take(now) {
+			return false
+		}
+	}
+	if msgLimit != nil {
+		b := g.msgBuckets[msgType]
+		if b == nil {
+			b = newTokenBucket(*msgLimit, now)
+			g.msgBuckets[msgType] = b
+		}
+		return b.take(now)
+	}
+	return true
+}
+
+/*
+
+记一次违规，返回断开连接的原因，为空时不断开
+踢掉后违规次数清零，被踢的次数够了再封禁
+
+*/
+func (g *userGuard) strike(limit AbuseLimit, now time.Time) string {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.lastSeen = now
+	if limit.KickAfter <= 0 {
+		return ""
+	}
+	g.strikes = append(recentTimes(g.strikes, now, limit.StrikeWindow), now)
+	if len(g.strikes) < limit.KickAfter {
+		return ""
+	}
+	g.strikes = nil
+	if limit.BanAfter <= 0 {
+		return CloseReasonAbuse
+	}
+	g.kicks = append(recentTimes(g.kicks, now, limit.BanWindow), now)
+	if len(g.kicks) < limit.BanAfter {
+		return CloseReasonAbuse
+	}
+	g.kicks = nil
+	g.bannedUntil = now.Add(limit.BanDuration)
+	return CloseReasonBanned
+}
+
+func (g *userGuard) banned(now time.Time) bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return now.Before(g.bannedUntil)
+}
+
+func (g *userGuard) expired(now time.Time, keep time.Duration) bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return now.Sub(g.lastSeen) > keep && !now.Before(g.bannedUntil)
+}
+
+// times是递增的，去掉window之前的
+func recentTimes(times []time.Time, now time.Time, window time.Duration) []time.Time {
+	i := 0
+	for i < len(times) && now.Sub(times[i]) > window {
+		i++
+	}
+	return times[i:]
+}
+
+// 不再用codec编码的消息体
+type rawContent []byte
+
+// 发close frame说明原因后关闭连接，对方收不收都不等
+func closeWithReason(c *websocket.Conn, code int, reason string) {
+	c.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(kickWait))
+	c.Close()
+}
+
+// 所有消息共用的限流，必须在Run之前调用
+func (s *WsServer) SetRateLimit(limit RateLimit) {
+	s.userLimit = &limit
+}
+
+// 某种消息的限流，和SetRateLimit的同时生效，必须在Run之前调用
+func (s *WsServer) SetMsgRateLimit(msgType int, limit RateLimit) {
+	s.msgLimits[msgType] = limit
+}
+
+// 配置了任何一种消息的格式后，没配置的消息都会被拒绝，必须在Run之前调用
+func (s *WsServer) AddMsgSchema(msgType int, schema MsgSchema) {
+	s.schemas[msgType] = schema
+}
+
+// 覆盖默认的defaultAbuseLimit，必须在Run之前调用
+func (s *WsServer) SetAbuseLimit(limit AbuseLimit) {
+	s.abuseLimit = limit
+}
+
+func (s *WsServer) guard(uID string) *userGuard {
+	if tmp, ok := s.guards.Load(uID); ok {
+		return tmp.(*userGuard)
+	}
+	tmp, _ := s.guards.LoadOrStore(uID, newUserGuard())
+	return tmp.(*userGuard)
+}
+
+func (s *WsServer) removeExpiredGuards(now time.Time) {
+	keep := s.abuseLimit.keep()
+	s.guards.Range(func(key, value interface{}) bool {
+		if value.(*userGuard).expired(now, keep) {
+			s.guards.Delete(key)
+		}
+		return true
+	})
+}
+
+// 检查收到的消息，返回RejectResp.Code，为0时可以交给msgHandler
+func (s *WsServer) check(g *userGuard, msgType int, msg []byte, codec Codec) (int, error) {
+	var msgLimit *RateLimit
+	if limit, ok := s.msgLimits[msgType]; ok {
+		msgLimit = &limit
+	}
+	if !g.allow(s.userLimit, msgLimit, msgType, time.Now()) {
+		return RejectCodeRateLimited, errors.New("rate limited")
+	}
+	if len(s.schemas) == 0 {
+		return 0, nil
+	}
+	schema, ok := s.schemas[msgType]
+	if !ok {
+		return RejectCodeUnknownMsg, errors.New(fmt.Sprintf("unknown msg type: %v", msgType))
+	}
+	if err := schema.check(msg, codec); err != nil {
+		return RejectCodeInvalidMsg, err
+	}
+	return 0, nil
+}
+
+// ack也算用户的频率，序号必须是发过的
+func (s *WsServer) checkAck(g *userGuard, st *reliableState, msg []byte) (int, error) {
+	if !g.allow(s.userLimit, nil, MsgTypeAck, time.Now()) {
+		return RejectCodeRateLimited, errors.New("rate limited")
+	}
+	if st == nil || len(msg) != 8 {
+		return RejectCodeInvalidMsg, errors.New("invalid ack")
+	}
+	if err := st.ack(binary.BigEndian.Uint64(msg)); err != nil {
+		return RejectCodeInvalidMsg, err
+	}
+	return 0, nil
+}
+
+// 回复MsgTypeReject并记一次违规，返回断开连接的原因，为空时不断开
+func (s *WsServer) reject(g *userGuard, uID string, msgType int, mID int64, code int, err error) string {
+	rejectedMsgsCounter.WithLabelValues(rejectReasons[code]).Inc()
+	if mID >= 0 {
+		s.Send(uID, MsgTypeReject, mID, rawContent(util.StringifyJsonToBytes(RejectResp{ Code: code, MsgType: msgType, Info: err.Error() })))
+	}
+	reason := g.strike(s.abuseLimit, time.Now())
+	if reason != "" {
+		abuseKicksCounter.Inc()
+	}
+	return reason
+}
@@ -0,0 +1,145 @@
+package msg_server
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/common/util"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	b := newTokenBucket(RateLimit{ Rate: 2, Burst: 3 }, now)
+	for i := 0; i < 3; i++ {
+		assert.True(t, b.take(now))
+	}
+	assert.False(t, b.take(now))
+	assert.True(t, b.take(now.Add(500 * time.Millisecond)))
+	assert.False(t, b.take(now.Add(500 * time.Millisecond)))
+	// 最多攒Burst个
+	later := now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		assert.True(t, b.take(later))
+	}
+	assert.False(t, b.take(later))
+}
+
+func TestUserGuard(t *testing.T) {
+	now := time.Now()
+	limit := AbuseLimit{ KickAfter: 2, StrikeWindow: time.Second, BanAfter: 2, BanWindow: time.Minute, BanDuration: time.Hour }
+	g := newUserGuard()
+	assert.Equal(t, "", g.strike(limit, now))
+	// 过了窗口的不算
+	assert.Equal(t, "", g.strike(limit, now.Add(2 * time.Second)))
+	assert.Equal(t, CloseReasonAbuse, g.strike(limit, now.Add(2 * time.Second)))
+	assert.False(t, g.banned(now.Add(2 * time.Second)))
+	assert.Equal(t, "", g.strike(limit, now.Add(3 * time.Second)))
+	assert.Equal(t, CloseReasonBanned, g.strike(limit, now.Add(3 * time.Second)))
+	assert.True(t, g.banned(now.Add(3 * time.Second)))
+	assert.False(t, g.expired(now.Add(time.Hour), limit.keep()))
+	assert.True(t, g.expired(now.Add(2 * time.Hour), limit.keep()))
+
+	// 用户和消息各自的桶
+	g = newUserGuard()
+	assert.True(t, g.allow(&RateLimit{ Rate: 0, Burst: 2 }, &RateLimit{ Rate: 0, Burst: 1 }, 1, now))
+	assert.False(t, g.allow(&RateLimit{ Rate: 0, Burst: 2 }, &RateLimit{ Rate: 0, Burst: 1 }, 1, now))
+	assert.False(t, g.allow(&RateLimit{ Rate: 0, Burst: 2 }, nil, 2, now))
+}
+
+type rejectHandler struct {
+	handled []int64
+}
+
+func (h *rejectHandler) Handle(uID string, msgType int, mID int64, msg []byte, codec Codec) error {
+	if mID == 99 {
+		return errors.New("bad move")
+	}
+	h.handled = append(h.handled, mID)
+	return nil
+}
+
+func readReject(t *testing.T, conn *websocket.Conn) (int64, RejectResp) {
+	_, mb, err := conn.ReadMessage()
+	assert.NoError(t, err)
+	msgType, mID, body := UnWrapMsg(mb)
+	assert.Equal(t, MsgTypeReject, msgType)
+	var resp RejectResp
+	assert.NoError(t, util.ParseJsonFromBytes(body, &resp))
+	return mID, resp
+}
+
+func readUntilClosed(conn *websocket.Conn) error {
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return err
+		}
+	}
+}
+
+// 被拒绝的消息回复原因，违规太多被踢，被踢太多封禁
+func TestGuard(t *testing.T) {
+	h := &rejectHandler{}
+	server := NewWsServer(3341, &fakeUserGetter{}, h)
+	server.SetMsgRateLimit(playReqMsg, RateLimit{ Rate: 0.001, Burst: 2 })
+	server.AddMsgSchema(playReqMsg, MsgSchema{ MaxSize: 64, New: func() interface{} { return &playReq{} }, Validate: func(v interface{}) error {
+		if v.(*playReq).Name == "" {
+			return errors.New("empty name")
+		}
+		return nil
+	}})
+	server.SetAbuseLimit(AbuseLimit{ KickAfter: 4, StrikeWindow: time.Minute, BanAfter: 2, BanWindow: time.Minute, BanDuration: time.Minute })
+	go server.Run()
+	defer server.Shutdown(context.Background())
+	time.Sleep(10 * time.Millisecond)
+
+	conn := dialWithCodec(t, "localhost:3341", "3", CodecJson)
+	write := func(msgType int, mID int64, body []byte) {
+		assert.NoError(t, conn.WriteMessage(websocket.BinaryMessage, WrapMsg(msgType, mID, body)))
+	}
+	write(playReqMsg, 1, util.StringifyJsonToBytes(playReq{ Name: "alice" }))
+	write(playReqMsg, 2, util.StringifyJsonToBytes(playReq{}))
+	write(playRespMsg, 3, nil)
+	mID, resp := readReject(t, conn)
+	assert.Equal(t, 2, int(mID))
+	assert.Equal(t, RejectCodeInvalidMsg, resp.Code)
+	assert.Equal(t, playReqMsg, resp.MsgType)
+	assert.Equal(t, "empty name", resp.Info)
+	mID, resp = readReject(t, conn)
+	assert.Equal(t, 3, int(mID))
+	assert.Equal(t, RejectCodeUnknownMsg, resp.Code)
+	assert.Equal(t, []int64{ 1 }, h.handled)
+
+	// 桶里没令牌了，第四次违规被踢，最后一条的回复不一定能在close frame之前发出来
+	write(playReqMsg, 4, util.StringifyJsonToBytes(playReq{ Name: "bob" }))
+	mID, resp = readReject(t, conn)
+	assert.Equal(t, 4, int(mID))
+	assert.Equal(t, RejectCodeRateLimited, resp.Code)
+	write(playReqMsg, 5, util.StringifyJsonToBytes(playReq{ Name: "bob" }))
+	err := readUntilClosed(conn)
+	assert.Equal(t, CloseReasonAbuse, err.(*websocket.CloseError).Text)
+	conn.Close()
+
+	// 重连后msgHandler的错误也算违规，又被踢一次就封禁
+	time.Sleep(20 * time.Millisecond)
+	conn = dialWithCodec(t, "localhost:3341", "3", CodecJson)
+	for i := 0; i < 4; i++ {
+		write(playReqMsg, 99, util.StringifyJsonToBytes(playReq{ Name: "carol" }))
+	}
+	err = readUntilClosed(conn)
+	assert.Equal(t, CloseReasonBanned, err.(*websocket.CloseError).Text)
+	conn.Close()
+
+	// 封禁期间握手失败
+	u := url.URL{ Scheme: "ws", Host: "localhost:3341", Path: "/msg" }
+	var dialer *websocket.Dialer
+	conn, _, err = dialer.Dial(u.String(), nil)
+	assert.NoError(t, err)
+	defer conn.Close()
+	assert.NoError(t, conn.WriteMessage(websocket.BinaryMessage, WrapMsg(MsgTypeHandShake, 1, util.StringifyJsonToBytes(HandShakeReq{ Token: "3" }))))
+	_, _, err = conn.ReadMessage()
+	assert.Equal(t, CloseReasonBanned, err.(*websocket.CloseError).Text)
+}
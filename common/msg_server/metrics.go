@@ -0,0 +1,126 @@
+package msg_server
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+/*
+
+发送流量的统计
+payload是消息编码后、不批量不压缩时每条消息一个frame的字节数，wire是实际写到连接上的字节数（含websocket的frame头）
+节省的流量 = payload - wire，ping和握手不算
+
+*/
+var (
+	payloadBytesCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "msg_server_payload_bytes_total",
+		Help: "bytes of the sent msgs before batching and compression",
+	})
+	wireBytesCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "msg_server_wire_bytes_total",
+		Help: "bytes of the sent msgs written to the connections",
+	})
+	sentMsgsCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "msg_server_sent_msgs_total",
+		Help: "msgs sent to the clients",
+	})
+	sentFramesCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "msg_server_sent_frames_total",
+		Help: "websocket frames carrying the sent msgs",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(payloadBytesCounter, wireBytesCounter, sentMsgsCounter, sentFramesCounter)
+}
+
+// 单个WsServer的统计，和上边的metrics一起更新
+type SendStats struct {
+	PayloadBytes int64
+	WireBytes int64
+	Msgs int64
+	Frames int64
+}
+
+func (s SendStats) SavedBytes() int64 {
+	return s.PayloadBytes - s.WireBytes
+}
+
+type sendStats struct {
+	payloadBytes int64
+	wireBytes int64
+	msgs int64
+	frames int64
+}
+
+// 发了一个frame，里边有msgs条消息
+func (s *sendStats) add(msgs int, payload int, wire int64) {
+	atomic.AddInt64(&s.payloadBytes, int64(payload))
+	atomic.AddInt64(&s.wireBytes, wire)
+	atomic.AddInt64(&s.msgs, int64(msgs))
+	atomic.AddInt64(&s.frames, 1)
+	payloadBytesCounter.Add(float64(payload))
+	wireBytesCounter.Add(float64(wire))
+	sentMsgsCounter.Add(float64(msgs))
+	sentFramesCounter.Inc()
+}
+
+func (s *sendStats) get() SendStats {
+	return SendStats{
+		PayloadBytes: atomic.LoadInt64(&s.payloadBytes),
+		WireBytes: atomic.LoadInt64(&s.wireBytes),
+		Msgs: atomic.LoadInt64(&s.msgs),
+		Frames: atomic.LoadInt64(&s.frames),
+	}
+}
+
+// 服务器发的frame头的长度，不带mask
+func frameHeaderSize(payload int) int {
+	switch {
+	case payload <= 125:
+		return 2
+	case payload <= 65535:
+		return 4
+	}
+	return 10
+}
+
+// 记录写到连接上的字节数，压缩后的大小只能从这里拿到
+type countingConn struct {
+	net.Conn
+	written int64
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.AddInt64(&c.written, int64(n))
+	return n, err
+}
+
+func (c *countingConn) getWritten() int64 {
+	return atomic.LoadInt64(&c.written)
+}
+
+// upgrader hijack时拿到的是countingConn
+type countingResponseWriter struct {
+	http.ResponseWriter
+	conn *countingConn
+}
+
+func (w *countingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response does not implement http.Hijacker")
+	}
+	c, brw, err := h.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	w.conn = &countingConn{ Conn: c }
+	return w.conn, brw, nil
+}
@@ -0,0 +1,122 @@
+package msg_server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// WsServer的配置，为0的字段用DefaultWsOptions中的值
+type WsOptions struct {
+	// 监听的地址，如":3030"
+	Addr string
+	// websocket的路径
+	Path string
+
+	MaxPeers int64
+	// 客户端发的一条消息最多多少byte，包括10byte的头
+	MaxMessageSize int64
+	// 每个peer的发送队列，满了就当作slow consumer踢掉
+	SendQueueSize int
+
+	// 连上后多久内必须握手
+	HandShakeWait time.Duration
+	// Time allowed to write a message to the peer.
+	WriteWait time.Duration
+	// Time allowed to read the next pong message from the peer.
+	PongWait time.Duration
+	// Send pings to peer with this period. Must be less than PongWait.
+	PingPeriod time.Duration
+
+	/*
+
+	允许的浏览器Origin，如"https://holehole.com"，"*"允许所有
+	为空时只允许和Host相同的Origin，不是浏览器的客户端不带Origin，总是允许
+	CheckOrigin不为nil时用它，不看这个
+
+	*/
+	AllowedOrigins []string
+	CheckOrigin func(r *http.Request) bool
+
+	// 都不为空时用TLS，也就是wss
+	CertFile string
+	KeyFile string
+}
+
+func DefaultWsOptions(port int) WsOptions {
+	return WsOptions{
+		Addr: fmt.Sprintf(":%v", port),
+		Path: "/msg",
+		MaxPeers: 1000,
+		MaxMessageSize: 512,
+		SendQueueSize: 50,
+		HandShakeWait: 8 * time.Second,
+		WriteWait: 10 * time.Second,
+		PongWait: 60 * time.Second,
+		PingPeriod: 54 * time.Second,
+	}
+}
+
+// 用DefaultWsOptions填上没设置的字段
+func (o WsOptions) withDefaults() WsOptions {
+	d := DefaultWsOptions(0)
+	if o.Addr == "" {
+		o.Addr = d.Addr
+	}
+	if o.Path == "" {
+		o.Path = d.Path
+	}
+	if o.MaxPeers == 0 {
+		o.MaxPeers = d.MaxPeers
+	}
+	if o.MaxMessageSize == 0 {
+		o.MaxMessageSize = d.MaxMessageSize
+	}
+	if o.SendQueueSize == 0 {
+		o.SendQueueSize = d.SendQueueSize
+	}
+	if o.HandShakeWait == 0 {
+		o.HandShakeWait = d.HandShakeWait
+	}
+	if o.WriteWait == 0 {
+		o.WriteWait = d.WriteWait
+	}
+	if o.PongWait == 0 {
+		o.PongWait = d.PongWait
+	}
+	if o.PingPeriod == 0 {
+		o.PingPeriod = o.PongWait * 9 / 10
+	}
+	return o
+}
+
+func (o *WsOptions) useTLS() bool {
+	return o.CertFile != "" && o.KeyFile != ""
+}
+
+// 给upgrader用，返回nil时用gorilla默认的同源检查
+func (o *WsOptions) originChecker() func(r *http.Request) bool {
+	if o.CheckOrigin != nil {
+		return o.CheckOrigin
+	}
+	if len(o.AllowedOrigins) == 0 {
+		return nil
+	}
+	allowed := map[string]bool{}
+	for _, origin := range o.AllowedOrigins {
+		allowed[strings.ToLower(strings.TrimSuffix(origin, "/"))] = true
+	}
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" || allowed["*"] {
+			return true
+		}
+		u, err := url.Parse(origin)
+		if err != nil {
+			return false
+		}
+		return allowed[strings.ToLower(u.Scheme + "://" + u.Host)]
+	}
+}
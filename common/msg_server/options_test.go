@@ -0,0 +1,126 @@
+package msg_server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/common/util"
+)
+
+func TestWsOptions(t *testing.T) {
+	opts := WsOptions{ PongWait: 10 * time.Second, MaxPeers: 2 }.withDefaults()
+	assert.Equal(t, ":0", opts.Addr)
+	assert.Equal(t, "/msg", opts.Path)
+	assert.Equal(t, 2, int(opts.MaxPeers))
+	assert.Equal(t, 512, int(opts.MaxMessageSize))
+	assert.Equal(t, 9 * time.Second, opts.PingPeriod)
+
+	assert.Nil(t, opts.originChecker())
+	opts.AllowedOrigins = []string{ "https://HoleHole.com/" }
+	check := opts.originChecker()
+	req := func(origin string) *http.Request {
+		r, _ := http.NewRequest("GET", "/msg", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		return r
+	}
+	assert.True(t, check(req("")))
+	assert.True(t, check(req("https://holehole.com")))
+	assert.False(t, check(req("http://holehole.com")))
+	assert.False(t, check(req("https://evil.com")))
+	opts.AllowedOrigins = []string{ "*" }
+	assert.True(t, opts.originChecker()(req("https://evil.com")))
+}
+
+// 生成自签名证书，返回证书和私钥的文件路径
+func writeTestCert(t *testing.T, dir string) (string, string) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{ CommonName: "localhost" },
+		IPAddresses: []net.IP{ net.ParseIP("127.0.0.1") },
+		DNSNames: []string{ "localhost" },
+		NotBefore: time.Now().Add(-time.Hour),
+		NotAfter: time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &priv.PublicKey, priv)
+	assert.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(priv)
+	assert.NoError(t, err)
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	assert.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{ Type: "CERTIFICATE", Bytes: der }), 0600))
+	assert.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{ Type: "EC PRIVATE KEY", Bytes: keyDer }), 0600))
+	return certFile, keyFile
+}
+
+// wss、自定义路径、同一个端口上的其他handler、origin检查
+func TestTLSAndExtraHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ws_tls")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	certFile, keyFile := writeTestCert(t, dir)
+
+	opts := DefaultWsOptions(3342)
+	opts.Path = "/ws"
+	opts.CertFile, opts.KeyFile = certFile, keyFile
+	opts.AllowedOrigins = []string{ "https://holehole.com" }
+	h := &fakeMsgHandler{}
+	server := NewWsServerWithOptions(opts, &fakeUserGetter{}, h)
+	h.server = server
+	server.Handle("/health", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- server.Run()
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	tlsConfig := &tls.Config{ RootCAs: x509.NewCertPool() }
+	certPem, _ := ioutil.ReadFile(certFile)
+	tlsConfig.RootCAs.AppendCertsFromPEM(certPem)
+
+	client := &http.Client{ Transport: &http.Transport{ TLSClientConfig: tlsConfig } }
+	resp, err := client.Get("https://localhost:3342/health")
+	if assert.NoError(t, err) {
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, "ok", string(body))
+	}
+
+	dialer := &websocket.Dialer{ TLSClientConfig: tlsConfig }
+	_, _, err = dialer.Dial("wss://localhost:3342/ws", http.Header{ "Origin": { "https://evil.com" } })
+	assert.Error(t, err)
+	conn, _, err := dialer.Dial("wss://localhost:3342/ws", http.Header{ "Origin": { "https://holehole.com" } })
+	if assert.NoError(t, err) {
+		defer conn.Close()
+		assert.NoError(t, conn.WriteMessage(websocket.BinaryMessage, WrapMsg(MsgTypeHandShake, 1, util.StringifyJsonToBytes(HandShakeReq{ Token: "4" }))))
+		assert.NoError(t, conn.WriteMessage(websocket.BinaryMessage, WrapMsg(playReqMsg, 2, util.StringifyJsonToBytes(playReq{ Name: "alice" }))))
+		_, mb, err := conn.ReadMessage()
+		assert.NoError(t, err)
+		msgType, _, _ := UnWrapMsg(mb)
+		assert.Equal(t, playRespMsg, msgType)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, server.Shutdown(ctx))
+	assert.NoError(t, <- runErr)
+}
@@ -2,6 +2,7 @@ package msg_server
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"math"
 )
@@ -26,4 +27,42 @@ func UnWrapMsg(msg []byte) (int, int64, []byte) {
 		return -1, -1, []byte{}
 	}
 	return int(binary.BigEndian.Uint16(msg[:2])), int64(binary.BigEndian.Uint64(msg[2:10])), msg[10:]
-}
\ No newline at end of file
+}
+
+/*
+
+批量消息：type为MsgTypeBatch，id为0，消息体是多条完整的消息（WrapMsg后的），每条前边4byte的长度
+握手时HandShakeReq.Batch为true的客户端才会收到
+
+*/
+func WrapBatch(msgs [][]byte) []byte {
+	size := 10
+	for _, m := range msgs {
+		size += 4 + len(m)
+	}
+	result := make([]byte, 10, size)
+	binary.BigEndian.PutUint16(result, MsgTypeBatch)
+	lb := make([]byte, 4)
+	for _, m := range msgs {
+		binary.BigEndian.PutUint32(lb, uint32(len(m)))
+		result = append(append(result, lb...), m...)
+	}
+	return result
+}
+
+// msg是UnWrapMsg后的消息体，返回其中每条完整的消息
+func UnWrapBatch(msg []byte) ([][]byte, error) {
+	var result [][]byte
+	for len(msg) > 0 {
+		if len(msg) < 4 {
+			return nil, errors.New("invalid batch, incomplete length")
+		}
+		l := binary.BigEndian.Uint32(msg[:4])
+		if uint64(len(msg) - 4) < uint64(l) {
+			return nil, errors.New(fmt.Sprintf("invalid batch, need %v bytes but only %v left", l, len(msg) - 4))
+		}
+		result = append(result, msg[4:4 + l])
+		msg = msg[4 + l:]
+	}
+	return result, nil
+}
@@ -0,0 +1,138 @@
+package msg_server
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+)
+
+/*
+
+可靠投递，握手时HandShakeReq.Ack为true的客户端才有
+1. 服务器发的每条消息套一层MsgTypeSeq，msg id的位置放这个用户的消息序号，从1开始递增，消息体是完整的原消息
+1. 客户端定期发MsgTypeAck，消息体是8byte的序号，表示这个序号及之前的消息都收到了
+1. ack和其他消息一样算用户的限流，序号比发过的最大序号还大时拒绝并记一次违规
+1. 没ack的消息留在服务器，最多maxUnacked条；断线后重连时HandShakeReq.LastSeq带上收到的最后一个序号，服务器把之后的消息重发
+1. 断线超过reliableKeep没重连就不再保留
+
+没ack的消息太多（客户端处理不过来或者一直不ack），或者断线期间丢了消息，重连时HandShakeResp.Resumed为false，客户端要重新拿一次桌子的场景
+
+*/
+
+const (
+	// 服务器发的可靠消息
+	MsgTypeSeq = 0xFFFE
+	// 客户端的ack
+	MsgTypeAck = 0xFFFD
+
+	maxUnacked = 256
+	reliableKeep = 2 * time.Minute
+)
+
+// 断开连接的原因，放在close frame中
+const (
+	CloseReasonSlowConsumer = "slow consumer"
+	CloseReasonTooManyUnacked = "too many unacked msgs"
+)
+
+func WrapAck(mID int64, seq uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, seq)
+	return WrapMsg(MsgTypeAck, mID, b)
+}
+
+// 一个用户的可靠投递状态，跨连接保留
+type reliableState struct {
+	// push和resume由调用方拿着，保证重发的消息在新消息之前交给peer
+	lock sync.Mutex
+	// 上一个消息的序号
+	lastSeq uint64
+	// 没ack的消息，seq递增
+	unacked []*cMsg
+	// 丢过没ack的消息，重连后续不上
+	overflowed bool
+	// 当前的连接，断开时为nil
+	peer *wsPeer
+	disconnectedAt time.Time
+}
+
+func newReliableState() *reliableState {
+	return &reliableState{}
+}
+
+/*
+
+给消息分配序号并留下来，返回要交给peer的消息
+没ack的消息超过maxUnacked时丢掉最老的，连着的话返回true要断开连接
+
+*/
+func (st *reliableState) push(msg *cMsg) (*cMsg, bool) {
+	st.lastSeq++
+	tagged := *msg
+	tagged.seq = st.lastSeq
+	st.unacked = append(st.unacked, &tagged)
+	if len(st.unacked) <= maxUnacked {
+		return &tagged, false
+	}
+	st.unacked = st.unacked[1:]
+	kick := !st.overflowed && st.peer != nil
+	st.overflowed = true
+	return &tagged, kick
+}
+
+// 比发过的最大序号还大的ack是假的
+func (st *reliableState) ack(seq uint64) error {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	if seq > st.lastSeq {
+		return fmt.Errorf("ack seq %v over last seq %v", seq, st.lastSeq)
+	}
+	st.dropAcked(seq)
+	return nil
+}
+
+func (st *reliableState) dropAcked(seq uint64) {
+	i := 0
+	for i < len(st.unacked) && st.unacked[i].seq <= seq {
+		i++
+	}
+	st.unacked = st.unacked[i:]
+}
+
+/*
+
+新连接接上，客户端收到的最后一个序号是lastSeq
+能续上时返回需要重发的消息，否则清空后返回false
+
+*/
+func (st *reliableState) resume(p *wsPeer, lastSeq uint64) ([]*cMsg, bool) {
+	st.peer = p
+	resumed := !st.overflowed && lastSeq <= st.lastSeq
+	if resumed && len(st.unacked) > 0 {
+		// 之间有消息已经ack了却说没收到，续不上
+		resumed = lastSeq + 1 >= st.unacked[0].seq
+	}
+	st.overflowed = false
+	if !resumed {
+		st.unacked = nil
+		return nil, false
+	}
+	st.dropAcked(lastSeq)
+	return append([]*cMsg{}, st.unacked...), true
+}
+
+func (st *reliableState) detach(p *wsPeer) {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	if st.peer == p {
+		st.peer = nil
+		st.disconnectedAt = time.Now()
+	}
+}
+
+func (st *reliableState) expired(now time.Time) bool {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	return st.peer == nil && now.Sub(st.disconnectedAt) > reliableKeep
+}
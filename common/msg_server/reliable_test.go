@@ -0,0 +1,196 @@
+package msg_server
+
+import (
+	"context"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/common/util"
+)
+
+func TestReliableState(t *testing.T) {
+	st := newReliableState()
+	for i := 1; i <= 3; i++ {
+		msg, kick := st.push(&cMsg{ msgID: int64(i) })
+		assert.False(t, kick)
+		assert.Equal(t, i, int(msg.seq))
+	}
+	assert.NoError(t, st.ack(1))
+	assert.Equal(t, 2, len(st.unacked))
+	// 没发过的序号不能ack
+	assert.Error(t, st.ack(4))
+	assert.Equal(t, 2, len(st.unacked))
+
+	// 收到了2，重发3
+	pending, resumed := st.resume(nil, 2)
+	assert.True(t, resumed)
+	assert.Equal(t, 1, len(pending))
+	assert.Equal(t, 3, int(pending[0].seq))
+	// 说自己收到了比服务器发的还多，续不上
+	_, resumed = st.resume(nil, 4)
+	assert.False(t, resumed)
+	assert.Equal(t, 0, len(st.unacked))
+
+	// 连着的时候没ack的太多要断开，只断一次
+	st.peer = &wsPeer{}
+	for i := 0; i < maxUnacked; i++ {
+		_, kick := st.push(&cMsg{})
+		assert.False(t, kick)
+	}
+	_, kick := st.push(&cMsg{})
+	assert.True(t, kick)
+	_, kick = st.push(&cMsg{})
+	assert.False(t, kick)
+	assert.Equal(t, maxUnacked, len(st.unacked))
+	_, resumed = st.resume(nil, st.lastSeq - 1)
+	assert.False(t, resumed)
+}
+
+func dialReliable(t *testing.T, host string, token string, lastSeq uint64) (*websocket.Conn, *HandShakeResp) {
+	u := url.URL{ Scheme: "ws", Host: host, Path: "/msg" }
+	var dialer *websocket.Dialer
+	conn, _, err := dialer.Dial(u.String(), nil)
+	if err != nil {
+		panic(err)
+	}
+	assert.NoError(t, conn.WriteMessage(websocket.BinaryMessage, WrapMsg(MsgTypeHandShake, 1, util.StringifyJsonToBytes(HandShakeReq{ Token: token, Ack: true, LastSeq: lastSeq }))))
+	_, mb, err := conn.ReadMessage()
+	assert.NoError(t, err)
+	msgType, mID, body := UnWrapMsg(mb)
+	assert.Equal(t, MsgTypeHandShake, msgType)
+	assert.Equal(t, 1, int(mID))
+	var resp HandShakeResp
+	assert.NoError(t, util.ParseJsonFromBytes(body, &resp))
+	return conn, &resp
+}
+
+// 读一条MsgTypeSeq，返回序号和原消息的id
+func readSeq(t *testing.T, conn *websocket.Conn) (int, int) {
+	_, mb, err := conn.ReadMessage()
+	assert.NoError(t, err)
+	msgType, seq, inner := UnWrapMsg(mb)
+	assert.Equal(t, MsgTypeSeq, msgType)
+	msgType, mID, _ := UnWrapMsg(inner)
+	assert.Equal(t, playRespMsg, msgType)
+	return int(seq), int(mID)
+}
+
+// 断线期间和没ack的消息重连后按顺序重发
+func TestReliableResend(t *testing.T) {
+	server := NewWsServer(3339, &fakeUserGetter{}, &fakeMsgHandler{})
+	go server.Run()
+	defer server.Shutdown(context.Background())
+	time.Sleep(10 * time.Millisecond)
+
+	conn, resp := dialReliable(t, "localhost:3339", "1", 0)
+	assert.True(t, resp.Resumed)
+	for i := 1; i <= 3; i++ {
+		server.Send("1", playRespMsg, int64(10 + i), playResp{})
+	}
+	for i := 1; i <= 3; i++ {
+		seq, mID := readSeq(t, conn)
+		assert.Equal(t, i, seq)
+		assert.Equal(t, 10 + i, mID)
+	}
+	assert.NoError(t, conn.WriteMessage(websocket.BinaryMessage, WrapAck(2, 2)))
+	time.Sleep(20 * time.Millisecond)
+	conn.Close()
+	time.Sleep(20 * time.Millisecond)
+	server.Send("1", playRespMsg, 14, playResp{})
+	time.Sleep(20 * time.Millisecond)
+
+	// 3收到了但没ack，重连时说只收到了2
+	conn, resp = dialReliable(t, "localhost:3339", "1", 2)
+	defer conn.Close()
+	assert.True(t, resp.Resumed)
+	server.Send("1", playRespMsg, 15, playResp{})
+	for i := 3; i <= 5; i++ {
+		seq, mID := readSeq(t, conn)
+		assert.Equal(t, i, seq)
+		assert.Equal(t, 10 + i, mID)
+	}
+
+	// 断线期间消息太多，续不上
+	conn.Close()
+	time.Sleep(20 * time.Millisecond)
+	for i := 0; i <= maxUnacked; i++ {
+		server.Send("1", playRespMsg, 16, playResp{})
+	}
+	time.Sleep(20 * time.Millisecond)
+	conn, resp = dialReliable(t, "localhost:3339", "1", 5)
+	defer conn.Close()
+	assert.False(t, resp.Resumed)
+}
+
+// 旧连接还没断时重连，旧连接退出时不能删掉新的peer；ack没发过的序号被拒绝
+func TestReliableTakeover(t *testing.T) {
+	server := NewWsServer(3343, &fakeUserGetter{}, &fakeMsgHandler{})
+	go server.Run()
+	defer server.Shutdown(context.Background())
+	time.Sleep(10 * time.Millisecond)
+
+	old, _ := dialReliable(t, "localhost:3343", "1", 0)
+	defer old.Close()
+	server.Send("1", playRespMsg, 11, playResp{})
+	seq, _ := readSeq(t, old)
+	assert.Equal(t, 1, seq)
+
+	conn, resp := dialReliable(t, "localhost:3343", "1", 1)
+	defer conn.Close()
+	assert.True(t, resp.Resumed)
+	// 旧连接被服务器关掉，它的handler退出
+	assert.Error(t, readUntilClosed(old))
+	time.Sleep(20 * time.Millisecond)
+	p := server.peerSet.getPeer("1")
+	if assert.NotNil(t, p) {
+		tmp, _ := server.reliable.Load("1")
+		assert.Equal(t, p, tmp.(*reliableState).peer)
+	}
+	assert.Equal(t, 1, int(atomic.LoadInt64(&server.peerSet.peerCount)))
+
+	server.Send("1", playRespMsg, 12, playResp{})
+	seq, mID := readSeq(t, conn)
+	assert.Equal(t, 2, seq)
+	assert.Equal(t, 12, mID)
+
+	assert.NoError(t, conn.WriteMessage(websocket.BinaryMessage, WrapAck(3, 100)))
+	// 可靠投递时回复的reject也套着MsgTypeSeq
+	_, mb, err := conn.ReadMessage()
+	assert.NoError(t, err)
+	msgType, _, inner := UnWrapMsg(mb)
+	assert.Equal(t, MsgTypeSeq, msgType)
+	msgType, rejectID, body := UnWrapMsg(inner)
+	assert.Equal(t, MsgTypeReject, msgType)
+	assert.Equal(t, 3, int(rejectID))
+	var reject RejectResp
+	assert.NoError(t, util.ParseJsonFromBytes(body, &reject))
+	assert.Equal(t, RejectCodeInvalidMsg, reject.Code)
+}
+
+type bigResp struct {
+	Data []byte
+}
+
+// 一直不读的客户端，发送队列满了后被踢掉，而不是悄悄丢消息
+func TestSlowConsumer(t *testing.T) {
+	server := NewWsServer(3340, &fakeUserGetter{}, &fakeMsgHandler{})
+	go server.Run()
+	defer server.Shutdown(context.Background())
+	time.Sleep(10 * time.Millisecond)
+
+	conn := dialWithCodec(t, "localhost:3340", "2", CodecJson)
+	defer conn.Close()
+	time.Sleep(20 * time.Millisecond)
+	assert.NotNil(t, server.peerSet.getPeer("2"))
+	data := make([]byte, 64 * 1024)
+	for i := 0; i < 300; i++ {
+		server.Send("2", playRespMsg, int64(i), bigResp{ Data: data })
+	}
+	for i := 0; i < 100 && server.peerSet.getPeer("2") != nil; i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	assert.Nil(t, server.peerSet.getPeer("2"))
+}
@@ -0,0 +1,243 @@
+package msg_server
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+/*
+
+握手后的加密会话
+1. 客户端在HandShakeReq.PubKey中带上临时的X25519公钥
+1. 服务器也生成临时的X25519密钥，用长期的ed25519私钥对两个公钥签名，在HandShakeResp中返回公钥和签名
+1. 客户端用内置的服务器ed25519公钥验签，防止中间人
+1. 双方用X25519的共享密钥经HKDF-SHA256得到两个方向各自的AES-256-GCM密钥
+
+之后每条消息的2byte type和8byte id明文传输，作为附加数据参与认证，消息体加密
+1. 客户端发的消息用msg id做nonce，msg id必须递增，服务器拒绝不递增的msg id，防重放
+1. 服务器发的每个frame（包括批量消息）用双方各自计数的序号做nonce，websocket保证有序，丢帧、重放、乱序都会解密失败
+
+*/
+
+const sessionInfo = "holehole session v1"
+
+func sessionTranscript(clientPub []byte, serverPub []byte) []byte {
+	return append(append([]byte(sessionInfo), clientPub...), serverPub...)
+}
+
+// 返回客户端到服务器、服务器到客户端两个方向的AEAD
+func deriveSessionKeys(secret []byte, clientPub []byte, serverPub []byte) (cipher.AEAD, cipher.AEAD, error) {
+	keys := hkdfSha256(secret, append(append([]byte{}, clientPub...), serverPub...), []byte(sessionInfo), 64)
+	c2s, err := newAEAD(keys[:32])
+	if err != nil {
+		return nil, nil, err
+	}
+	s2c, err := newAEAD(keys[32:])
+	if err != nil {
+		return nil, nil, err
+	}
+	return c2s, s2c, nil
+}
+
+// RFC 5869，length不超过255 * 32
+func hkdfSha256(secret []byte, salt []byte, info []byte, length int) []byte {
+	extractor := hmac.New(sha256.New, salt)
+	extractor.Write(secret)
+	expander := hmac.New(sha256.New, extractor.Sum(nil))
+	var result, block []byte
+	for i := byte(1); len(result) < length; i++ {
+		expander.Reset()
+		expander.Write(block)
+		expander.Write(info)
+		expander.Write([]byte{ i })
+		block = expander.Sum(nil)
+		result = append(result, block...)
+	}
+	return result[:length]
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// 12byte的nonce，前4byte为0，后8byte是msg id或序号
+func sessionNonce(n uint64) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[4:], n)
+	return nonce
+}
+
+// msg是WrapMsg后的完整消息，头部不加密
+func sealMsg(aead cipher.AEAD, n uint64, msg []byte) []byte {
+	return aead.Seal(append([]byte{}, msg[:10]...), sessionNonce(n), msg[10:], msg[:10])
+}
+
+func openMsg(aead cipher.AEAD, n uint64, msg []byte) ([]byte, error) {
+	if len(msg) < 10 {
+		return nil, errors.New("msg too short")
+	}
+	body, err := aead.Open(nil, sessionNonce(n), msg[10:], msg[:10])
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, msg[:10]...), body...), nil
+}
+
+// 服务器端的会话，只在peer的读写goroutine中用
+type serverSession struct {
+	c2s cipher.AEAD
+	s2c cipher.AEAD
+	// 收到的最大的msg id
+	lastMsgID int64
+	// 下一个发出去的frame的序号
+	sendSeq uint64
+}
+
+// 用客户端的公钥完成密钥交换，返回给客户端的HandShakeResp中的公钥和签名
+func newServerSession(signKey ed25519.PrivateKey, clientPub []byte) (*serverSession, *HandShakeResp, error) {
+	peerKey, err := ecdh.X25519().NewPublicKey(clientPub)
+	if err != nil {
+		return nil, nil, err
+	}
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	secret, err := priv.ECDH(peerKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	serverPub := priv.PublicKey().Bytes()
+	c2s, s2c, err := deriveSessionKeys(secret, clientPub, serverPub)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp := &HandShakeResp{ PubKey: serverPub, Signature: ed25519.Sign(signKey, sessionTranscript(clientPub, serverPub)) }
+	return &serverSession{ c2s: c2s, s2c: s2c, lastMsgID: -1 }, resp, nil
+}
+
+// 加密要发的frame
+func (s *serverSession) seal(msg []byte) []byte {
+	result := sealMsg(s.s2c, s.sendSeq, msg)
+	s.sendSeq++
+	return result
+}
+
+// 解密收到的消息，msg id不递增或者认证失败都返回错误
+func (s *serverSession) open(msg []byte) ([]byte, error) {
+	_, mID, _ := UnWrapMsg(msg)
+	if mID <= s.lastMsgID {
+		return nil, errors.New(fmt.Sprintf("replayed msg id %v, last %v", mID, s.lastMsgID))
+	}
+	result, err := openMsg(s.c2s, uint64(mID), msg)
+	if err != nil {
+		return nil, err
+	}
+	s.lastMsgID = mID
+	return result, nil
+}
+
+/*
+
+客户端的会话，给go写的客户端（机器人、压测）和测试用
+先NewClientSession拿到公钥放进HandShakeReq，收到HandShakeResp后Finish
+
+*/
+type ClientSession struct {
+	priv *ecdh.PrivateKey
+	serverSignKey ed25519.PublicKey
+
+	c2s cipher.AEAD
+	s2c cipher.AEAD
+	lastMsgID int64
+	recvSeq uint64
+}
+
+// serverSignKey是服务器长期的ed25519公钥
+func NewClientSession(serverSignKey ed25519.PublicKey) (*ClientSession, error) {
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &ClientSession{ priv: priv, serverSignKey: serverSignKey, lastMsgID: -1 }, nil
+}
+
+// 放进HandShakeReq.PubKey
+func (c *ClientSession) PubKey() []byte {
+	return c.priv.PublicKey().Bytes()
+}
+
+// 验证服务器的签名并算出密钥
+func (c *ClientSession) Finish(resp *HandShakeResp) error {
+	clientPub := c.PubKey()
+	if !ed25519.Verify(c.serverSignKey, sessionTranscript(clientPub, resp.PubKey), resp.Signature) {
+		return errors.New("invalid server signature")
+	}
+	serverKey, err := ecdh.X25519().NewPublicKey(resp.PubKey)
+	if err != nil {
+		return err
+	}
+	secret, err := c.priv.ECDH(serverKey)
+	if err != nil {
+		return err
+	}
+	c.c2s, c.s2c, err = deriveSessionKeys(secret, clientPub, resp.PubKey)
+	return err
+}
+
+// 加密要发的消息，msg id必须比上一条大
+func (c *ClientSession) Seal(msg []byte) ([]byte, error) {
+	if c.c2s == nil {
+		return nil, errors.New("session not finished")
+	}
+	_, mID, _ := UnWrapMsg(msg)
+	if mID <= c.lastMsgID {
+		return nil, errors.New(fmt.Sprintf("msg id %v isn't greater than %v", mID, c.lastMsgID))
+	}
+	c.lastMsgID = mID
+	return sealMsg(c.c2s, uint64(mID), msg), nil
+}
+
+// 解密收到的frame，必须按收到的顺序调用
+func (c *ClientSession) Open(msg []byte) ([]byte, error) {
+	if c.s2c == nil {
+		return nil, errors.New("session not finished")
+	}
+	result, err := openMsg(c.s2c, c.recvSeq, msg)
+	if err != nil {
+		return nil, err
+	}
+	c.recvSeq++
+	return result, nil
+}
+
+// 读文件中hex编码的32byte ed25519 seed
+func ReadSignKey(path string) (ed25519.PrivateKey, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := hex.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil {
+		return nil, err
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, errors.New(fmt.Sprintf("invalid seed size %v", len(seed)))
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
@@ -0,0 +1,138 @@
+package msg_server
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"math/big"
+	"net/url"
+	"testing"
+	"time"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/common/util"
+)
+
+func newTestSession(t *testing.T) (*ClientSession, *serverSession) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	client, err := NewClientSession(pub)
+	assert.NoError(t, err)
+	server, resp, err := newServerSession(priv, client.PubKey())
+	assert.NoError(t, err)
+	assert.NoError(t, client.Finish(resp))
+	return client, server
+}
+
+func TestSession(t *testing.T) {
+	client, server := newTestSession(t)
+
+	msg := WrapMsg(playReqMsg, 5, []byte("alice"))
+	sealed, err := client.Seal(msg)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(sealed), "alice")
+	opened, err := server.open(sealed)
+	assert.NoError(t, err)
+	assert.Equal(t, msg, opened)
+
+	// 重放，msg id不递增
+	_, err = server.open(sealed)
+	assert.Error(t, err)
+	_, err = client.Seal(WrapMsg(playReqMsg, 5, nil))
+	assert.Error(t, err)
+	// 改了明文的头
+	sealed, err = client.Seal(WrapMsg(playReqMsg, 6, []byte("bob")))
+	assert.NoError(t, err)
+	sealed[1] = playRespMsg
+	_, err = server.open(sealed)
+	assert.Error(t, err)
+
+	// 服务器发的frame按顺序解密，跳过一个就解不开
+	first, second := server.seal(WrapMsg(playRespMsg, 1, []byte("1"))), server.seal(WrapMsg(playRespMsg, 1, []byte("2")))
+	_, err = client.Open(second)
+	assert.Error(t, err)
+	opened, err = client.Open(first)
+	assert.NoError(t, err)
+	assert.Equal(t, WrapMsg(playRespMsg, 1, []byte("1")), opened)
+	_, err = client.Open(first)
+	assert.Error(t, err)
+	_, err = client.Open(second)
+	assert.NoError(t, err)
+}
+
+// 签名对不上的服务器
+func TestSession_WrongServerKey(t *testing.T) {
+	pub, _, _ := ed25519.GenerateKey(rand.Reader)
+	_, other, _ := ed25519.GenerateKey(rand.Reader)
+	client, err := NewClientSession(pub)
+	assert.NoError(t, err)
+	_, resp, err := newServerSession(other, client.PubKey())
+	assert.NoError(t, err)
+	assert.Error(t, client.Finish(resp))
+}
+
+func TestEncryptedConnection(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	h := &fakeMsgHandler{}
+	server := NewWsServer(3338, &fakeUserGetter{}, h)
+	server.EnableEncryption(priv, true)
+	h.server = server
+	go server.Run()
+	defer server.Shutdown(context.Background())
+	time.Sleep(10 * time.Millisecond)
+
+	u := url.URL{ Scheme: "ws", Host: "localhost:3338", Path: "/msg" }
+	var dialer *websocket.Dialer
+
+	// 必须加密
+	conn, _, err := dialer.Dial(u.String(), nil)
+	assert.NoError(t, err)
+	assert.NoError(t, conn.WriteMessage(websocket.BinaryMessage, WrapMsg(MsgTypeHandShake, 1, util.StringifyJsonToBytes(HandShakeReq{ Token: "1" }))))
+	_, _, err = conn.ReadMessage()
+	assert.Error(t, err)
+	conn.Close()
+
+	session, err := NewClientSession(pub)
+	assert.NoError(t, err)
+	conn, _, err = dialer.Dial(u.String(), nil)
+	assert.NoError(t, err)
+	defer conn.Close()
+	assert.NoError(t, conn.WriteMessage(websocket.BinaryMessage, WrapMsg(MsgTypeHandShake, 1, util.StringifyJsonToBytes(HandShakeReq{ Token: "1", PubKey: session.PubKey() }))))
+	_, mb, err := conn.ReadMessage()
+	assert.NoError(t, err)
+	msgType, _, body := UnWrapMsg(mb)
+	assert.Equal(t, MsgTypeHandShake, msgType)
+	var resp HandShakeResp
+	assert.NoError(t, util.ParseJsonFromBytes(body, &resp))
+	assert.NoError(t, session.Finish(&resp))
+
+	sealed, err := session.Seal(WrapMsg(playReqMsg, 2, util.StringifyJsonToBytes(playReq{ Name: "alice" })))
+	assert.NoError(t, err)
+	assert.NoError(t, conn.WriteMessage(websocket.BinaryMessage, sealed))
+	_, mb, err = conn.ReadMessage()
+	assert.NoError(t, err)
+	mb, err = session.Open(mb)
+	assert.NoError(t, err)
+	msgType, _, body = UnWrapMsg(mb)
+	assert.Equal(t, playRespMsg, msgType)
+	var pResp playResp
+	assert.NoError(t, util.ParseJsonFromBytes(body, &pResp))
+	assert.Equal(t, big.NewInt(122), pResp.Balance)
+	assert.Equal(t, 1, h.msgCount)
+
+	// 重放的消息直接断开连接
+	assert.NoError(t, conn.WriteMessage(websocket.BinaryMessage, sealed))
+	_, _, err = conn.ReadMessage()
+	assert.Error(t, err)
+	assert.Equal(t, 1, h.msgCount)
+}
+
+// RFC 5869 A.1
+func TestHkdfSha256(t *testing.T) {
+	ikm, _ := hex.DecodeString("0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b")
+	salt, _ := hex.DecodeString("000102030405060708090a0b0c")
+	info, _ := hex.DecodeString("f0f1f2f3f4f5f6f7f8f9")
+	okm := hkdfSha256(ikm, salt, info, 42)
+	assert.Equal(t, "3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865", hex.EncodeToString(okm))
+}
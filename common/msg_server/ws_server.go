@@ -1,6 +1,9 @@
 package msg_server
 
 import (
+	"compress/flate"
+	"context"
+	"crypto/ed25519"
 	"net/http"
 	"github.com/gorilla/websocket"
 	"fmt"
@@ -13,30 +16,23 @@ import (
 	"github.com/LeaguesOfHoleHoleShoes/HoleHole/common/util"
 )
 
-var upgrader = websocket.Upgrader{} // use default options
-
 // msg type
 const (
 	MsgTypeHandShake = 0x0
+	// 批量消息，见WrapBatch
+	MsgTypeBatch = 0xFFFF
 )
 
+// 其他可以配置的见WsOptions
 const (
 	sendMsgChanCache = 50
-	maxPeerCount = 1000
-
-	handShakeWait = 8 * time.Second
-
-	// Time allowed to write a message to the peer.
-	writeWait = 10 * time.Second
-
-	// Time allowed to read the next pong message from the peer.
-	pongWait = 60 * time.Second
-
-	// Send pings to peer with this period. Must be less than pongWait.
-	pingPeriod = (pongWait * 9) / 10
 
-	// Maximum message size allowed from peer.
-	maxMessageSize = 512
+	// 小于这个大小的消息压缩不划算
+	minCompressSize = 256
+	// 一个批量消息最多这么大，超过了就先发出去
+	maxBatchSize = 64 * 1024
+	// 踢掉客户端时最多等close frame这么久
+	kickWait = time.Second
 )
 
 type AbsUser interface {
@@ -48,81 +44,252 @@ type userGetter interface {
 }
 
 type msgHandler interface {
-	Handle(uID string, msgType int, msgID int64, msg []byte) error
+	// codec是这个连接握手时选的，用它解码msg
+	Handle(uID string, msgType int, msgID int64, msg []byte, codec Codec) error
 }
 
 func NewWsServer(port int, userGetter userGetter, msgHandler msgHandler) *WsServer {
-	return &WsServer {
-		port: port,
+	return NewWsServerWithOptions(DefaultWsOptions(port), userGetter, msgHandler)
+}
+
+func NewWsServerWithOptions(opts WsOptions, userGetter userGetter, msgHandler msgHandler) *WsServer {
+	opts = opts.withDefaults()
+	s := &WsServer {
+		opts: opts,
 		userGetter: userGetter,
 		msgHandler: msgHandler,
 		peerSet: newWsPeerSet(),
 		sendMsgChan: make(chan *cMsg, sendMsgChanCache),
+		codecs: map[string]Codec{ CodecJson: JsonCodec },
+		compressionLevel: flate.DefaultCompression,
+		msgLimits: map[int]RateLimit{},
+		schemas: map[int]MsgSchema{},
+		abuseLimit: defaultAbuseLimit,
 	}
+	s.upgrader.CheckOrigin = opts.originChecker()
+	s.mux = http.NewServeMux()
+	s.mux.HandleFunc(opts.Path, s.handlePeer)
+	s.httpServer = &http.Server{ Addr: opts.Addr, Handler: s.mux }
+	return s
 }
 
 type WsServer struct {
-	port int
+	opts WsOptions
+	// 除了websocket还可以挂别的handler，见Handle
+	mux *http.ServeMux
+	httpServer *http.Server
 
 	userGetter userGetter
 	msgHandler msgHandler
 
 	peerSet *wsPeerSet
+	// 客户端可以选的codec，key是Name()
+	codecs map[string]Codec
+
+	upgrader websocket.Upgrader
+	compressionLevel int
+	// 为0时不批量发送
+	batchWindow time.Duration
+	stats sendStats
+
+	// key用户id，value *reliableState
+	reliable sync.Map
+
+	// 为nil时不支持加密会话
+	signKey ed25519.PrivateKey
+	// 不加密的客户端握手失败
+	encryptionRequired bool
+
+	// 为nil时不限流
+	userLimit *RateLimit
+	// key msg type
+	msgLimits map[int]RateLimit
+	// key msg type，为空时不校验
+	schemas map[int]MsgSchema
+	abuseLimit AbuseLimit
+	// key用户id，value *userGuard
+	guards sync.Map
 
 	sendMsgChan chan *cMsg
+	// 已经Send但还没交给peer的消息数
+	pendingCount int64
 }
 
 type cMsg struct {
 	msgID int64
 	uID string
 	msgType int
-	content []byte
+	// 发给peer时才用peer的codec编码，为nil时消息体为空
+	content interface{}
+	// 可靠投递的序号，为0时不是可靠投递
+	seq uint64
+}
+
+// 增加客户端可以选的codec，必须在Run之前调用
+func (s *WsServer) AddCodec(codec Codec) {
+	s.codecs[codec.Name()] = codec
 }
 
+// 客户端支持permessage-deflate时压缩较大的消息，level见compress/flate，必须在Run之前调用
+func (s *WsServer) EnableCompression(level int) {
+	s.upgrader.EnableCompression = true
+	s.compressionLevel = level
+}
+
+/*
+
+开启批量发送，必须在Run之前调用
+握手时HandShakeReq.Batch为true的连接，peer收到消息后再等window，期间收到的消息合成一个MsgTypeBatch发出去
+广播事件扎堆时可以省下很多frame
+
+*/
+func (s *WsServer) EnableBatching(window time.Duration) {
+	s.batchWindow = window
+}
+
+/*
+
+支持握手后的加密会话，见session.go，必须在Run之前调用
+signKey是服务器长期的ed25519私钥，客户端内置对应的公钥
+required为true时不加密的客户端握手失败
+
+*/
+func (s *WsServer) EnableEncryption(signKey ed25519.PrivateKey, required bool) {
+	s.signKey = signKey
+	s.encryptionRequired = required
+}
+
+// 在同一个端口上挂别的http handler，比如健康检查、metrics，必须在Run之前调用
+func (s *WsServer) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// 启动以来发消息的流量统计
+func (s *WsServer) Stats() SendStats {
+	return s.stats.get()
+}
+
+// 阻塞至Shutdown
 func (s *WsServer) Run() error {
 	go s.loop()
-	http.HandleFunc("/msg", s.handlePeer)
-	return http.ListenAndServe(fmt.Sprintf(":%v", s.port), nil)
+	var err error
+	if s.opts.useTLS() {
+		err = s.httpServer.ListenAndServeTLS(s.opts.CertFile, s.opts.KeyFile)
+	} else {
+		err = s.httpServer.ListenAndServe()
+	}
+	if err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+/*
+
+停止服务
+1. 不再接受新连接
+1. 等待已经提交的消息都交给peer，ctx到期则不再等
+1. 断开所有连接，peer断开前会把手上的消息发完
+
+*/
+func (s *WsServer) Shutdown(ctx context.Context) error {
+	err := s.httpServer.Shutdown(ctx)
+	s.flush(ctx)
+	s.peerSet.removeAll()
+	return err
+}
+
+func (s *WsServer) flush(ctx context.Context) {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for atomic.LoadInt64(&s.pendingCount) > 0 {
+		select {
+		case <- ticker.C:
+		case <- ctx.Done():
+			log.L.Warn("flush msg timeout", zap.Int64("remain", atomic.LoadInt64(&s.pendingCount)))
+			return
+		}
+	}
 }
 
 func (s *WsServer) loop() {
-	for tmp := range s.sendMsgChan {
-		s.send(tmp)
+	ticker := time.NewTicker(reliableKeep / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case tmp := <- s.sendMsgChan:
+			s.send(tmp)
+			atomic.AddInt64(&s.pendingCount, -1)
+
+		case now := <- ticker.C:
+			s.removeExpiredReliable(now)
+			s.removeExpiredGuards(now)
+		}
 	}
 }
 
+// 断线太久的用户不再保留没ack的消息
+func (s *WsServer) removeExpiredReliable(now time.Time) {
+	s.reliable.Range(func(key, value interface{}) bool {
+		if value.(*reliableState).expired(now) {
+			s.reliable.Delete(key)
+		}
+		return true
+	})
+}
+
 func (s *WsServer) handlePeer(w http.ResponseWriter, r *http.Request) {
 	log.L.Debug("receive new peer", zap.String("remote addr", r.RemoteAddr))
-	if s.peerSet.peerCount >= maxPeerCount {
-		log.L.Warn("can't receive new peer, too many peers", zap.Int64("cur count", s.peerSet.peerCount), zap.Uint64("max count", maxPeerCount))
+	if atomic.LoadInt64(&s.peerSet.peerCount) >= s.opts.MaxPeers {
+		log.L.Warn("can't receive new peer, too many peers", zap.Int64("cur count", s.peerSet.peerCount), zap.Int64("max count", s.opts.MaxPeers))
 		return
 	}
 
-	c, err := upgrader.Upgrade(w, r, nil)
+	cw := &countingResponseWriter{ ResponseWriter: w }
+	c, err := s.upgrader.Upgrade(cw, r, nil)
 	if err != nil {
 		return
 	}
 	defer c.Close()
+	// 没协商permessage-deflate时是noop
+	c.SetCompressionLevel(s.compressionLevel)
 
-	c.SetReadLimit(maxMessageSize)
+	c.SetReadLimit(s.opts.MaxMessageSize)
 	// hand shake
-	uID, err := s.handleShake(c)
+	uID, config, err := s.handleShake(c)
+	if err == errBanned {
+		closeWithReason(c, websocket.ClosePolicyViolation, CloseReasonBanned)
+	}
 	if uID == "" || err != nil {
 		log.L.Debug("hand shake failed", zap.Error(err), zap.String("u id", uID))
 		return
 	}
-
-	np := newWsPeer(uID, c)
-	s.peerSet.addPeer(np)
-	defer s.peerSet.removePeer(uID)
+	codec := config.codec
+
+	np := newWsPeer(uID, c, cw.conn, config, &s.opts, &s.stats)
+	resumed := s.attachPeer(np, config)
+	// 被新连接顶掉后set里已经是新的peer，只删自己
+	defer s.peerSet.removePeer(np)
+	if np.reliable != nil {
+		defer np.reliable.detach(np)
+	}
+	// 在peer开始发消息之前回复握手
+	if config.resp != nil {
+		config.resp.Resumed = resumed
+		c.SetWriteDeadline(time.Now().Add(s.opts.WriteWait))
+		if err = c.WriteMessage(websocket.BinaryMessage, WrapMsg(MsgTypeHandShake, config.shakeID, util.StringifyJsonToBytes(config.resp))); err != nil {
+			log.L.Debug("reply hand shake failed", zap.Error(err), zap.String("u id", uID))
+			return
+		}
+	}
 	if err := np.start(); err != nil {
 		panic(err)
 	}
 
-	c.SetReadDeadline(time.Now().Add(pongWait))
+	c.SetReadDeadline(time.Now().Add(s.opts.PongWait))
 	c.SetPongHandler(func(string) error {
 		//log.L.Debug("receive pong msg", zap.String("uid", uID))
-		c.SetReadDeadline(time.Now().Add(pongWait))
+		c.SetReadDeadline(time.Now().Add(s.opts.PongWait))
 		return nil
 	})
 	for {
@@ -135,55 +302,178 @@ func (s *WsServer) handlePeer(w http.ResponseWriter, r *http.Request) {
 			log.L.Debug("receive invalid msg", zap.Int("msg type", mt))
 			return
 		}
+		if config.session != nil {
+			if message, err = config.session.open(message); err != nil {
+				log.L.Warn("decrypt msg failed", zap.String("uid", uID), zap.Error(err))
+				return
+			}
+		}
 		msgType, mID, msgB := UnWrapMsg(message)
-		if err = s.msgHandler.Handle(uID, msgType, mID, msgB); err != nil {
-			log.L.Error("handle msg failed", zap.Error(err))
+		// 重连后要拿到同一个，过期删掉后的也不能再用
+		g := s.guard(uID)
+		var code int
+		if msgType == MsgTypeAck {
+			code, err = s.checkAck(g, np.reliable, msgB)
+		} else if code, err = s.check(g, msgType, msgB, codec); code == 0 {
+			if err = s.msgHandler.Handle(uID, msgType, mID, msgB, codec); err != nil {
+				code = RejectCodeHandleFailed
+			}
+		}
+		if code == 0 {
+			continue
+		}
+		log.L.Debug("reject msg", zap.String("uid", uID), zap.Int("msg type", msgType), zap.Int("code", code), zap.Error(err))
+		if reason := s.reject(g, uID, msgType, mID, code, err); reason != "" {
+			log.L.Warn("kick abusive peer", zap.String("uid", uID), zap.String("reason", reason))
+			closeWithReason(c, websocket.ClosePolicyViolation, reason)
 			return
 		}
 	}
 }
 
 type HandShakeReq struct {
-	// 可以考虑下发一个公钥，随后消息需要加解密传输
 	Token string `json:"token"`
+	// 之后的消息用什么编码，CodecJson或CodecProtobuf，为空时用json
+	Codec string `json:"codec,omitempty"`
+	// 能解析MsgTypeBatch的客户端设为true，服务器开启了批量发送时才有用
+	Batch bool `json:"batch,omitempty"`
+	// 要加密时带上客户端临时的X25519公钥，见session.go
+	PubKey []byte `json:"pub_key,omitempty"`
+	// 要可靠投递时设为true，见reliable.go
+	Ack bool `json:"ack,omitempty"`
+	// 重连时带上之前收到的最后一个序号
+	LastSeq uint64 `json:"last_seq,omitempty"`
 }
 
-func (s *WsServer) handleShake(c *websocket.Conn) (string, error) {
-	c.SetReadDeadline(time.Now().Add(handShakeWait))
+// 加密或者可靠投递时服务器用json回复握手，msg id和HandShakeReq的一样
+type HandShakeResp struct {
+	// 服务器的临时X25519公钥
+	PubKey []byte `json:"pub_key,omitempty"`
+	// ed25519签名，签的是sessionInfo + 客户端公钥 + 服务器公钥
+	Signature []byte `json:"signature,omitempty"`
+	// 可靠投递时LastSeq之后的消息都会重发，为false时有消息丢了，客户端要重新拿场景
+	Resumed bool `json:"resumed"`
+}
+
+// 握手时协商的结果
+type peerConfig struct {
+	codec Codec
+	// 为0时不批量发送
+	batchWindow time.Duration
+	// 为nil时不加密
+	session *serverSession
+	ack bool
+	lastSeq uint64
+	// 不为nil时要回复握手
+	resp *HandShakeResp
+	shakeID int64
+}
+
+func (s *WsServer) handleShake(c *websocket.Conn) (string, *peerConfig, error) {
+	c.SetReadDeadline(time.Now().Add(s.opts.HandShakeWait))
 
 	var req HandShakeReq
 	mt, mb, err := c.ReadMessage()
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 	if mt != websocket.BinaryMessage {
-		return "", errors.New(fmt.Sprintf("invalid msg type: %v", mt))
+		return "", nil, errors.New(fmt.Sprintf("invalid msg type: %v", mt))
 	}
 
-	msgType, _, msgB := UnWrapMsg(mb)
+	msgType, mID, msgB := UnWrapMsg(mb)
 	if msgType != MsgTypeHandShake {
-		return "", errors.New(fmt.Sprintf("msg type isn't MsgTypeHandShake, %v", msgType))
+		return "", nil, errors.New(fmt.Sprintf("msg type isn't MsgTypeHandShake, %v", msgType))
 	}
 	if err = util.ParseJsonFromBytes(msgB, &req); err != nil {
-		return "", err
+		return "", nil, err
 	}
 	if req.Token == "" {
-		return "", errors.New("empty token")
+		return "", nil, errors.New("empty token")
+	}
+
+	if req.Codec == "" {
+		req.Codec = CodecJson
+	}
+	codec := s.codecs[req.Codec]
+	if codec == nil {
+		return "", nil, errors.New(fmt.Sprintf("unsupported codec: %v", req.Codec))
 	}
 
 	u := s.userGetter.GetUserByToken(req.Token)
 	if u == nil {
-		return "", errors.New("invalid token")
+		return "", nil, errors.New("invalid token")
+	}
+	if s.guard(u.ID()).banned(time.Now()) {
+		return "", nil, errBanned
+	}
+	config := &peerConfig{ codec: codec, ack: req.Ack, lastSeq: req.LastSeq, shakeID: mID }
+	if req.Ack {
+		config.resp = &HandShakeResp{}
+	}
+	if req.Batch {
+		config.batchWindow = s.batchWindow
+	}
+	if err = s.startSession(&req, config); err != nil {
+		return "", nil, err
+	}
+	log.L.Debug("hand shake success", zap.String("u id", u.ID()), zap.String("codec", req.Codec), zap.Bool("batch", config.batchWindow > 0), zap.Bool("encrypted", config.session != nil))
+	return u.ID(), config, nil
+}
+
+// 客户端带了公钥时完成密钥交换，要在HandShakeResp中回复服务器的公钥
+func (s *WsServer) startSession(req *HandShakeReq, config *peerConfig) error {
+	if len(req.PubKey) == 0 {
+		if s.encryptionRequired {
+			return errors.New("encryption required")
+		}
+		return nil
+	}
+	if s.signKey == nil {
+		return errors.New("encryption not supported")
+	}
+	session, resp, err := newServerSession(s.signKey, req.PubKey)
+	if err != nil {
+		return err
+	}
+	config.session = session
+	if config.resp == nil {
+		config.resp = resp
+	} else {
+		config.resp.PubKey, config.resp.Signature = resp.PubKey, resp.Signature
+	}
+	return nil
+}
+
+// 把peer放进peer set，可靠投递时接上之前的状态，返回没ack的消息是否都能重发
+func (s *WsServer) attachPeer(p *wsPeer, config *peerConfig) bool {
+	if !config.ack {
+		s.reliable.Delete(p.id)
+		s.peerSet.addPeer(p)
+		return false
 	}
-	log.L.Debug("hand shake success", zap.String("u id", u.ID()))
-	return u.ID(), nil
+	tmp, _ := s.reliable.LoadOrStore(p.id, newReliableState())
+	st := tmp.(*reliableState)
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	s.peerSet.addPeer(p)
+	var resumed bool
+	p.pending, resumed = st.resume(p, config.lastSeq)
+	p.reliable = st
+	return resumed
 }
 
-func (s *WsServer) Send(id string, msgType int, msgID int64, msg []byte) {
+// msg用接收者握手时选的codec编码
+func (s *WsServer) Send(id string, msgType int, msgID int64, msg interface{}) {
+	atomic.AddInt64(&s.pendingCount, 1)
 	s.sendMsgChan <- &cMsg{ msgID: msgID, uID: id, msgType: msgType, content: msg }
 }
 
 func (s *WsServer) send(msg *cMsg) {
+	if tmp, ok := s.reliable.Load(msg.uID); ok {
+		s.sendReliable(tmp.(*reliableState), msg)
+		return
+	}
 	p := s.peerSet.getPeer(msg.uID)
 	if p == nil {
 		log.L.Warn("can't find peer in peer set, msg not send", zap.String("uid", msg.uID))
@@ -193,6 +483,20 @@ func (s *WsServer) send(msg *cMsg) {
 	p.send(msg)
 }
 
+// 断线时消息留着等重连
+func (s *WsServer) sendReliable(st *reliableState, msg *cMsg) {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	tagged, kick := st.push(msg)
+	if kick {
+		st.peer.kick(CloseReasonTooManyUnacked)
+		return
+	}
+	if st.peer != nil {
+		st.peer.send(tagged)
+	}
+}
+
 func newWsPeerSet() *wsPeerSet {
 	return &wsPeerSet{}
 }
@@ -200,6 +504,9 @@ func newWsPeerSet() *wsPeerSet {
 type wsPeerSet struct {
 	// key player id
 	peers     sync.Map
+	// 比较后删除、替换时拿着，不会删掉刚放进来的新peer
+	lock sync.Mutex
+	// peers中有几个，同一个用户只算一个
 	peerCount int64
 }
 
@@ -210,31 +517,44 @@ func (ps *wsPeerSet) getPeer(id string) *wsPeer {
 	return nil
 }
 
-func (ps *wsPeerSet) removePeer(id string) {
-	if p := ps.getPeer(id); p != nil {
-		log.L.Debug("remove peer", zap.String("uid", id))
-		p.stop()
-		ps.peers.Delete(id)
+// 停掉p，set中存的还是p时才删除
+func (ps *wsPeerSet) removePeer(p *wsPeer) {
+	p.stop()
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+	if ps.getPeer(p.id) == p {
+		log.L.Debug("remove peer", zap.String("uid", p.id))
+		ps.peers.Delete(p.id)
 		atomic.AddInt64(&ps.peerCount, -1)
 	}
 }
 
+func (ps *wsPeerSet) removeAll() {
+	ps.peers.Range(func(key, value interface{}) bool {
+		ps.removePeer(value.(*wsPeer))
+		return true
+	})
+}
+
+// 同一个用户之前的peer被顶掉，它的连接退出时不会再删掉p
 func (ps *wsPeerSet) addPeer(p *wsPeer) {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
 	if preP := ps.getPeer(p.id); preP != nil {
-		// Close后会触发remove，执行一次count-1
 		preP.stop()
-		// 等待上一个conn从peer set中删除
-		time.Sleep(10 * time.Millisecond)
+	} else {
+		atomic.AddInt64(&ps.peerCount, 1)
 	}
-	atomic.AddInt64(&ps.peerCount, 1)
-
 	ps.peers.Store(p.id, p)
 }
 
-func newWsPeer(id string, conn *websocket.Conn) *wsPeer {
+func newWsPeer(id string, conn *websocket.Conn, netConn *countingConn, config *peerConfig, opts *WsOptions, stats *sendStats) *wsPeer {
 	return &wsPeer{
-		id: id, conn: conn,
-		sendChan: make(chan *cMsg, sendMsgChanCache),
+		id: id, conn: conn, netConn: netConn, stats: stats,
+		codec: config.codec, batchWindow: config.batchWindow, session: config.session,
+		writeWait: opts.WriteWait, pingPeriod: opts.PingPeriod,
+		sendChan: make(chan *cMsg, opts.SendQueueSize),
+		stopChan: make(chan struct{}),
 	}
 }
 
@@ -242,73 +562,194 @@ type wsPeer struct {
 	// user id
 	id string
 	conn *websocket.Conn
+	// conn底下的连接，用来统计实际写了多少byte
+	netConn *countingConn
+	stats *sendStats
+	codec Codec
+	batchWindow time.Duration
+	session *serverSession
+	writeWait time.Duration
+	pingPeriod time.Duration
+	// 为nil时不是可靠投递
+	reliable *reliableState
+	// 重连后要先重发的消息
+	pending []*cMsg
 	sendChan chan *cMsg
+	// 只close不重新赋值，loop一直在读它
 	stopChan chan struct{}
+	stopOnce sync.Once
+	started int32
+	kickOnce sync.Once
 }
 
 func (p *wsPeer) start() error {
-	if p.stopChan != nil {
+	if !atomic.CompareAndSwapInt32(&p.started, 0, 1) {
 		return errors.New("peer already started")
 	}
-	p.stopChan = make(chan struct{})
 	go p.loop()
 
 	return nil
 }
 
-// close stop chan 后会调用conn.close
-func (p *wsPeer) stop() error {
-	if p.stopChan == nil {
-		return errors.New("peer not started")
-	}
-	close(p.stopChan)
-	p.stopChan = nil
-
-	return nil
+// close stop chan 后会调用conn.close，在start之前stop的话loop一开始就退出
+func (p *wsPeer) stop() {
+	p.stopOnce.Do(func() {
+		close(p.stopChan)
+	})
 }
 
 func (p *wsPeer) loop() {
-	ticker := time.NewTicker(pingPeriod)
+	ticker := time.NewTicker(p.pingPeriod)
 	defer func() {
 		ticker.Stop()
 		p.conn.Close()
 	}()
+	for _, msg := range p.pending {
+		if err := p.doSend(msg); err != nil {
+			return
+		}
+	}
 	for {
 		select {
 		case msg := <- p.sendChan:
-			if err := p.doSend(msg); err != nil {
+			send := p.doSend
+			if p.batchWindow > 0 {
+				send = p.sendBatch
+			}
+			if err := send(msg); err != nil {
 				return
 			}
 
 		case <- ticker.C:
 			//log.L.Debug("send ping msg to", zap.String("uid", p.id))
-			if err := p.doSend(&cMsg{ msgType: websocket.PingMessage }); err != nil {
+			p.conn.SetWriteDeadline(time.Now().Add(p.writeWait))
+			if err := p.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
 
 		case <- p.stopChan:
+			p.flush()
 			log.L.Debug("peer loop returned", zap.String("uid", p.id))
 			return
 		}
 	}
 }
 
+// 关闭前把已经收到的消息发完
+func (p *wsPeer) flush() {
+	for {
+		select {
+		case msg := <- p.sendChan:
+			if err := p.doSend(msg); err != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// 客户端收得太慢，发送队列满了就断开，可靠投递的客户端重连后会重发
 func (p *wsPeer) send(msg *cMsg) {
 	select {
 	case p.sendChan <- msg:
 	default:
-		log.L.Warn("can't send msg to client", zap.String("uid", p.id), zap.Int("send chan len", len(p.sendChan)))
-		// todo add retry in server
+		p.kick(CloseReasonSlowConsumer)
+	}
+}
+
+/*
+
+发close frame说明原因后断开，read失败后handlePeer会移除peer
+客户端收得慢时close frame可能要等，不能卡住调用方
+
+*/
+func (p *wsPeer) kick(reason string) {
+	p.kickOnce.Do(func() {
+		log.L.Warn("kick peer", zap.String("uid", p.id), zap.String("reason", reason), zap.Int("send chan len", len(p.sendChan)))
+		go closeWithReason(p.conn, websocket.CloseTryAgainLater, reason)
+	})
+}
+
+// 编码失败只丢掉这条消息，返回nil，不断开连接
+func (p *wsPeer) encode(msg *cMsg) []byte {
+	var content []byte
+	if raw, ok := msg.content.(rawContent); ok {
+		content = raw
+	} else if msg.content != nil {
+		var err error
+		if content, err = p.codec.Marshal(msg.content); err != nil {
+			log.L.Error("marshal msg failed", zap.String("uid", p.id), zap.Int("msg type", msg.msgType), zap.String("codec", p.codec.Name()), zap.Error(err))
+			return nil
+		}
 	}
+	if msg.seq > 0 {
+		return WrapMsg(MsgTypeSeq, int64(msg.seq), WrapMsg(msg.msgType, msg.msgID, content))
+	}
+	return WrapMsg(msg.msgType, msg.msgID, content)
 }
 
 func (p *wsPeer) doSend(msg *cMsg) error {
-	p.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	mb := p.encode(msg)
+	if mb == nil {
+		return nil
+	}
+	return p.write(mb, 1, len(mb) + frameHeaderSize(len(mb)))
+}
+
+/*
 
-	mb := msg.content
-	if msg.msgType == websocket.BinaryMessage {
-		mb = WrapMsg(msg.msgType, msg.msgID, msg.content)
+在batchWindow内收集消息，合成一个MsgTypeBatch发出去
+窗口内只有一条消息时照常发，不加批量的头
+
+*/
+func (p *wsPeer) sendBatch(first *cMsg) error {
+	var msgs [][]byte
+	size, payload := 0, 0
+	add := func(msg *cMsg) {
+		if mb := p.encode(msg); mb != nil {
+			msgs = append(msgs, mb)
+			size += len(mb)
+			payload += len(mb) + frameHeaderSize(len(mb))
+		}
 	}
+	add(first)
 
-	return p.conn.WriteMessage(msg.msgType, mb)
+	timer := time.NewTimer(p.batchWindow)
+	defer timer.Stop()
+	collecting := true
+	for collecting && size < maxBatchSize {
+		select {
+		case msg := <- p.sendChan:
+			add(msg)
+		case <- timer.C:
+			collecting = false
+		}
+	}
+
+	switch len(msgs) {
+	case 0:
+		return nil
+	case 1:
+		return p.write(msgs[0], 1, payload)
+	}
+	return p.write(WrapBatch(msgs), len(msgs), payload)
+}
+
+// payload是这些消息不批量不压缩时要写的byte数
+func (p *wsPeer) write(mb []byte, msgCount int, payload int) error {
+	p.conn.SetWriteDeadline(time.Now().Add(p.writeWait))
+	if p.session != nil {
+		// 密文压缩不了
+		mb = p.session.seal(mb)
+		p.conn.EnableWriteCompression(false)
+	} else {
+		p.conn.EnableWriteCompression(len(mb) >= minCompressSize)
+	}
+	before := p.netConn.getWritten()
+	if err := p.conn.WriteMessage(websocket.BinaryMessage, mb); err != nil {
+		return err
+	}
+	p.stats.add(msgCount, payload, p.netConn.getWritten() - before)
+	return nil
 }
\ No newline at end of file
@@ -1,6 +1,7 @@
 package msg_server
 
 import (
+	"context"
 	"testing"
 	"fmt"
 	"net/url"
@@ -46,12 +47,12 @@ type fakeMsgHandler struct {
 	server *WsServer
 }
 
-func (f *fakeMsgHandler) Handle(uID string, msgType int, mID int64, msg []byte) error {
+func (f *fakeMsgHandler) Handle(uID string, msgType int, mID int64, msg []byte, codec Codec) error {
 	f.msgCount++
 	switch msgType {
 	case playReqMsg:
 		var req playReq
-		if err := util.ParseJsonFromBytes(msg, &req); err != nil {
+		if err := codec.Unmarshal(msg, &req); err != nil {
 			return err
 		}
 		log.L.Sugar().Debug("receive req", req)
@@ -59,7 +60,7 @@ func (f *fakeMsgHandler) Handle(uID string, msgType int, mID int64, msg []byte)
 			if f.server == nil {
 				panic("f.server is nil")
 			}
-			f.server.Send(uID, playRespMsg, 1, util.StringifyJsonToBytes(playResp{ Balance: big.NewInt(122) }))
+			f.server.Send(uID, playRespMsg, 1, playResp{ Balance: big.NewInt(122) })
 		}
 	}
 	return nil
@@ -176,4 +177,42 @@ func TestNormalSeen(t *testing.T) {
 	//assert.Error(t, err)
 
 	time.Sleep(100 * time.Millisecond)
-}
\ No newline at end of file
+}
+// 停服时已经提交的消息会先发出去，随后断开所有连接，Run正常返回
+func TestShutdown(t *testing.T) {
+	server := NewWsServer(3334, &fakeUserGetter{}, &fakeMsgHandler{})
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- server.Run()
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	u := url.URL{Scheme: "ws", Host: "localhost:3334", Path: "/msg"}
+	var dialer *websocket.Dialer
+	conn, _, err := dialer.Dial(u.String(), nil)
+	if err != nil {
+		panic(err)
+	}
+	err = conn.WriteMessage(websocket.BinaryMessage, WrapMsg(MsgTypeHandShake, 1, util.StringifyJsonToBytes(HandShakeReq{ Token: "2" })))
+	assert.NoError(t, err)
+	time.Sleep(20 * time.Millisecond)
+
+	server.Send("2", playRespMsg, 2, playResp{ Balance: big.NewInt(1) })
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, server.Shutdown(ctx))
+	assert.NoError(t, <- runErr)
+
+	_, mb, err := conn.ReadMessage()
+	assert.NoError(t, err)
+	msgType, mID, _ := UnWrapMsg(mb)
+	assert.Equal(t, playRespMsg, msgType)
+	assert.Equal(t, 2, int(mID))
+	_, _, err = conn.ReadMessage()
+	assert.Error(t, err)
+	assert.Nil(t, server.peerSet.getPeer("2"))
+
+	// 不再接受新连接
+	_, _, err = dialer.Dial(u.String(), nil)
+	assert.Error(t, err)
+}
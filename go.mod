@@ -2,6 +2,7 @@ module github.com/LeaguesOfHoleHoleShoes/HoleHole
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/golang/protobuf v1.2.0
 	github.com/gorilla/websocket v1.4.0
 	github.com/json-iterator/go v1.1.5
 	github.com/kr/pretty v0.1.0 // indirect
@@ -1,5 +1,7 @@
 package abstracts
 
+import "time"
+
 type User interface {
 	// user的id
 	ID() string
@@ -55,6 +57,8 @@ type Table interface {
 	Do(action PlayerActionMsg) error
 
 	GetScene(uID string) TableScene
+	// 停服前调用：不再接受Enter，等当前这局打完（超过timeout则取消本局并退回下注），通知桌上的人后清空座位
+	Drain(timeout time.Duration) error
 	//TakeASeat()
 	//StandUp()
 }
@@ -65,6 +69,8 @@ type Game interface {
 	OnMsg(msg PlayerActionMsg)
 	CanLeave(uID string) bool
 	GetScene(uid string) *GameScene
+	// 取消本局并退回所有下注
+	Cancel()
 }
 
 type HandMatcher interface {
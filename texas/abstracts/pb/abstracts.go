@@ -0,0 +1,267 @@
+package pb
+
+import "github.com/golang/protobuf/proto"
+
+/*
+
+abstracts.proto中的消息，vendor里只有proto库没有protoc-gen-go，这里是手写的
+struct tag的格式和protoc-gen-go生成的一样，proto库按tag反射编解码
+改abstracts.proto时要同步改这里，TestProtoFileMatchStructs会检查两边的字段
+
+*/
+
+type PlayerActionMsg struct {
+	GameID int64 `protobuf:"varint,1,opt,name=game_id,proto3"`
+	Round uint32 `protobuf:"varint,2,opt,name=round,proto3"`
+	ActionType uint32 `protobuf:"varint,3,opt,name=action_type,proto3"`
+	Amount uint64 `protobuf:"varint,4,opt,name=amount,proto3"`
+	Player uint32 `protobuf:"varint,5,opt,name=player,proto3"`
+}
+
+type ErrResp struct {
+	ErrCode int64 `protobuf:"varint,1,opt,name=err_code,proto3"`
+	Info string `protobuf:"bytes,2,opt,name=info,proto3"`
+}
+
+type SuccessResp struct {
+	Info string `protobuf:"bytes,1,opt,name=info,proto3"`
+}
+
+type TableClosedResp struct {
+	TableID int64 `protobuf:"varint,1,opt,name=table_id,proto3"`
+	GameCancelled bool `protobuf:"varint,2,opt,name=game_cancelled,proto3"`
+}
+
+type TableScene struct {
+	CurD int64 `protobuf:"varint,1,opt,name=cur_d,proto3"`
+	CurBet int64 `protobuf:"varint,2,opt,name=cur_bet,proto3"`
+	Players []*PlayerScene `protobuf:"bytes,3,rep,name=players,proto3"`
+	CommonPokers []*PokerScene `protobuf:"bytes,4,rep,name=common_pokers,proto3"`
+	ChipPools []*ChipPoolScene `protobuf:"bytes,5,rep,name=chip_pool,proto3"`
+}
+
+type GameScene struct {
+	CurBet string `protobuf:"bytes,1,opt,name=cur_bet,proto3"`
+	Players map[string]*PlayerScene `protobuf:"bytes,2,rep,name=players,proto3" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	CommonPokers []*PokerScene `protobuf:"bytes,3,rep,name=common_pokers,proto3"`
+	ChipPools []*ChipPoolScene `protobuf:"bytes,4,rep,name=chip_pools,proto3"`
+}
+
+type PlayerScene struct {
+	UserID string `protobuf:"bytes,1,opt,name=user_id,proto3"`
+	RemainChip uint64 `protobuf:"varint,2,opt,name=remain_chip,proto3"`
+	Pokers []*PokerScene `protobuf:"bytes,3,rep,name=pokers,proto3"`
+	Status int64 `protobuf:"varint,4,opt,name=status,proto3"`
+}
+
+type TournamentStartResp struct {
+	TournamentID int64 `protobuf:"varint,1,opt,name=tournament_id,proto3"`
+	TableID int64 `protobuf:"varint,2,opt,name=table_id,proto3"`
+	StartingStack uint64 `protobuf:"varint,3,opt,name=starting_stack,proto3"`
+}
+
+type TournamentLevelResp struct {
+	TournamentID int64 `protobuf:"varint,1,opt,name=tournament_id,proto3"`
+	Level int64 `protobuf:"varint,2,opt,name=level,proto3"`
+	Xm uint64 `protobuf:"varint,3,opt,name=xm,proto3"`
+	Dm uint64 `protobuf:"varint,4,opt,name=dm,proto3"`
+	Ante uint64 `protobuf:"varint,5,opt,name=ante,proto3"`
+	Break bool `protobuf:"varint,6,opt,name=break,proto3"`
+	Seconds int64 `protobuf:"varint,7,opt,name=seconds,proto3"`
+}
+
+type TournamentResultResp struct {
+	TournamentID int64 `protobuf:"varint,1,opt,name=tournament_id,proto3"`
+	Place int64 `protobuf:"varint,2,opt,name=place,proto3"`
+	Prize uint64 `protobuf:"varint,3,opt,name=prize,proto3"`
+}
+
+type TournamentMoveResp struct {
+	TournamentID int64 `protobuf:"varint,1,opt,name=tournament_id,proto3"`
+	FromTableID int64 `protobuf:"varint,2,opt,name=from_table_id,proto3"`
+	ToTableID int64 `protobuf:"varint,3,opt,name=to_table_id,proto3"`
+}
+
+type TournamentLobbyResp struct {
+	TournamentID int64 `protobuf:"varint,1,opt,name=tournament_id,proto3"`
+	Level int64 `protobuf:"varint,2,opt,name=level,proto3"`
+	Xm uint64 `protobuf:"varint,3,opt,name=xm,proto3"`
+	Entrants int64 `protobuf:"varint,4,opt,name=entrants,proto3"`
+	PrizePool uint64 `protobuf:"varint,5,opt,name=prize_pool,proto3"`
+	LateRegistration bool `protobuf:"varint,6,opt,name=late_registration,proto3"`
+	Remain int64 `protobuf:"varint,7,opt,name=remain,proto3"`
+	AvgStack uint64 `protobuf:"varint,8,opt,name=avg_stack,proto3"`
+	HandForHand bool `protobuf:"varint,9,opt,name=hand_for_hand,proto3"`
+	FinalTable bool `protobuf:"varint,10,opt,name=final_table,proto3"`
+	Tables []*TournamentTableScene `protobuf:"bytes,11,rep,name=tables,proto3"`
+}
+
+type TournamentTableScene struct {
+	TableID int64 `protobuf:"varint,1,opt,name=table_id,proto3"`
+	PlayerCount int64 `protobuf:"varint,2,opt,name=player_count,proto3"`
+}
+
+type AllInEquityResp struct {
+	Round uint32 `protobuf:"varint,1,opt,name=round,proto3"`
+	RunTimes int64 `protobuf:"varint,2,opt,name=run_times,proto3"`
+	Players []*PlayerEquity `protobuf:"bytes,3,rep,name=players,proto3"`
+}
+
+type PlayerEquity struct {
+	UserID string `protobuf:"bytes,1,opt,name=user_id,proto3"`
+	Equity float64 `protobuf:"fixed64,2,opt,name=equity,proto3"`
+}
+
+type RunoutsResp struct {
+	Boards []*Board `protobuf:"bytes,1,rep,name=boards,proto3"`
+}
+
+type Board struct {
+	Pokers []*PokerScene `protobuf:"bytes,1,rep,name=pokers,proto3"`
+}
+
+type ShowdownResp struct {
+	CommonPokers []*PokerScene `protobuf:"bytes,1,rep,name=common_pokers,proto3"`
+	Players []*ShowdownPlayer `protobuf:"bytes,2,rep,name=players,proto3"`
+	Pots []*PotResult `protobuf:"bytes,3,rep,name=pots,proto3"`
+	Boards []*Board `protobuf:"bytes,4,rep,name=boards,proto3"`
+}
+
+type ShowdownPlayer struct {
+	UserID string `protobuf:"bytes,1,opt,name=user_id,proto3"`
+	Mucked bool `protobuf:"varint,2,opt,name=mucked,proto3"`
+	Pokers []*PokerScene `protobuf:"bytes,3,rep,name=pokers,proto3"`
+	BestFive []*PokerScene `protobuf:"bytes,4,rep,name=best_five,proto3"`
+	HandType int64 `protobuf:"varint,5,opt,name=hand_type,proto3"`
+	HandName string `protobuf:"bytes,6,opt,name=hand_name,proto3"`
+	CnHandName string `protobuf:"bytes,7,opt,name=cn_hand_name,proto3"`
+	LowName string `protobuf:"bytes,8,opt,name=low_name,proto3"`
+	Won uint64 `protobuf:"varint,9,opt,name=won,proto3"`
+	WonPots []int64 `protobuf:"varint,10,rep,packed,name=won_pots,proto3"`
+	Runs []*ShowdownRun `protobuf:"bytes,11,rep,name=runs,proto3"`
+}
+
+type ShowdownRun struct {
+	BestFive []*PokerScene `protobuf:"bytes,1,rep,name=best_five,proto3"`
+	HandType int64 `protobuf:"varint,2,opt,name=hand_type,proto3"`
+	HandName string `protobuf:"bytes,3,opt,name=hand_name,proto3"`
+	CnHandName string `protobuf:"bytes,4,opt,name=cn_hand_name,proto3"`
+	LowName string `protobuf:"bytes,5,opt,name=low_name,proto3"`
+}
+
+type PotResult struct {
+	Amount uint64 `protobuf:"varint,1,opt,name=amount,proto3"`
+	Winners []*PotWinner `protobuf:"bytes,2,rep,name=winners,proto3"`
+}
+
+type PotWinner struct {
+	UserID string `protobuf:"bytes,1,opt,name=user_id,proto3"`
+	Amount uint64 `protobuf:"varint,2,opt,name=amount,proto3"`
+	Low bool `protobuf:"varint,3,opt,name=low,proto3"`
+}
+
+type PokerScene struct {
+	Whole string `protobuf:"bytes,1,opt,name=whole,proto3"`
+	As string `protobuf:"bytes,2,opt,name=as,proto3"`
+	Up bool `protobuf:"varint,3,opt,name=up,proto3"`
+}
+
+type ChipPoolScene struct {
+	Chips uint64 `protobuf:"varint,1,opt,name=chips,proto3"`
+}
+
+func (m *PlayerActionMsg) Reset() { *m = PlayerActionMsg{} }
+func (m *PlayerActionMsg) String() string { return proto.CompactTextString(m) }
+func (*PlayerActionMsg) ProtoMessage() {}
+
+func (m *ErrResp) Reset() { *m = ErrResp{} }
+func (m *ErrResp) String() string { return proto.CompactTextString(m) }
+func (*ErrResp) ProtoMessage() {}
+
+func (m *SuccessResp) Reset() { *m = SuccessResp{} }
+func (m *SuccessResp) String() string { return proto.CompactTextString(m) }
+func (*SuccessResp) ProtoMessage() {}
+
+func (m *TableClosedResp) Reset() { *m = TableClosedResp{} }
+func (m *TableClosedResp) String() string { return proto.CompactTextString(m) }
+func (*TableClosedResp) ProtoMessage() {}
+
+func (m *TableScene) Reset() { *m = TableScene{} }
+func (m *TableScene) String() string { return proto.CompactTextString(m) }
+func (*TableScene) ProtoMessage() {}
+
+func (m *GameScene) Reset() { *m = GameScene{} }
+func (m *GameScene) String() string { return proto.CompactTextString(m) }
+func (*GameScene) ProtoMessage() {}
+
+func (m *PlayerScene) Reset() { *m = PlayerScene{} }
+func (m *PlayerScene) String() string { return proto.CompactTextString(m) }
+func (*PlayerScene) ProtoMessage() {}
+
+func (m *TournamentStartResp) Reset() { *m = TournamentStartResp{} }
+func (m *TournamentStartResp) String() string { return proto.CompactTextString(m) }
+func (*TournamentStartResp) ProtoMessage() {}
+
+func (m *TournamentLevelResp) Reset() { *m = TournamentLevelResp{} }
+func (m *TournamentLevelResp) String() string { return proto.CompactTextString(m) }
+func (*TournamentLevelResp) ProtoMessage() {}
+
+func (m *TournamentResultResp) Reset() { *m = TournamentResultResp{} }
+func (m *TournamentResultResp) String() string { return proto.CompactTextString(m) }
+func (*TournamentResultResp) ProtoMessage() {}
+
+func (m *TournamentMoveResp) Reset() { *m = TournamentMoveResp{} }
+func (m *TournamentMoveResp) String() string { return proto.CompactTextString(m) }
+func (*TournamentMoveResp) ProtoMessage() {}
+
+func (m *TournamentLobbyResp) Reset() { *m = TournamentLobbyResp{} }
+func (m *TournamentLobbyResp) String() string { return proto.CompactTextString(m) }
+func (*TournamentLobbyResp) ProtoMessage() {}
+
+func (m *TournamentTableScene) Reset() { *m = TournamentTableScene{} }
+func (m *TournamentTableScene) String() string { return proto.CompactTextString(m) }
+func (*TournamentTableScene) ProtoMessage() {}
+
+func (m *AllInEquityResp) Reset() { *m = AllInEquityResp{} }
+func (m *AllInEquityResp) String() string { return proto.CompactTextString(m) }
+func (*AllInEquityResp) ProtoMessage() {}
+
+func (m *PlayerEquity) Reset() { *m = PlayerEquity{} }
+func (m *PlayerEquity) String() string { return proto.CompactTextString(m) }
+func (*PlayerEquity) ProtoMessage() {}
+
+func (m *RunoutsResp) Reset() { *m = RunoutsResp{} }
+func (m *RunoutsResp) String() string { return proto.CompactTextString(m) }
+func (*RunoutsResp) ProtoMessage() {}
+
+func (m *Board) Reset() { *m = Board{} }
+func (m *Board) String() string { return proto.CompactTextString(m) }
+func (*Board) ProtoMessage() {}
+
+func (m *ShowdownResp) Reset() { *m = ShowdownResp{} }
+func (m *ShowdownResp) String() string { return proto.CompactTextString(m) }
+func (*ShowdownResp) ProtoMessage() {}
+
+func (m *ShowdownPlayer) Reset() { *m = ShowdownPlayer{} }
+func (m *ShowdownPlayer) String() string { return proto.CompactTextString(m) }
+func (*ShowdownPlayer) ProtoMessage() {}
+
+func (m *ShowdownRun) Reset() { *m = ShowdownRun{} }
+func (m *ShowdownRun) String() string { return proto.CompactTextString(m) }
+func (*ShowdownRun) ProtoMessage() {}
+
+func (m *PotResult) Reset() { *m = PotResult{} }
+func (m *PotResult) String() string { return proto.CompactTextString(m) }
+func (*PotResult) ProtoMessage() {}
+
+func (m *PotWinner) Reset() { *m = PotWinner{} }
+func (m *PotWinner) String() string { return proto.CompactTextString(m) }
+func (*PotWinner) ProtoMessage() {}
+
+func (m *PokerScene) Reset() { *m = PokerScene{} }
+func (m *PokerScene) String() string { return proto.CompactTextString(m) }
+func (*PokerScene) ProtoMessage() {}
+
+func (m *ChipPoolScene) Reset() { *m = ChipPoolScene{} }
+func (m *ChipPoolScene) String() string { return proto.CompactTextString(m) }
+func (*ChipPoolScene) ProtoMessage() {}
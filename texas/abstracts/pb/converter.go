@@ -0,0 +1,475 @@
+package pb
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"github.com/golang/protobuf/proto"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/common/msg_server"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/abstracts"
+)
+
+// 收发abstracts中消息的protobuf codec
+func NewCodec() msg_server.Codec {
+	return msg_server.NewProtoCodec(Converter{})
+}
+
+// abstracts中的消息和proto消息互相转换，发消息时值和指针都可以
+type Converter struct {}
+
+func (Converter) ToProto(v interface{}) (proto.Message, error) {
+	switch m := indirect(v).(type) {
+	case abstracts.PlayerActionMsg:
+		return &PlayerActionMsg{ GameID: m.GameID, Round: uint32(m.Round), ActionType: uint32(m.ActionType), Amount: m.Amount, Player: uint32(m.Player) }, nil
+	case abstracts.ErrResp:
+		return &ErrResp{ ErrCode: int64(m.ErrCode), Info: m.Info }, nil
+	case abstracts.SuccessResp:
+		return &SuccessResp{ Info: m.Info }, nil
+	case abstracts.TableClosedResp:
+		return &TableClosedResp{ TableID: int64(m.TableID), GameCancelled: m.GameCancelled }, nil
+	case abstracts.TableScene:
+		return toTableScene(&m), nil
+	case abstracts.GameScene:
+		return toGameScene(&m), nil
+	case abstracts.PlayerScene:
+		return toPlayerScene(&m), nil
+	case abstracts.TournamentStartResp:
+		return &TournamentStartResp{ TournamentID: int64(m.TournamentID), TableID: int64(m.TableID), StartingStack: m.StartingStack }, nil
+	case abstracts.TournamentLevelResp:
+		return &TournamentLevelResp{ TournamentID: int64(m.TournamentID), Level: int64(m.Level), Xm: m.Xm, Dm: m.Dm, Ante: m.Ante, Break: m.Break, Seconds: m.Seconds }, nil
+	case abstracts.TournamentResultResp:
+		return &TournamentResultResp{ TournamentID: int64(m.TournamentID), Place: int64(m.Place), Prize: m.Prize }, nil
+	case abstracts.TournamentMoveResp:
+		return &TournamentMoveResp{ TournamentID: int64(m.TournamentID), FromTableID: int64(m.FromTableID), ToTableID: int64(m.ToTableID) }, nil
+	case abstracts.TournamentLobbyResp:
+		return toTournamentLobbyResp(&m), nil
+	case abstracts.TournamentTableScene:
+		return toTournamentTableScene(&m), nil
+	case abstracts.AllInEquityResp:
+		return toAllInEquityResp(&m), nil
+	case abstracts.PlayerEquity:
+		return toPlayerEquity(&m), nil
+	case abstracts.RunoutsResp:
+		return toRunoutsResp(&m), nil
+	case abstracts.ShowdownResp:
+		return toShowdownResp(&m), nil
+	case abstracts.ShowdownPlayer:
+		return toShowdownPlayer(&m), nil
+	case abstracts.PotResult:
+		return toPotResult(&m), nil
+	case abstracts.PotWinner:
+		return toPotWinner(&m), nil
+	case abstracts.PokerScene:
+		return toPokerScene(&m), nil
+	case abstracts.ChipPoolScene:
+		return toChipPoolScene(&m), nil
+	}
+	return nil, errors.New(fmt.Sprintf("no proto message for %T", v))
+}
+
+func (Converter) NewProto(v interface{}) (proto.Message, error) {
+	switch v.(type) {
+	case *abstracts.PlayerActionMsg:
+		return &PlayerActionMsg{}, nil
+	case *abstracts.ErrResp:
+		return &ErrResp{}, nil
+	case *abstracts.SuccessResp:
+		return &SuccessResp{}, nil
+	case *abstracts.TableClosedResp:
+		return &TableClosedResp{}, nil
+	case *abstracts.TableScene:
+		return &TableScene{}, nil
+	case *abstracts.GameScene:
+		return &GameScene{}, nil
+	case *abstracts.PlayerScene:
+		return &PlayerScene{}, nil
+	case *abstracts.TournamentStartResp:
+		return &TournamentStartResp{}, nil
+	case *abstracts.TournamentLevelResp:
+		return &TournamentLevelResp{}, nil
+	case *abstracts.TournamentResultResp:
+		return &TournamentResultResp{}, nil
+	case *abstracts.TournamentMoveResp:
+		return &TournamentMoveResp{}, nil
+	case *abstracts.TournamentLobbyResp:
+		return &TournamentLobbyResp{}, nil
+	case *abstracts.TournamentTableScene:
+		return &TournamentTableScene{}, nil
+	case *abstracts.AllInEquityResp:
+		return &AllInEquityResp{}, nil
+	case *abstracts.PlayerEquity:
+		return &PlayerEquity{}, nil
+	case *abstracts.RunoutsResp:
+		return &RunoutsResp{}, nil
+	case *abstracts.ShowdownResp:
+		return &ShowdownResp{}, nil
+	case *abstracts.ShowdownPlayer:
+		return &ShowdownPlayer{}, nil
+	case *abstracts.PotResult:
+		return &PotResult{}, nil
+	case *abstracts.PotWinner:
+		return &PotWinner{}, nil
+	case *abstracts.PokerScene:
+		return &PokerScene{}, nil
+	case *abstracts.ChipPoolScene:
+		return &ChipPoolScene{}, nil
+	}
+	return nil, errors.New(fmt.Sprintf("no proto message for %T", v))
+}
+
+// m必须是NewProto(v)返回的类型
+func (Converter) FromProto(m proto.Message, v interface{}) error {
+	switch r := v.(type) {
+	case *abstracts.PlayerActionMsg:
+		p := m.(*PlayerActionMsg)
+		// MsgID、UserID由服务器赋值，不覆盖
+		r.GameID, r.Round, r.ActionType, r.Amount, r.Player = p.GameID, uint(p.Round), abstracts.GameAction(p.ActionType), p.Amount, uint(p.Player)
+	case *abstracts.ErrResp:
+		p := m.(*ErrResp)
+		*r = abstracts.ErrResp{ ErrCode: int(p.ErrCode), Info: p.Info }
+	case *abstracts.SuccessResp:
+		*r = abstracts.SuccessResp{ Info: m.(*SuccessResp).Info }
+	case *abstracts.TableClosedResp:
+		p := m.(*TableClosedResp)
+		*r = abstracts.TableClosedResp{ TableID: int(p.TableID), GameCancelled: p.GameCancelled }
+	case *abstracts.TableScene:
+		*r = *fromTableScene(m.(*TableScene))
+	case *abstracts.GameScene:
+		*r = *fromGameScene(m.(*GameScene))
+	case *abstracts.PlayerScene:
+		*r = *fromPlayerScene(m.(*PlayerScene))
+	case *abstracts.TournamentStartResp:
+		p := m.(*TournamentStartResp)
+		*r = abstracts.TournamentStartResp{ TournamentID: int(p.TournamentID), TableID: int(p.TableID), StartingStack: p.StartingStack }
+	case *abstracts.TournamentLevelResp:
+		p := m.(*TournamentLevelResp)
+		*r = abstracts.TournamentLevelResp{ TournamentID: int(p.TournamentID), Level: int(p.Level), Xm: p.Xm, Dm: p.Dm, Ante: p.Ante, Break: p.Break, Seconds: p.Seconds }
+	case *abstracts.TournamentResultResp:
+		p := m.(*TournamentResultResp)
+		*r = abstracts.TournamentResultResp{ TournamentID: int(p.TournamentID), Place: int(p.Place), Prize: p.Prize }
+	case *abstracts.TournamentMoveResp:
+		p := m.(*TournamentMoveResp)
+		*r = abstracts.TournamentMoveResp{ TournamentID: int(p.TournamentID), FromTableID: int(p.FromTableID), ToTableID: int(p.ToTableID) }
+	case *abstracts.TournamentLobbyResp:
+		*r = *fromTournamentLobbyResp(m.(*TournamentLobbyResp))
+	case *abstracts.TournamentTableScene:
+		*r = *fromTournamentTableScene(m.(*TournamentTableScene))
+	case *abstracts.AllInEquityResp:
+		*r = *fromAllInEquityResp(m.(*AllInEquityResp))
+	case *abstracts.PlayerEquity:
+		*r = *fromPlayerEquity(m.(*PlayerEquity))
+	case *abstracts.RunoutsResp:
+		*r = *fromRunoutsResp(m.(*RunoutsResp))
+	case *abstracts.ShowdownResp:
+		*r = *fromShowdownResp(m.(*ShowdownResp))
+	case *abstracts.ShowdownPlayer:
+		*r = *fromShowdownPlayer(m.(*ShowdownPlayer))
+	case *abstracts.PotResult:
+		*r = *fromPotResult(m.(*PotResult))
+	case *abstracts.PotWinner:
+		*r = *fromPotWinner(m.(*PotWinner))
+	case *abstracts.PokerScene:
+		*r = *fromPokerScene(m.(*PokerScene))
+	case *abstracts.ChipPoolScene:
+		*r = *fromChipPoolScene(m.(*ChipPoolScene))
+	default:
+		return errors.New(fmt.Sprintf("no proto message for %T", v))
+	}
+	return nil
+}
+
+func indirect(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		return rv.Elem().Interface()
+	}
+	return v
+}
+
+// repeated中不能有nil，nil的场景转成空消息
+
+func toPokerScene(s *abstracts.PokerScene) *PokerScene {
+	if s == nil {
+		return &PokerScene{}
+	}
+	return &PokerScene{ Whole: s.Whole, As: s.As, Up: s.Up }
+}
+
+func fromPokerScene(p *PokerScene) *abstracts.PokerScene {
+	return &abstracts.PokerScene{ Whole: p.Whole, As: p.As, Up: p.Up }
+}
+
+func toPokerScenes(ss []*abstracts.PokerScene) []*PokerScene {
+	if ss == nil {
+		return nil
+	}
+	result := make([]*PokerScene, len(ss))
+	for i, s := range ss {
+		result[i] = toPokerScene(s)
+	}
+	return result
+}
+
+func fromPokerScenes(ps []*PokerScene) []*abstracts.PokerScene {
+	if ps == nil {
+		return nil
+	}
+	result := make([]*abstracts.PokerScene, len(ps))
+	for i, p := range ps {
+		result[i] = fromPokerScene(p)
+	}
+	return result
+}
+
+func toChipPoolScene(s *abstracts.ChipPoolScene) *ChipPoolScene {
+	if s == nil {
+		return &ChipPoolScene{}
+	}
+	return &ChipPoolScene{ Chips: s.Chips }
+}
+
+func fromChipPoolScene(p *ChipPoolScene) *abstracts.ChipPoolScene {
+	return &abstracts.ChipPoolScene{ Chips: p.Chips }
+}
+
+func toChipPoolScenes(ss []*abstracts.ChipPoolScene) []*ChipPoolScene {
+	if ss == nil {
+		return nil
+	}
+	result := make([]*ChipPoolScene, len(ss))
+	for i, s := range ss {
+		result[i] = toChipPoolScene(s)
+	}
+	return result
+}
+
+func fromChipPoolScenes(ps []*ChipPoolScene) []*abstracts.ChipPoolScene {
+	if ps == nil {
+		return nil
+	}
+	result := make([]*abstracts.ChipPoolScene, len(ps))
+	for i, p := range ps {
+		result[i] = fromChipPoolScene(p)
+	}
+	return result
+}
+
+func toPlayerScene(s *abstracts.PlayerScene) *PlayerScene {
+	if s == nil {
+		return &PlayerScene{}
+	}
+	return &PlayerScene{ UserID: s.UserID, RemainChip: s.RemainChip, Pokers: toPokerScenes(s.Pokers), Status: int64(s.Status) }
+}
+
+func fromPlayerScene(p *PlayerScene) *abstracts.PlayerScene {
+	return &abstracts.PlayerScene{ UserID: p.UserID, RemainChip: p.RemainChip, Pokers: fromPokerScenes(p.Pokers), Status: int(p.Status) }
+}
+
+func toTableScene(s *abstracts.TableScene) *TableScene {
+	result := &TableScene{ CurD: int64(s.CurD), CurBet: int64(s.CurBet), CommonPokers: toPokerScenes(s.CommonPokers), ChipPools: toChipPoolScenes(s.ChipPools) }
+	for _, p := range s.Players {
+		result.Players = append(result.Players, toPlayerScene(p))
+	}
+	return result
+}
+
+func fromTableScene(p *TableScene) *abstracts.TableScene {
+	result := &abstracts.TableScene{ CurD: int(p.CurD), CurBet: int(p.CurBet), CommonPokers: fromPokerScenes(p.CommonPokers), ChipPools: fromChipPoolScenes(p.ChipPools) }
+	for _, ps := range p.Players {
+		result.Players = append(result.Players, fromPlayerScene(ps))
+	}
+	return result
+}
+
+func toGameScene(s *abstracts.GameScene) *GameScene {
+	result := &GameScene{ CurBet: s.CurBet, CommonPokers: toPokerScenes(s.CommonPokers), ChipPools: toChipPoolScenes(s.ChipPools) }
+	if s.Players != nil {
+		result.Players = map[string]*PlayerScene{}
+		for k, p := range s.Players {
+			result.Players[k] = toPlayerScene(p)
+		}
+	}
+	return result
+}
+
+func fromGameScene(p *GameScene) *abstracts.GameScene {
+	result := &abstracts.GameScene{ CurBet: p.CurBet, CommonPokers: fromPokerScenes(p.CommonPokers), ChipPools: fromChipPoolScenes(p.ChipPools) }
+	if p.Players != nil {
+		result.Players = map[string]*abstracts.PlayerScene{}
+		for k, ps := range p.Players {
+			result.Players[k] = fromPlayerScene(ps)
+		}
+	}
+	return result
+}
+
+func toTournamentTableScene(s *abstracts.TournamentTableScene) *TournamentTableScene {
+	if s == nil {
+		return &TournamentTableScene{}
+	}
+	return &TournamentTableScene{ TableID: int64(s.TableID), PlayerCount: int64(s.PlayerCount) }
+}
+
+func fromTournamentTableScene(p *TournamentTableScene) *abstracts.TournamentTableScene {
+	return &abstracts.TournamentTableScene{ TableID: int(p.TableID), PlayerCount: int(p.PlayerCount) }
+}
+
+func toTournamentLobbyResp(s *abstracts.TournamentLobbyResp) *TournamentLobbyResp {
+	result := &TournamentLobbyResp{
+		TournamentID: int64(s.TournamentID), Level: int64(s.Level), Xm: s.Xm,
+		Entrants: int64(s.Entrants), PrizePool: s.PrizePool, LateRegistration: s.LateRegistration,
+		Remain: int64(s.Remain), AvgStack: s.AvgStack, HandForHand: s.HandForHand, FinalTable: s.FinalTable,
+	}
+	for _, t := range s.Tables {
+		result.Tables = append(result.Tables, toTournamentTableScene(t))
+	}
+	return result
+}
+
+func fromTournamentLobbyResp(p *TournamentLobbyResp) *abstracts.TournamentLobbyResp {
+	result := &abstracts.TournamentLobbyResp{
+		TournamentID: int(p.TournamentID), Level: int(p.Level), Xm: p.Xm,
+		Entrants: int(p.Entrants), PrizePool: p.PrizePool, LateRegistration: p.LateRegistration,
+		Remain: int(p.Remain), AvgStack: p.AvgStack, HandForHand: p.HandForHand, FinalTable: p.FinalTable,
+	}
+	for _, t := range p.Tables {
+		result.Tables = append(result.Tables, fromTournamentTableScene(t))
+	}
+	return result
+}
+
+func toPlayerEquity(s *abstracts.PlayerEquity) *PlayerEquity {
+	if s == nil {
+		return &PlayerEquity{}
+	}
+	return &PlayerEquity{ UserID: s.UserID, Equity: s.Equity }
+}
+
+func fromPlayerEquity(p *PlayerEquity) *abstracts.PlayerEquity {
+	return &abstracts.PlayerEquity{ UserID: p.UserID, Equity: p.Equity }
+}
+
+func toAllInEquityResp(s *abstracts.AllInEquityResp) *AllInEquityResp {
+	result := &AllInEquityResp{ Round: uint32(s.Round), RunTimes: int64(s.RunTimes) }
+	for _, p := range s.Players {
+		result.Players = append(result.Players, toPlayerEquity(p))
+	}
+	return result
+}
+
+func fromAllInEquityResp(p *AllInEquityResp) *abstracts.AllInEquityResp {
+	result := &abstracts.AllInEquityResp{ Round: uint(p.Round), RunTimes: int(p.RunTimes) }
+	for _, pe := range p.Players {
+		result.Players = append(result.Players, fromPlayerEquity(pe))
+	}
+	return result
+}
+
+func toRunoutsResp(s *abstracts.RunoutsResp) *RunoutsResp {
+	result := &RunoutsResp{}
+	for _, b := range s.Boards {
+		result.Boards = append(result.Boards, &Board{ Pokers: toPokerScenes(b) })
+	}
+	return result
+}
+
+func fromRunoutsResp(p *RunoutsResp) *abstracts.RunoutsResp {
+	result := &abstracts.RunoutsResp{}
+	for _, b := range p.Boards {
+		result.Boards = append(result.Boards, fromPokerScenes(b.Pokers))
+	}
+	return result
+}
+
+func toPotWinner(s *abstracts.PotWinner) *PotWinner {
+	if s == nil {
+		return &PotWinner{}
+	}
+	return &PotWinner{ UserID: s.UserID, Amount: s.Amount, Low: s.Low }
+}
+
+func fromPotWinner(p *PotWinner) *abstracts.PotWinner {
+	return &abstracts.PotWinner{ UserID: p.UserID, Amount: p.Amount, Low: p.Low }
+}
+
+func toPotResult(s *abstracts.PotResult) *PotResult {
+	if s == nil {
+		return &PotResult{}
+	}
+	result := &PotResult{ Amount: s.Amount }
+	for _, w := range s.Winners {
+		result.Winners = append(result.Winners, toPotWinner(w))
+	}
+	return result
+}
+
+func fromPotResult(p *PotResult) *abstracts.PotResult {
+	result := &abstracts.PotResult{ Amount: p.Amount }
+	for _, w := range p.Winners {
+		result.Winners = append(result.Winners, fromPotWinner(w))
+	}
+	return result
+}
+
+func toShowdownPlayer(s *abstracts.ShowdownPlayer) *ShowdownPlayer {
+	if s == nil {
+		return &ShowdownPlayer{}
+	}
+	result := &ShowdownPlayer{
+		UserID: s.UserID, Mucked: s.Mucked, Pokers: toPokerScenes(s.Pokers), BestFive: toPokerScenes(s.BestFive),
+		HandType: int64(s.HandType), HandName: s.HandName, CnHandName: s.CnHandName, LowName: s.LowName, Won: s.Won,
+	}
+	for _, pot := range s.WonPots {
+		result.WonPots = append(result.WonPots, int64(pot))
+	}
+	for _, run := range s.Runs {
+		result.Runs = append(result.Runs, &ShowdownRun{
+			BestFive: toPokerScenes(run.BestFive), HandType: int64(run.HandType), HandName: run.HandName, CnHandName: run.CnHandName, LowName: run.LowName,
+		})
+	}
+	return result
+}
+
+func fromShowdownPlayer(p *ShowdownPlayer) *abstracts.ShowdownPlayer {
+	result := &abstracts.ShowdownPlayer{
+		UserID: p.UserID, Mucked: p.Mucked, Pokers: fromPokerScenes(p.Pokers), BestFive: fromPokerScenes(p.BestFive),
+		HandType: int(p.HandType), HandName: p.HandName, CnHandName: p.CnHandName, LowName: p.LowName, Won: p.Won,
+	}
+	for _, pot := range p.WonPots {
+		result.WonPots = append(result.WonPots, int(pot))
+	}
+	for _, run := range p.Runs {
+		result.Runs = append(result.Runs, &abstracts.ShowdownRun{
+			BestFive: fromPokerScenes(run.BestFive), HandType: int(run.HandType), HandName: run.HandName, CnHandName: run.CnHandName, LowName: run.LowName,
+		})
+	}
+	return result
+}
+
+func toShowdownResp(s *abstracts.ShowdownResp) *ShowdownResp {
+	result := &ShowdownResp{ CommonPokers: toPokerScenes(s.CommonPokers) }
+	for _, p := range s.Players {
+		result.Players = append(result.Players, toShowdownPlayer(p))
+	}
+	for _, pot := range s.Pots {
+		result.Pots = append(result.Pots, toPotResult(pot))
+	}
+	for _, b := range s.Boards {
+		result.Boards = append(result.Boards, &Board{ Pokers: toPokerScenes(b) })
+	}
+	return result
+}
+
+func fromShowdownResp(p *ShowdownResp) *abstracts.ShowdownResp {
+	result := &abstracts.ShowdownResp{ CommonPokers: fromPokerScenes(p.CommonPokers) }
+	for _, sp := range p.Players {
+		result.Players = append(result.Players, fromShowdownPlayer(sp))
+	}
+	for _, pot := range p.Pots {
+		result.Pots = append(result.Pots, fromPotResult(pot))
+	}
+	for _, b := range p.Boards {
+		result.Boards = append(result.Boards, fromPokerScenes(b.Pokers))
+	}
+	return result
+}
@@ -0,0 +1,167 @@
+package pb
+
+import (
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/common/util"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/abstracts"
+)
+
+var allMessages = []proto.Message{
+	&PlayerActionMsg{}, &ErrResp{}, &SuccessResp{}, &TableClosedResp{}, &TableScene{}, &GameScene{}, &PlayerScene{},
+	&TournamentStartResp{}, &TournamentLevelResp{}, &TournamentResultResp{}, &TournamentMoveResp{}, &TournamentLobbyResp{}, &TournamentTableScene{},
+	&AllInEquityResp{}, &PlayerEquity{}, &RunoutsResp{}, &Board{}, &ShowdownResp{}, &ShowdownPlayer{}, &ShowdownRun{}, &PotResult{}, &PotWinner{}, &PokerScene{}, &ChipPoolScene{},
+}
+
+var (
+	messageRegexp = regexp.MustCompile(`(?s)message (\w+) \{(.*?)\n\}`)
+	fieldRegexp = regexp.MustCompile(`(repeated )?(map<[^>]+>|\w+) (\w+) = (\d+);`)
+)
+
+// proto中的类型在struct tag中的编码方式
+var protoWireTypes = map[string]string{
+	"int64": "varint", "uint32": "varint", "uint64": "varint", "bool": "varint",
+	"double": "fixed64", "string": "bytes",
+}
+
+// abstracts.proto和手写的struct字段名、编号、编码方式都要一致
+func TestProtoFileMatchStructs(t *testing.T) {
+	content, err := ioutil.ReadFile("abstracts.proto")
+	assert.NoError(t, err)
+	structs := map[string]reflect.Type{}
+	for _, m := range allMessages {
+		typ := reflect.TypeOf(m).Elem()
+		structs[typ.Name()] = typ
+	}
+
+	messages := messageRegexp.FindAllStringSubmatch(string(content), -1)
+	assert.Equal(t, len(allMessages), len(messages))
+	for _, m := range messages {
+		typ := structs[m[1]]
+		if !assert.NotNil(t, typ, m[1]) {
+			continue
+		}
+		fields := fieldRegexp.FindAllStringSubmatch(m[2], -1)
+		assert.Equal(t, typ.NumField(), len(fields), m[1])
+		for i, f := range fields {
+			if i >= typ.NumField() {
+				break
+			}
+			wire, ok := protoWireTypes[f[2]]
+			if !ok {
+				// 消息或map
+				wire = "bytes"
+			}
+			label := "opt"
+			if f[1] != "" || strings.HasPrefix(f[2], "map<") {
+				label = "rep"
+			}
+			tag := strings.Split(typ.Field(i).Tag.Get("protobuf"), ",")
+			assert.Equal(t, []string{ wire, f[4], label }, tag[:3], m[1] + "." + f[3])
+			assert.Contains(t, tag, "name=" + f[3], m[1] + "." + f[3])
+		}
+	}
+}
+
+var scene = &abstracts.TableScene{
+	CurD: 2,
+	CurBet: 1,
+	Players: []*abstracts.PlayerScene{
+		{ UserID: "5b0e1a2c9d3f4e0011aa2233", RemainChip: 19850, Pokers: []*abstracts.PokerScene{ { Whole: "As" }, { Whole: "Kd" } } },
+		{ UserID: "5b0e1a2c9d3f4e0011aa2234", RemainChip: 2400, Status: abstracts.PlayerStatusAllInned, Pokers: []*abstracts.PokerScene{ {}, {} } },
+		{ UserID: "5b0e1a2c9d3f4e0011aa2235", RemainChip: 0, Status: abstracts.PlayerStatusDiscarded },
+	},
+	CommonPokers: []*abstracts.PokerScene{ { Whole: "7c" }, { Whole: "2h" }, { Whole: "Kh", As: "Kh" } },
+	ChipPools: []*abstracts.ChipPoolScene{ { Chips: 600 }, { Chips: 150 } },
+}
+
+// 所有消息编码后再解码回来都和原来一样
+func TestCodec_RoundTrip(t *testing.T) {
+	msgs := []interface{}{
+		&abstracts.PlayerActionMsg{ GameID: 12, Round: 2, ActionType: abstracts.GameActionOfDiscard, Amount: 40, Player: 3 },
+		&abstracts.ErrResp{ ErrCode: -1, Info: "no more seat" },
+		&abstracts.SuccessResp{ Info: "leave success" },
+		&abstracts.TableClosedResp{ TableID: 3, GameCancelled: true },
+		scene,
+		&abstracts.GameScene{ CurBet: "1", Players: map[string]*abstracts.PlayerScene{ "1": { UserID: "1", RemainChip: 10 } }, CommonPokers: scene.CommonPokers, ChipPools: scene.ChipPools },
+		scene.Players[0],
+		&abstracts.TournamentStartResp{ TournamentID: 1, TableID: 2, StartingStack: 1500 },
+		&abstracts.TournamentLevelResp{ TournamentID: 1, Level: 3, Xm: 25, Dm: 50, Ante: 5, Break: true, Seconds: 600 },
+		&abstracts.TournamentResultResp{ TournamentID: 1, Place: 2, Prize: 300 },
+		&abstracts.TournamentMoveResp{ TournamentID: 1, FromTableID: 3, ToTableID: 1 },
+		&abstracts.TournamentLobbyResp{
+			TournamentID: 1, Level: 4, Xm: 50, Entrants: 18, PrizePool: 1800, LateRegistration: true,
+			Remain: 12, AvgStack: 2250, HandForHand: true, FinalTable: true,
+			Tables: []*abstracts.TournamentTableScene{ { TableID: 1, PlayerCount: 6 }, { TableID: 2, PlayerCount: 6 } },
+		},
+		&abstracts.TournamentTableScene{ TableID: 1, PlayerCount: 6 },
+		&abstracts.AllInEquityResp{ Round: 2, RunTimes: 2, Players: []*abstracts.PlayerEquity{ { UserID: "1", Equity: 0.8125 }, { UserID: "2", Equity: 0.1875 } } },
+		&abstracts.PlayerEquity{ UserID: "1", Equity: 0.5 },
+		&abstracts.RunoutsResp{ Boards: [][]*abstracts.PokerScene{ scene.CommonPokers, { { Whole: "Ts" } } } },
+		&abstracts.ShowdownResp{
+			CommonPokers: scene.CommonPokers,
+			Players: []*abstracts.ShowdownPlayer{
+				{ UserID: "1", Pokers: scene.Players[0].Pokers, BestFive: scene.CommonPokers, HandType: 8, HandName: "Pair of Kings", CnHandName: "一对K", LowName: "7-5-4-2-A", Won: 300, WonPots: []int{ 0, 1 } },
+				{ UserID: "2", Mucked: true },
+			},
+			Pots: []*abstracts.PotResult{ { Amount: 300, Winners: []*abstracts.PotWinner{ { UserID: "1", Amount: 150 }, { UserID: "1", Amount: 150, Low: true } } } },
+		},
+		&abstracts.ShowdownResp{
+			CommonPokers: scene.CommonPokers,
+			Boards: [][]*abstracts.PokerScene{ scene.CommonPokers, { { Whole: "Ts" } } },
+			Players: []*abstracts.ShowdownPlayer{
+				{ UserID: "1", HandType: 8, HandName: "Pair of Kings", Won: 300, Runs: []*abstracts.ShowdownRun{
+					{ BestFive: scene.CommonPokers, HandType: 8, HandName: "Pair of Kings", CnHandName: "一对K" },
+					{ HandType: 1, HandName: "Straight flush", LowName: "7-5-4-2-A" },
+				} },
+			},
+		},
+		&abstracts.PotWinner{ UserID: "1", Amount: 150, Low: true },
+		&abstracts.PokerScene{ Whole: "Jk", As: "As", Up: true },
+		&abstracts.ChipPoolScene{ Chips: 600 },
+	}
+	codec := NewCodec()
+	for _, msg := range msgs {
+		b, err := codec.Marshal(msg)
+		if !assert.NoError(t, err, "%T", msg) {
+			continue
+		}
+		result := reflect.New(reflect.TypeOf(msg).Elem()).Interface()
+		assert.NoError(t, codec.Unmarshal(b, result), "%T", msg)
+		assert.Equal(t, msg, result)
+	}
+
+	// 值也可以编码
+	b, err := codec.Marshal(*scene)
+	assert.NoError(t, err)
+	var result abstracts.TableScene
+	assert.NoError(t, codec.Unmarshal(b, &result))
+	assert.Equal(t, *scene, result)
+
+	_, err = codec.Marshal(abstracts.CommonMsg{})
+	assert.Error(t, err)
+}
+
+// 解码PlayerActionMsg不会覆盖服务器赋值的字段
+func TestCodec_PlayerActionMsg(t *testing.T) {
+	codec := NewCodec()
+	b, err := codec.Marshal(abstracts.PlayerActionMsg{ MsgID: 1, UserID: "1", Amount: 20 })
+	assert.NoError(t, err)
+	msg := abstracts.PlayerActionMsg{ MsgID: 2, UserID: "2" }
+	assert.NoError(t, codec.Unmarshal(b, &msg))
+	assert.Equal(t, abstracts.PlayerActionMsg{ MsgID: 2, UserID: "2", Amount: 20 }, msg)
+}
+
+// 桌子场景protobuf编码后比json小很多
+func TestCodec_Size(t *testing.T) {
+	b, err := NewCodec().Marshal(scene)
+	assert.NoError(t, err)
+	jsonB := util.StringifyJsonToBytes(scene)
+	t.Logf("table scene: protobuf %v bytes, json %v bytes", len(b), len(jsonB))
+	assert.True(t, len(b) * 2 < len(jsonB))
+}
@@ -29,6 +29,35 @@ const (
 	MsgTypeSuccess = 0x21
 	// s - c
 	MsgTypeTableScene = 0x22
+	// s - c 桌子关闭（停服），桌上的人都被请离
+	MsgTypeTableClosed = 0x23
+	// s - c 所有人all in后，发剩下的牌之前广播每个人的胜率
+	MsgTypeAllInEquity = 0x24
+	// s - c 所有人all in后发了几次剩下的牌，每次的公共牌
+	MsgTypeRunouts = 0x25
+	// s - c 摊牌结果，每个人亮的牌、牌型和每个池子的输赢
+	MsgTypeShowdown = 0x26
+
+	// c - s
+	MsgTypeTournamentRegister = 0x30
+	// c - s
+	MsgTypeTournamentUnregister = 0x31
+	// s - c
+	MsgTypeTournamentStart = 0x32
+	// s - c
+	MsgTypeTournamentLevelUp = 0x33
+	// s - c
+	MsgTypeTournamentResult = 0x34
+	// s - c
+	MsgTypeTournamentMoveTable = 0x35
+	// s - c
+	MsgTypeTournamentHandForHand = 0x36
+	// s - c
+	MsgTypeTournamentFinalTable = 0x37
+	// s - c
+	MsgTypeTournamentLobby = 0x38
+	// c - s
+	MsgTypeTournamentAddOn = 0x39
 )
 
 type CommonMsg struct {
@@ -58,6 +87,12 @@ type SuccessResp struct {
 	Info string `json:"info"`
 }
 
+type TableClosedResp struct {
+	TableID int `json:"table_id"`
+	// 最后一局是否因为超时被取消，取消时所有下注都已退回
+	GameCancelled bool `json:"game_cancelled"`
+}
+
 /*
 
 当前桌子的快照
@@ -103,8 +138,160 @@ type PlayerScene struct {
 	Status int `json:"status"`
 }
 
+// 锦标赛开始
+type TournamentStartResp struct {
+	TournamentID int `json:"tournament_id"`
+	TableID int `json:"table_id"`
+	StartingStack uint64 `json:"starting_stack"`
+}
+
+// 涨盲，或者进入休息
+type TournamentLevelResp struct {
+	TournamentID int `json:"tournament_id"`
+	Level int `json:"level"`
+	Xm uint64 `json:"xm"`
+	Dm uint64 `json:"dm"`
+	Ante uint64 `json:"ante"`
+	Break bool `json:"break"`
+	// 距离下一个级别还有多少秒，为0则不按时间涨盲
+	Seconds int64 `json:"seconds"`
+}
+
+// 用户在锦标赛中的名次及奖金
+type TournamentResultResp struct {
+	TournamentID int `json:"tournament_id"`
+	Place int `json:"place"`
+	Prize uint64 `json:"prize"`
+}
+
+// 换桌，拆桌或平衡桌子人数时发给被移动的用户
+type TournamentMoveResp struct {
+	TournamentID int `json:"tournament_id"`
+	FromTableID int `json:"from_table_id"`
+	ToTableID int `json:"to_table_id"`
+}
+
+/*
+
+锦标赛大厅的快照
+1. 当前级别及盲注
+1. 剩余人数，平均筹码
+1. 奖池和各名次的奖金
+1. 每张桌子的人数
+1. 是否手对手，是否已到决赛桌，是否在休息，是否已经结束
+
+*/
+type TournamentLobbyResp struct {
+	TournamentID int `json:"tournament_id"`
+	Level int `json:"level"`
+	Xm uint64 `json:"xm"`
+	// 参赛人次，包括重入
+	Entrants int `json:"entrants"`
+	PrizePool uint64 `json:"prize_pool"`
+	// 各名次的奖金，0号位为第一名。迟到报名期间还会变
+	Prizes []uint64 `json:"prizes"`
+	LateRegistration bool `json:"late_registration"`
+	Remain int `json:"remain"`
+	AvgStack uint64 `json:"avg_stack"`
+	HandForHand bool `json:"hand_for_hand"`
+	FinalTable bool `json:"final_table"`
+	// 休息中
+	Break bool `json:"break"`
+	Finished bool `json:"finished"`
+	Tables []*TournamentTableScene `json:"tables"`
+}
+
+type TournamentTableScene struct {
+	TableID int `json:"table_id"`
+	PlayerCount int `json:"player_count"`
+}
+
+// 所有人all in后每个没弃牌的人的胜率
+type AllInEquityResp struct {
+	// 在哪一轮all in的
+	Round uint `json:"round"`
+	// 剩下的牌发几次
+	RunTimes int `json:"run_times"`
+	Players []*PlayerEquity `json:"players"`
+}
+
+type PlayerEquity struct {
+	UserID string `json:"user_id"`
+	// 0到1，平分的算一部分
+	Equity float64 `json:"equity"`
+}
+
+// 发了几次牌，每次完整的公共牌，每个池子按次数平分
+type RunoutsResp struct {
+	Boards [][]*PokerScene `json:"boards"`
+}
+
+/*
+
+摊牌结果，结算筹码前广播
+赢了筹码的人和最后一轮最后下注或加注的人亮牌，其他输家盖牌；all in后发牌到最后时所有人都亮牌
+发了多次牌时CommonPokers和玩家的牌型按第一次的公共牌算，每一次的公共牌和牌型在Boards和玩家的Runs中
+
+*/
+type ShowdownResp struct {
+	CommonPokers []*PokerScene `json:"common_pokers"`
+	// 发了多次牌时每次完整的公共牌，只发一次时为空
+	Boards [][]*PokerScene `json:"boards,omitempty"`
+	// 按座位顺序，只有没弃牌的人
+	Players []*ShowdownPlayer `json:"players"`
+	// 0号是主池
+	Pots []*PotResult `json:"pots"`
+}
+
+type ShowdownPlayer struct {
+	UserID string `json:"user_id"`
+	// 盖牌时下边牌相关的字段都为空
+	Mucked bool `json:"mucked"`
+	Pokers []*PokerScene `json:"pokers,omitempty"`
+	// 组成牌型的5张牌
+	BestFive []*PokerScene `json:"best_five,omitempty"`
+	HandType int `json:"hand_type"`
+	// 如"Full house, Kings full of Sevens"
+	HandName string `json:"hand_name,omitempty"`
+	// 如"葫芦，三条K带一对7"
+	CnHandName string `json:"cn_hand_name,omitempty"`
+	// 高低牌玩法中成了低牌时的低牌，如"8-6-4-2-A"
+	LowName string `json:"low_name,omitempty"`
+	// 所有池子一共赢了多少
+	Won uint64 `json:"won"`
+	// 赢了哪几个池子
+	WonPots []int `json:"won_pots,omitempty"`
+	// 发了多次牌时每次的牌型，和Boards一一对应
+	Runs []*ShowdownRun `json:"runs,omitempty"`
+}
+
+// 发了多次牌时用某一次的公共牌组成的牌型
+type ShowdownRun struct {
+	BestFive []*PokerScene `json:"best_five,omitempty"`
+	HandType int `json:"hand_type"`
+	HandName string `json:"hand_name,omitempty"`
+	CnHandName string `json:"cn_hand_name,omitempty"`
+	LowName string `json:"low_name,omitempty"`
+}
+
+type PotResult struct {
+	Amount uint64 `json:"amount"`
+	Winners []*PotWinner `json:"winners"`
+}
+
+type PotWinner struct {
+	UserID string `json:"user_id"`
+	Amount uint64 `json:"amount"`
+	// 高低牌玩法中赢的是低牌的一半
+	Low bool `json:"low,omitempty"`
+}
+
 type PokerScene struct {
 	Whole string `json:"whole"`
+	// 赖子当成了哪张牌，只在赖子玩法中有
+	As string `json:"as,omitempty"`
+	// 七张梭哈中是否是明牌，明牌所有人可见
+	Up bool `json:"up,omitempty"`
 }
 
 type ChipPoolScene struct {
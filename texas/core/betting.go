@@ -0,0 +1,149 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+)
+
+/*
+
+下注结构：无限注、底池限注、固定限注
+game每次收到下注时交给下注结构检查，同一个game loop可以跑任意一种
+
+*/
+type bettingStructure interface {
+	// 检查player本轮这次下amount是否合法，amount是本次新下的筹码（包括跟注的部分）
+	checkBet(g *Game, player uint, amount uint64) error
+}
+
+func newBettingStructure(level TableLevel) bettingStructure {
+	switch level.BettingType() {
+	case BettingPotLimit:
+		return &potLimitBetting{}
+	case BettingFixedLimit:
+		small, big := level.LimitBets()
+		return &fixedLimitBetting{ smallBet: small, bigBet: big, raiseCap: level.RaiseLimit() }
+	default:
+		return &noLimitBetting{}
+	}
+}
+
+// 本轮还要跟多少
+func (g *Game) toCall(player uint) uint64 {
+	return g.chipPool.maxBetAmountAt(g.curRound) - g.chipPool.playerHaveBetAt(g.curRound, player)
+}
+
+// 所有结构都要满足：不够跟注时只能all in
+func checkCall(g *Game, player uint, amount uint64) error {
+	if amount < g.toCall(player) && amount != g.players[player].RemainChip() {
+		return errors.New("bet not enough to call")
+	}
+	return nil
+}
+
+/*
+
+无限注和底池限注的最小加注：加注的部分不能少于本轮上一次下注或加注的大小，本轮还没人下注时不能少于大盲
+筹码不够时可以all in，all in不够一次完整加注时不改变最小加注
+
+*/
+func checkMinRaise(g *Game, player uint, amount uint64) error {
+	toCall := g.toCall(player)
+	if amount <= toCall || amount == g.players[player].RemainChip() {
+		return nil
+	}
+	if min := g.minRaise(); amount - toCall < min {
+		return fmt.Errorf("raise less than min raise %v", min)
+	}
+	return nil
+}
+
+func (g *Game) minRaise() uint64 {
+	if g.lastRaise > g.dmBet {
+		return g.lastRaise
+	}
+	return g.dmBet
+}
+
+// 无限注，跟注后最少加一个最小加注，最多all in
+type noLimitBetting struct {}
+
+func (b *noLimitBetting) checkBet(g *Game, player uint, amount uint64) error {
+	if err := checkCall(g, player, amount); err != nil {
+		return err
+	}
+	return checkMinRaise(g, player, amount)
+}
+
+// 底池限注，最多加注到跟注后的底池大小
+type potLimitBetting struct {}
+
+func (b *potLimitBetting) checkBet(g *Game, player uint, amount uint64) error {
+	if err := checkCall(g, player, amount); err != nil {
+		return err
+	}
+	if err := checkMinRaise(g, player, amount); err != nil {
+		return err
+	}
+	if limit := g.potLimitBet(player); amount > limit {
+		return fmt.Errorf("bet over pot limit %v", limit)
+	}
+	return nil
+}
+
+/*
+
+底池限注时本次最多下多少：先跟注，再最多加注到跟注后的底池大小
+跟注数 + (底池 + 跟注数)，筹码不够时只能all in
+
+*/
+func (g *Game) potLimitBet(player uint) uint64 {
+	toCall := g.toCall(player)
+	return toCall + g.chipPool.potSize() + toCall
+}
+
+/*
+
+固定限注，前两轮每次加注小注，后两轮每次加注大注（梭哈是第三、四街小注，后三街大注）
+每轮（第一轮算上大盲，不算梭哈的bring-in）最多下注加加注raiseCap次，到了之后只能跟注
+筹码不够一次加注时可以all in
+
+*/
+type fixedLimitBetting struct {
+	smallBet uint64
+	bigBet uint64
+	raiseCap int
+}
+
+func (b *fixedLimitBetting) betSize(round uint) uint64 {
+	if round <= 2 {
+		return b.smallBet
+	}
+	return b.bigBet
+}
+
+func (b *fixedLimitBetting) checkBet(g *Game, player uint, amount uint64) error {
+	if err := checkCall(g, player, amount); err != nil {
+		return err
+	}
+	toCall := g.toCall(player)
+	if amount <= toCall {
+		return nil
+	}
+	if g.raiseCount >= b.raiseCap {
+		return fmt.Errorf("raise capped at %v", b.raiseCap)
+	}
+	raiseTo := toCall + b.betSize(g.curRound)
+	// 梭哈第三街只有bring-in时，加注是补齐到一整注
+	if g.raiseCount == 0 && g.chipPool.maxBetAmountAt(g.curRound) < b.betSize(g.curRound) {
+		raiseTo = b.betSize(g.curRound) - g.chipPool.playerHaveBetAt(g.curRound, player)
+	}
+	if amount == raiseTo {
+		return nil
+	}
+	// 筹码不够加一整注，all in
+	if amount < raiseTo && amount == g.players[player].RemainChip() {
+		return nil
+	}
+	return fmt.Errorf("fixed limit bet must be %v", raiseTo)
+}
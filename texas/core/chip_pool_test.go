@@ -392,4 +392,44 @@ func TestChipPool12(t *testing.T) {
 
 	assert.Equal(t, 1000 * 5, int(tp.pool.totalChip()))
 	assert.Nil(t, tp.pool.nextPool)
-}
\ No newline at end of file
+}
+// 发两次牌，每个池子平分成两份，每份按那次的排名分
+func TestChipPool_FinalizeRuns(t *testing.T) {
+	tp := newTermChipPool()
+	tp.bet(1, 0, 1000, true)
+	tp.bet(1, 1, 2001, true)
+	tp.bet(1, 2, 2001, true)
+	// 主池3000，边池2002
+	r := tp.finalizeRuns([][][]uint{ { {0}, {1}, {2} }, { {2}, {1}, {0} } })
+	assert.Equal(t, 1500, int(r[0]))
+	assert.Equal(t, 1001, int(r[1]))
+	assert.Equal(t, 1500 + 1001, int(r[2]))
+	// 发三次，边池除不尽的算在第一次
+	r = tp.finalizeRuns([][][]uint{ { {1}, {0, 2} }, { {2}, {0, 1} }, { {0}, {2}, {1} } })
+	assert.Equal(t, 1000, int(r[0]))
+	assert.Equal(t, 1000 + 668, int(r[1]))
+	assert.Equal(t, 1000 + 667 * 2, int(r[2]))
+}
+
+// 高低牌平分，除不尽的给高牌，低牌两人平分时各拿四分之一，没人成低牌时都给高牌
+func TestChipPool_FinalizeHiLo(t *testing.T) {
+	tp := newTermChipPool()
+	tp.bet(1, 0, 1000, true)
+	tp.bet(1, 1, 2001, true)
+	tp.bet(1, 2, 2001, true)
+	// 主池3000，边池2002
+	high, low := tp.finalizeRunsPots([][][]uint{ { {0}, {1}, {2} } }, [][][]uint{ { {1, 2} } })
+	assert.Equal(t, []map[uint]uint64{ { 0: 1500 }, { 1: 1001 } }, high)
+	assert.Equal(t, []map[uint]uint64{ { 1: 750, 2: 750 }, { 1: 500, 2: 500 } }, low)
+
+	// 高牌赢家也平分低牌
+	high, low = tp.finalizeRunsPots([][][]uint{ { {0}, {1}, {2} } }, [][][]uint{ { {0, 1} } })
+	assert.Equal(t, []map[uint]uint64{ { 0: 1500 }, { 1: 1001 } }, high)
+	assert.Equal(t, []map[uint]uint64{ { 0: 750, 1: 750 }, { 1: 1001 } }, low)
+
+	// 没人成低牌
+	high, low = tp.finalizeRunsPots([][][]uint{ { {2}, {0, 1} } }, [][][]uint{ {} })
+	assert.Equal(t, []map[uint]uint64{ { 2: 3000 }, { 2: 2002 } }, high)
+	assert.Equal(t, []map[uint]uint64{ {}, {} }, low)
+	assert.Equal(t, tp.finalizePots([][]uint{ {2}, {0, 1} }), high)
+}
@@ -0,0 +1,431 @@
+package equity
+
+import (
+	"errors"
+	"math/rand"
+	"runtime"
+	"sort"
+	"time"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/core/hand_processor"
+)
+
+/*
+
+胜率计算：给每个玩家的手牌（或范围）、已有的公共牌和死牌，算每个人赢、平、输的百分比
+1. 枚举次数（范围组合数 * 剩余公共牌组合数）不超过MaxEnumerations时精确枚举
+1. 否则按Samples次随机抽样
+两种方式都按CPU核数分组并发计算，每组算完从自己的chan返回结果
+
+*/
+
+var coreNum = runtime.NumCPU()
+
+const (
+	DefaultMaxEnumerations = 2000000
+	DefaultSamples = 200000
+	// 抽样时一次抽到的组合互相冲突就重抽，连续这么多次都冲突认为范围之间没有不冲突的组合
+	maxRejects = 10000
+)
+
+type Card = hand_processor.Card
+
+// 一手两张的起手牌
+type Combo [2]Card
+
+func (c Combo) String() string {
+	return c[0].String() + c[1].String()
+}
+
+func (c Combo) mask() uint64 {
+	return 1 << c[0] | 1 << c[1]
+}
+
+// 范围中的一种起手牌，Weight是相对的权重
+type WeightedCombo struct {
+	Combo Combo
+	Weight float64
+}
+
+// 一个玩家可能的起手牌
+type Range []WeightedCombo
+
+// 确定的两张手牌，如"AsKd"
+func HandRange(handStr string) (Range, error) {
+	cards, err := hand_processor.CardsOf(handStr)
+	if err != nil {
+		return nil, err
+	}
+	if len(cards) != 2 {
+		return nil, errors.New("手牌必须是两张")
+	}
+	return Range{ { Combo: newCombo(cards[0], cards[1]), Weight: 1 } }, nil
+}
+
+// 去掉和已知的牌冲突的组合
+func (r Range) without(used uint64) (result Range) {
+	for _, c := range r {
+		if c.Combo.mask() & used == 0 && c.Weight > 0 {
+			result = append(result, c)
+		}
+	}
+	return
+}
+
+type Request struct {
+	Players []Range
+	// 0到5张
+	Board []Card
+	// 已知不在牌堆里的牌，如弃掉的牌
+	Dead []Card
+	// 为0时用DefaultMaxEnumerations
+	MaxEnumerations int
+	// 为0时用DefaultSamples
+	Samples int
+	// 为0时用当前时间
+	Seed int64
+}
+
+// 都是0到100的百分比，平分的池子按人数算进Equity
+type PlayerResult struct {
+	Win float64 `json:"win"`
+	Tie float64 `json:"tie"`
+	Loss float64 `json:"loss"`
+	Equity float64 `json:"equity"`
+}
+
+type Result struct {
+	Players []*PlayerResult `json:"players"`
+	// 是否精确枚举
+	Exact bool `json:"exact"`
+	// 一共比了多少次牌
+	Trials int `json:"trials"`
+}
+
+func Calculate(req *Request) (*Result, error) {
+	n := len(req.Players)
+	if n < 2 {
+		return nil, errors.New("至少要两个玩家")
+	}
+	if len(req.Board) > 5 {
+		return nil, errors.New("公共牌最多5张")
+	}
+	var used uint64
+	for _, c := range append(append([]Card{}, req.Board...), req.Dead...) {
+		if c >= 52 {
+			return nil, errors.New("不合法的牌")
+		}
+		if used & (1 << c) != 0 {
+			return nil, errors.New("公共牌和死牌中出现了两张一样的牌")
+		}
+		used |= 1 << c
+	}
+	ranges := make([]Range, n)
+	for i, r := range req.Players {
+		ranges[i] = r.without(used)
+		if len(ranges[i]) == 0 {
+			return nil, errors.New("玩家的范围去掉已知的牌后为空")
+		}
+	}
+	need := 5 - len(req.Board)
+	deckSize := 52 - bitCount(used) - 2 * n
+	if deckSize < need {
+		return nil, errors.New("剩下的牌不够发公共牌")
+	}
+
+	maxEnumerations := req.MaxEnumerations
+	if maxEnumerations == 0 {
+		maxEnumerations = DefaultMaxEnumerations
+	}
+	var t *tally
+	var err error
+	exact := enumerations(ranges, deckSize, need) <= float64(maxEnumerations)
+	if exact {
+		t, err = enumerate(ranges, req.Board, used, need)
+	} else {
+		samples := req.Samples
+		if samples == 0 {
+			samples = DefaultSamples
+		}
+		seed := req.Seed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		t, err = sample(ranges, req.Board, used, need, samples, seed)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t.result(exact), nil
+}
+
+// 精确枚举要比多少次牌，可能很大所以用float64
+func enumerations(ranges []Range, deckSize int, need int) float64 {
+	result := 1.0
+	for _, r := range ranges {
+		result *= float64(len(r))
+	}
+	for i := 0; i < need; i++ {
+		result = result * float64(deckSize - i) / float64(i + 1)
+	}
+	return result
+}
+
+/*
+
+各玩家的累计结果，都按权重累加
+
+*/
+type tally struct {
+	win []float64
+	tie []float64
+	equity []float64
+	total float64
+	trials int
+	err error
+	// 5张公共牌+2张手牌
+	cards [7]Card
+	ranks []hand_processor.HandRank
+}
+
+func newTally(n int) *tally {
+	return &tally{
+		win: make([]float64, n),
+		tie: make([]float64, n),
+		equity: make([]float64, n),
+		ranks: make([]hand_processor.HandRank, n),
+	}
+}
+
+// 公共牌已经放在cards的前5张
+func (t *tally) showdown(holes []Combo, weight float64) {
+	var best hand_processor.HandRank
+	winners := 0
+	for i, h := range holes {
+		t.cards[5], t.cards[6] = h[0], h[1]
+		t.ranks[i] = hand_processor.EvaluateCards(t.cards[:])
+		if t.ranks[i] > best {
+			best, winners = t.ranks[i], 1
+		} else if t.ranks[i] == best {
+			winners++
+		}
+	}
+	for i := range holes {
+		if t.ranks[i] != best {
+			continue
+		}
+		if winners == 1 {
+			t.win[i] += weight
+		} else {
+			t.tie[i] += weight
+		}
+		t.equity[i] += weight / float64(winners)
+	}
+	t.total += weight
+	t.trials++
+}
+
+func (t *tally) add(other *tally) {
+	if other.err != nil {
+		t.err = other.err
+	}
+	for i := range t.win {
+		t.win[i] += other.win[i]
+		t.tie[i] += other.tie[i]
+		t.equity[i] += other.equity[i]
+	}
+	t.total += other.total
+	t.trials += other.trials
+}
+
+func (t *tally) result(exact bool) *Result {
+	result := &Result{ Exact: exact, Trials: t.trials }
+	for i := range t.win {
+		p := &PlayerResult{
+			Win: t.win[i] * 100 / t.total,
+			Tie: t.tie[i] * 100 / t.total,
+			Equity: t.equity[i] * 100 / t.total,
+		}
+		p.Loss = 100 - p.Win - p.Tie
+		if p.Loss < 0 {
+			p.Loss = 0
+		}
+		result.Players = append(result.Players, p)
+	}
+	return result
+}
+
+// 把jobs分成最多coreNum组并发跑，每组的结果从自己的chan返回后合并
+func runByChan(n int, jobs int, run func(t *tally, job int)) *tally {
+	workers := coreNum
+	if workers > jobs {
+		workers = jobs
+	}
+	chans := []chan *tally{}
+	for w := 0; w < workers; w++ {
+		c := make(chan *tally)
+		chans = append(chans, c)
+		go func(w int, c chan *tally) {
+			t := newTally(n)
+			// 交错分配，前边的任务可能更重
+			for job := w; job < jobs; job += workers {
+				run(t, job)
+			}
+			c <- t
+		}(w, c)
+	}
+	total := newTally(n)
+	for _, c := range chans {
+		total.add(<- c)
+	}
+	return total
+}
+
+// 一种每个玩家手牌都不冲突的分配
+type assignment struct {
+	holes []Combo
+	weight float64
+	used uint64
+}
+
+func enumerate(ranges []Range, board []Card, used uint64, need int) (*tally, error) {
+	var assignments []*assignment
+	holes := make([]Combo, len(ranges))
+	var assign func(i int, used uint64, weight float64)
+	assign = func(i int, used uint64, weight float64) {
+		if i == len(ranges) {
+			assignments = append(assignments, &assignment{ append([]Combo{}, holes...), weight, used })
+			return
+		}
+		for _, c := range ranges[i] {
+			if c.Combo.mask() & used != 0 {
+				continue
+			}
+			holes[i] = c.Combo
+			assign(i + 1, used | c.Combo.mask(), weight * c.Weight)
+		}
+	}
+	assign(0, used, 1)
+	if len(assignments) == 0 {
+		return nil, errors.New("玩家的范围之间没有不冲突的组合")
+	}
+
+	// 每种分配按剩余公共牌的第一张拆成多个任务
+	firsts := 1
+	if need > 0 {
+		firsts = 52 - bitCount(assignments[0].used) - need + 1
+	}
+	t := runByChan(len(ranges), len(assignments) * firsts, func(t *tally, job int) {
+		a := assignments[job / firsts]
+		copy(t.cards[:], board)
+		if need == 0 {
+			t.showdown(a.holes, a.weight)
+			return
+		}
+		deck := deckWithout(a.used)
+		first := job % firsts
+		t.cards[len(board)] = deck[first]
+		forEachCombination(deck[first + 1:], need - 1, t.cards[len(board) + 1:5], func() {
+			t.showdown(a.holes, a.weight)
+		})
+	})
+	return t, nil
+}
+
+func sample(ranges []Range, board []Card, used uint64, need int, samples int, seed int64) (*tally, error) {
+	// 按权重抽组合用的累计权重
+	cumulative := make([][]float64, len(ranges))
+	for i, r := range ranges {
+		sum := 0.0
+		for _, c := range r {
+			sum += c.Weight
+			cumulative[i] = append(cumulative[i], sum)
+		}
+	}
+	workers := coreNum
+	if workers > samples {
+		workers = samples
+	}
+	t := runByChan(len(ranges), workers, func(t *tally, w int) {
+		rnd := rand.New(rand.NewSource(seed + int64(w)))
+		count := samples / workers
+		if w == workers - 1 {
+			count += samples % workers
+		}
+		holes := make([]Combo, len(ranges))
+		copy(t.cards[:], board)
+		for n := 0; n < count; n++ {
+			picked, ok := pickHoles(ranges, cumulative, used, holes, rnd)
+			if !ok {
+				t.err = errors.New("玩家的范围之间没有不冲突的组合")
+				return
+			}
+			deck := deckWithout(picked)
+			// 只洗前need张
+			for i := 0; i < need; i++ {
+				j := i + rnd.Intn(len(deck) - i)
+				deck[i], deck[j] = deck[j], deck[i]
+				t.cards[len(board) + i] = deck[i]
+			}
+			t.showdown(holes, 1)
+		}
+	})
+	if t.err != nil {
+		return nil, t.err
+	}
+	return t, nil
+}
+
+// 按权重给每个玩家抽一手牌，有冲突就整体重抽，返回用掉的牌
+func pickHoles(ranges []Range, cumulative [][]float64, used uint64, holes []Combo, rnd *rand.Rand) (uint64, bool) {
+	for n := 0; n < maxRejects; n++ {
+		picked := used
+		ok := true
+		for i, r := range ranges {
+			sum := cumulative[i][len(cumulative[i]) - 1]
+			idx := sort.SearchFloat64s(cumulative[i], rnd.Float64() * sum)
+			if idx >= len(r) {
+				idx = len(r) - 1
+			}
+			c := r[idx].Combo
+			if c.mask() & picked != 0 {
+				ok = false
+				break
+			}
+			holes[i] = c
+			picked |= c.mask()
+		}
+		if ok {
+			return picked, true
+		}
+	}
+	return 0, false
+}
+
+func deckWithout(used uint64) []Card {
+	deck := make([]Card, 0, 52)
+	for c := Card(0); c < 52; c++ {
+		if used & (1 << c) == 0 {
+			deck = append(deck, c)
+		}
+	}
+	return deck
+}
+
+// 从deck中取len(out)张的每种组合放到out中
+func forEachCombination(deck []Card, count int, out []Card, cb func()) {
+	if count == 0 {
+		cb()
+		return
+	}
+	for i := 0; i <= len(deck) - count; i++ {
+		out[len(out) - count] = deck[i]
+		forEachCombination(deck[i + 1:], count - 1, out, cb)
+	}
+}
+
+func bitCount(mask uint64) (count int) {
+	for ; mask != 0; mask &= mask - 1 {
+		count++
+	}
+	return
+}
@@ -0,0 +1,129 @@
+package equity
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/core/hand_processor"
+)
+
+func mustRange(t *testing.T, handStr string) Range {
+	r, err := HandRange(handStr)
+	assert.NoError(t, err)
+	return r
+}
+
+func mustCards(t *testing.T, str string) []Card {
+	cards, err := hand_processor.CardsOf(str)
+	assert.NoError(t, err)
+	return cards
+}
+
+func TestCalculate_River(t *testing.T) {
+	result, err := Calculate(&Request{
+		Players: []Range{ mustRange(t, "Ts3c"), mustRange(t, "AhAd") },
+		Board: mustCards(t, "AsKsQsJs2d"),
+	})
+	assert.NoError(t, err)
+	assert.True(t, result.Exact)
+	assert.Equal(t, 1, result.Trials)
+	assert.Equal(t, &PlayerResult{ Win: 100, Equity: 100 }, result.Players[0])
+	assert.Equal(t, &PlayerResult{ Loss: 100 }, result.Players[1])
+
+	// 公共牌是皇家同花顺，都平分
+	result, err = Calculate(&Request{
+		Players: []Range{ mustRange(t, "2c3c"), mustRange(t, "AhAd"), mustRange(t, "7h8h") },
+		Board: mustCards(t, "AsKsQsJsTs"),
+	})
+	assert.NoError(t, err)
+	for _, p := range result.Players {
+		assert.Equal(t, 100.0, p.Tie)
+		assert.InDelta(t, 100.0 / 3, p.Equity, 1e-9)
+	}
+}
+
+func TestCalculate_Turn(t *testing.T) {
+	// 河牌44张，剩下7张红桃（2h、3h让对手葫芦）和3张4（A2345）能赢
+	result, err := Calculate(&Request{
+		Players: []Range{ mustRange(t, "Ah5h"), mustRange(t, "KsKd") },
+		Board: mustCards(t, "Kh9h2c3s"),
+	})
+	assert.NoError(t, err)
+	assert.True(t, result.Exact)
+	assert.Equal(t, 44, result.Trials)
+	assert.InDelta(t, 1000.0 / 44, result.Players[0].Equity, 1e-9)
+	assert.InDelta(t, 100, result.Players[0].Equity + result.Players[1].Equity, 1e-9)
+}
+
+func TestCalculate_Preflop(t *testing.T) {
+	// 枚举全部1712304种公共牌
+	result, err := Calculate(&Request{
+		Players: []Range{ mustRange(t, "AsAh"), mustRange(t, "KsKh") },
+	})
+	assert.NoError(t, err)
+	assert.True(t, result.Exact)
+	assert.Equal(t, 1712304, result.Trials)
+	assert.InDelta(t, 82.6, result.Players[0].Equity, 0.5)
+	for _, p := range result.Players {
+		assert.InDelta(t, 100, p.Win + p.Tie + p.Loss, 1e-9)
+	}
+
+	// 抽样和精确的结果差不多
+	sampled, err := Calculate(&Request{
+		Players: []Range{ mustRange(t, "AsAh"), mustRange(t, "KsKh") },
+		MaxEnumerations: 1000,
+		Samples: 100000,
+		Seed: 1,
+	})
+	assert.NoError(t, err)
+	assert.False(t, sampled.Exact)
+	assert.Equal(t, 100000, sampled.Trials)
+	assert.InDelta(t, result.Players[0].Equity, sampled.Players[0].Equity, 1)
+}
+
+func TestCalculate_Range(t *testing.T) {
+	// 对手是所有的KK，有一张K在死牌里
+	var kings Range
+	for _, str := range []string{ "KsKh", "KsKd", "KsKc", "KhKd", "KhKc", "KdKc" } {
+		kings = append(kings, mustRange(t, str)...)
+	}
+	result, err := Calculate(&Request{
+		Players: []Range{ mustRange(t, "AsAh"), kings },
+		Board: mustCards(t, "2c7d9h"),
+		Dead: mustCards(t, "Kc"),
+	})
+	assert.NoError(t, err)
+	assert.True(t, result.Exact)
+	// 去掉含Kc的只剩3种KK，每种C(44, 2)种公共牌
+	assert.Equal(t, 3 * 44 * 43 / 2, result.Trials)
+
+	// 权重为0的组合不算
+	kings[0].Weight = 0
+	_, err = Calculate(&Request{ Players: []Range{ mustRange(t, "AsAh"), kings[:1] } })
+	assert.Error(t, err)
+}
+
+func TestCalculate_Errors(t *testing.T) {
+	_, err := Calculate(&Request{ Players: []Range{ mustRange(t, "AsAh") } })
+	assert.Error(t, err)
+	_, err = Calculate(&Request{
+		Players: []Range{ mustRange(t, "AsAh"), mustRange(t, "KsKh") },
+		Board: mustCards(t, "As2c3d"),
+	})
+	assert.Error(t, err)
+	_, err = Calculate(&Request{
+		Players: []Range{ mustRange(t, "AsAh"), mustRange(t, "AsKh") },
+		MaxEnumerations: 1,
+		Samples: 10,
+	})
+	assert.Error(t, err)
+	_, err = HandRange("AsKsQs")
+	assert.Error(t, err)
+}
+
+func BenchmarkCalculate_Preflop(b *testing.B) {
+	aces, _ := HandRange("AsAh")
+	kings, _ := HandRange("KsKh")
+	for i := 0; i < b.N; i++ {
+		Calculate(&Request{ Players: []Range{ aces, kings }, MaxEnumerations: 1, Samples: 10000, Seed: 1 })
+	}
+}
@@ -0,0 +1,346 @@
+package equity
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+
+范围的写法，逗号分隔：
+1. "AA"、"AKs"、"AKo"、"AK"（同花和不同花都要）
+1. "TT+"：TT到AA，"ATs+"：ATs到AKs
+1. "22-66"、"A2s-A5s"：两头都包括
+1. "AsKd"：一手具体的牌
+1. 后边加":0.5"表示权重，不加为1，同一手牌后写的覆盖先写的
+
+13x13的表格第0行第0列是AA，对角线是对子，右上是同花，左下是不同花
+
+*/
+
+const ranksStr = "23456789TJQKA"
+
+// 大的牌在前，同一手牌只有一种写法
+func newCombo(a Card, b Card) Combo {
+	if a < b {
+		a, b = b, a
+	}
+	return Combo{ a, b }
+}
+
+// 13x13表格中的一格，如AKs
+type handClass struct {
+	high int
+	low int
+	suited bool
+}
+
+func (h handClass) pair() bool {
+	return h.high == h.low
+}
+
+func (h handClass) String() string {
+	result := ranksStr[h.high:h.high + 1] + ranksStr[h.low:h.low + 1]
+	if h.pair() {
+		return result
+	}
+	if h.suited {
+		return result + "s"
+	}
+	return result + "o"
+}
+
+func (h handClass) combos() (result []Combo) {
+	for s1 := 0; s1 < 4; s1++ {
+		for s2 := 0; s2 < 4; s2++ {
+			if h.pair() && s2 <= s1 || !h.pair() && (s1 == s2) != h.suited {
+				continue
+			}
+			result = append(result, newCombo(Card(h.high * 4 + s1), Card(h.low * 4 + s2)))
+		}
+	}
+	return
+}
+
+// 第row行第col列
+func (h handClass) cell() (row int, col int) {
+	if h.suited || h.pair() {
+		return 12 - h.high, 12 - h.low
+	}
+	return 12 - h.low, 12 - h.high
+}
+
+func classOfCell(row int, col int) handClass {
+	if row <= col {
+		return handClass{ 12 - row, 12 - col, row < col }
+	}
+	return handClass{ 12 - col, 12 - row, false }
+}
+
+func classOf(c Combo) handClass {
+	return handClass{ c[0].Rank(), c[1].Rank(), c[0].Suit() == c[1].Suit() }
+}
+
+func ParseRange(str string) (Range, error) {
+	weights := map[Combo]float64{}
+	var order []Combo
+	for _, token := range strings.Split(str, ",") {
+		token = strings.TrimSpace(token)
+		if token == "" {
+			continue
+		}
+		weight := 1.0
+		if i := strings.Index(token, ":"); i >= 0 {
+			w, err := strconv.ParseFloat(token[i + 1:], 64)
+			if err != nil || w < 0 {
+				return nil, errors.New("不合法的权重: " + token)
+			}
+			token, weight = token[:i], w
+		}
+		combos, err := parseToken(token)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range combos {
+			if _, ok := weights[c]; !ok {
+				order = append(order, c)
+			}
+			weights[c] = weight
+		}
+	}
+	var result Range
+	for _, c := range order {
+		if weights[c] > 0 {
+			result = append(result, WeightedCombo{ c, weights[c] })
+		}
+	}
+	return result, nil
+}
+
+func parseToken(token string) ([]Combo, error) {
+	if r, err := HandRange(token); err == nil {
+		return []Combo{ r[0].Combo }, nil
+	}
+	var classes []handClass
+	if i := strings.Index(token, "-"); i >= 0 {
+		from, err := parseClass(token[:i])
+		if err != nil {
+			return nil, err
+		}
+		to, err := parseClass(token[i + 1:])
+		if err != nil {
+			return nil, err
+		}
+		if len(from) != len(to) {
+			return nil, errors.New("不合法的范围: " + token)
+		}
+		for n := range from {
+			c, err := classesBetween(from[n], to[n])
+			if err != nil {
+				return nil, errors.New("不合法的范围: " + token)
+			}
+			classes = append(classes, c...)
+		}
+	} else if strings.HasSuffix(token, "+") {
+		from, err := parseClass(token[:len(token) - 1])
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range from {
+			to := handClass{ 12, 12, false }
+			if !h.pair() {
+				to = handClass{ h.high, h.high - 1, h.suited }
+			}
+			c, _ := classesBetween(h, to)
+			classes = append(classes, c...)
+		}
+	} else {
+		var err error
+		if classes, err = parseClass(token); err != nil {
+			return nil, err
+		}
+	}
+	var result []Combo
+	for _, h := range classes {
+		result = append(result, h.combos()...)
+	}
+	return result, nil
+}
+
+// "AK"同花和不同花两种，其他都是一种
+func parseClass(str string) ([]handClass, error) {
+	if len(str) != 2 && len(str) != 3 {
+		return nil, errors.New("不合法的手牌: " + str)
+	}
+	high := strings.Index(ranksStr, str[0:1])
+	low := strings.Index(ranksStr, str[1:2])
+	if high < 0 || low < 0 {
+		return nil, errors.New("不合法的手牌: " + str)
+	}
+	if high < low {
+		high, low = low, high
+	}
+	if high == low {
+		if len(str) == 3 {
+			return nil, errors.New("对子不能分同花: " + str)
+		}
+		return []handClass{ { high, low, false } }, nil
+	}
+	if len(str) == 2 {
+		return []handClass{ { high, low, true }, { high, low, false } }, nil
+	}
+	switch str[2] {
+	case 's':
+		return []handClass{ { high, low, true } }, nil
+	case 'o':
+		return []handClass{ { high, low, false } }, nil
+	}
+	return nil, errors.New("不合法的手牌: " + str)
+}
+
+// 两个对子之间，或大牌一样、同花一样的两手牌之间
+func classesBetween(from handClass, to handClass) ([]handClass, error) {
+	if from.pair() != to.pair() || !from.pair() && (from.high != to.high || from.suited != to.suited) {
+		return nil, errors.New("不合法的范围")
+	}
+	lo, hi := from.low, to.low
+	if lo > hi {
+		lo, hi = hi, lo
+	}
+	var result []handClass
+	for r := lo; r <= hi; r++ {
+		if from.pair() {
+			result = append(result, handClass{ r, r, false })
+		} else {
+			result = append(result, handClass{ from.high, r, from.suited })
+		}
+	}
+	return result, nil
+}
+
+// 去掉和公共牌、死牌冲突的组合
+func (r Range) RemoveCards(cards []Card) Range {
+	var used uint64
+	for _, c := range cards {
+		used |= 1 << c
+	}
+	return r.without(used)
+}
+
+// 每格中的组合按权重算占了多少，1为全部都在
+func (r Range) Grid() (grid [13][13]float64) {
+	for _, c := range r {
+		h := classOf(c.Combo)
+		row, col := h.cell()
+		grid[row][col] += c.Weight / float64(len(h.combos()))
+	}
+	return
+}
+
+// 表格中每格的名字，如"AKs"
+func GridLabel(row int, col int) string {
+	return classOfCell(row, col).String()
+}
+
+/*
+
+转回简短的写法：一格中的组合都在且权重一样时写成一格，
+连着的格子权重一样时合并成"TT+"、"ATs+"、"22-66"、"A2s-A5s"，剩下的写成具体的牌
+
+*/
+func (r Range) String() string {
+	weights := map[Combo]float64{}
+	for _, c := range r {
+		weights[newCombo(c.Combo[0], c.Combo[1])] = c.Weight
+	}
+	// 每格都在时的权重，不是都在或权重不一样为0
+	full := map[handClass]float64{}
+	for row := 0; row < 13; row++ {
+		for col := 0; col < 13; col++ {
+			h := classOfCell(row, col)
+			w := -1.0
+			for _, c := range h.combos() {
+				if cw, ok := weights[c]; !ok || w >= 0 && cw != w {
+					w = 0
+					break
+				} else {
+					w = cw
+				}
+			}
+			if w > 0 {
+				full[h] = w
+			}
+		}
+	}
+
+	var tokens []string
+	// 一串大牌一样、同花一样的格子，kicker从大到小
+	run := func(classes []handClass, top int) {
+		for i := 0; i < len(classes); {
+			w := full[classes[i]]
+			if w == 0 {
+				i++
+				continue
+			}
+			j := i
+			for j + 1 < len(classes) && full[classes[j + 1]] == w {
+				j++
+			}
+			first, last := classes[i], classes[j]
+			var token string
+			switch {
+			case i == j:
+				token = first.String()
+			case first.low == top:
+				token = last.String() + "+"
+			default:
+				token = last.String() + "-" + first.String()
+			}
+			tokens = append(tokens, withWeight(token, w))
+			for _, h := range classes[i:j + 1] {
+				for _, c := range h.combos() {
+					delete(weights, c)
+				}
+			}
+			i = j + 1
+		}
+	}
+	var pairs []handClass
+	for rank := 12; rank >= 0; rank-- {
+		pairs = append(pairs, handClass{ rank, rank, false })
+	}
+	run(pairs, 12)
+	for _, suited := range []bool{ true, false } {
+		for high := 12; high >= 1; high-- {
+			var classes []handClass
+			for low := high - 1; low >= 0; low-- {
+				classes = append(classes, handClass{ high, low, suited })
+			}
+			run(classes, high - 1)
+		}
+	}
+
+	var rest []Combo
+	for c := range weights {
+		rest = append(rest, c)
+	}
+	sort.Slice(rest, func(i, j int) bool {
+		if rest[i][0] != rest[j][0] {
+			return rest[i][0] > rest[j][0]
+		}
+		return rest[i][1] > rest[j][1]
+	})
+	for _, c := range rest {
+		tokens = append(tokens, withWeight(c.String(), weights[c]))
+	}
+	return strings.Join(tokens, ", ")
+}
+
+func withWeight(token string, weight float64) string {
+	if weight == 1 {
+		return token
+	}
+	return token + ":" + strconv.FormatFloat(weight, 'g', -1, 64)
+}
@@ -0,0 +1,108 @@
+package equity
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func mustParse(t *testing.T, str string) Range {
+	r, err := ParseRange(str)
+	assert.NoError(t, err)
+	return r
+}
+
+func TestParseRange(t *testing.T) {
+	assert.Len(t, mustParse(t, "AA"), 6)
+	assert.Len(t, mustParse(t, "AKs"), 4)
+	assert.Len(t, mustParse(t, "AKo"), 12)
+	assert.Len(t, mustParse(t, "AK"), 16)
+	assert.Len(t, mustParse(t, "TT+"), 5 * 6)
+	assert.Len(t, mustParse(t, "22-66"), 5 * 6)
+	assert.Len(t, mustParse(t, "66-22"), 5 * 6)
+	assert.Len(t, mustParse(t, "A2s-A5s"), 4 * 4)
+	assert.Len(t, mustParse(t, "ATs+"), 4 * 4)
+	assert.Len(t, mustParse(t, "KQo, AsKd"), 12 + 1)
+	assert.Len(t, mustParse(t, "AKs, TT+, A2s-A5s, KQo, 22-66"), 4 + 30 + 16 + 12 + 30)
+	// 重复的只算一次
+	assert.Len(t, mustParse(t, "AKs, AK, AsKs"), 16)
+
+	r := mustParse(t, "AsKd")
+	assert.Equal(t, "AsKd", r[0].Combo.String())
+	// 权重，后写的覆盖先写的，权重为0的去掉
+	r = mustParse(t, "AA:0.5, AsAh:0.25, KK, KsKh:0")
+	assert.Len(t, r, 11)
+	for _, c := range r {
+		switch c.Combo {
+		case mustParse(t, "AsAh")[0].Combo:
+			assert.Equal(t, 0.25, c.Weight)
+		case mustParse(t, "KsKh")[0].Combo:
+			t.Error("weight 0 should be removed")
+		default:
+			if classOf(c.Combo).high == 12 {
+				assert.Equal(t, 0.5, c.Weight)
+			} else {
+				assert.Equal(t, 1.0, c.Weight)
+			}
+		}
+	}
+
+	for _, str := range []string{ "AKx", "A", "AAs", "A2s-K5s", "AKs-AKo", "22-AKs", "AK:x", "AK:-1", "AsAs" } {
+		_, err := ParseRange(str)
+		assert.Error(t, err, str)
+	}
+}
+
+func TestRange_RemoveCards(t *testing.T) {
+	r := mustParse(t, "AA, AKs").RemoveCards(mustCards(t, "As2c"))
+	// AA剩3种，AKs剩3种，都不全了只能写具体的牌
+	assert.Len(t, r, 6)
+	assert.Equal(t, "AcAd, AcAh, AcKc, AdAh, AdKd, AhKh", r.String())
+}
+
+func TestRange_String(t *testing.T) {
+	for str, expected := range map[string]string{
+		"AKs, TT+, A2s-A5s, KQo, 22-66": "TT+, 22-66, AKs, A2s-A5s, KQo",
+		"AQs, AKs, KQs+": "AQs+, KQs",
+		"JJ, QQ, KK, AA": "JJ+",
+		"AA:0.5, KK": "AA:0.5, KK",
+		"AK": "AKs, AKo",
+		"A9o+": "A9o+",
+		"K2s-K4s, K9s-KJs": "K9s-KJs, K2s-K4s",
+		"AsKd, 7h2c": "AsKd, 7h2c",
+		"": "",
+	} {
+		assert.Equal(t, expected, mustParse(t, str).String(), str)
+	}
+	// 转回字符串再解析得到一样的范围
+	r := mustParse(t, "AA:0.5, AsAh, 77-99, A2s-A5s:0.3, KQo, 8h7h")
+	assert.ElementsMatch(t, r, mustParse(t, r.String()))
+}
+
+func TestRange_Grid(t *testing.T) {
+	assert.Equal(t, "AA", GridLabel(0, 0))
+	assert.Equal(t, "AKs", GridLabel(0, 1))
+	assert.Equal(t, "AKo", GridLabel(1, 0))
+	assert.Equal(t, "72o", GridLabel(12, 7))
+	assert.Equal(t, "22", GridLabel(12, 12))
+
+	grid := mustParse(t, "AA, AKs:0.5, AsKd, 72o").RemoveCards(mustCards(t, "Ah")).Grid()
+	assert.InDelta(t, 0.5, grid[0][0], 1e-9)
+	assert.InDelta(t, 0.375, grid[0][1], 1e-9)
+	assert.InDelta(t, 1.0 / 12, grid[1][0], 1e-9)
+	assert.InDelta(t, 1, grid[12][7], 1e-9)
+	assert.Equal(t, 0.0, grid[7][12])
+	assert.Equal(t, 0.0, grid[1][1])
+}
+
+func TestCalculate_ParsedRange(t *testing.T) {
+	// AA对一个很宽的范围
+	villain := mustParse(t, "22+, A2s+, K9s+, QTs+, JTs, ATo+, KJo+")
+	result, err := Calculate(&Request{
+		Players: []Range{ mustParse(t, "AsAh"), villain },
+		Samples: 20000,
+		Seed: 1,
+	})
+	assert.NoError(t, err)
+	assert.False(t, result.Exact)
+	assert.True(t, result.Players[0].Equity > 80)
+}
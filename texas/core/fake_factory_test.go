@@ -2,8 +2,10 @@ package core
 
 import (
 	"strconv"
+	"sync"
 	"time"
 	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/abstracts"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/common/util"
 )
 
 type fakeMsgSender struct {}
@@ -12,6 +14,40 @@ func (s *fakeMsgSender) SendMsg(playerID string, msgType int, msgID int64, msg i
 
 func (s *fakeMsgSender) BroadcastMsg(msgType int, msgID int64, msg interface{}) {}
 
+// 给table用的msg sender，记录每种类型的消息
+type fakeTableMsgSender struct {
+	lock sync.Mutex
+	msgs map[int][][]byte
+}
+
+// 按json编码后记下来
+func (s *fakeTableMsgSender) Send(id string, msgType int, mID int64, msg interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.msgs == nil {
+		s.msgs = map[int][][]byte{}
+	}
+	var content []byte
+	if msg != nil {
+		content = util.StringifyJsonToBytes(msg)
+	}
+	s.msgs[msgType] = append(s.msgs[msgType], content)
+}
+
+func (s *fakeTableMsgSender) get(msgType int) [][]byte {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.msgs[msgType]
+}
+
+type fakeTableObserver struct {
+	finishedChan chan []BustedUser
+}
+
+func (o *fakeTableObserver) OnGameFinished(tableID int, busted []BustedUser) {
+	o.finishedChan <- busted
+}
+
 type fakeUser struct {
 	uid string
 	balance uint64
@@ -19,7 +55,7 @@ type fakeUser struct {
 
 func (u *fakeUser) ID() string { return u.uid }
 
-func (u *fakeUser) Copy() abstracts.User { return &fakeUser{ uid: u.uid } }
+func (u *fakeUser) Copy() abstracts.User { return &fakeUser{ uid: u.uid, balance: u.balance } }
 
 func (u *fakeUser) Balance() uint64 { return u.balance }
 
@@ -46,6 +82,13 @@ func newFakePlayersInTable1() map[uint]abstracts.Player {
 	}
 }
 
+func newFakePlayersHeadsUp() map[uint]abstracts.Player {
+	return map[uint]abstracts.Player{
+		0: newPlayerWithFakeUser(0, 2000),
+		1: newPlayerWithFakeUser(1, 2000),
+	}
+}
+
 func newFakePlayersInTable2() map[uint]abstracts.Player {
 	return map[uint]abstracts.Player{
 		0: newPlayerWithFakeUser(0, 2000),
@@ -70,3 +113,26 @@ func (g *Game) newPlayerActionMsg(player uint, action abstracts.GameAction, amou
 		Amount: amount,
 	}
 }
+
+// 记录game广播的消息
+type recordMsgSender struct {
+	lock sync.Mutex
+	broadcasts map[int][]interface{}
+}
+
+func (s *recordMsgSender) SendMsg(playerID string, msgType int, msgID int64, msg interface{}) {}
+
+func (s *recordMsgSender) BroadcastMsg(msgType int, msgID int64, msg interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.broadcasts == nil {
+		s.broadcasts = map[int][]interface{}{}
+	}
+	s.broadcasts[msgType] = append(s.broadcasts[msgType], msg)
+}
+
+func (s *recordMsgSender) get(msgType int) []interface{} {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.broadcasts[msgType]
+}
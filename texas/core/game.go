@@ -5,13 +5,21 @@ import (
 	"errors"
 	"go.uber.org/zap"
 	"sort"
+	"sync"
+	"sync/atomic"
 	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/abstracts"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/core/hand_processor"
 	"github.com/LeaguesOfHoleHoleShoes/HoleHole/common/log"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/common/util"
 )
 
 var (
 	// 测试时会修改该值
 	betTimeout = 10 * time.Second
+	// 还要发三张以上的牌时，胜率用随机发牌估算，这是随机的次数
+	equitySamples = 1000
+	// 每局的牌堆，测试时会替换成指定的牌
+	newGameCardHeap = newVariantPokerHeap
 )
 
 type gameMsgSender interface {
@@ -22,22 +30,38 @@ type gameMsgSender interface {
 }
 
 // 初始化一个game，随后调用Run获得执行结果
-func NewGame(xmBet uint64, players map[uint]abstracts.Player, sender gameMsgSender, resultChan chan *GameResult) *Game {
+func NewGame(level TableLevel, players map[uint]abstracts.Player, sender gameMsgSender, resultChan chan *GameResult) *Game {
+	// 一开始由大盲左边第一个开始下注（D用户始终为0），只有D盲注时由D左边第一个开始
+	_, bb := blindPlayers(uint(len(players)))
+	if level.ButtonBlind > 0 {
+		bb = 0
+	}
+	firstBet := bb + 1
+	if firstBet >= uint(len(players)) {
+		firstBet = 0
+	}
 	g := &Game{
 		id: time.Now().UnixNano(),
-		xmBet: xmBet, players: players, playersLen: uint(len(players)),
+		xmBet: level.Xm, dmBet: level.DmBet(), ante: level.Ante, buttonBlind: level.ButtonBlind, variant: level.Variant,
+		betting: newBettingStructure(level),
+		runTimes: level.RunTimes,
+		players: players, playersLen: uint(len(players)),
 		msgSender: sender,
 		handMatcher: &HMatcher{},
-		cardHeap: newPokerHeap(),
+		cardHeap: newGameCardHeap(level.Variant),
 		msgChan: make(chan abstracts.PlayerActionMsg), timer: newGameTimer(nil),
 		canLeaveChan: make(chan *canLeaveMsg),
 		gameSceneChan: make(chan gameSceneMsg),
+		cancelChan: make(chan struct{}, 1),
+		snapshotChan: make(chan chan gameSnapshotResult),
+		doneChan: make(chan struct{}),
+		stopChan: make(chan struct{}),
 		gameStatus: gameStatus{
 			chipPool: newTermChipPool(),
-			// 一开始由大盲左边第一个开始下注（D用户始终为0）
-			curBetPlayer: 3,
+			curBetPlayer: firstBet,
 			curRound: 1,
-			startBetAt: 3,
+			startBetAt: firstBet,
+			lastAggressor: -1,
 		},
 		resultChan: resultChan,
 	}
@@ -47,9 +71,11 @@ func NewGame(xmBet uint64, players map[uint]abstracts.Player, sender gameMsgSend
 type GameResult struct {
 	id int64
 	players map[uint]abstracts.Player
+	// 被取消的局，所有下注都已退回
+	cancelled bool
 }
 
-// D为0，因此第一轮开始下注位置为3，后三轮开始下注位置为1
+// D为0，因此第一轮开始下注位置为3（人数不够时往前绕），后三轮开始下注位置为1
 type gameStatus struct {
 	chipPool     *termChipPool
 	curBetPlayer uint
@@ -62,13 +88,31 @@ type gameStatus struct {
 	allInnedPlayerCount uint
 	// 公共牌
 	commonPokers []abstracts.Poker
+	// 本轮下注加加注的次数，第一轮的大盲算一次
+	raiseCount int
+	// 本轮最后一个下注或加注的人，没有为-1，摊牌时他输了也要亮牌
+	lastAggressor int
+	// 本轮最大的一次下注或加注比之前的最大下注多出多少，不算盲注，用来算最小加注
+	lastRaise uint64
+	// 所有人all in或弃牌后直接发牌到最后，摊牌时所有人都亮牌
+	runout bool
 }
 
 type Game struct {
 	id int64
 	// 游戏的配置
-	// 小盲应该下注多少，大盲是他的两倍
+	// 小盲应该下注多少
 	xmBet uint64
+	// 大盲应该下注多少
+	dmBet uint64
+	// 每人的前注，不算在第一轮的下注中
+	ante uint64
+	// D盲注，不为0时不下大小盲
+	buttonBlind uint64
+	variant GameVariant
+	betting bettingStructure
+	// 所有人all in后剩下的牌发几次
+	runTimes int
 	// 用户个数
 	playersLen uint
 	// 当前轮的所有用户。从D为0开始，顺时针一次递增1，D顺数1、2个为小盲和大盲，因此小盲是1，大盲是2
@@ -81,12 +125,24 @@ type Game struct {
 	cardHeap abstracts.CardHeap
 
 	canLeaveChan chan *canLeaveMsg
+	cancelChan chan struct{}
+	cancelled bool
 	msgChan chan abstracts.PlayerActionMsg
 	gameSceneChan chan gameSceneMsg
+	snapshotChan chan chan gameSnapshotResult
+	// 记录每一步操作，用于快照
+	actions []GameActionRecord
+	// 从快照恢复的局，Run时不再发牌和下盲注
+	restored bool
 	// 工具类都用指针，只有小的纯数据类不用指针
 	timer *gameTimer
 
+	// 只close不重新赋值，其他协程通过started判断是否在跑
 	stopChan chan struct{}
+	stopOnce sync.Once
+	started int32
+	// loop结束后关闭，之后同步调用的方法不能再等loop处理
+	doneChan chan struct{}
 	resultChan chan *GameResult
 }
 
@@ -108,7 +164,6 @@ msg处理中触发了timeout
 */
 func (g *Game) loop() {
 	log.L.Debug("start game loop")
-	defer func() { g.stopChan = nil }()
 	for {
 		select {
 		case msg := <- g.msgChan:
@@ -120,6 +175,13 @@ func (g *Game) loop() {
 			g.doGetScene(msg)
 		case msg := <- g.canLeaveChan:
 			g.canLeave(msg)
+		case resultChan := <- g.snapshotChan:
+			s, err := toGameSnapshot(g)
+			resultChan <- gameSnapshotResult{ snapshot: s, err: err }
+		case <- g.cancelChan:
+			g.cancel()
+			g.timer.Stop()
+			return
 		case <- g.stopChan:
 			g.timer.Stop()
 			return
@@ -146,11 +208,15 @@ func (g *Game) canLeave(msg *canLeaveMsg) {
 }
 
 func (g *Game) OnMsg(msg abstracts.PlayerActionMsg) {
-	if g.stopChan == nil {
+	if atomic.LoadInt32(&g.started) == 0 {
 		log.L.Warn("game not started, but receive game msg", zap.String("uid", msg.UserID))
 		return
 	}
-	g.msgChan <- msg
+	select {
+	case g.msgChan <- msg:
+	case <-g.doneChan:
+		log.L.Warn("game finished, but receive game msg", zap.String("uid", msg.UserID))
+	}
 }
 
 /*
@@ -175,19 +241,38 @@ func (g *Game) onMsg(msg abstracts.PlayerActionMsg) {
 	// 执行用户的操作
 	switch msg.ActionType {
 	case abstracts.GameActionOfBet:
-		// 如果all in，在里边会标记
-		enough, isAllIn := p.Bet(msg.Amount)
-		if !enough {
+		if err := g.betting.checkBet(g, g.curBetPlayer, msg.Amount); err != nil {
+			log.L.Debug("invalid bet", zap.String("player", p.ID()), zap.Uint64("msg.Amount", msg.Amount), zap.Error(err))
+			return
+		}
+		if msg.Amount > p.RemainChip() {
 			log.L.Debug("chip not enough", zap.String("player", p.ID()), zap.Uint64("remain", p.RemainChip()), zap.Uint64("msg.Amount", msg.Amount))
 			// todo send not enough amount
 			return
 		}
+		isAllIn := msg.Amount == p.RemainChip()
+		curMax := g.chipPool.maxBetAmountAt(g.curRound)
+		// 先下到筹码池，被拒绝时玩家的筹码还没动
+		if msg.Amount > 0 {
+			if err := g.chipPool.bet(g.curRound, g.curBetPlayer, msg.Amount, isAllIn); err != nil {
+				log.L.Debug("chip pool reject bet", zap.String("player", p.ID()), zap.Uint64("msg.Amount", msg.Amount), zap.Error(err))
+				return
+			}
+		}
+		// 如果all in，在里边会标记
+		p.Bet(msg.Amount)
 		if isAllIn {
 			g.allInnedPlayerCount++
 		}
 		if msg.Amount > 0 {
 			log.L.Debug("player bet", zap.String("player", p.ID()), zap.Uint("round", g.curRound), zap.Uint64("amount", msg.Amount), zap.Bool("is all in", isAllIn))
-			g.chipPool.bet(g.curRound, g.curBetPlayer, msg.Amount, isAllIn)
+			if newMax := g.chipPool.maxBetAmountAt(g.curRound); newMax > curMax {
+				g.raiseCount++
+				g.lastAggressor = int(g.curBetPlayer)
+				if newMax - curMax > g.lastRaise {
+					g.lastRaise = newMax - curMax
+				}
+			}
 		} else {
 			log.L.Debug("player bet nothing", zap.String("player", p.ID()), zap.Uint("round", g.curRound))
 		}
@@ -196,6 +281,7 @@ func (g *Game) onMsg(msg abstracts.PlayerActionMsg) {
 		p.Discard()
 		g.discardedPlayerCount++
 	}
+	g.actions = append(g.actions, GameActionRecord{ Round: g.curRound, Player: g.curBetPlayer, ActionType: msg.ActionType, Amount: msg.Amount })
 	g.afterPlayerActionOrTimeout()
 }
 
@@ -204,13 +290,17 @@ type canLeaveMsg struct {
 	resultChan chan bool
 }
 func (g *Game) CanLeave(uID string) bool {
-	if g.stopChan == nil {
+	if atomic.LoadInt32(&g.started) == 0 {
 		log.L.Warn("call can leave, but game not running", zap.String("u id", uID))
 		return true
 	}
 	resultC := make(chan bool)
-	g.canLeaveChan <- &canLeaveMsg{ uID: uID, resultChan: resultC }
-	return <- resultC
+	select {
+	case g.canLeaveChan <- &canLeaveMsg{ uID: uID, resultChan: resultC }:
+		return <- resultC
+	case <-g.doneChan:
+		return true
+	}
 }
 
 /*
@@ -248,7 +338,7 @@ func (g *Game) afterPlayerActionOrTimeout() {
 	}
 
 	// 判断游戏结束
-	if g.curRound > 4 {
+	if g.curRound > g.lastRound() {
 		g.end()
 		return
 	}
@@ -261,12 +351,17 @@ func (g *Game) afterPlayerActionOrTimeout() {
 
 func (g *Game) dealCards() {
 	// todo 发出消息通知客户端
+	if g.variant.Stud() {
+		g.dealStudCards()
+		return
+	}
 	// round是从1开始的
 	switch g.curRound {
 	case 1:
-		// 每人发两张牌
-		for _, p := range g.players {
-			p.GotPokers(g.cardHeap.DispatchPokers(2))
+		// 每人发两张牌（奥马哈四张）
+		// 按位置顺序发，牌堆确定时每人拿到的牌也是确定的
+		for i := 0; i < len(g.players); i++ {
+			g.players[uint(i)].GotPokers(g.cardHeap.DispatchPokers(g.variant.HoleCards()))
 			// 不能广播，因为每人都只能收到自己的手牌，不能收到别人的手牌
 		}
 	case 2:
@@ -309,18 +404,200 @@ func (g *Game) allDiscardedEnd() {
 /*
 
 所有人all in或弃牌了，直接发牌到最后
+发牌前广播每个人的胜率，开了发多次时每次都从牌堆接着发，每个池子按次数平分
 
 */
 func (g *Game) dealingCardsToEnd() {
 	log.L.Debug("game dealingCardsToEnd")
-	// 进来时round尚未++
-	// 发牌到最后
-	for g.curRound < 4 {
-		g.curRound++
-		g.dealCards()
+	g.runout = true
+	g.broadcastEquity()
+	runTimes := g.availableRunTimes()
+	if runTimes <= 1 {
+		// 进来时round尚未++
+		// 发牌到最后
+		for g.curRound < g.lastRound() {
+			g.curRound++
+			g.dealCards()
+		}
+		// 随后就是正常结束的流程，end里边会调stop
+		g.end()
+		return
 	}
-	// 随后就是正常结束的流程，end里边会调stop
-	g.end()
+
+	round, board := g.curRound, g.commonPokers
+	var runouts [][]abstracts.Poker
+	var rankings, lowRankings [][][]uint
+	for i := 0; i < runTimes; i++ {
+		g.curRound = round
+		g.commonPokers = append([]abstracts.Poker{}, board...)
+		for g.curRound < g.lastRound() {
+			g.curRound++
+			g.dealCards()
+		}
+		runouts = append(runouts, g.commonPokers)
+		rankings = append(rankings, g.rankPlayers())
+		if g.variant.HiLo() {
+			lowRankings = append(lowRankings, g.rankLowPlayers())
+		}
+	}
+	log.L.Debug("run it times", zap.Int("times", runTimes))
+	// 第一次的公共牌作为这局的公共牌
+	g.commonPokers = runouts[0]
+	resp := abstracts.RunoutsResp{}
+	for _, runout := range runouts {
+		resp.Boards = append(resp.Boards, toPokerScenes(runout))
+	}
+	g.msgSender.BroadcastMsg(abstracts.MsgTypeRunouts, time.Now().UnixNano(), resp)
+	g.settle(rankings, lowRankings, runouts)
+}
+
+// 剩下的牌实际能发几次，牌堆不够时少发几次
+func (g *Game) availableRunTimes() int {
+	need := 5 - len(g.commonPokers)
+	// 梭哈每人的牌都不一样，只发一次
+	if g.runTimes <= 1 || need <= 0 || g.variant.Stud() {
+		return 1
+	}
+	heap, ok := g.cardHeap.(*PokerHeap)
+	if !ok {
+		return g.runTimes
+	}
+	times := g.runTimes
+	for times > 1 && need * times > len(heap.Pokers) {
+		times--
+	}
+	return times
+}
+
+// 还有牌没发时，广播每个没弃牌的人的胜率
+func (g *Game) broadcastEquity() {
+	equity := g.allInEquity()
+	if equity == nil {
+		return
+	}
+	resp := abstracts.AllInEquityResp{ Round: g.curRound, RunTimes: g.availableRunTimes() }
+	for i := uint(0); i < g.playersLen; i++ {
+		if e, ok := equity[i]; ok {
+			resp.Players = append(resp.Players, &abstracts.PlayerEquity{ UserID: g.players[i].ID(), Equity: e })
+		}
+	}
+	g.msgSender.BroadcastMsg(abstracts.MsgTypeAllInEquity, time.Now().UnixNano(), resp)
+}
+
+/*
+
+用牌堆中剩下的牌算每个没弃牌的人的胜率，平分的按人数算一部分
+只差一两张牌时枚举所有可能，否则随机发equitySamples次估算
+没有要发的牌或者不到两个人时返回nil
+
+*/
+func (g *Game) allInEquity() map[uint]float64 {
+	heap, ok := g.cardHeap.(*PokerHeap)
+	need := 5 - len(g.commonPokers)
+	// 梭哈要发的是每个人自己的牌，不算胜率
+	if !ok || need <= 0 || need > len(heap.Pokers) || g.variant.Stud() {
+		return nil
+	}
+	var live []uint
+	for i := uint(0); i < g.playersLen; i++ {
+		if !g.players[i].Discarded() {
+			live = append(live, i)
+		}
+	}
+	if len(live) < 2 {
+		return nil
+	}
+
+	wins := map[uint]float64{}
+	total := 0
+	onBoard := func(rest []*hand_processor.Poker) {
+		board := append([]abstracts.Poker{}, g.commonPokers...)
+		for _, p := range rest {
+			board = append(board, p)
+		}
+		var best []uint
+		var bestHand abstracts.Hand
+		for _, i := range live {
+			hand := g.handOfBoard(g.players[i], board)
+			cmp := 1
+			if bestHand != nil {
+				cmp = g.handMatcher.Cmp(hand, bestHand)
+			}
+			if cmp == 1 {
+				best, bestHand = []uint{ i }, hand
+			} else if cmp == 0 {
+				best = append(best, i)
+			}
+		}
+		// 高低牌玩法中有人成低牌时高牌只拿一半
+		share := 1.0
+		if g.variant.HiLo() {
+			if lowBest := g.bestLows(live, board); len(lowBest) > 0 {
+				share = 0.5
+				for _, i := range lowBest {
+					wins[i] += share / float64(len(lowBest))
+				}
+			}
+		}
+		for _, i := range best {
+			wins[i] += share / float64(len(best))
+		}
+		total++
+	}
+	if need <= 2 {
+		forEachCombination(heap.Pokers, need, onBoard)
+	} else {
+		deck := append([]*hand_processor.Poker{}, heap.Pokers...)
+		for n := 0; n < equitySamples; n++ {
+			// 只洗前need张
+			for i := 0; i < need; i++ {
+				j := i + util.RandANum(len(deck) - i)
+				deck[i], deck[j] = deck[j], deck[i]
+			}
+			onBoard(deck[:need])
+		}
+	}
+
+	result := map[uint]float64{}
+	for _, i := range live {
+		result[i] = wins[i] / float64(total)
+	}
+	return result
+}
+
+// 某组公共牌下低牌最好的人，没人成低牌时为空
+func (g *Game) bestLows(live []uint, board []abstracts.Poker) (result []uint) {
+	var best hand_processor.LowRank
+	for _, i := range live {
+		low, ok := g.lowOfBoard(g.players[i], board)
+		if !ok {
+			continue
+		}
+		if len(result) == 0 || low.BetterThan(best) {
+			result, best = []uint{ i }, low
+		} else if low == best {
+			result = append(result, i)
+		}
+	}
+	return
+}
+
+// 从pokers中取count张的每种组合
+func forEachCombination(pokers []*hand_processor.Poker, count int, cb func(ps []*hand_processor.Poker)) {
+	var picked []*hand_processor.Poker
+	var pick func(start int)
+	pick = func(start int) {
+		if len(picked) == count {
+			cb(picked)
+			return
+		}
+		for i := start; i < len(pokers); i++ {
+			picked = append(picked, pokers[i])
+			pick(i + 1)
+			picked = picked[:len(picked) - 1]
+		}
+	}
+	pick(0)
 }
 
 /*
@@ -332,11 +609,28 @@ func (g *Game) dealingCardsToEnd() {
 */
 func (g *Game) end() {
 	//log.L.Debug("game end")
-	g.mergeResultToPlayers(g.chipPool.finalize(g.rankPlayers()))
+	var lowRuns [][][]uint
+	if g.variant.HiLo() {
+		lowRuns = [][][]uint{ g.rankLowPlayers() }
+	}
+	g.settle([][][]uint{ g.rankPlayers() }, lowRuns, nil)
+}
+
+// 按每次的排名结算，广播摊牌后把筹码发给赢家，boards是发了多次牌时每次的公共牌
+func (g *Game) settle(runs [][][]uint, lowRuns [][][]uint, boards [][]abstracts.Poker) {
+	pots, lowPots := g.chipPool.finalizeRunsPots(runs, lowRuns)
+	g.broadcastShowdown(pots, lowPots, boards)
+	g.mergeResultToPlayers(sumPots(append(append([]map[uint]uint64{}, pots...), lowPots...)))
 	g.stop()
 }
 
 func (g *Game) rankPlayers() (result [][]uint) {
+	hands := map[uint]abstracts.Hand{}
+	for i, p := range g.players {
+		if !p.Discarded() {
+			hands[i] = g.handOf(p)
+		}
+	}
 	// 将相同牌的玩家放同一个数组中
 	var notDiscardedPlayers [][]abstracts.Player
 out:
@@ -344,7 +638,7 @@ out:
 		if !p.Discarded() {
 			// 检查是否牌型相同
 			for psIndex, ps := range notDiscardedPlayers {
-				if g.handMatcher.Cmp(ps[0].GetHand(g.commonPokers), p.GetHand(g.commonPokers)) == 0 {
+				if g.handMatcher.Cmp(hands[ps[0].PlaceIndex()], hands[p.PlaceIndex()]) == 0 {
 					notDiscardedPlayers[psIndex] = append(ps, p)
 					continue out
 				}
@@ -357,7 +651,7 @@ out:
 	// 对这些数组排序
 	sort.Slice(notDiscardedPlayers, func(i, j int) bool {
 		// 大的排前，i在后，j在前，true代表换位，因此i>j则需要返回true
-		if g.handMatcher.Cmp(notDiscardedPlayers[i][0].GetHand(g.commonPokers), notDiscardedPlayers[j][0].GetHand(g.commonPokers)) == 1 {
+		if g.handMatcher.Cmp(hands[notDiscardedPlayers[i][0].PlaceIndex()], hands[notDiscardedPlayers[j][0].PlaceIndex()]) == 1 {
 			return true
 		}
 		return false
@@ -374,28 +668,164 @@ out:
 	return
 }
 
+// 玩家的最终牌型
+func (g *Game) handOf(p abstracts.Player) abstracts.Hand {
+	return g.handOfBoard(p, g.commonPokers)
+}
+
+// 玩家用某组公共牌组成的牌型，奥马哈必须用两张手牌加三张公共牌，短牌按短牌的规则比大小，德州和赖子7张里任选5张
+// 发多次牌时每次的公共牌不一样，因此不能用Player缓存的牌型
+func (g *Game) handOfBoard(p abstracts.Player, board []abstracts.Poker) abstracts.Hand {
+	// 德州和梭哈直接查表，比Analyst2快很多
+	if g.variant == VariantHoldem || g.variant.Stud() {
+		holeStr, boardStr := pokersStrOf(p, board)
+		rank, err := hand_processor.HandStrToRank(holeStr + boardStr)
+		if err != nil {
+			panic("parse hand failed: " + err.Error())
+		}
+		return rank
+	}
+	return g.analystHandOfBoard(p, board)
+}
+
+// 用Analyst2算的牌型，能拿到组成牌型的5张牌
+func (g *Game) analystHandOfBoard(p abstracts.Player, board []abstracts.Poker) *hand_processor.Hand {
+	holeStr, boardStr := pokersStrOf(p, board)
+	var hand *hand_processor.Hand
+	var err error
+	switch {
+	case g.variant.Omaha():
+		hand, err = hand_processor.OmahaHandStrToHand(holeStr, boardStr)
+	case g.variant == VariantShortDeck:
+		hand, err = hand_processor.ShortDeckHandStrToHand(holeStr + boardStr)
+	default:
+		hand, err = hand_processor.HandStrToHand(holeStr + boardStr)
+	}
+	if err != nil {
+		panic("parse hand failed: " + err.Error())
+	}
+	return hand
+}
+
+// 高低牌玩法中成低牌的人的排名，低牌越小排越前，同样的低牌在同一名，没人成低牌时为空
+func (g *Game) rankLowPlayers() (result [][]uint) {
+	lows := map[uint]hand_processor.LowRank{}
+	var players []uint
+	for i := uint(0); i < g.playersLen; i++ {
+		if g.players[i].Discarded() {
+			continue
+		}
+		if low, ok := g.lowOfBoard(g.players[i], g.commonPokers); ok {
+			lows[i] = low
+			players = append(players, i)
+		}
+	}
+	sort.SliceStable(players, func(i, j int) bool {
+		return lows[players[i]].BetterThan(lows[players[j]])
+	})
+	for i, u := range players {
+		if i > 0 && lows[u] == lows[players[i - 1]] {
+			result[len(result) - 1] = append(result[len(result) - 1], u)
+		} else {
+			result = append(result, []uint{ u })
+		}
+	}
+	return
+}
+
+// 玩家用某组公共牌组成的低牌，奥马哈必须用两张手牌加三张公共牌，七张梭哈用自己的七张牌（牌不够时加上公共牌）
+func (g *Game) lowOfBoard(p abstracts.Player, board []abstracts.Poker) (hand_processor.LowRank, bool) {
+	holeStr, boardStr := pokersStrOf(p, board)
+	var low hand_processor.LowRank
+	var ok bool
+	var err error
+	if g.variant.Omaha() {
+		low, ok, err = hand_processor.OmahaLowHandStrToLow(holeStr, boardStr)
+	} else {
+		low, ok, err = hand_processor.LowHandStrToLow(holeStr + boardStr)
+	}
+	if err != nil {
+		panic("parse low hand failed: " + err.Error())
+	}
+	return low, ok
+}
+
+func pokersStrOf(p abstracts.Player, board []abstracts.Poker) (holeStr string, boardStr string) {
+	for _, poker := range p.Pokers() {
+		holeStr += poker.GetWhole()
+	}
+	for _, poker := range board {
+		boardStr += poker.GetWhole()
+	}
+	return
+}
+
 func (g *Game) stop() {
-	close(g.stopChan)
+	g.stopOnce.Do(func() { close(g.stopChan) })
+}
+
+// 取消本局，所有人下的注原样退回，结算时每个人的筹码都不变
+func (g *Game) cancel() {
+	// 已经结算过了（cancelChan和stopChan同时可读时select可能先选到cancel），不能再退一次注
+	select {
+	case <-g.stopChan:
+		return
+	default:
+	}
+	log.L.Info("game cancelled", zap.Int64("game id", g.id))
+	g.cancelled = true
+	for _, p := range g.players {
+		p.WinChip(p.HaveBet())
+	}
+	g.stop()
+}
+
+/*
+
+取消正在进行的游戏（如停服时超时还没打完），结果照常通过resultChan返回
+游戏已经结束时调用不会有任何效果
+
+*/
+func (g *Game) Cancel() {
+	select {
+	case g.cancelChan <- struct{}{}:
+	default:
+	}
 }
 
 func (g *Game) setupNewRound() {
-	// 判断场上是否只有1个人能操作了，是的话则发牌到最后结束游戏
-	if g.discardedPlayerCount + g.allInnedPlayerCount + 1 == g.playersLen {
+	// 判断场上是否最多只有1个人能操作了（可能所有人都all in了），是的话则发牌到最后结束游戏
+	if g.discardedPlayerCount + g.allInnedPlayerCount + 1 >= g.playersLen {
 		g.dealingCardsToEnd()
 		return
 	}
 
 	g.curRound++
+	// 最后一轮结束后不清，亮牌时要知道最后一轮谁下注或加注了
+	if g.curRound > g.lastRound() {
+		return
+	}
+	g.raiseCount = 0
+	g.lastAggressor = -1
+	g.lastRaise = 0
+	// 发牌，梭哈要看发完的明牌才知道谁先说话
+	g.dealCards()
 	// 在第一个下注轮中，大盲注左边的玩家第一个行动。从第二个下注轮开始，由D位置左边的第一个玩家开始行动。不能是已经弃牌和all in的玩家，否则逻辑会卡死
 	sAt := g.nextBetPlayer(0)
+	if g.variant.Stud() {
+		sAt = g.studFirstToAct()
+	}
 	g.startBetAt = sAt
 	g.curBetPlayer = sAt
-
 	log.L.Debug("setup new round", zap.Uint("round", g.curRound), zap.Uint("start at", sAt))
-	if g.curRound < 5 {
-		// 发牌
-		g.dealCards()
+}
+
+// 最后一个下注轮，德州是河牌，七张梭哈是第七街
+func (g *Game) lastRound() uint {
+	if g.variant.Stud() {
+		return studLastRound
 	}
+	return 4
 }
 
 func (g *Game) nextBetPlayer(cur uint) uint {
@@ -432,12 +862,15 @@ func (g *Game) onTimeout(info timeoutInfo) {
 		return
 	}
 	log.L.Debug("on game Timeout", zap.Uint("round", info.round), zap.Uint("player", info.player))
+	record := GameActionRecord{ Round: g.curRound, Player: g.curBetPlayer, ActionType: abstracts.GameActionOfBet, Timeout: true }
 	// 如果他下注等于当前最大下注值那么就是过牌，否则执行弃牌
 	if !g.chipPool.playerHaveBetToMax(g.curRound, g.curBetPlayer) {
 		log.L.Debug("timeout discard", zap.Uint("round", info.round), zap.Uint("player", info.player))
 		p.Discard()
 		g.discardedPlayerCount++
+		record.ActionType = abstracts.GameActionOfDiscard
 	}
+	g.actions = append(g.actions, record)
 	g.afterPlayerActionOrTimeout()
 }
 
@@ -448,37 +881,129 @@ func (g *Game) onTimeout(info timeoutInfo) {
 
 */
 func (g *Game) Run() {
-	if g.stopChan != nil {
+	if !atomic.CompareAndSwapInt32(&g.started, 0, 1) {
 		panic("game already started")
 	}
-	g.stopChan = make(chan struct{})
-	g.doStart()
+	if g.restored {
+		g.doResume()
+	} else {
+		g.doStart()
+	}
 	// 阻塞至loop stop，则game结束，返回结果
 	g.loop()
+	close(g.doneChan)
 	// send result
 	g.resultChan <- &GameResult{
 		id: g.id,
 		players: g.players,
+		cancelled: g.cancelled,
 	}
 	return
 }
 
+// 小盲和大盲的位置，两个人时D就是小盲
+func blindPlayers(playersLen uint) (xm uint, dm uint) {
+	if playersLen == 2 {
+		return 0, 1
+	}
+	return 1, 2
+}
+
+// 下盲注，筹码不够盲注的人直接all in
+func (g *Game) betBlind(player uint, amount uint64) {
+	g.betForced(1, player, amount)
+}
+
+// 下强制的注（前注、盲注），前注记在第0轮，不影响第一轮的跟注数
+func (g *Game) betForced(round uint, player uint, amount uint64) {
+	p := g.players[player]
+	// 下前注时已经all in了
+	if p.AllInned() || amount == 0 {
+		return
+	}
+	if amount > p.RemainChip() {
+		amount = p.RemainChip()
+	}
+	_, isAllIn := p.Bet(amount)
+	if isAllIn {
+		g.allInnedPlayerCount++
+	}
+	g.chipPool.bet(round, player, amount, isAllIn)
+}
+
 // 在loop之前执行开始操作
 func (g *Game) doStart() {
 	g.dealCards()
+	// 从D开始下前注
+	if g.ante > 0 {
+		for i := uint(0); i < g.playersLen; i++ {
+			g.betForced(0, i, g.ante)
+		}
+	}
 	// 下大小盲，广播当前下注的玩家
-	g.players[1].Bet(g.xmBet)
-	g.chipPool.bet(1, 1, g.xmBet, false)
-	g.players[2].Bet(g.xmBet * 2)
-	g.chipPool.bet(1, 2, g.xmBet * 2, false)
+	if g.variant.Stud() {
+		g.betBringIn()
+	} else if g.buttonBlind > 0 {
+		g.betBlind(0, g.buttonBlind)
+	} else {
+		xm, dm := blindPlayers(g.playersLen)
+		g.betBlind(xm, g.xmBet)
+		g.betBlind(dm, g.dmBet)
+	}
+	// 梭哈的bring-in不算一注
+	if g.chipPool.maxBetAmountAt(1) > 0 && !g.variant.Stud() {
+		g.raiseCount = 1
+	}
 
 	// 启动timer
 	g.timer.Start()
+	// 所有人下前注、盲注就all in了，直接发牌到最后
+	if g.allInnedPlayerCount == g.playersLen {
+		g.dealingCardsToEnd()
+		return
+	}
+	// 第一个下注的人下盲注就all in了（两人时小盲即是第一个下注的人），直接轮到下一个人
+	if g.players[g.curBetPlayer].AllInned() {
+		g.afterPlayerActionOrTimeout()
+		return
+	}
+	g.timer.Set(betTimeout, timeoutInfo{ round: g.curRound, player: g.curBetPlayer })
+}
+
+// 从快照恢复的局，接着给当前该下注的人计时
+func (g *Game) doResume() {
+	log.L.Info("resume game", zap.Int64("game id", g.id), zap.Uint("round", g.curRound), zap.Uint("cur bet", g.curBetPlayer))
+	g.timer.Start()
 	g.timer.Set(betTimeout, timeoutInfo{ round: g.curRound, player: g.curBetPlayer })
 }
 
 /*
 
+获取当前这局的快照，在game的loop中生成，保证状态一致
+这局已经结束（结果可能还没被桌子处理）时返回nil
+
+*/
+func (g *Game) Snapshot() (*GameSnapshot, error) {
+	if atomic.LoadInt32(&g.started) == 0 {
+		return nil, nil
+	}
+	resultChan := make(chan gameSnapshotResult, 1)
+	select {
+	case g.snapshotChan <- resultChan:
+		result := <-resultChan
+		return result.snapshot, result.err
+	case <-g.doneChan:
+		return nil, nil
+	}
+}
+
+type gameSnapshotResult struct {
+	snapshot *GameSnapshot
+	err error
+}
+
+/*
+
 整局游戏的筹码池
 并在最后结算筹码分配
 
@@ -508,15 +1033,70 @@ type termChipPool struct {
 
 */
 func (p *termChipPool) finalize(winners [][]uint) map[uint]uint64 {
+	return sumPots(p.finalizePots(winners))
+}
+
+// 每个池子各自的分配结果，0号是主池
+func (p *termChipPool) finalizePots(winners [][]uint) []map[uint]uint64 {
+	pots, _ := p.finalizeRunsPots([][][]uint{ winners }, nil)
+	return pots
+}
+
+// 发了多次牌时，每个池子按次数平分，每份按那次的排名分，除不尽的算在第一次
+func (p *termChipPool) finalizeRuns(runs [][][]uint) map[uint]uint64 {
+	pots, _ := p.finalizeRunsPots(runs, nil)
+	return sumPots(pots)
+}
+
+/*
+
+每个池子各自的分配结果，0号是主池
+lowRuns不为nil时是高低牌玩法，每次是成低牌的人的排名，每份再分成高牌和低牌两半，分别返回
+
+*/
+func (p *termChipPool) finalizeRunsPots(runs [][][]uint, lowRuns [][][]uint) (highPots []map[uint]uint64, lowPots []map[uint]uint64) {
+	times := uint64(len(runs))
+	for next := p.pool; next != nil; next = next.nextPool {
+		high, low := map[uint]uint64{}, map[uint]uint64{}
+		total := next.allPlayerTotalChip()
+		for i, winners := range runs {
+			amount := total / times
+			if i == 0 {
+				amount += total % times
+			}
+			var lowWinners [][]uint
+			if lowRuns != nil {
+				lowWinners = lowRuns[i]
+			}
+			h, l := next.finalizeHiLo(winners, lowWinners, amount)
+			for u, r := range h {
+				high[u] += r
+			}
+			for u, r := range l {
+				low[u] += r
+			}
+		}
+		highPots = append(highPots, high)
+		lowPots = append(lowPots, low)
+	}
+	return
+}
+
+// 每个池子的筹码数，0号是主池
+func (p *termChipPool) potAmounts() (result []uint64) {
+	for next := p.pool; next != nil; next = next.nextPool {
+		result = append(result, next.allPlayerTotalChip())
+	}
+	return
+}
+
+// 汇总每个池子的结果
+func sumPots(pots []map[uint]uint64) map[uint]uint64 {
 	result := map[uint]uint64{}
-	nextPool := p.pool
-	for nextPool != nil {
-		tmpR := nextPool.finalize(winners)
-		// 汇总结果
-		for u, r := range tmpR {
+	for _, pot := range pots {
+		for u, r := range pot {
 			result[u] += r
 		}
-		nextPool = nextPool.nextPool
 	}
 	return result
 }
@@ -556,6 +1136,14 @@ func (p *termChipPool) bet(round uint, player uint, amount uint64, isAllIn bool)
 	return nil
 }
 
+// 底池大小，所有池子（含前注和本轮已下的注）的筹码总数
+func (p *termChipPool) potSize() (total uint64) {
+	for next := p.pool; next != nil; next = next.nextPool {
+		total += next.totalChip()
+	}
+	return
+}
+
 func (p *termChipPool) maxBetAmountAt(round uint) uint64 {
 	return p.roundMaxAmount[round]
 }
@@ -733,6 +1321,20 @@ func (p *chipPool) splitByLess(round uint, player uint, curUTotal uint64) {
 
 */
 func (p *chipPool) finalize(winners [][]uint) map[uint]uint64 {
+	return p.finalizeAmount(winners, p.allPlayerTotalChip())
+}
+
+// 高牌和低牌平分amount，除不尽的一个给高牌，池子里没人成低牌时都给高牌；两人平分低牌时各拿四分之一
+func (p *chipPool) finalizeHiLo(high [][]uint, low [][]uint, amount uint64) (map[uint]uint64, map[uint]uint64) {
+	lowResult := p.finalizeAmount(low, amount / 2)
+	if len(lowResult) == 0 {
+		return p.finalizeAmount(high, amount), lowResult
+	}
+	return p.finalizeAmount(high, amount - amount / 2), lowResult
+}
+
+// 把池子中的amount个筹码按排名分给赢家
+func (p *chipPool) finalizeAmount(winners [][]uint, amount uint64) map[uint]uint64 {
 	result := map[uint]uint64{}
 	// 从排名开始往下发放奖励
 	for _, ws := range winners {
@@ -740,7 +1342,7 @@ func (p *chipPool) finalize(winners [][]uint) map[uint]uint64 {
 		mLen := len(matchedWs)
 		if mLen > 0 {
 			// 余数是抽成
-			avg := p.allPlayerTotalChip() / uint64(mLen)
+			avg := amount / uint64(mLen)
 			for _, w := range matchedWs {
 				result[w] = avg
 			}
@@ -795,6 +1397,8 @@ type timeoutInfo struct {
 }
 
 type gameTimer struct {
+	// Set在game的loop里调用，超时在timer自己的loop里读info，所以要加锁
+	lock sync.Mutex
 	t *time.Timer
 	info timeoutInfo
 
@@ -803,25 +1407,35 @@ type gameTimer struct {
 }
 
 func (t *gameTimer) Start() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
 	if t.stopChan != nil {
 		return errors.New("timer already started")
 	}
 	t.stopChan = make(chan struct{})
 	t.t = time.NewTimer(0)
 	t.t.Stop()
-	go t.loop()
+	// 用参数传进去，Stop后再Start也不会和上一个loop抢变量
+	go t.loop(t.t, t.stopChan)
 	return nil
 }
 
-func (t *gameTimer) loop() {
+func (t *gameTimer) loop(timer *time.Timer, stopChan chan struct{}) {
 	for {
 		select {
-		case <- t.t.C:
-			t.timeoutChan <- t.info
+		case <- timer.C:
+			t.lock.Lock()
+			info := t.info
+			t.lock.Unlock()
+			select {
+			case t.timeoutChan <- info:
+			case <- stopChan:
+				log.L.Debug("gameTimer loop finished")
+				return
+			}
 
-		case <- t.stopChan:
-			t.t.Stop()
-			t.t = nil
+		case <- stopChan:
+			timer.Stop()
 			log.L.Debug("gameTimer loop finished")
 			return
 		}
@@ -829,15 +1443,19 @@ func (t *gameTimer) loop() {
 }
 
 func (t *gameTimer) Set(d time.Duration, info timeoutInfo) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
 	t.t.Reset(d)
 	t.info = info
 }
 
 func (t *gameTimer) Stop() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
 	if t.stopChan == nil {
 		return errors.New("timer already stopped")
 	}
 	close(t.stopChan)
 	t.stopChan = nil
 	return nil
-}
\ No newline at end of file
+}
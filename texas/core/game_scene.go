@@ -1,6 +1,10 @@
 package core
 
-import "github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/abstracts"
+import (
+	"sync/atomic"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/abstracts"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/core/hand_processor"
+)
 
 type gameSceneMsg struct {
 	uid string
@@ -8,13 +12,17 @@ type gameSceneMsg struct {
 }
 
 func (g *Game) GetScene(uid string) *abstracts.GameScene {
-	if g.stopChan == nil {
+	if atomic.LoadInt32(&g.started) == 0 {
 		return nil
 	}
 
 	resultChan := make(chan *abstracts.GameScene)
-	g.gameSceneChan <- gameSceneMsg{ uid: uid, resultChan: resultChan }
-	return <-resultChan
+	select {
+	case g.gameSceneChan <- gameSceneMsg{ uid: uid, resultChan: resultChan }:
+		return <-resultChan
+	case <-g.doneChan:
+		return nil
+	}
 }
 
 /*
@@ -30,16 +38,57 @@ func (g *Game) doGetScene(msg gameSceneMsg) {
 	}
 
 	for _, p := range g.players {
-		result.Players[p.ID()] = toPlayerScene(msg.uid, p)
+		if g.variant.Stud() {
+			result.Players[p.ID()] = toStudPlayerScene(msg.uid, p)
+		} else {
+			result.Players[p.ID()] = toPlayerScene(msg.uid, p)
+		}
 	}
 
 	for _, poker := range g.commonPokers {
 		result.CommonPokers = append(result.CommonPokers, &abstracts.PokerScene{ Whole: poker.GetWhole() })
 	}
 
+	// 赖子玩法中标出赖子在看的人的牌型中当成了哪张牌
+	if lzAs := g.lzAsFor(msg.uid); lzAs != "" {
+		markLzAs(result.CommonPokers, lzAs)
+		markLzAs(result.Players[msg.uid].Pokers, lzAs)
+	}
+
 	msg.resultChan <- result
 }
 
+// 用户用手牌和当前的公共牌组成的最大牌型中，赖子当成了哪张牌，没用到赖子时返回空
+func (g *Game) lzAsFor(uid string) string {
+	if g.variant != VariantWildcard {
+		return ""
+	}
+	for _, p := range g.players {
+		if p.ID() != uid {
+			continue
+		}
+		handStr := ""
+		for _, poker := range append(append([]abstracts.Poker{}, p.Pokers()...), g.commonPokers...) {
+			handStr += poker.GetWhole()
+		}
+		// 不足5张时还算不出牌型
+		hand, err := hand_processor.HandStrToHand(handStr)
+		if err != nil || hand.GetLzAs() == nil {
+			return ""
+		}
+		return hand.GetLzAs().GetWhole()
+	}
+	return ""
+}
+
+func markLzAs(pokers []*abstracts.PokerScene, lzAs string) {
+	for _, p := range pokers {
+		if p.Whole == hand_processor.LzWhole {
+			p.As = lzAs
+		}
+	}
+}
+
 func toChipPoolScene(pool *termChipPool) []*abstracts.ChipPoolScene {
 	var result []*abstracts.ChipPoolScene
 	for next := pool.pool; next != nil; next = next.nextPool {
@@ -7,11 +7,12 @@ import (
 	"go.uber.org/zap"
 	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/abstracts"
 	"github.com/LeaguesOfHoleHoleShoes/HoleHole/common/log"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/core/hand_processor"
 )
 
 func TestGame_GetScene(t *testing.T) {
 	resultC := make(chan *GameResult)
-	g := NewGame(10, newFakePlayersInTable1(), &fakeMsgSender{}, resultC)
+	g := NewGame(TableLevel{ Xm: 10 }, newFakePlayersInTable1(), &fakeMsgSender{}, resultC)
 	go g.Run()
 	time.Sleep(100 * time.Millisecond)
 
@@ -27,21 +28,22 @@ func TestGame_GetScene(t *testing.T) {
 	assert.Len(t, gScene.Players["1"].Pokers, 2)
 	assert.Nil(t, gScene.Players["0"].Pokers)
 	assert.Equal(t, 1980, int(gScene.Players["2"].RemainChip))
-	g.OnMsg(g.newPlayerActionMsg(1, abstracts.GameActionOfBet, 100))
-	g.OnMsg(g.newPlayerActionMsg(2, abstracts.GameActionOfBet, 100))
+	// 每次加注至少加上一次加注的80
+	g.OnMsg(g.newPlayerActionMsg(1, abstracts.GameActionOfBet, 170))
+	g.OnMsg(g.newPlayerActionMsg(2, abstracts.GameActionOfBet, 240))
 	time.Sleep(100 * time.Millisecond)
 	assert.Equal(t, 1, int(g.curRound))
 	assert.Equal(t, 3, int(g.curBetPlayer))
-	g.betRight(t, 1, 110)
-	g.betRight(t, 2, 120)
+	g.betRight(t, 1, 180)
+	g.betRight(t, 2, 260)
 	g.betRight(t, 3, 100)
 	g.betRight(t, 4, 100)
 	g.betRight(t, 0, 100)
 	// 所有人下注到相同，进入下一轮
-	g.OnMsg(g.newPlayerActionMsg(3, abstracts.GameActionOfBet, 20))
-	g.OnMsg(g.newPlayerActionMsg(4, abstracts.GameActionOfBet, 20))
-	g.OnMsg(g.newPlayerActionMsg(0, abstracts.GameActionOfBet, 20))
-	g.OnMsg(g.newPlayerActionMsg(1, abstracts.GameActionOfBet, 10))
+	g.OnMsg(g.newPlayerActionMsg(3, abstracts.GameActionOfBet, 160))
+	g.OnMsg(g.newPlayerActionMsg(4, abstracts.GameActionOfBet, 160))
+	g.OnMsg(g.newPlayerActionMsg(0, abstracts.GameActionOfBet, 160))
+	g.OnMsg(g.newPlayerActionMsg(1, abstracts.GameActionOfBet, 80))
 	time.Sleep(100 * time.Millisecond)
 	assert.Equal(t, 2, int(g.curRound))
 	assert.Equal(t, 1, int(g.curBetPlayer))
@@ -76,11 +78,11 @@ func TestGame_GetScene(t *testing.T) {
 	g.OnMsg(g.newPlayerActionMsg(4, abstracts.GameActionOfBet, 300))
 	g.OnMsg(g.newPlayerActionMsg(0, abstracts.GameActionOfBet, 300))
 	time.Sleep(100 * time.Millisecond)
-	g.betRight(t, 1, 720)
-	g.betRight(t, 2, 720)
-	g.betRight(t, 3, 720)
-	g.betRight(t, 4, 720)
-	g.betRight(t, 0, 720)
+	g.betRight(t, 1, 860)
+	g.betRight(t, 2, 860)
+	g.betRight(t, 3, 860)
+	g.betRight(t, 4, 860)
+	g.betRight(t, 0, 860)
 	assert.Equal(t, 5, int(g.curRound))
 
 	result := <- resultC
@@ -92,3 +94,33 @@ func TestGame_GetScene(t *testing.T) {
 		log.L.Debug("player result", zap.Uint("player", i), zap.Uint64("result", change), zap.Bool("is add", isAdd))
 	}
 }
+
+// 赖子玩法的牌堆多一张赖子，看场景时标出赖子在自己的牌型中当成了哪张牌
+func TestGame_WildcardScene(t *testing.T) {
+	g := NewGame(TableLevel{ Xm: 10, Variant: VariantWildcard }, newFakePlayersHeadsUp(), &fakeMsgSender{}, make(chan *GameResult, 1))
+	assert.Len(t, g.cardHeap.(*PokerHeap).Pokers, 53)
+
+	mustPokers := func(strs ...string) []abstracts.Poker {
+		ps, err := stringsToAbsPokers(strs)
+		assert.NoError(t, err)
+		return ps
+	}
+	g.players[0].GotPokers(mustPokers("zz", "Ks"))
+	g.players[1].GotPokers(mustPokers("Qh", "Qd"))
+	g.commonPokers = mustPokers("Qs", "Js", "Ts", "4h", "5d")
+
+	getScene := func(uid string) *abstracts.GameScene {
+		resultC := make(chan *abstracts.GameScene, 1)
+		g.doGetScene(gameSceneMsg{ uid: uid, resultChan: resultC })
+		return <- resultC
+	}
+	scene := getScene("0")
+	assert.Equal(t, "zz", scene.Players["0"].Pokers[0].Whole)
+	assert.Equal(t, "As", scene.Players["0"].Pokers[0].As)
+	assert.Equal(t, "", scene.Players["0"].Pokers[1].As)
+	// 别人看不到我的手牌，也就看不到赖子当成了什么
+	assert.Len(t, getScene("1").Players["0"].Pokers, 0)
+
+	assert.Equal(t, int(hand_processor.HandOfHJTHS), g.handOf(g.players[0]).HandType())
+	assert.Equal(t, int(hand_processor.HandOfST3), g.handOf(g.players[1]).HandType())
+}
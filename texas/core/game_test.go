@@ -12,7 +12,7 @@ import (
 // 正常下注到结束
 func TestGame1(t *testing.T) {
 	resultC := make(chan *GameResult)
-	g := NewGame(10, newFakePlayersInTable1(), &fakeMsgSender{}, resultC)
+	g := NewGame(TableLevel{ Xm: 10 }, newFakePlayersInTable1(), &fakeMsgSender{}, resultC)
 	go g.Run()
 	time.Sleep(100 * time.Millisecond)
 
@@ -23,21 +23,22 @@ func TestGame1(t *testing.T) {
 	g.OnMsg(g.newPlayerActionMsg(3, abstracts.GameActionOfBet, 100))
 	g.OnMsg(g.newPlayerActionMsg(4, abstracts.GameActionOfBet, 100))
 	g.OnMsg(g.newPlayerActionMsg(0, abstracts.GameActionOfBet, 100))
-	g.OnMsg(g.newPlayerActionMsg(1, abstracts.GameActionOfBet, 100))
-	g.OnMsg(g.newPlayerActionMsg(2, abstracts.GameActionOfBet, 100))
+	// 每次加注至少加上一次加注的80
+	g.OnMsg(g.newPlayerActionMsg(1, abstracts.GameActionOfBet, 170))
+	g.OnMsg(g.newPlayerActionMsg(2, abstracts.GameActionOfBet, 240))
 	time.Sleep(100 * time.Millisecond)
 	assert.Equal(t, 1, int(g.curRound))
 	assert.Equal(t, 3, int(g.curBetPlayer))
-	g.betRight(t, 1, 110)
-	g.betRight(t, 2, 120)
+	g.betRight(t, 1, 180)
+	g.betRight(t, 2, 260)
 	g.betRight(t, 3, 100)
 	g.betRight(t, 4, 100)
 	g.betRight(t, 0, 100)
 	// 所有人下注到相同，进入下一轮
-	g.OnMsg(g.newPlayerActionMsg(3, abstracts.GameActionOfBet, 20))
-	g.OnMsg(g.newPlayerActionMsg(4, abstracts.GameActionOfBet, 20))
-	g.OnMsg(g.newPlayerActionMsg(0, abstracts.GameActionOfBet, 20))
-	g.OnMsg(g.newPlayerActionMsg(1, abstracts.GameActionOfBet, 10))
+	g.OnMsg(g.newPlayerActionMsg(3, abstracts.GameActionOfBet, 160))
+	g.OnMsg(g.newPlayerActionMsg(4, abstracts.GameActionOfBet, 160))
+	g.OnMsg(g.newPlayerActionMsg(0, abstracts.GameActionOfBet, 160))
+	g.OnMsg(g.newPlayerActionMsg(1, abstracts.GameActionOfBet, 80))
 	time.Sleep(100 * time.Millisecond)
 	assert.Equal(t, 2, int(g.curRound))
 	assert.Equal(t, 1, int(g.curBetPlayer))
@@ -70,11 +71,11 @@ func TestGame1(t *testing.T) {
 	g.OnMsg(g.newPlayerActionMsg(4, abstracts.GameActionOfBet, 300))
 	g.OnMsg(g.newPlayerActionMsg(0, abstracts.GameActionOfBet, 300))
 	time.Sleep(100 * time.Millisecond)
-	g.betRight(t, 1, 720)
-	g.betRight(t, 2, 720)
-	g.betRight(t, 3, 720)
-	g.betRight(t, 4, 720)
-	g.betRight(t, 0, 720)
+	g.betRight(t, 1, 860)
+	g.betRight(t, 2, 860)
+	g.betRight(t, 3, 860)
+	g.betRight(t, 4, 860)
+	g.betRight(t, 0, 860)
 	assert.Equal(t, 5, int(g.curRound))
 
 	result := <- resultC
@@ -91,7 +92,7 @@ func TestGame1(t *testing.T) {
 func TestGame2(t *testing.T) {
 	resultC := make(chan *GameResult)
 	// 2是1500，其他人都是2000，最后一轮2 all in，其他人不all in，但是超过1500，触发分池
-	g := NewGame(10, newFakePlayersInTable2(), &fakeMsgSender{}, resultC)
+	g := NewGame(TableLevel{ Xm: 10 }, newFakePlayersInTable2(), &fakeMsgSender{}, resultC)
 	go g.Run()
 	time.Sleep(100 * time.Millisecond)
 
@@ -125,9 +126,11 @@ func TestGame2(t *testing.T) {
 	// 从未弃牌的玩家开始
 	assert.Equal(t, 2, int(g.curBetPlayer))
 
-	g.OnMsg(g.newPlayerActionMsg(2, abstracts.GameActionOfBet, 1400))
+	// 加注至少要加一次all in的大小，因此2先过牌，3下注后2再all in跟不够
+	g.OnMsg(g.newPlayerActionMsg(2, abstracts.GameActionOfBet, 0))
 	g.OnMsg(g.newPlayerActionMsg(3, abstracts.GameActionOfBet, 1600))
 	g.OnMsg(g.newPlayerActionMsg(4, abstracts.GameActionOfBet, 1600))
+	g.OnMsg(g.newPlayerActionMsg(2, abstracts.GameActionOfBet, 1400))
 	time.Sleep(100 * time.Millisecond)
 	assert.Equal(t, 5, int(g.curRound))
 	// 从未弃牌的玩家开始。2 all in了，因此会从3开始
@@ -147,7 +150,7 @@ func TestGame2(t *testing.T) {
 func TestGame3(t *testing.T) {
 	resultC := make(chan *GameResult)
 	// 2是1500，其他人都是2000，最后一轮2 all in，其他人不all in，但是超过1500，触发分池
-	g := NewGame(10, newFakePlayersInTable2(), &fakeMsgSender{}, resultC)
+	g := NewGame(TableLevel{ Xm: 10 }, newFakePlayersInTable2(), &fakeMsgSender{}, resultC)
 	go g.Run()
 	time.Sleep(100 * time.Millisecond)
 
@@ -178,7 +181,7 @@ func TestGame3(t *testing.T) {
 func TestGame4(t *testing.T) {
 	resultC := make(chan *GameResult)
 	// 2是1500，其他人都是2000，最后一轮2 all in，其他人不all in，但是超过1500，触发分池
-	g := NewGame(10, newFakePlayersInTable2(), &fakeMsgSender{}, resultC)
+	g := NewGame(TableLevel{ Xm: 10 }, newFakePlayersInTable2(), &fakeMsgSender{}, resultC)
 	go g.Run()
 	time.Sleep(100 * time.Millisecond)
 
@@ -210,7 +213,7 @@ func TestGame4(t *testing.T) {
 func TestGame5(t *testing.T) {
 	resultC := make(chan *GameResult)
 	// 2是1500，其他人都是2000，最后一轮2 all in，其他人不all in，但是超过1500，触发分池
-	g := NewGame(10, newFakePlayersInTable2(), &fakeMsgSender{}, resultC)
+	g := NewGame(TableLevel{ Xm: 10 }, newFakePlayersInTable2(), &fakeMsgSender{}, resultC)
 	go g.Run()
 	time.Sleep(100 * time.Millisecond)
 
@@ -245,7 +248,7 @@ func TestGame6(t *testing.T) {
 	betTimeout = 200 * time.Millisecond
 	resultC := make(chan *GameResult)
 	// 2是1500，其他人都是2000，最后一轮2 all in，其他人不all in，但是超过1500，触发分池
-	g := NewGame(10, newFakePlayersInTable2(), &fakeMsgSender{}, resultC)
+	g := NewGame(TableLevel{ Xm: 10 }, newFakePlayersInTable2(), &fakeMsgSender{}, resultC)
 	go g.Run()
 	time.Sleep(50 * time.Millisecond)
 
@@ -301,7 +304,7 @@ func TestGame7(t *testing.T) {
 	betTimeout = 200 * time.Millisecond
 	resultC := make(chan *GameResult)
 	// 2是1500，其他人都是2000，最后一轮2 all in，其他人不all in，但是超过1500，触发分池
-	g := NewGame(10, newFakePlayersInTable2(), &fakeMsgSender{}, resultC)
+	g := NewGame(TableLevel{ Xm: 10 }, newFakePlayersInTable2(), &fakeMsgSender{}, resultC)
 	go g.Run()
 	time.Sleep(50 * time.Millisecond)
 
@@ -351,6 +354,382 @@ func TestGame7(t *testing.T) {
 	}
 }
 
+// 两个人时D是小盲，第一轮D先下注，后三轮大盲先下注
+func TestGameHeadsUp(t *testing.T) {
+	resultC := make(chan *GameResult)
+	g := NewGame(TableLevel{ Xm: 10 }, newFakePlayersHeadsUp(), &fakeMsgSender{}, resultC)
+	go g.Run()
+	time.Sleep(100 * time.Millisecond)
+
+	g.betRight(t, 0, 10)
+	g.betRight(t, 1, 20)
+	assert.Equal(t, 0, int(g.curBetPlayer))
+
+	g.OnMsg(g.newPlayerActionMsg(0, abstracts.GameActionOfBet, 10))
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 2, int(g.curRound))
+	assert.Equal(t, 1, int(g.curBetPlayer))
+
+	g.OnMsg(g.newPlayerActionMsg(1, abstracts.GameActionOfBet, 0))
+	g.OnMsg(g.newPlayerActionMsg(0, abstracts.GameActionOfBet, 0))
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 3, int(g.curRound))
+	assert.Equal(t, 1, int(g.curBetPlayer))
+
+	g.OnMsg(g.newPlayerActionMsg(1, abstracts.GameActionOfDiscard, 0))
+
+	result := <- resultC
+	assert.Equal(t, g.id, result.id)
+	change, isAdd := result.players[0].Result()
+	assert.Equal(t, 20, int(change))
+	assert.True(t, isAdd)
+}
+
+// 有前注时每人先下前注，前注不算第一轮的下注
+func TestGameAnte(t *testing.T) {
+	resultC := make(chan *GameResult)
+	g := NewGame(TableLevel{ Xm: 10, Dm: 25, Ante: 5 }, newFakePlayersInTable1(), &fakeMsgSender{}, resultC)
+	go g.Run()
+	time.Sleep(100 * time.Millisecond)
+
+	g.betRight(t, 0, 5)
+	g.betRight(t, 1, 15)
+	g.betRight(t, 2, 30)
+	g.betRight(t, 3, 5)
+	assert.Equal(t, 25, int(g.chipPool.maxBetAmountAt(1)))
+	assert.Equal(t, 3, int(g.curBetPlayer))
+
+	// 其他人都弃牌，大盲拿走所有前注和小盲
+	for _, i := range []uint{ 3, 4, 0, 1 } {
+		g.OnMsg(g.newPlayerActionMsg(i, abstracts.GameActionOfDiscard, 0))
+	}
+	result := <- resultC
+	change, isAdd := result.players[2].Result()
+	assert.Equal(t, 5 * 4 + 10, int(change))
+	assert.True(t, isAdd)
+}
+
+// 所有人下前注就all in了，直接比牌结束
+func TestGameAnteAllIn(t *testing.T) {
+	resultC := make(chan *GameResult)
+	g := NewGame(TableLevel{ Xm: 10, Ante: 50 }, map[uint]abstracts.Player{
+		0: newPlayerWithFakeUser(0, 50),
+		1: newPlayerWithFakeUser(1, 50),
+		2: newPlayerWithFakeUser(2, 50),
+	}, &fakeMsgSender{}, resultC)
+	go g.Run()
+
+	select {
+	case result := <- resultC:
+		var total uint64
+		for _, p := range result.players {
+			total += p.RemainChip() + p.(*Player).win
+		}
+		assert.Equal(t, 150, int(total))
+	case <- time.After(time.Second):
+		t.Fatal("game not finished")
+	}
+}
+
+// 取消后所有下注退回，每个人的结果都是0
+func TestGameCancel(t *testing.T) {
+	resultC := make(chan *GameResult)
+	g := NewGame(TableLevel{ Xm: 10 }, newFakePlayersInTable1(), &fakeMsgSender{}, resultC)
+	go g.Run()
+	time.Sleep(100 * time.Millisecond)
+
+	g.OnMsg(g.newPlayerActionMsg(3, abstracts.GameActionOfBet, 100))
+	time.Sleep(10 * time.Millisecond)
+	g.Cancel()
+
+	result := <- resultC
+	assert.True(t, result.cancelled)
+	for _, p := range result.players {
+		change, _ := p.Result()
+		assert.Equal(t, 0, int(change))
+	}
+	// 已经结束的局取消不会阻塞
+	g.Cancel()
+}
+
+// 结算后才轮到处理取消时什么都不做，不能再退一次注
+func TestGameCancelAfterSettle(t *testing.T) {
+	g := NewGame(TableLevel{ Xm: 10 }, newFakePlayersHeadsUp(), &fakeMsgSender{}, make(chan *GameResult, 1))
+	g.setPokers(t, []string{ "Kd", "Kc", "7d", "9h", "2c" }, []string{ "As", "Ah" }, []string{ "Ks", "7h" })
+	for i := uint(0); i < 2; i++ {
+		g.players[i].Bet(100)
+		assert.NoError(t, g.chipPool.bet(1, i, 100, false))
+	}
+	g.settle([][][]uint{ g.rankPlayers() }, nil, nil)
+	g.Cancel()
+	// cancelChan和stopChan都可读
+	g.loop()
+	g.cancel()
+
+	assert.False(t, g.cancelled)
+	var total uint64
+	for _, p := range g.players {
+		total += p.RemainChip() + p.(*Player).win
+	}
+	assert.Equal(t, 4000, int(total))
+	change, isAdd := g.players[1].Result()
+	assert.Equal(t, 100, int(change))
+	assert.True(t, isAdd)
+}
+
+// 奥马哈每人四张手牌，下注不能超过底池
+func TestGameOmaha(t *testing.T) {
+	resultC := make(chan *GameResult)
+	g := NewGame(TableLevel{ Xm: 10, Variant: VariantOmaha }, newFakePlayersHeadsUp(), &fakeMsgSender{}, resultC)
+	go g.Run()
+	time.Sleep(100 * time.Millisecond)
+	assert.Len(t, g.players[0].Pokers(), 4)
+	assert.Len(t, g.players[1].Pokers(), 4)
+
+	// 小盲跟注10后底池40，最多再加40，一共下50
+	assert.Equal(t, 50, int(g.potLimitBet(0)))
+	g.OnMsg(g.newPlayerActionMsg(0, abstracts.GameActionOfBet, 60))
+	time.Sleep(10 * time.Millisecond)
+	g.betRight(t, 0, 10)
+	g.OnMsg(g.newPlayerActionMsg(0, abstracts.GameActionOfBet, 50))
+	time.Sleep(10 * time.Millisecond)
+	g.betRight(t, 0, 60)
+	assert.Equal(t, 1, int(g.curBetPlayer))
+
+	// 大盲跟注40后底池120，最多下160
+	assert.Equal(t, 160, int(g.potLimitBet(1)))
+	g.OnMsg(g.newPlayerActionMsg(1, abstracts.GameActionOfBet, 40))
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, 2, int(g.curRound))
+	assert.Equal(t, 120, int(g.chipPool.potSize()))
+
+	for round := 2; round <= 4; round++ {
+		g.OnMsg(g.newPlayerActionMsg(1, abstracts.GameActionOfBet, 0))
+		time.Sleep(10 * time.Millisecond)
+		g.OnMsg(g.newPlayerActionMsg(0, abstracts.GameActionOfBet, 0))
+		time.Sleep(10 * time.Millisecond)
+	}
+	result := <- resultC
+	var total uint64
+	for _, p := range result.players {
+		total += p.RemainChip() + p.(*Player).win
+	}
+	assert.Equal(t, 4000, int(total))
+}
+
+// 固定限注每次只能加一注，前两轮小注后两轮大注，每轮加注次数有上限
+func TestGameFixedLimit(t *testing.T) {
+	resultC := make(chan *GameResult)
+	g := NewGame(TableLevel{ Xm: 10, Betting: BettingFixedLimit }, newFakePlayersHeadsUp(), &fakeMsgSender{}, resultC)
+	go g.Run()
+	time.Sleep(100 * time.Millisecond)
+	gs := []*Game{ g }
+
+	// 大盲算第一注，小盲只能跟到20或加到40
+	onGames(gs, 0, abstracts.GameActionOfBet, 50)
+	onGames(gs, 0, abstracts.GameActionOfBet, 5)
+	g.betRight(t, 0, 10)
+	onGames(gs, 0, abstracts.GameActionOfBet, 30)
+	onGames(gs, 1, abstracts.GameActionOfBet, 40)
+	onGames(gs, 0, abstracts.GameActionOfBet, 40)
+	g.betRight(t, 0, 80)
+	g.betRight(t, 1, 60)
+	assert.Equal(t, 4, g.raiseCount)
+	// 已经加到上限，只能跟注
+	onGames(gs, 1, abstracts.GameActionOfBet, 40)
+	g.betRight(t, 1, 60)
+	onGames(gs, 1, abstracts.GameActionOfBet, 20)
+	assert.Equal(t, 2, int(g.curRound))
+	assert.Equal(t, 0, g.raiseCount)
+
+	// 第二轮小注20
+	onGames(gs, 1, abstracts.GameActionOfBet, 40)
+	g.betRight(t, 1, 80)
+	onGames(gs, 1, abstracts.GameActionOfBet, 20)
+	onGames(gs, 0, abstracts.GameActionOfBet, 20)
+	assert.Equal(t, 3, int(g.curRound))
+
+	// 第三轮大注40
+	onGames(gs, 1, abstracts.GameActionOfBet, 20)
+	g.betRight(t, 1, 100)
+	onGames(gs, 1, abstracts.GameActionOfBet, 40)
+	onGames(gs, 0, abstracts.GameActionOfBet, 40)
+	assert.Equal(t, 4, int(g.curRound))
+	onGames(gs, 1, abstracts.GameActionOfBet, 0)
+	onGames(gs, 0, abstracts.GameActionOfBet, 0)
+
+	result := <- resultC
+	var total uint64
+	for _, p := range result.players {
+		total += p.RemainChip() + p.(*Player).win
+	}
+	assert.Equal(t, 4000, int(total))
+}
+
+// 任何下注结构都不能下得比跟注少，除非all in
+func TestGameBetNotEnoughToCall(t *testing.T) {
+	g := NewGame(TableLevel{ Xm: 10 }, newFakePlayersHeadsUp(), &fakeMsgSender{}, make(chan *GameResult, 1))
+	go g.Run()
+	time.Sleep(100 * time.Millisecond)
+
+	onGames([]*Game{ g }, 0, abstracts.GameActionOfBet, 5)
+	g.betRight(t, 0, 10)
+	assert.Equal(t, 0, int(g.curBetPlayer))
+	onGames([]*Game{ g }, 0, abstracts.GameActionOfBet, 1990)
+	assert.True(t, g.players[0].AllInned())
+	g.Cancel()
+}
+
+// 无限注和底池限注加注至少加上一次加注的大小，没人加过时至少一个大盲，all in不够时可以下，但不改变最小加注
+func TestGameMinRaise(t *testing.T) {
+	g := NewGame(TableLevel{ Xm: 10 }, map[uint]abstracts.Player{
+		0: newPlayerWithFakeUser(0, 2000),
+		1: newPlayerWithFakeUser(1, 90),
+		2: newPlayerWithFakeUser(2, 2000),
+	}, &fakeMsgSender{}, make(chan *GameResult, 1))
+	go g.Run()
+	time.Sleep(100 * time.Millisecond)
+	gs := []*Game{ g }
+
+	// 大盲20，最少加到40，被拒绝时筹码不动
+	onGames(gs, 0, abstracts.GameActionOfBet, 30)
+	g.betRight(t, 0, 0)
+	assert.Equal(t, 2000, int(g.players[0].RemainChip()))
+	onGames(gs, 0, abstracts.GameActionOfBet, 60)
+	g.betRight(t, 0, 60)
+	// 上一次加了40，小盲加20不够
+	onGames(gs, 1, abstracts.GameActionOfBet, 70)
+	g.betRight(t, 1, 10)
+	// all in加30可以，最小加注还是40
+	onGames(gs, 1, abstracts.GameActionOfBet, 80)
+	g.betRight(t, 1, 90)
+	assert.True(t, g.players[1].AllInned())
+	assert.Equal(t, 40, int(g.minRaise()))
+	onGames(gs, 2, abstracts.GameActionOfBet, 100)
+	g.betRight(t, 2, 20)
+	onGames(gs, 2, abstracts.GameActionOfBet, 110)
+	g.betRight(t, 2, 130)
+	g.Cancel()
+
+	// 底池限注一样有最小加注
+	g = NewGame(TableLevel{ Xm: 10, Betting: BettingPotLimit }, newFakePlayersHeadsUp(), &fakeMsgSender{}, make(chan *GameResult, 1))
+	go g.Run()
+	time.Sleep(100 * time.Millisecond)
+	onGames([]*Game{ g }, 0, abstracts.GameActionOfBet, 20)
+	g.betRight(t, 0, 10)
+	onGames([]*Game{ g }, 0, abstracts.GameActionOfBet, 30)
+	g.betRight(t, 0, 40)
+	g.Cancel()
+}
+
+// 开了发两次时，all in后先广播胜率，再发两次公共牌，池子平分
+func TestGameRunItTwice(t *testing.T) {
+	sender := &recordMsgSender{}
+	resultC := make(chan *GameResult)
+	g := NewGame(TableLevel{ Xm: 10, RunTimes: 2 }, newFakePlayersHeadsUp(), sender, resultC)
+	go g.Run()
+	time.Sleep(100 * time.Millisecond)
+
+	onGames([]*Game{ g }, 0, abstracts.GameActionOfBet, 1990)
+	g.OnMsg(g.newPlayerActionMsg(1, abstracts.GameActionOfBet, 1980))
+	result := <- resultC
+
+	equities := sender.get(abstracts.MsgTypeAllInEquity)
+	if assert.Len(t, equities, 1) {
+		resp := equities[0].(abstracts.AllInEquityResp)
+		assert.Equal(t, 1, int(resp.Round))
+		assert.Equal(t, 2, resp.RunTimes)
+		assert.Len(t, resp.Players, 2)
+		assert.InDelta(t, 1, resp.Players[0].Equity + resp.Players[1].Equity, 0.0001)
+	}
+	runouts := sender.get(abstracts.MsgTypeRunouts)
+	if assert.Len(t, runouts, 1) {
+		boards := runouts[0].(abstracts.RunoutsResp).Boards
+		assert.Len(t, boards, 2)
+		// 两次发的是牌堆中不同的牌
+		seen := map[string]bool{}
+		for _, board := range boards {
+			assert.Len(t, board, 5)
+			for _, p := range board {
+				assert.False(t, seen[p.Whole])
+				seen[p.Whole] = true
+			}
+		}
+	}
+	// all in后都亮牌，每次发的牌都有牌型
+	showdowns := sender.get(abstracts.MsgTypeShowdown)
+	if assert.Len(t, showdowns, 1) {
+		resp := showdowns[0].(abstracts.ShowdownResp)
+		assert.Equal(t, runouts[0].(abstracts.RunoutsResp).Boards, resp.Boards)
+		for _, p := range resp.Players {
+			assert.False(t, p.Mucked)
+			assert.Len(t, p.Runs, 2)
+		}
+	}
+	var total uint64
+	for _, p := range result.players {
+		total += p.RemainChip() + p.(*Player).win
+	}
+	assert.Equal(t, 4000, int(total))
+}
+
+// 只差一张牌时枚举牌堆中剩下的每一张
+func TestGameAllInEquity(t *testing.T) {
+	g := NewGame(TableLevel{ Xm: 10 }, newFakePlayersHeadsUp(), &fakeMsgSender{}, make(chan *GameResult, 1))
+	mustPokers := func(strs ...string) []abstracts.Poker {
+		ps, err := stringsToAbsPokers(strs)
+		assert.NoError(t, err)
+		return ps
+	}
+	g.players[0].GotPokers(mustPokers("As", "Ah"))
+	g.players[1].GotPokers(mustPokers("Ks", "Kh"))
+	g.commonPokers = mustPokers("2c", "7d", "9h", "Jc")
+	deck, err := stringsToPokers([]string{ "Kd", "3c", "4c", "5s" })
+	assert.NoError(t, err)
+	g.cardHeap = &PokerHeap{ Pokers: deck }
+
+	equity := g.allInEquity()
+	assert.InDelta(t, 0.75, equity[0], 0.0001)
+	assert.InDelta(t, 0.25, equity[1], 0.0001)
+
+	// 弃牌的人不算
+	g.players[1].Discard()
+	assert.Nil(t, g.allInEquity())
+}
+
+// 短牌只下前注和D盲注，由D左边的人先说话
+func TestGameShortDeckButtonBlind(t *testing.T) {
+	resultC := make(chan *GameResult)
+	g := NewGame(TableLevel{ Xm: 10, Ante: 10, ButtonBlind: 20, Variant: VariantShortDeck }, newFakePlayersInTable1(), &fakeMsgSender{}, resultC)
+	go g.Run()
+	time.Sleep(100 * time.Millisecond)
+
+	g.betRight(t, 0, 30)
+	g.betRight(t, 1, 10)
+	g.betRight(t, 2, 10)
+	assert.Equal(t, 20, int(g.chipPool.maxBetAmountAt(1)))
+	assert.Equal(t, 1, int(g.curBetPlayer))
+	assert.Len(t, g.cardHeap.(*PokerHeap).Pokers, 36 - 5 * 2)
+
+	for _, i := range []uint{ 1, 2, 3, 4 } {
+		g.OnMsg(g.newPlayerActionMsg(i, abstracts.GameActionOfBet, 20))
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, 2, int(g.curRound))
+	for round := 2; round <= 4; round++ {
+		for _, i := range []uint{ 1, 2, 3, 4, 0 } {
+			g.OnMsg(g.newPlayerActionMsg(i, abstracts.GameActionOfBet, 0))
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	result := <- resultC
+	var total uint64
+	for _, p := range result.players {
+		total += p.RemainChip() + p.(*Player).win
+	}
+	assert.True(t, total <= 2000 * 5 && total >= 2000 * 5 - 4)
+}
+
 // 断言用户在某一刻的下注数量是否正确
 func (g *Game) betRight(t *testing.T, player uint, shouldBe uint64) {
 	assert.Equal(t, int(shouldBe), int(g.players[player].HaveBet()))
@@ -0,0 +1,199 @@
+package hand_processor
+
+import (
+	"math/rand"
+	"os"
+	"runtime"
+	"sync"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+
+Analyst和Analyst2的正确性：
+1. 所有5张牌的组合（以及设置了HOLEHOLE_EXHAUSTIVE时所有7张牌的组合）每种牌型的个数和理论值一样
+2. 多加一张牌牌不会变小，换个顺序牌型和权重都不变
+
+Analyst只支持5张牌
+
+*/
+type handAnalyst interface {
+	setTotalPokerCount(tCount int)
+	doAnalysis(index int, poker *Poker)
+	exportHand() *Hand
+}
+
+var analysts = []struct {
+	name string
+	new func() handAnalyst
+	maxPokers int
+}{
+	{ "Analyst", func() handAnalyst { return DefaultAnalyst() }, 5 },
+	{ "Analyst2", func() handAnalyst { return DefaultAnalyst2() }, 7 },
+}
+
+// 下标就是Card，不用每次都解析字符串
+var cardPokers = func() (result []*Poker) {
+	for c := Card(0); c <= CardJoker; c++ {
+		p, _ := CardToPoker(c)
+		result = append(result, p)
+	}
+	return
+}()
+
+func analyze(analyst handAnalyst, cards []Card) *Hand {
+	analyst.setTotalPokerCount(len(cards))
+	for i, c := range cards {
+		analyst.doAnalysis(i, cardPokers[c])
+	}
+	return analyst.exportHand()
+}
+
+// 一副牌中每种牌型5张牌的组合数
+var fiveCardClassCounts = map[HandType]int{
+	HandOfHJTHS: 4,
+	HandOfTHS: 36,
+	HandOfST4: 624,
+	HandOfHL: 3744,
+	HandOfTH: 5108,
+	HandOfSZ: 10200,
+	HandOfST3: 54912,
+	HandOfLD: 123552,
+	HandOfYD: 1098240,
+	HandOfDZ: 1302540,
+}
+
+// 7张牌中最大的5张是每种牌型的组合数
+var sevenCardClassCounts = map[HandType]int{
+	HandOfHJTHS: 4324,
+	HandOfTHS: 37260,
+	HandOfST4: 224848,
+	HandOfHL: 3473184,
+	HandOfTH: 4047644,
+	HandOfSZ: 6180020,
+	HandOfST3: 6461620,
+	HandOfLD: 31433400,
+	HandOfYD: 58627800,
+	HandOfDZ: 23294460,
+}
+
+func TestAnalyst_FiveCardClassCounts(t *testing.T) {
+	if testing.Short() {
+		t.Skip("enumerating all 2598960 hands twice")
+	}
+	for _, a := range analysts {
+		counts := map[HandType]int{}
+		forEachCardCombination(5, func(cards []Card) {
+			counts[analyze(a.new(), cards).GetHandType()]++
+		})
+		assert.Equal(t, fiveCardClassCounts, counts, a.name)
+	}
+}
+
+/*
+
+1.3亿种组合，很慢，设置了HOLEHOLE_EXHAUSTIVE才跑
+查表的牌型个数也一起数
+
+*/
+func TestAnalyst2_SevenCardClassCounts(t *testing.T) {
+	if os.Getenv("HOLEHOLE_EXHAUSTIVE") == "" {
+		t.Skip("set HOLEHOLE_EXHAUSTIVE=1 to count all 7 card combinations")
+	}
+	var lock sync.Mutex
+	counts, lookupCounts := map[HandType]int{}, map[HandType]int{}
+	var wg sync.WaitGroup
+	firsts := make(chan int)
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mine, lookup := map[HandType]int{}, map[HandType]int{}
+			for first := range firsts {
+				cards := make([]Card, 7)
+				cards[0] = Card(first)
+				forEachCardCombinationFrom(first + 1, cards, 1, func(cards []Card) {
+					mine[analyze(DefaultAnalyst2(), cards).GetHandType()]++
+					lookup[EvaluateCards(cards).GetHandType()]++
+				})
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			for k, v := range mine {
+				counts[k] += v
+			}
+			for k, v := range lookup {
+				lookupCounts[k] += v
+			}
+		}()
+	}
+	for first := 0; first < 52; first++ {
+		firsts <- first
+	}
+	close(firsts)
+	wg.Wait()
+	assert.Equal(t, sevenCardClassCounts, counts)
+	assert.Equal(t, sevenCardClassCounts, lookupCounts)
+}
+
+func randomCards(rnd *rand.Rand, count int, withLz bool) []Card {
+	deck := 52
+	if withLz {
+		deck++
+	}
+	var cards []Card
+	for _, c := range rnd.Perm(deck)[:count] {
+		cards = append(cards, Card(c))
+	}
+	return cards
+}
+
+// 多加一张牌，牌型不会变小
+func TestAnalyst_AddCardNeverLowers(t *testing.T) {
+	rnd := rand.New(rand.NewSource(3))
+	for _, a := range analysts {
+		for n := 0; n < 20000; n++ {
+			withLz := a.name == "Analyst2" && n % 4 == 0
+			cards := randomCards(rnd, a.maxPokers, withLz)
+			// 前count张和多一张的牌型比
+			for count := 5; count < a.maxPokers; count++ {
+				less := analyze(a.new(), cards[:count])
+				more := analyze(a.new(), cards[:count + 1])
+				if more.Match(less) == 2 {
+					t.Fatalf("%v: %v (%v) is lower than %v (%v)", a.name, PokersToString(more.GetOriginPokers()), more.GetHandType(), PokersToString(less.GetOriginPokers()), less.GetHandType())
+				}
+			}
+		}
+	}
+}
+
+// 多加一张赖子，牌型不会变小
+func TestAnalyst2_AddLzNeverLowers(t *testing.T) {
+	rnd := rand.New(rand.NewSource(4))
+	for n := 0; n < 20000; n++ {
+		cards := randomCards(rnd, 6, false)
+		less := analyze(DefaultAnalyst2(), cards)
+		more := analyze(DefaultAnalyst2(), append(cards, CardJoker))
+		if more.Match(less) == 2 {
+			t.Fatalf("%v (%v) is lower than %v (%v)", PokersToString(more.GetOriginPokers()), more.GetHandType(), PokersToString(less.GetOriginPokers()), less.GetHandType())
+		}
+	}
+}
+
+// 换个顺序，牌型和权重都不变
+func TestAnalyst_OrderIndependent(t *testing.T) {
+	rnd := rand.New(rand.NewSource(5))
+	for _, a := range analysts {
+		for n := 0; n < 20000; n++ {
+			cards := randomCards(rnd, a.maxPokers, a.name == "Analyst2" && n % 4 == 0)
+			hand := analyze(a.new(), cards)
+			shuffled := append([]Card{}, cards...)
+			rnd.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+			other := analyze(a.new(), shuffled)
+			if hand.GetHandType() != other.GetHandType() || hand.GetWeight() != other.GetWeight() {
+				t.Fatalf("%v: %v (%v %v) and %v (%v %v)", a.name, PokersToString(hand.GetOriginPokers()), hand.GetHandType(), hand.GetWeight(), PokersToString(other.GetOriginPokers()), other.GetHandType(), other.GetWeight())
+			}
+		}
+	}
+}
@@ -7,7 +7,7 @@ import (
 )
 
 //go:generate stringer -type=HandType
-// 手牌类型（从单张开始，到皇家同花顺）
+// 手牌类型（从单张开始，到皇家同花顺，带赖子时还有五条）
 type HandType int
 const (
 	HandOfDZ HandType = iota
@@ -20,10 +20,14 @@ const (
 	HandOfST4
 	HandOfTHS
 	HandOfHJTHS
+	// 五条，只有带赖子才可能出现，比皇家同花顺大
+	HandOfWT
 )
 
 func (i HandType)CnString() string {
 	switch i {
+	case HandOfWT:
+		return "五条"
 	case HandOfHJTHS:
 		return "皇家同花顺"
 	case HandOfTHS:
@@ -55,6 +59,10 @@ type Hand struct {
 	pokers []*Poker
 	handType HandType
 	weight int
+	// 短牌的牌，同花比葫芦大
+	shortDeck bool
+	// 赖子当成了哪张牌，没有用到赖子时为nil
+	lzAs *Poker
 }
 
 func (hand *Hand) GetWeight() int {
@@ -69,6 +77,9 @@ func (hand *Hand) GetSortedPokers() []*Poker {
 func (hand *Hand) GetHandType() HandType {
 	return hand.handType
 }
+func (hand *Hand) GetLzAs() *Poker {
+	return hand.lzAs
+}
 // 牌型和权重一样大才是相等
 func (hand *Hand) EqualsTo(otherH *Hand) bool {
 	return hand.weight == otherH.weight && hand.handType == otherH.handType
@@ -107,9 +118,9 @@ type IAnalyst interface {
 	exportHand()(h *Hand)
 }
 
-// 将手牌转换成字符串手牌
+// 将手牌转换成字符串手牌，用原始的牌，HandStrToHand可以转回来
 func (hand *Hand)HandToHandStr() *string {
-	var result string
+	result := PokersToString(hand.originPokers)
 	return &result
 }
 
@@ -215,7 +226,7 @@ func HandsCollectionToString(hands map[int][]*Hand) string {
 // 比较两个牌的大小，otherH大返回2，hand大返回1，相等返回0
 func (hand *Hand) Match(otherH *Hand) int {
 	// 如果牌型一样才比较权重，type越大代表牌型越大
-	if hand.handType == otherH.handType {
+	if hand.rank() == otherH.rank() {
 		if hand.weight == otherH.weight {
 			return 0
 		}else if hand.weight > otherH.weight {
@@ -223,14 +234,27 @@ func (hand *Hand) Match(otherH *Hand) int {
 		}else {
 			return 2
 		}
-	}else if hand.handType > otherH.handType {
+	}else if hand.rank() > otherH.rank() {
 		return 1
 	}else {
 		return 2
 	}
 }
 
+// 用于比较大小的牌型，越大牌型越大。短牌中同花和葫芦的顺序是反的，要拿真正的牌型用GetHandType
 func (hand *Hand) HandType() int {
+	return hand.rank()
+}
+
+func (hand *Hand) rank() int {
+	if hand.shortDeck {
+		switch hand.handType {
+		case HandOfTH:
+			return int(HandOfHL)
+		case HandOfHL:
+			return int(HandOfTH)
+		}
+	}
 	return int(hand.handType)
 }
 
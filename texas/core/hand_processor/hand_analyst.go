@@ -498,9 +498,9 @@ func (analyst *Analyst)weightOfTHS() {
 	analyst.weightOfSZ()
 }
 
-// 判断是否是皇家同花顺
+// 判断是否是皇家同花顺，A2345的同花顺也有A，要从T开始才是
 func isHJTHS(analyst *Analyst) (settedQz bool) {
-	if analyst.haveA {
+	if analyst.haveA && analyst.checkedPokers[0].face == "T" {
 		analyst.handType = HandOfHJTHS
 		// 设置权重
 		settedQz = true
@@ -646,16 +646,10 @@ func checkSZ(analyst *Analyst2) {
 func (analyst *Analyst2)weightOfSZ() {
 	weight := 0
 	// 只用看最后一张的大小即可
-	if analyst.haveA {
-		tmpFaces := ""
-		for _, p := range analyst.tmpPokers {
-			tmpFaces += p.face
-		}
-		// 如果是该特殊顺子，其是最小的顺子，因此权重为0就行了
-		if tmpFaces == "2345A" {
-			analyst.handWeight = 0
-			return
-		}
+	// A2345是最小的顺子，因此权重为0就行了。要看结果牌（A排在最前）而不是所有牌，2345A加赖子是23456
+	if analyst.checkedPokers[0].face == "A" {
+		analyst.handWeight = 0
+		return
 	}
 	p := analyst.checkedPokers[len(analyst.checkedPokers) - 1]
 	weight += faceWeightMulti(p.face, 1)
@@ -826,21 +820,11 @@ func checkTZ4(analyst *Analyst2) {
 	var bigStr string
 	tz4Len := len(analyst.allTZ4)
 	if analyst.haveLz {
-		tz3Len := len(analyst.allTZ3)
-		if tz3Len > 0 && tz4Len > 0 {
-			tmp3 := analyst.allTZ3[tz3Len - 1]
-			tmp4 := analyst.allTZ4[tz4Len - 1]
-			if isFirstFaceSmall(tmp3, tmp4) {
-				bigStr = tmp4
-			}else{
-				bigStr = tmp3
-			}
+		// 四条加赖子是五条，比任何四条都大
+		if tz4Len > 0 {
+			bigStr = analyst.allTZ4[tz4Len - 1]
 		}else {
-			if tz4Len > 0 {
-				bigStr = analyst.allTZ4[tz4Len - 1]
-			}else {
-				bigStr = analyst.allTZ3[tz3Len - 1]
-			}
+			bigStr = analyst.allTZ3[len(analyst.allTZ3) - 1]
 		}
 	}else {
 		bigStr = analyst.allTZ4[tz4Len - 1]
@@ -875,6 +859,9 @@ func checkTZ4(analyst *Analyst2) {
 	}
 	analyst.moreThanOnes = []string{bigStr}
 	analyst.handType = HandOfST4
+	if analyst.haveLz && tz4Len > 0 {
+		analyst.handType = HandOfWT
+	}
 	analyst.next = -1
 	analyst.weightOfST4()
 }
@@ -1087,12 +1074,12 @@ func checkIsSZ2WithLz(pokers []*Poker) (int, []*Poker) {
 	if lzFace == "" {
 		// 如果没有用赖子，则首选放在结尾，如果结尾是A，那么就放在开头
 		if maxPoker.face == "A" {
-			lzP.face = "T"
+			lzP.changeLzFace("T")
 		}else {
-			lzP.face = maxPoker.nextFace()
+			lzP.changeLzFace(maxPoker.nextFace())
 		}
 	}else {
-		lzP.face = lzFace
+		lzP.changeLzFace(lzFace)
 	}
 	pokers = sortAppendPokerWithoutSeem(pokers, lzP)
 	return -1, pokers
@@ -1175,6 +1162,7 @@ func (analyst *Analyst2)exportHand() *Hand {
 	if analyst.haveLz {
 		for index, p := range analyst.checkedPokers {
 			if p.bornByLz {
+				hand.lzAs = p
 				tmp := analyst.checkedPokers
 				rear := append([]*Poker{}, tmp[(index + 1):]...)
 				tmp = append(tmp[:index], newLz())
@@ -0,0 +1,195 @@
+package hand_processor
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+)
+
+/*
+
+Hand的序列化
+牌用Card编码：点数*4+花色（点数和花色都按facesStr、colorsStr的顺序），赖子是CardJoker，这个编码不会再变
+JSON和二进制都会带上原始的牌、组成牌型的5张牌、牌型、权重、是否短牌和赖子当成了哪张牌，解出来的Hand和原来的一样
+
+二进制格式：版本，牌型，标志位（1短牌，2有赖子），权重（varint），赖子当成的牌（有赖子时），原始牌数及每张牌，牌型牌数及每张牌
+
+*/
+
+const (
+	// 赖子
+	CardJoker Card = 52
+	// Hand中为nil的牌
+	cardNil Card = 0xff
+
+	handBinaryVersion = 1
+	handFlagShortDeck = 1
+	handFlagLz = 2
+)
+
+// 转成Card，赖子是CardJoker，赖子替的牌按替成的牌算
+func (poker *Poker) Card() Card {
+	if poker == nil {
+		return cardNil
+	}
+	if poker.face == laiZiStr {
+		return CardJoker
+	}
+	c, _ := CardOf(poker.whole)
+	return c
+}
+
+func CardToPoker(c Card) (*Poker, error) {
+	switch {
+	case c == cardNil:
+		return nil, nil
+	case c == CardJoker:
+		return newLz(), nil
+	case c < CardJoker:
+		return NewPoker(facesStr[c.Rank():c.Rank() + 1], colorsStr[c.Suit():c.Suit() + 1]), nil
+	}
+	return nil, errors.New("不合法的牌")
+}
+
+func pokersToCards(pokers []*Poker) []int {
+	result := []int{}
+	for _, p := range pokers {
+		result = append(result, int(p.Card()))
+	}
+	return result
+}
+
+func cardsToPokers(cards []int) ([]*Poker, error) {
+	var result []*Poker
+	for _, c := range cards {
+		if c < 0 || c > int(cardNil) {
+			return nil, errors.New("不合法的牌")
+		}
+		p, err := CardToPoker(Card(c))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, p)
+	}
+	return result, nil
+}
+
+type handJSON struct {
+	Origin []int `json:"origin"`
+	Pokers []int `json:"pokers"`
+	HandType HandType `json:"hand_type"`
+	Weight int `json:"weight"`
+	ShortDeck bool `json:"short_deck,omitempty"`
+	LzAs *int `json:"lz_as,omitempty"`
+}
+
+func (hand *Hand) MarshalJSON() ([]byte, error) {
+	h := &handJSON{
+		Origin: pokersToCards(hand.originPokers),
+		Pokers: pokersToCards(hand.pokers),
+		HandType: hand.handType,
+		Weight: hand.weight,
+		ShortDeck: hand.shortDeck,
+	}
+	if hand.lzAs != nil {
+		lzAs := int(hand.lzAs.Card())
+		h.LzAs = &lzAs
+	}
+	return json.Marshal(h)
+}
+
+func (hand *Hand) UnmarshalJSON(data []byte) error {
+	var h handJSON
+	if err := json.Unmarshal(data, &h); err != nil {
+		return err
+	}
+	var lzAs []int
+	if h.LzAs != nil {
+		lzAs = []int{ *h.LzAs }
+	}
+	return hand.setEncoded(h.Origin, h.Pokers, h.HandType, h.Weight, h.ShortDeck, lzAs)
+}
+
+func (hand *Hand) MarshalBinary() ([]byte, error) {
+	var flags byte
+	if hand.shortDeck {
+		flags |= handFlagShortDeck
+	}
+	if hand.lzAs != nil {
+		flags |= handFlagLz
+	}
+	result := []byte{ handBinaryVersion, byte(hand.handType), flags }
+	buf := make([]byte, binary.MaxVarintLen64)
+	result = append(result, buf[:binary.PutVarint(buf, int64(hand.weight))]...)
+	if hand.lzAs != nil {
+		result = append(result, byte(hand.lzAs.Card()))
+	}
+	for _, pokers := range [][]*Poker{ hand.originPokers, hand.pokers } {
+		if len(pokers) > 0xff {
+			return nil, errors.New("牌太多了")
+		}
+		result = append(result, byte(len(pokers)))
+		for _, p := range pokers {
+			result = append(result, byte(p.Card()))
+		}
+	}
+	return result, nil
+}
+
+func (hand *Hand) UnmarshalBinary(data []byte) error {
+	if len(data) < 3 || data[0] != handBinaryVersion {
+		return errors.New("不支持的手牌格式")
+	}
+	handType, flags := HandType(data[1]), data[2]
+	weight, n := binary.Varint(data[3:])
+	if n <= 0 {
+		return errors.New("手牌权重不合法")
+	}
+	data = data[3 + n:]
+	var lzAs []int
+	if flags & handFlagLz != 0 {
+		if len(data) < 1 {
+			return errors.New("手牌数据不完整")
+		}
+		lzAs = []int{ int(data[0]) }
+		data = data[1:]
+	}
+	var cards [2][]int
+	for i := range cards {
+		if len(data) < 1 || len(data) < 1 + int(data[0]) {
+			return errors.New("手牌数据不完整")
+		}
+		cards[i] = []int{}
+		for _, c := range data[1:1 + int(data[0])] {
+			cards[i] = append(cards[i], int(c))
+		}
+		data = data[1 + int(data[0]):]
+	}
+	if len(data) != 0 {
+		return errors.New("手牌数据后边有多余的字节")
+	}
+	return hand.setEncoded(cards[0], cards[1], handType, int(weight), flags & handFlagShortDeck != 0, lzAs)
+}
+
+func (hand *Hand) setEncoded(origin []int, pokers []int, handType HandType, weight int, shortDeck bool, lzAs []int) error {
+	if handType < HandOfDZ || handType > HandOfWT {
+		return errors.New("不合法的牌型")
+	}
+	var err error
+	result := &Hand{ handType: handType, weight: weight, shortDeck: shortDeck }
+	if result.originPokers, err = cardsToPokers(origin); err != nil {
+		return err
+	}
+	if result.pokers, err = cardsToPokers(pokers); err != nil {
+		return err
+	}
+	if len(lzAs) > 0 {
+		if lzAs[0] < 0 || lzAs[0] >= int(CardJoker) {
+			return errors.New("赖子当成的牌不合法")
+		}
+		p, _ := CardToPoker(Card(lzAs[0]))
+		result.lzAs = p.bornALaiZi()
+	}
+	*hand = *result
+	return nil
+}
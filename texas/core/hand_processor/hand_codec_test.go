@@ -0,0 +1,93 @@
+package hand_processor
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPokerCard(t *testing.T) {
+	for c := Card(0); c <= CardJoker; c++ {
+		p, err := CardToPoker(c)
+		assert.NoError(t, err)
+		assert.Equal(t, c, p.Card())
+		assert.Equal(t, c.String(), p.GetWhole())
+	}
+	assert.Equal(t, Card(0), PokerStrToPoker("2s").Card())
+	assert.Equal(t, Card(51), PokerStrToPoker("Ac").Card())
+	assert.Equal(t, CardJoker, PokerStrToPoker("Xn").Card())
+	_, err := CardToPoker(53)
+	assert.Error(t, err)
+}
+
+func TestHand_HandToHandStr(t *testing.T) {
+	hand := mustHand(t, "KsKh7dKc7s2h3d")
+	assert.Equal(t, "KsKh7dKc7s2h3d", *hand.HandToHandStr())
+	assert.Equal(t, 0, hand.Match(mustHand(t, *hand.HandToHandStr())))
+}
+
+func TestHand_Marshal(t *testing.T) {
+	short, err := ShortDeckHandStrToHand("As6h7d8c9s")
+	assert.NoError(t, err)
+	for _, hand := range []*Hand{
+		mustHand(t, "KsKh7dKc7s2h3d"),
+		mustHand(t, "zzAsAhAdAc2h3d"),
+		mustHand(t, "zz9s8s7s5s2h3d"),
+		mustHand(t, "As2h3d4c5s"),
+		short,
+	} {
+		data, err := json.Marshal(hand)
+		assert.NoError(t, err)
+		var fromJSON Hand
+		assert.NoError(t, json.Unmarshal(data, &fromJSON))
+		assert.Equal(t, hand, &fromJSON, string(data))
+
+		data, err = hand.MarshalBinary()
+		assert.NoError(t, err)
+		var fromBinary Hand
+		assert.NoError(t, fromBinary.UnmarshalBinary(data))
+		assert.Equal(t, hand, &fromBinary)
+		assert.Equal(t, 0, hand.Match(&fromBinary))
+	}
+
+	data, _ := json.Marshal(mustHand(t, "zzAsAhAdAc2h3d"))
+	assert.Contains(t, string(data), `"lz_as":`)
+	full := mustHand(t, "KsKh7dKc7s")
+	data, _ = full.MarshalBinary()
+	// 版本，牌型，标志位，权重，两组各5张牌
+	assert.Len(t, data, 3 + binary.PutVarint(make([]byte, binary.MaxVarintLen64), int64(full.weight)) + 6 + 6)
+
+	var hand Hand
+	assert.Error(t, hand.UnmarshalBinary(nil))
+	assert.Error(t, hand.UnmarshalBinary(data[:len(data) - 1]))
+	assert.Error(t, hand.UnmarshalBinary(append(data, 0)))
+	assert.Error(t, json.Unmarshal([]byte(`{"origin":[60],"pokers":[],"hand_type":0}`), &hand))
+	assert.Error(t, json.Unmarshal([]byte(`{"origin":[],"pokers":[],"hand_type":11}`), &hand))
+	assert.Error(t, json.Unmarshal([]byte(`{"origin":[],"pokers":[],"hand_type":0,"lz_as":52}`), &hand))
+}
+
+func TestHand_Description(t *testing.T) {
+	short, _ := ShortDeckHandStrToHand("As6h7d8c9s")
+	for _, c := range []struct {
+		hand *Hand
+		en string
+		cn string
+	}{
+		{ mustHand(t, "KsKh7dKc7s2h3d"), "Full house, Kings full of Sevens", "葫芦，三条K带一对7" },
+		{ mustHand(t, "zzAsAhAdAc2h3d"), "Five of a kind, Aces", "五条A" },
+		{ mustHand(t, "AsKsQsJsTs2h3d"), "Royal flush", "皇家同花顺" },
+		{ mustHand(t, "9s8s7s6s5s2h3d"), "Straight flush, Nine high", "同花顺，9最大" },
+		{ mustHand(t, "6s6h6d6c9s2h3d"), "Four of a kind, Sixes", "四条6" },
+		{ mustHand(t, "As9s7s4s2s2h3d"), "Flush, Ace high", "同花，A最大" },
+		{ mustHand(t, "As2h3d4c5s"), "Straight, Five high", "顺子，5最大" },
+		{ short, "Straight, Nine high", "顺子，9最大" },
+		{ mustHand(t, "QsQhQdAs2c"), "Three of a kind, Queens", "三条Q" },
+		{ mustHand(t, "JsJh4d4sAc"), "Two pair, Jacks and Fours", "两对，J和4" },
+		{ mustHand(t, "TsTh4d3sAc"), "Pair of Tens", "一对T" },
+		{ mustHand(t, "As9h7d4s2c"), "High card, Ace high", "单张，A最大" },
+	} {
+		assert.Equal(t, c.en, c.hand.Description())
+		assert.Equal(t, c.cn, c.hand.CnDescription())
+	}
+}
@@ -0,0 +1,128 @@
+package hand_processor
+
+import (
+	"sort"
+	"strings"
+)
+
+var rankNames = []string{ "Two", "Three", "Four", "Five", "Six", "Seven", "Eight", "Nine", "Ten", "Jack", "Queen", "King", "Ace" }
+
+func rankPlural(rank int) string {
+	if rank == 4 {
+		return "Sixes"
+	}
+	return rankNames[rank] + "s"
+}
+
+func (i HandType) EnString() string {
+	switch i {
+	case HandOfWT:
+		return "Five of a kind"
+	case HandOfHJTHS:
+		return "Royal flush"
+	case HandOfTHS:
+		return "Straight flush"
+	case HandOfST4:
+		return "Four of a kind"
+	case HandOfHL:
+		return "Full house"
+	case HandOfTH:
+		return "Flush"
+	case HandOfSZ:
+		return "Straight"
+	case HandOfST3:
+		return "Three of a kind"
+	case HandOfLD:
+		return "Two pair"
+	case HandOfYD:
+		return "One pair"
+	case HandOfDZ:
+		return "High card"
+	}
+	return ""
+}
+
+// 组成牌型的5张牌的点数，张数多的在前，一样多的大的在前，赖子按替成的牌算
+func (hand *Hand) describedRanks() (ranks []int) {
+	counts := map[int]int{}
+	for _, p := range hand.pokers {
+		if p == nil {
+			continue
+		}
+		if p.face == laiZiStr {
+			if hand.lzAs == nil {
+				continue
+			}
+			p = hand.lzAs
+		}
+		r := strings.Index(facesStr, p.face)
+		if counts[r] == 0 {
+			ranks = append(ranks, r)
+		}
+		counts[r]++
+	}
+	sort.Slice(ranks, func(i, j int) bool {
+		if counts[ranks[i]] != counts[ranks[j]] {
+			return counts[ranks[i]] > counts[ranks[j]]
+		}
+		return ranks[i] > ranks[j]
+	})
+	// A2345时5最大，短牌A6789时9最大
+	if (hand.handType == HandOfSZ || hand.handType == HandOfTHS) && len(ranks) == 5 && ranks[0] == 12 && ranks[1] != 11 {
+		ranks = append(ranks[1:], 12)
+	}
+	return
+}
+
+// 英文描述，如"Full house, Kings full of Sevens"
+func (hand *Hand) Description() string {
+	ranks := hand.describedRanks()
+	if len(ranks) == 0 {
+		return hand.handType.EnString()
+	}
+	switch hand.handType {
+	case HandOfWT, HandOfST4, HandOfST3:
+		return hand.handType.EnString() + ", " + rankPlural(ranks[0])
+	case HandOfHL:
+		if len(ranks) > 1 {
+			return "Full house, " + rankPlural(ranks[0]) + " full of " + rankPlural(ranks[1])
+		}
+	case HandOfLD:
+		if len(ranks) > 1 {
+			return "Two pair, " + rankPlural(ranks[0]) + " and " + rankPlural(ranks[1])
+		}
+	case HandOfYD:
+		return "Pair of " + rankPlural(ranks[0])
+	case HandOfTHS, HandOfTH, HandOfSZ, HandOfDZ:
+		return hand.handType.EnString() + ", " + rankNames[ranks[0]] + " high"
+	}
+	return hand.handType.EnString()
+}
+
+// 中文描述，如"葫芦，三条K带一对7"
+func (hand *Hand) CnDescription() string {
+	ranks := hand.describedRanks()
+	if len(ranks) == 0 {
+		return hand.handType.CnString()
+	}
+	face := func(i int) string {
+		return facesStr[ranks[i]:ranks[i] + 1]
+	}
+	switch hand.handType {
+	case HandOfWT, HandOfST4, HandOfST3:
+		return hand.handType.CnString() + face(0)
+	case HandOfHL:
+		if len(ranks) > 1 {
+			return "葫芦，三条" + face(0) + "带一对" + face(1)
+		}
+	case HandOfLD:
+		if len(ranks) > 1 {
+			return "两对，" + face(0) + "和" + face(1)
+		}
+	case HandOfYD:
+		return "一对" + face(0)
+	case HandOfTHS, HandOfTH, HandOfSZ, HandOfDZ:
+		return hand.handType.CnString() + "，" + face(0) + "最大"
+	}
+	return hand.handType.CnString()
+}
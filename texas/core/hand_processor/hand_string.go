@@ -4,9 +4,9 @@ package hand_processor
 
 import "strconv"
 
-const _HandType_name = "HandOfDZHandOfYDHandOfLDHandOfST3HandOfSZHandOfTHHandOfHLHandOfST4HandOfTHSHandOfHJTHS"
+const _HandType_name = "HandOfDZHandOfYDHandOfLDHandOfST3HandOfSZHandOfTHHandOfHLHandOfST4HandOfTHSHandOfHJTHSHandOfWT"
 
-var _HandType_index = [...]uint8{0, 8, 16, 24, 33, 41, 49, 57, 66, 75, 86}
+var _HandType_index = [...]uint8{0, 8, 16, 24, 33, 41, 49, 57, 66, 75, 86, 94}
 
 func (i HandType) String() string {
 	if i < 0 || i >= HandType(len(_HandType_index)-1) {
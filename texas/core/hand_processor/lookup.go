@@ -0,0 +1,320 @@
+package hand_processor
+
+import (
+	"errors"
+	"strings"
+)
+
+/*
+
+查表的牌型估值器，5到7张牌，不支持赖子
+牌用0到51的整数表示：点数*4+花色，点数0是2，12是A，花色按colorsStr的顺序
+结果HandRank可以直接比大小，越大牌越大：20位以上是牌型（和HandType一致），低20位是决定大小的5个点数（每个4位）
+
+同花时按该花色的点数掩码查flushTable（同花和葫芦、四条在7张牌中不可能同时出现）
+否则把每个点数有几张完美哈希成连续的下标查nonFlushTable
+两张表都在init时生成，Analyst2作为参照实现，两者的比较结果必须一致
+
+*/
+type Card uint8
+
+type HandRank uint32
+
+const (
+	// 每个点数最多4张，最多7张牌
+	maxCardsToRank = 7
+	rankTypeShift = 20
+)
+
+var (
+	// 下标是某个花色的点数掩码，只有5张以上的才有值
+	flushTable [1 << 13]HandRank
+	// 下标是点数掩码，值为顺子最大的点数+1，不是顺子为0
+	straightTable [1 << 13]uint8
+	// nonFlushTable[k]是k张牌时，按点数个数哈希后的牌型
+	nonFlushTable [maxCardsToRank + 1][]HandRank
+	// 完美哈希用的偏移：k张牌，第r个点数之前已经有before张，第r个点数有c张时下标要加多少
+	rankOffsets [maxCardsToRank + 1][13][maxCardsToRank + 1][5]uint32
+)
+
+func init() {
+	initStraightTable()
+	initFlushTable()
+	initNonFlushTable()
+}
+
+// 解析一张牌，如"As"
+func CardOf(str string) (Card, error) {
+	if len(str) != 2 {
+		return 0, errors.New("牌必须是两个字符")
+	}
+	rank := strings.Index(facesStr, str[0:1])
+	suit := strings.Index(colorsStr, str[1:2])
+	if rank < 0 || suit < 0 {
+		return 0, errors.New("不合法的牌: " + str)
+	}
+	return Card(rank * 4 + suit), nil
+}
+
+// 解析一组牌，如"AsKd2c"，不能有重复的牌
+func CardsOf(str string) ([]Card, error) {
+	if len(str) % 2 != 0 {
+		return nil, errors.New("手牌字符串长度必须是2的倍数")
+	}
+	var result []Card
+	var seen uint64
+	for i := 0; i < len(str); i += 2 {
+		c, err := CardOf(str[i:i + 2])
+		if err != nil {
+			return nil, err
+		}
+		if seen & (1 << c) != 0 {
+			return nil, errors.New("同一手牌中出现了两张一样的牌")
+		}
+		seen |= 1 << c
+		result = append(result, c)
+	}
+	return result, nil
+}
+
+func (c Card) Rank() int {
+	return int(c) / 4
+}
+
+func (c Card) Suit() int {
+	return int(c) % 4
+}
+
+func (c Card) String() string {
+	if c == CardJoker {
+		return LzWhole
+	}
+	return facesStr[c.Rank():c.Rank() + 1] + colorsStr[c.Suit():c.Suit() + 1]
+}
+
+// 字符串手牌直接查表估值
+func HandStrToRank(handStr string) (HandRank, error) {
+	cards, err := CardsOf(handStr)
+	if err != nil {
+		return 0, err
+	}
+	if len(cards) < 5 || len(cards) > maxCardsToRank {
+		return 0, errors.New("查表只支持5到7张牌")
+	}
+	return EvaluateCards(cards), nil
+}
+
+// 5到7张牌的牌型，调用方保证张数和没有重复的牌
+func EvaluateCards(cards []Card) HandRank {
+	var suitCount [4]uint8
+	var suitMask [4]uint16
+	var rankCount [13]uint8
+	for _, c := range cards {
+		r, s := c >> 2, c & 3
+		suitCount[s]++
+		suitMask[s] |= 1 << r
+		rankCount[r]++
+	}
+	for s := 0; s < 4; s++ {
+		if suitCount[s] >= 5 {
+			return flushTable[suitMask[s]]
+		}
+	}
+	k := len(cards)
+	var idx uint32
+	before := 0
+	for r := 0; r < 13; r++ {
+		c := rankCount[r]
+		idx += rankOffsets[k][r][before][c]
+		before += int(c)
+	}
+	return nonFlushTable[k][idx]
+}
+
+// 和Analyst2的牌型一致
+func (r HandRank) GetHandType() HandType {
+	return HandType(r >> rankTypeShift)
+}
+
+// 实现abstracts.Hand，牌型一样时Weight大的牌大
+func (r HandRank) HandType() int {
+	return int(r >> rankTypeShift)
+}
+
+func (r HandRank) Weight() int {
+	return int(r)
+}
+
+func makeRank(handType HandType, ranks ...int) HandRank {
+	var result HandRank
+	for i := 0; i < 5; i++ {
+		result <<= 4
+		if i < len(ranks) {
+			result |= HandRank(ranks[i])
+		}
+	}
+	return result | HandRank(handType) << rankTypeShift
+}
+
+func initStraightTable() {
+	for mask := 0; mask < 1 << 13; mask++ {
+		// 从A开始往下找连着的5个，A2345时A当1
+		for high := 12; high >= 3; high-- {
+			straight := true
+			for i := 0; i < 5; i++ {
+				r := high - i
+				if r < 0 {
+					r = 12
+				}
+				if mask & (1 << uint(r)) == 0 {
+					straight = false
+					break
+				}
+			}
+			if straight {
+				straightTable[mask] = uint8(high + 1)
+				break
+			}
+		}
+	}
+}
+
+// 掩码中从大到小的count个点数
+func topRanks(mask int, count int) (result []int) {
+	for r := 12; r >= 0 && len(result) < count; r-- {
+		if mask & (1 << uint(r)) != 0 {
+			result = append(result, r)
+		}
+	}
+	return
+}
+
+func bitCount(mask int) (count int) {
+	for ; mask != 0; mask &= mask - 1 {
+		count++
+	}
+	return
+}
+
+func initFlushTable() {
+	for mask := 0; mask < 1 << 13; mask++ {
+		if bitCount(mask) < 5 {
+			continue
+		}
+		if high := straightTable[mask]; high != 0 {
+			if high - 1 == 12 {
+				flushTable[mask] = makeRank(HandOfHJTHS, 12)
+			} else {
+				flushTable[mask] = makeRank(HandOfTHS, int(high) - 1)
+			}
+			continue
+		}
+		flushTable[mask] = makeRank(HandOfTH, topRanks(mask, 5)...)
+	}
+}
+
+/*
+
+点数个数的完美哈希：把13个点数的张数看成一个序列，按字典序编号
+ways[r][n]是从第r个点数开始往后放n张牌（每个点数最多4张）的放法数
+第r个点数放c张时，排在它前边的序列是放0到c-1张的所有序列
+
+*/
+func initNonFlushTable() {
+	var ways [14][maxCardsToRank + 1]uint32
+	ways[13][0] = 1
+	for r := 12; r >= 0; r-- {
+		for n := 0; n <= maxCardsToRank; n++ {
+			for c := 0; c <= 4 && c <= n; c++ {
+				ways[r][n] += ways[r + 1][n - c]
+			}
+		}
+	}
+	for k := 5; k <= maxCardsToRank; k++ {
+		for r := 0; r < 13; r++ {
+			for before := 0; before <= k; before++ {
+				var offset uint32
+				for c := 0; c <= 4 && before + c <= k; c++ {
+					rankOffsets[k][r][before][c] = offset
+					offset += ways[r + 1][k - before - c]
+				}
+			}
+		}
+		nonFlushTable[k] = make([]HandRank, ways[0][k])
+		var counts [13]uint8
+		fillNonFlushTable(k, 0, k, &counts)
+	}
+}
+
+func fillNonFlushTable(k int, r int, remain int, counts *[13]uint8) {
+	if r == 13 {
+		if remain != 0 {
+			return
+		}
+		var idx uint32
+		before := 0
+		for i := 0; i < 13; i++ {
+			idx += rankOffsets[k][i][before][counts[i]]
+			before += int(counts[i])
+		}
+		nonFlushTable[k][idx] = rankOfCounts(counts)
+		return
+	}
+	for c := 0; c <= 4 && c <= remain; c++ {
+		counts[r] = uint8(c)
+		fillNonFlushTable(k, r + 1, remain - c, counts)
+	}
+	counts[r] = 0
+}
+
+// 不是同花时，按每个点数的张数算牌型
+func rankOfCounts(counts *[13]uint8) HandRank {
+	var mask int
+	var quads, trips, pairs, singles []int
+	for r := 12; r >= 0; r-- {
+		switch counts[r] {
+		case 4:
+			quads = append(quads, r)
+		case 3:
+			trips = append(trips, r)
+		case 2:
+			pairs = append(pairs, r)
+		case 1:
+			singles = append(singles, r)
+		}
+		if counts[r] > 0 {
+			mask |= 1 << uint(r)
+		}
+	}
+	// 除去用掉的点数后最大的几个点数
+	kickers := func(count int, used ...int) []int {
+		m := mask
+		for _, r := range used {
+			m &^= 1 << uint(r)
+		}
+		return topRanks(m, count)
+	}
+	switch {
+	case len(quads) > 0:
+		return makeRank(HandOfST4, append([]int{ quads[0] }, kickers(1, quads[0])...)...)
+	case len(trips) > 0 && len(trips) + len(pairs) > 1:
+		// 第二个三条也可以当对子用
+		pair := -1
+		if len(trips) > 1 {
+			pair = trips[1]
+		}
+		if len(pairs) > 0 && pairs[0] > pair {
+			pair = pairs[0]
+		}
+		return makeRank(HandOfHL, trips[0], pair)
+	case straightTable[mask] != 0:
+		return makeRank(HandOfSZ, int(straightTable[mask]) - 1)
+	case len(trips) > 0:
+		return makeRank(HandOfST3, append([]int{ trips[0] }, kickers(2, trips[0])...)...)
+	case len(pairs) > 1:
+		return makeRank(HandOfLD, append([]int{ pairs[0], pairs[1] }, kickers(1, pairs[0], pairs[1])...)...)
+	case len(pairs) > 0:
+		return makeRank(HandOfYD, append([]int{ pairs[0] }, kickers(3, pairs[0])...)...)
+	}
+	return makeRank(HandOfDZ, singles[:5]...)
+}
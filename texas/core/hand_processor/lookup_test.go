@@ -0,0 +1,242 @@
+package hand_processor
+
+import (
+	"math/rand"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func mustRank(t *testing.T, handStr string) HandRank {
+	r, err := HandStrToRank(handStr)
+	assert.NoError(t, err)
+	return r
+}
+
+func TestCardsOf(t *testing.T) {
+	cards, err := CardsOf("2sAcTd")
+	assert.NoError(t, err)
+	assert.Equal(t, []Card{ 0, 51, 34 }, cards)
+	assert.Equal(t, "Tc", Card(35).String())
+	_, err = CardsOf("AsAs")
+	assert.Error(t, err)
+	_, err = CardsOf("Xn")
+	assert.Error(t, err)
+	_, err = HandStrToRank("AsKs")
+	assert.Error(t, err)
+}
+
+func TestHandStrToRank(t *testing.T) {
+	assert.Equal(t, HandOfHJTHS, mustRank(t, "AsKsQsJsTs2h3d").GetHandType())
+	assert.Equal(t, HandOfTHS, mustRank(t, "As2s3s4s5s9h9d").GetHandType())
+	assert.Equal(t, HandOfST4, mustRank(t, "9s9h9d9c2h3d").GetHandType())
+	assert.Equal(t, HandOfHL, mustRank(t, "KsKhKdQsQh").GetHandType())
+	assert.Equal(t, HandOfTH, mustRank(t, "2s7s9sJsKs").GetHandType())
+	assert.Equal(t, HandOfSZ, mustRank(t, "As2h3d4c5s").GetHandType())
+	assert.Equal(t, HandOfST3, mustRank(t, "7s7h7dKs2c").GetHandType())
+	assert.Equal(t, HandOfLD, mustRank(t, "7s7hKdKs2c").GetHandType())
+	assert.Equal(t, HandOfYD, mustRank(t, "7s7hKd3s2c").GetHandType())
+	assert.Equal(t, HandOfDZ, mustRank(t, "7s8hKd3s2c").GetHandType())
+
+	// A2345是最小的顺子
+	assert.True(t, mustRank(t, "As2h3d4c5s") < mustRank(t, "2h3d4c5s6s"))
+	// 两个三条时小的当对子
+	assert.Equal(t, mustRank(t, "KsKhKdQsQh"), mustRank(t, "KsKhKdQsQhQd2c"))
+	// 三对时取最大的单张
+	assert.True(t, mustRank(t, "KsKhQdQs2h2dAc") > mustRank(t, "KsKhQdQs3h3d2c"))
+	// 7张中不用的牌不影响大小
+	assert.Equal(t, mustRank(t, "AsKhQdJs9h"), mustRank(t, "AsKhQdJs9h3d2c"))
+}
+
+type analystKey struct {
+	handType HandType
+	weight int
+}
+
+func (k analystKey) less(other analystKey) bool {
+	if k.handType != other.handType {
+		return k.handType < other.handType
+	}
+	return k.weight < other.weight
+}
+
+func analystKeyOf(t *testing.T, cards []Card) analystKey {
+	str := ""
+	for _, c := range cards {
+		str += c.String()
+	}
+	hand, err := HandStrToHand(str)
+	assert.NoError(t, err)
+	return analystKey{ hand.GetHandType(), hand.GetWeight() }
+}
+
+/*
+
+查表和Analyst2的比较结果一致：同一个HandRank对应的Analyst2牌型和权重都一样，
+HandRank越大Analyst2的牌也越大，查表算出的牌型也和Analyst2一样
+
+*/
+type crossChecker struct {
+	lock sync.Mutex
+	keys map[HandRank]analystKey
+	// 按Analyst2的牌型数每种有几手牌
+	counts map[HandType]int
+	conflicts int
+}
+
+func (c *crossChecker) add(r HandRank, key analystKey) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.keys == nil {
+		c.keys = map[HandRank]analystKey{}
+		c.counts = map[HandType]int{}
+	}
+	c.counts[key.handType]++
+	if r.GetHandType() != key.handType {
+		c.conflicts++
+		return
+	}
+	if old, ok := c.keys[r]; ok && old != key {
+		c.conflicts++
+		return
+	}
+	c.keys[r] = key
+}
+
+func (c *crossChecker) check(t *testing.T) {
+	assert.Equal(t, 0, c.conflicts)
+	var ranks []HandRank
+	for r := range c.keys {
+		ranks = append(ranks, r)
+	}
+	sort.Slice(ranks, func(i, j int) bool { return ranks[i] < ranks[j] })
+	for i := 1; i < len(ranks); i++ {
+		if !c.keys[ranks[i - 1]].less(c.keys[ranks[i]]) {
+			t.Errorf("rank %x (%v) should be smaller than %x (%v)", ranks[i - 1], c.keys[ranks[i - 1]], ranks[i], c.keys[ranks[i]])
+		}
+	}
+}
+
+func TestEvaluateCards_MatchAnalyst2(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, count := range []int{ 5, 6, 7 } {
+		checker := &crossChecker{}
+		for n := 0; n < 20000; n++ {
+			perm := rnd.Perm(52)
+			cards := make([]Card, count)
+			for i := range cards {
+				cards[i] = Card(perm[i])
+			}
+			checker.add(EvaluateCards(cards), analystKeyOf(t, cards))
+		}
+		checker.check(t)
+	}
+}
+
+// 所有count张牌的组合按第一张牌分给每个cpu，都和Analyst2对一遍
+func crossCheckAll(t *testing.T, count int) *crossChecker {
+	checker := &crossChecker{}
+	var wg sync.WaitGroup
+	firsts := make(chan int)
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for first := range firsts {
+				cards := make([]Card, count)
+				cards[0] = Card(first)
+				forEachCardCombinationFrom(first + 1, cards, 1, func(cards []Card) {
+					checker.add(EvaluateCards(cards), analystKeyOf(t, cards))
+				})
+			}
+		}()
+	}
+	for first := 0; first <= 52 - count; first++ {
+		firsts <- first
+	}
+	close(firsts)
+	wg.Wait()
+	checker.check(t)
+	return checker
+}
+
+// 全部2598960种5张牌的组合，一共7462种大小，每种牌型的手数是固定的
+func TestEvaluateCards_Exhaustive5(t *testing.T) {
+	checker := crossCheckAll(t, 5)
+	assert.Len(t, checker.keys, 7462)
+	assert.Equal(t, map[HandType]int{
+		HandOfHJTHS: 4,
+		HandOfTHS: 36,
+		HandOfST4: 624,
+		HandOfHL: 3744,
+		HandOfTH: 5108,
+		HandOfSZ: 10200,
+		HandOfST3: 54912,
+		HandOfLD: 123552,
+		HandOfYD: 1098240,
+		HandOfDZ: 1302540,
+	}, checker.counts)
+}
+
+// 全部1.3亿种7张牌的组合都和Analyst2对一遍，很慢，设置了HOLEHOLE_EXHAUSTIVE才跑
+func TestEvaluateCards_Exhaustive7(t *testing.T) {
+	if os.Getenv("HOLEHOLE_EXHAUSTIVE") == "" {
+		t.Skip("set HOLEHOLE_EXHAUSTIVE=1 to cross check all 7 card combinations")
+	}
+	checker := crossCheckAll(t, 7)
+	assert.Len(t, checker.keys, 4824)
+}
+
+func forEachCardCombination(count int, cb func(cards []Card)) {
+	forEachCardCombinationFrom(0, make([]Card, count), 0, cb)
+}
+
+func forEachCardCombinationFrom(start int, cards []Card, filled int, cb func(cards []Card)) {
+	if filled == len(cards) {
+		cb(cards)
+		return
+	}
+	for c := start; c <= 52 - (len(cards) - filled); c++ {
+		cards[filled] = Card(c)
+		forEachCardCombinationFrom(c + 1, cards, filled + 1, cb)
+	}
+}
+
+var benchHands = func() (result []string) {
+	rnd := rand.New(rand.NewSource(2))
+	for n := 0; n < 1024; n++ {
+		str := ""
+		for _, c := range rnd.Perm(52)[:7] {
+			str += Card(c).String()
+		}
+		result = append(result, str)
+	}
+	return
+}()
+
+func BenchmarkHandStrToHand7(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		HandStrToHand(benchHands[i % len(benchHands)])
+	}
+}
+
+func BenchmarkHandStrToRank7(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		HandStrToRank(benchHands[i % len(benchHands)])
+	}
+}
+
+func BenchmarkEvaluateCards7(b *testing.B) {
+	var hands [][]Card
+	for _, str := range benchHands {
+		cards, _ := CardsOf(str)
+		hands = append(hands, cards)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		EvaluateCards(hands[i % len(hands)])
+	}
+}
@@ -0,0 +1,116 @@
+package hand_processor
+
+import (
+	"errors"
+	"strings"
+)
+
+/*
+
+低牌（A到5低牌，8或更小才算）
+A当1，顺子和同花不影响低牌，对子不能用，5张不同点数的牌都不大于8才成低牌
+LowRank是从大到小的5个点数（每个4位），越小低牌越好，最好的是5432A（0x54321）
+
+*/
+type LowRank uint32
+
+const lowQualifier = 8
+
+// 低牌中的点数，A是1，其他按牌面
+func lowFace(rank int) int {
+	if rank == 12 {
+		return 1
+	}
+	return rank + 2
+}
+
+// 一组牌中最好的低牌，不成低牌时ok为false
+func LowOfCards(cards []Card) (low LowRank, ok bool) {
+	var mask int
+	for _, c := range cards {
+		if c >= CardJoker {
+			continue
+		}
+		mask |= 1 << uint(lowFace(c.Rank()))
+	}
+	// 从小往大取5个不同的点数
+	var faces []int
+	for f := 1; f <= lowQualifier && len(faces) < 5; f++ {
+		if mask & (1 << uint(f)) != 0 {
+			faces = append(faces, f)
+		}
+	}
+	if len(faces) < 5 {
+		return 0, false
+	}
+	for i := 4; i >= 0; i-- {
+		low = low << 4 | LowRank(faces[i])
+	}
+	return low, true
+}
+
+// 字符串手牌的低牌，5张及以上任选5张
+func LowHandStrToLow(handStr string) (LowRank, bool, error) {
+	cards, err := CardsOf(handStr)
+	if err != nil {
+		return 0, false, err
+	}
+	if len(cards) < 5 {
+		return 0, false, errors.New("手牌长度不足以计算结果")
+	}
+	low, ok := LowOfCards(cards)
+	return low, ok, nil
+}
+
+// 奥马哈的低牌，必须用两张手牌加三张公共牌
+func OmahaLowHandStrToLow(holeStr string, boardStr string) (LowRank, bool, error) {
+	hole, err := CardsOf(holeStr)
+	if err != nil {
+		return 0, false, err
+	}
+	board, err := CardsOf(boardStr)
+	if err != nil {
+		return 0, false, err
+	}
+	if len(hole) < 2 || len(board) < 3 {
+		return 0, false, errors.New("奥马哈至少要两张手牌和三张公共牌")
+	}
+	var best LowRank
+	found := false
+	five := make([]Card, 5)
+	for i := 0; i < len(hole); i++ {
+		for j := i + 1; j < len(hole); j++ {
+			five[0], five[1] = hole[i], hole[j]
+			for a := 0; a < len(board); a++ {
+				for b := a + 1; b < len(board); b++ {
+					for c := b + 1; c < len(board); c++ {
+						five[2], five[3], five[4] = board[a], board[b], board[c]
+						if low, ok := LowOfCards(five); ok && (!found || low < best) {
+							best, found = low, true
+						}
+					}
+				}
+			}
+		}
+	}
+	return best, found, nil
+}
+
+// 比other好（更小）
+func (r LowRank) BetterThan(other LowRank) bool {
+	return r < other
+}
+
+// 如"8-6-4-2-A"
+func (r LowRank) String() string {
+	var faces []string
+	for i := 4; i >= 0; i-- {
+		f := int(r >> uint(i * 4)) & 0xf
+		if f == 1 {
+			faces = append(faces, "A")
+		} else {
+			faces = append(faces, facesStr[f - 2:f - 1])
+		}
+	}
+	return strings.Join(faces, "-")
+}
@@ -0,0 +1,69 @@
+package hand_processor
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func mustLow(t *testing.T, handStr string) (LowRank, bool) {
+	low, ok, err := LowHandStrToLow(handStr)
+	assert.NoError(t, err)
+	return low, ok
+}
+
+func TestLowHandStrToLow(t *testing.T) {
+	wheel, ok := mustLow(t, "As2h3d4c5s")
+	assert.True(t, ok)
+	assert.Equal(t, LowRank(0x54321), wheel)
+	assert.Equal(t, "5-4-3-2-A", wheel.String())
+
+	// 顺子和同花不影响低牌
+	flushWheel, _ := mustLow(t, "As2s3s4s5s")
+	assert.Equal(t, wheel, flushWheel)
+
+	// 7张中任选，对子不能用
+	low, ok := mustLow(t, "8s6h4d2cAsAh2d")
+	assert.True(t, ok)
+	assert.Equal(t, "8-6-4-2-A", low.String())
+	low, ok = mustLow(t, "Ks7h6d4c3s2h2d")
+	assert.True(t, ok)
+	assert.Equal(t, "7-6-4-3-2", low.String())
+
+	// 比最大的点数，一样再往下比
+	l1, _ := mustLow(t, "7s5h4d3c2s")
+	l2, _ := mustLow(t, "7s6h3d2cAs")
+	l3, _ := mustLow(t, "8s5h4d3c2s")
+	assert.True(t, wheel.BetterThan(l1))
+	assert.True(t, l1.BetterThan(l2))
+	assert.True(t, l2.BetterThan(l3))
+
+	// 不到5个不大于8的点数不成低牌
+	_, ok = mustLow(t, "9s6h4d2cAs")
+	assert.False(t, ok)
+	_, ok = mustLow(t, "As2h3d4c4sKhKd")
+	assert.False(t, ok)
+
+	_, _, err := LowHandStrToLow("As2h3d")
+	assert.Error(t, err)
+}
+
+func TestOmahaLowHandStrToLow(t *testing.T) {
+	// 必须用两张手牌，只有A2能用
+	low, ok, err := OmahaLowHandStrToLow("As2hKdKc", "3s4d5cQhJh")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "5-4-3-2-A", low.String())
+
+	// 公共牌不足三张小牌时没有低牌
+	_, ok, err = OmahaLowHandStrToLow("As2h3d4c", "5s9dTcQhJh")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// 手牌中有4张小牌也只能用两张
+	low, ok, _ = OmahaLowHandStrToLow("As2h3d4c", "5s6d8cQhJh")
+	assert.True(t, ok)
+	assert.Equal(t, "8-6-5-2-A", low.String())
+	// 手牌只有一张小牌
+	_, ok, _ = OmahaLowHandStrToLow("AsKhQdJc", "2s3d4c5hTh")
+	assert.False(t, ok)
+}
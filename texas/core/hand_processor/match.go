@@ -0,0 +1,201 @@
+package hand_processor
+
+import (
+	"fmt"
+	"runtime"
+	"io/ioutil"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/common/util"
+)
+
+var coreNum = runtime.NumCPU()
+
+// 从文件中读取json数据
+func ReadJsonFromFile(path string, result interface{}) error {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := util.ParseJsonFromBytes(bytes, result); err != nil {
+		return fmt.Errorf("unmarshal %v: %v", path, err)
+	}
+	return nil
+}
+
+type Matches struct {
+	Matches []*Match `json:"matches"`
+}
+
+/*
+
+校验每组对比，返回算出来和期望结果不一样的，以及牌不合格的
+大概80为分界线，80个以下开线程去做的开销比直接算的开销更大
+
+*/
+func (matches *Matches) Validate() (failed []*Match) {
+	if coreNum < 2 || len(matches.Matches) < 80 {
+		doMatch(matches.Matches)
+	} else {
+		// 多核计算
+		doMatchesByChan(matches)
+	}
+	for _, m := range matches.Matches {
+		if m.Err != "" || m.Got != m.Result {
+			failed = append(failed, m)
+		}
+	}
+	return
+}
+
+// 同步计算
+func doMatch(matches []*Match) {
+	for _, m := range matches {
+		m.match()
+	}
+}
+
+// 对match数组执行匹配操作，异步
+func doMatchWithChan(matches []*Match, c chan int) {
+	doMatch(matches)
+	c <- 1
+}
+
+type Match struct {
+	Alice string `json:"alice"`
+	Bob string `json:"bob"`
+	aliceHand *Hand
+	bobHand *Hand
+	// 期望的结果，1是alice大，2是bob大，0是一样大
+	Result int `json:"result"`
+	// 算出来的结果
+	Got int `json:"got"`
+	// 牌不合格时的错误
+	Err string `json:"error,omitempty"`
+}
+
+// 执行对比方法，得出对比结果
+func (m *Match)match() {
+	var err error
+	if m.aliceHand, err = HandStrToHand(m.Alice); err != nil {
+		m.Err = "alice: " + err.Error()
+		return
+	}
+	if m.bobHand, err = HandStrToHand(m.Bob); err != nil {
+		m.Err = "bob: " + err.Error()
+		return
+	}
+	m.Got = m.aliceHand.Match(m.bobHand)
+}
+// 异步执行所有对比
+func doMatchesByChan(matches *Matches) {
+	mLen := len(matches.Matches)
+	chans := []chan int{}
+	step := mLen / coreNum
+	for i := 0; i < coreNum; i++ {
+		var tmp []*Match
+		// 如果是最后一组，可能因为除不尽而丢掉之后的数据
+		if i == coreNum - 1{
+			tmp = matches.Matches[i * step:]
+		}else{
+			tmp = matches.Matches[i * step:(i + 1) * step]
+		}
+		c := make(chan int)
+		chans = append(chans, c)
+		go doMatchWithChan(tmp, c)
+	}
+	// 将在这里阻塞
+	for _, tmpC := range chans {
+		<- tmpC
+	}
+}
+
+type RankHand struct {
+	RHand string `json:"hand"`
+	RScore int `json:"score"`
+	hand *Hand
+	Rank string `json:"rank"`
+}
+
+func (rHand *RankHand)setTypeStr() {
+	switch rHand.hand.handType {
+	case HandOfWT:
+		rHand.Rank = "五条"
+	case HandOfHJTHS:
+		rHand.Rank = "皇家同花顺"
+	case HandOfTHS:
+		rHand.Rank = "同花顺"
+	case HandOfST4:
+		rHand.Rank = "四条"
+	case HandOfHL:
+		rHand.Rank = "葫芦"
+	case HandOfTH:
+		rHand.Rank = "同花"
+	case HandOfSZ:
+		rHand.Rank = "顺子"
+	case HandOfST3:
+		rHand.Rank = "三条"
+	case HandOfLD:
+		rHand.Rank = "两对"
+	case HandOfYD:
+		rHand.Rank = "一对"
+	case HandOfDZ:
+		rHand.Rank = "单张"
+	}
+}
+
+type RHands struct {
+	Hands []*RankHand `json:"hands"`
+}
+
+// 从大到小排序，score是倒数的名次，牌不合格时返回错误
+func (rHands *RHands) Sort() (*RHands, error) {
+	var resultHands []*RankHand
+	resultRHands := new(RHands)
+	tmpHands := make(map[HandType][]*RankHand)
+	// 分类排序
+	for _, rh := range rHands.Hands {
+		var err error
+		if rh.hand, err = HandStrToHand(rh.RHand); err != nil {
+			return nil, fmt.Errorf("%v: %v", rh.RHand, err)
+		}
+		rh.setTypeStr()
+		tmpArr := tmpHands[rh.hand.handType]
+		if tmpArr == nil {
+			tmpArr = []*RankHand{}
+		}
+		inserted := false
+		for index, t := range tmpArr {
+			// 从大到小排序
+			if rh.hand.weight > t.hand.weight {
+				rear := append([]*RankHand{}, tmpArr[index:]...)
+				tmpArr = append(tmpArr[:index], rh)
+				tmpArr = append(tmpArr, rear...)
+				inserted = true
+				break
+			}
+		}
+		if !inserted {
+			tmpArr = append(tmpArr, rh)
+		}
+		tmpHands[rh.hand.handType] = tmpArr
+	}
+	resultHands = tmpHands[HandOfWT]
+	if resultHands == nil{
+		resultHands = []*RankHand{}
+	}
+	resultHands = append(resultHands, tmpHands[HandOfHJTHS]...)
+	resultHands = append(resultHands, tmpHands[HandOfTHS]...)
+	resultHands = append(resultHands, tmpHands[HandOfST4]...)
+	resultHands = append(resultHands, tmpHands[HandOfHL]...)
+	resultHands = append(resultHands, tmpHands[HandOfTH]...)
+	resultHands = append(resultHands, tmpHands[HandOfSZ]...)
+	resultHands = append(resultHands, tmpHands[HandOfST3]...)
+	resultHands = append(resultHands, tmpHands[HandOfLD]...)
+	resultHands = append(resultHands, tmpHands[HandOfYD]...)
+	resultHands = append(resultHands, tmpHands[HandOfDZ]...)
+	rLen := len(resultHands)
+	for index, rh := range resultHands {
+		rh.RScore = rLen - index
+	}
+	resultRHands.Hands = resultHands
+	return resultRHands, nil
+}
\ No newline at end of file
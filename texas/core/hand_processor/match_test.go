@@ -0,0 +1,48 @@
+package hand_processor
+
+import (
+	"fmt"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatches_Validate(t *testing.T) {
+	matches := &Matches{ Matches: []*Match{
+		{ Alice: "AsAhKdKc2s", Bob: "KsKhKd7c7s", Result: 2 },
+		{ Alice: "AsAhKdKc3s", Bob: "AdAcKhKs2s", Result: 1 },
+		{ Alice: "AsAhKdKc3s", Bob: "AdAcKhKs3d", Result: 0 },
+		// 期望错了
+		{ Alice: "AsAhKdKc2s", Bob: "AdAcKhKs3s", Result: 1 },
+		// 牌不合格
+		{ Alice: "AsAh", Bob: "AdAcKhKs3s", Result: 1 },
+	} }
+	failed := matches.Validate()
+	if assert.Len(t, failed, 2) {
+		assert.Equal(t, 2, failed[0].Got)
+		assert.Equal(t, "AsAh", failed[1].Alice)
+		assert.NotEmpty(t, failed[1].Err)
+	}
+
+	// 多核计算的结果一样
+	matches = &Matches{}
+	for i := 0; i < 200; i++ {
+		matches.Matches = append(matches.Matches, &Match{ Alice: "KsKhKd7c7s", Bob: fmt.Sprintf("AsAhKdKc%vs", i % 8 + 2), Result: 1 })
+	}
+	assert.Empty(t, matches.Validate())
+}
+
+func TestRHands_Sort(t *testing.T) {
+	rHands := &RHands{ Hands: []*RankHand{ { RHand: "AsAhKdKc2s" }, { RHand: "KsKhKd7c7s" }, { RHand: "AsAhKdKc3s" } } }
+	sorted, err := rHands.Sort()
+	assert.NoError(t, err)
+	var hands []string
+	for _, rh := range sorted.Hands {
+		hands = append(hands, rh.RHand)
+	}
+	assert.Equal(t, []string{ "KsKhKd7c7s", "AsAhKdKc3s", "AsAhKdKc2s" }, hands)
+	assert.Equal(t, 3, sorted.Hands[0].RScore)
+	assert.Equal(t, "葫芦", sorted.Hands[0].Rank)
+
+	_, err = (&RHands{ Hands: []*RankHand{ { RHand: "As" } } }).Sort()
+	assert.Error(t, err)
+}
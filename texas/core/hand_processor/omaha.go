@@ -0,0 +1,58 @@
+package hand_processor
+
+import (
+	"errors"
+)
+
+/*
+
+奥马哈的牌型
+必须用两张手牌加三张公共牌组成5张牌，不能像德州一样7张里任选5张
+枚举所有组合（4张手牌、5张公共牌时为6*10=60种），取最大的一手
+
+*/
+func OmahaHandStrToHand(holeStr string, boardStr string) (*Hand, error) {
+	if len(holeStr) % 2 != 0 || len(boardStr) % 2 != 0 {
+		return nil, errors.New("手牌字符串长度必须是2的倍数")
+	}
+	hole := splitPokersStr(holeStr)
+	board := splitPokersStr(boardStr)
+	if len(hole) < 2 || len(board) < 3 {
+		return nil, errors.New("奥马哈至少要两张手牌和三张公共牌")
+	}
+
+	var best *Hand
+	for _, hs := range combinePokersStr(hole, 2) {
+		for _, bs := range combinePokersStr(board, 3) {
+			hand, err := pokersStrToHand(append(append([]string{}, hs...), bs...))
+			if err != nil {
+				return nil, err
+			}
+			if best == nil || hand.Match(best) == 1 {
+				best = hand
+			}
+		}
+	}
+	return best, nil
+}
+
+func splitPokersStr(str string) []string {
+	var result []string
+	for i := 0; i < len(str) / 2; i++ {
+		result = append(result, str[(i*2):(i+1)*2])
+	}
+	return result
+}
+
+// 从pokers中取count张的所有组合
+func combinePokersStr(pokers []string, count int) (result [][]string) {
+	if count == 0 {
+		return [][]string{ {} }
+	}
+	for i := 0; i + count <= len(pokers); i++ {
+		for _, rest := range combinePokersStr(pokers[i + 1:], count - 1) {
+			result = append(result, append([]string{ pokers[i] }, rest...))
+		}
+	}
+	return
+}
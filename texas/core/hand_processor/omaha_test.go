@@ -0,0 +1,34 @@
+package hand_processor
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+// 必须用两张手牌加三张公共牌
+func TestOmahaHandStrToHand(t *testing.T) {
+	// 德州是四条，奥马哈只能用两张A，是葫芦
+	hand, err := OmahaHandStrToHand("AsAhAdAc", "KsKhKd2c3c")
+	assert.NoError(t, err)
+	assert.Equal(t, HandOfHL, hand.GetHandType())
+
+	// 公共牌是同花顺，但手牌只有一张黑桃，最大是顺子
+	hand, err = OmahaHandStrToHand("AsKd2c3h", "QsJsTs9s8s")
+	assert.NoError(t, err)
+	assert.Equal(t, HandOfSZ, hand.GetHandType())
+
+	// 手牌两张同花才能组成同花
+	hand, err = OmahaHandStrToHand("2s7sKdKh", "QsJs3s4h9c")
+	assert.NoError(t, err)
+	assert.Equal(t, HandOfTH, hand.GetHandType())
+
+	// 同样是顺子，大的赢
+	h1, _ := OmahaHandStrToHand("AsKd2c3h", "QsJsTs4d5c")
+	h2, _ := OmahaHandStrToHand("9h8d2d3d", "QsJsTs4d5c")
+	assert.Equal(t, 1, h1.Match(h2))
+
+	_, err = OmahaHandStrToHand("As", "QsJsTs")
+	assert.Error(t, err)
+	_, err = OmahaHandStrToHand("AsKd2c3h", "QsJ")
+	assert.Error(t, err)
+}
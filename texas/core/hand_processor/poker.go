@@ -10,6 +10,10 @@ import (
 const facesStr = "23456789TJQKA"
 const colorsStr = "shdc"
 const laiZiStr = "z"
+// 牌堆里赖子的写法
+const LzWhole = laiZiStr + laiZiStr
+// 短牌中去掉的牌
+const shortDeckRemovedStr = "2345"
 
 // poker最好不要有运行时状态
 type Poker struct {
@@ -42,7 +46,7 @@ func PokerStrToPoker(str string) *Poker {
 	validPoker := false
 	if strings.Contains(facesStr, tmpFace) && strings.Contains(colorsStr, tmpColor) {
 		validPoker = true
-	} else if tmpFace == "X" && tmpColor == "n" {
+	} else if (tmpFace == "X" && tmpColor == "n") || (tmpFace == laiZiStr && tmpColor == laiZiStr) {
 		tmpFace = laiZiStr
 		tmpColor = laiZiStr
 		str = laiZiStr + laiZiStr
@@ -238,7 +242,7 @@ func NewPoker(face string, color string) *Poker {
 	return p
 }
 
-// 生成一副牌，主要用于随机生成牌型
+// 生成一副牌，withLz时多一张赖子（写作zz或Xn）
 func MakeDeckOfCards(withLz bool) []*Poker {
 	faceLen := len(facesStr)
 	colorLen := len(colorsStr)
@@ -256,6 +260,21 @@ func MakeDeckOfCards(withLz bool) []*Poker {
 	return result
 }
 
+// 生成一副短牌（6+），去掉2到5，共36张
+func MakeShortDeckOfCards() []*Poker {
+	result := []*Poker{}
+	for _, p := range MakeDeckOfCards(false) {
+		if !isShortDeckRemoved(p.face) {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+func isShortDeckRemoved(face string) bool {
+	return strings.Contains(shortDeckRemovedStr, face)
+}
+
 // 遍历一组牌，拿最小顺子
 func getSmallestSZFromPokers(pokers []*Poker) []*Poker {
 	allHave := []*Poker{nil, nil, nil, nil, nil}
@@ -0,0 +1,67 @@
+package hand_processor
+
+import (
+	"errors"
+)
+
+/*
+
+短牌（6+）的牌型
+1. 没有2到5，A6789是最小的顺子（同花则是最小的同花顺）
+1. 同花比葫芦大
+从所有牌中任选5张，取最大的一手
+
+*/
+func ShortDeckHandStrToHand(handStr string) (*Hand, error) {
+	if len(handStr) % 2 != 0 {
+		return nil, errors.New("手牌字符串长度必须是2的倍数")
+	}
+	pokersStr := splitPokersStr(handStr)
+	if len(pokersStr) < 5 {
+		return nil, errors.New("手牌长度不足以计算结果")
+	}
+	for _, str := range pokersStr {
+		if isShortDeckRemoved(str[0:1]) {
+			return nil, errors.New("短牌中不能有2到5")
+		}
+	}
+
+	var best *Hand
+	for _, ps := range combinePokersStr(pokersStr, 5) {
+		hand, err := pokersStrToHand(ps)
+		if err != nil {
+			return nil, err
+		}
+		hand.shortDeck = true
+		checkShortDeckWheel(hand)
+		if best == nil || hand.Match(best) == 1 {
+			best = hand
+		}
+	}
+	return best, nil
+}
+
+// A6789当作9开头的顺子，权重比6789T小
+func checkShortDeckWheel(hand *Hand) {
+	if hand.handType != HandOfDZ && hand.handType != HandOfTH {
+		return
+	}
+	faces := ""
+	for _, f := range facesStr {
+		for _, p := range hand.originPokers {
+			if p.face == string(f) {
+				faces += p.face
+				break
+			}
+		}
+	}
+	if faces != "6789A" {
+		return
+	}
+	if hand.handType == HandOfTH {
+		hand.handType = HandOfTHS
+	} else {
+		hand.handType = HandOfSZ
+	}
+	hand.weight = faceWeightMulti("9", 1)
+}
@@ -0,0 +1,94 @@
+package hand_processor
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func mustShortDeckHand(t *testing.T, handStr string) *Hand {
+	hand, err := ShortDeckHandStrToHand(handStr)
+	assert.NoError(t, err)
+	return hand
+}
+
+func TestMakeShortDeckOfCards(t *testing.T) {
+	deck := MakeShortDeckOfCards()
+	assert.Len(t, deck, 36)
+	for _, p := range deck {
+		assert.NotContains(t, shortDeckRemovedStr, p.face)
+	}
+}
+
+// A6789是最小的顺子
+func TestShortDeckWheel(t *testing.T) {
+	wheel := mustShortDeckHand(t, "As6h7d8c9s")
+	assert.Equal(t, HandOfSZ, wheel.GetHandType())
+	low := mustShortDeckHand(t, "6h7d8c9sTs")
+	assert.Equal(t, HandOfSZ, low.GetHandType())
+	assert.Equal(t, 2, wheel.Match(low))
+	// 比三条大
+	trips := mustShortDeckHand(t, "AsAhAd8c9s")
+	assert.Equal(t, 1, wheel.Match(trips))
+
+	// 同花的A6789是最小的同花顺
+	sf := mustShortDeckHand(t, "As6s7s8s9s")
+	assert.Equal(t, HandOfTHS, sf.GetHandType())
+	assert.Equal(t, 2, sf.Match(mustShortDeckHand(t, "6s7s8s9sTs")))
+	assert.Equal(t, 1, sf.Match(mustShortDeckHand(t, "AsAhAdAcKs")))
+
+	// 7张牌中A6789和更大的顺子都有时取大的
+	hand := mustShortDeckHand(t, "As6h7d8c9sTdJh")
+	assert.Equal(t, HandOfSZ, hand.GetHandType())
+	assert.Equal(t, faceWeightMulti("J", 1), hand.Weight())
+}
+
+// 同花比葫芦大
+func TestShortDeckFlushBeatsFullHouse(t *testing.T) {
+	flush := mustShortDeckHand(t, "6h8hThQhKh")
+	fullHouse := mustShortDeckHand(t, "AsAhAdKcKs")
+	assert.Equal(t, HandOfTH, flush.GetHandType())
+	assert.Equal(t, HandOfHL, fullHouse.GetHandType())
+	assert.Equal(t, 1, flush.Match(fullHouse))
+	assert.Equal(t, 2, fullHouse.Match(flush))
+	assert.True(t, flush.HandType() > fullHouse.HandType())
+	// 四条比同花大
+	quads := mustShortDeckHand(t, "6s6h6d6c7s")
+	assert.Equal(t, 1, quads.Match(flush))
+
+	// 同花和葫芦都有时取同花
+	hand := mustShortDeckHand(t, "AhAsAd9h9sKhQhJh")
+	assert.Equal(t, HandOfTH, hand.GetHandType())
+
+	// 同样的牌在普通德州中葫芦更大
+	h1, _ := HandStrToHand("6h8hThQhKh")
+	h2, _ := HandStrToHand("AsAhAdKcKs")
+	assert.Equal(t, 2, h1.Match(h2))
+}
+
+// 其他牌型顺序不变
+func TestShortDeckOrder(t *testing.T) {
+	hands := []string{
+		"6s8hTdQcAs",
+		"6s6h8dTcAs",
+		"6s6h8d8cAs",
+		"6s6h6d8cAs",
+		"6h7d8c9sTs",
+		"AsAhAdKcKs",
+		"6h8hThQhKh",
+		"6s6h6d6c7s",
+		"6s7s8s9sTs",
+		"TsJsQsKsAs",
+	}
+	for i := 1; i < len(hands); i++ {
+		assert.Equal(t, 1, mustShortDeckHand(t, hands[i]).Match(mustShortDeckHand(t, hands[i - 1])), hands[i])
+	}
+}
+
+func TestShortDeckInvalid(t *testing.T) {
+	_, err := ShortDeckHandStrToHand("As6h7d8c5s")
+	assert.Error(t, err)
+	_, err = ShortDeckHandStrToHand("As6h7d8c")
+	assert.Error(t, err)
+	_, err = ShortDeckHandStrToHand("As6h7d8c9")
+	assert.Error(t, err)
+}
@@ -0,0 +1,52 @@
+package hand_processor
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func mustHand(t *testing.T, handStr string) *Hand {
+	hand, err := HandStrToHand(handStr)
+	assert.NoError(t, err)
+	return hand
+}
+
+func TestMakeDeckOfCardsWithLz(t *testing.T) {
+	deck := MakeDeckOfCards(true)
+	assert.Len(t, deck, 53)
+	lz := deck[52]
+	assert.Equal(t, "zz", lz.GetWhole())
+	// 牌堆里的赖子写作zz，和Xn一样能解析
+	assert.Equal(t, "zz", PokerStrToPoker("zz").GetWhole())
+	assert.Equal(t, "zz", PokerStrToPoker("Xn").GetWhole())
+}
+
+// 四条加赖子是五条，比皇家同花顺大
+func TestLzFiveOfAKind(t *testing.T) {
+	five := mustHand(t, "zzAsAhAdAc2h3d")
+	assert.Equal(t, HandOfWT, five.GetHandType())
+	assert.Equal(t, "A", five.GetLzAs().face)
+	assert.Equal(t, 1, five.Match(mustHand(t, "AsKsQsJsTs2h3d")))
+	assert.Equal(t, 1, five.Match(mustHand(t, "zz9s9h9d9c2h3d")))
+	assert.Equal(t, "五条", five.GetHandType().CnString())
+	assert.Equal(t, "HandOfWT", five.GetHandType().String())
+
+	// 三条加赖子还是四条
+	four := mustHand(t, "zzKsKhKdQs2h3d")
+	assert.Equal(t, HandOfST4, four.GetHandType())
+	assert.Equal(t, "K", four.GetLzAs().face)
+}
+
+// 赖子变成能组成最大牌型的那张
+func TestLzAs(t *testing.T) {
+	sf := mustHand(t, "zzKsQsJsTs2h3d")
+	assert.Equal(t, HandOfHJTHS, sf.GetHandType())
+	assert.Equal(t, "As", sf.GetLzAs().GetWhole())
+
+	sz := mustHand(t, "zz5s6h7d8c2h3d")
+	assert.Equal(t, HandOfSZ, sz.GetHandType())
+	assert.Equal(t, "9", sz.GetLzAs().face)
+
+	// 没有赖子时为nil
+	assert.Nil(t, mustHand(t, "AsKsQsJsTs2h3d").GetLzAs())
+}
@@ -9,19 +9,41 @@ import (
 )
 
 func newPokerHeap() *PokerHeap {
-	ph := &PokerHeap{}
+	return newPokerHeapOf(originPokers)
+}
+
+// 用指定的牌组洗牌，短牌等玩法的牌不是完整的52张
+func newPokerHeapOf(origin []*hand_processor.Poker) *PokerHeap {
+	ph := &PokerHeap{ origin: origin }
 	ph.onInit()
 	return ph
 }
 
+// 根据玩法选牌组
+func newVariantPokerHeap(variant GameVariant) *PokerHeap {
+	switch variant {
+	case VariantShortDeck:
+		return newPokerHeapOf(shortDeckPokers)
+	case VariantWildcard:
+		return newPokerHeapOf(wildcardPokers)
+	}
+	return newPokerHeap()
+}
+
 // todo 测试多协程是否有问题，应该没问题，因为不会对该数组做修改操作，都是读操作
 // 最原始的牌，所有桌子的牌都由该牌组随机排序后生成的
 var originPokers = hand_processor.MakeDeckOfCards(false)
+// 短牌（6+）的牌，去掉了2到5
+var shortDeckPokers = hand_processor.MakeShortDeckOfCards()
+// 赖子玩法的牌，52张加一张赖子
+var wildcardPokers = hand_processor.MakeDeckOfCards(true)
 
 // 牌堆（每次新建game都会重新创建该对象）
 type PokerHeap struct {
 	// 牌堆里的牌
 	Pokers []*hand_processor.Poker `json:"pokers"`
+	// 洗牌用的牌组，为nil则是完整的52张
+	origin []*hand_processor.Poker
 }
 
 func (pokerHeap *PokerHeap) DispatchPokers(count int) []abstracts.Poker {
@@ -39,8 +61,12 @@ func (pokerHeap *PokerHeap) onInit() {
 
 // 洗牌
 func (pokerHeap *PokerHeap) shuffleTheDeck() {
-	totalLen := len(originPokers)
-	pokerHeap.Pokers = append([]*hand_processor.Poker{}, originPokers...)
+	origin := pokerHeap.origin
+	if origin == nil {
+		origin = originPokers
+	}
+	totalLen := len(origin)
+	pokerHeap.Pokers = append([]*hand_processor.Poker{}, origin...)
 	for i := range pokerHeap.Pokers {
 		j := util.RandANum(totalLen)
 		pokerHeap.Pokers[i], pokerHeap.Pokers[j] = pokerHeap.Pokers[j], pokerHeap.Pokers[i]
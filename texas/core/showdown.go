@@ -0,0 +1,124 @@
+package core
+
+import (
+	"time"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/abstracts"
+)
+
+/*
+
+摊牌：结算前广播每个没弃牌的人的牌型和每个池子的输赢
+pots是每个池子的分配结果，0号是主池，lowPots是高低牌玩法中每个池子低牌的分配结果
+boards是发了多次牌时每次的公共牌，只发一次时为空
+
+*/
+func (g *Game) broadcastShowdown(pots []map[uint]uint64, lowPots []map[uint]uint64, boards [][]abstracts.Poker) {
+	resp := g.showdownResp(pots, lowPots, boards)
+	g.msgSender.BroadcastMsg(abstracts.MsgTypeShowdown, time.Now().UnixNano(), *resp)
+}
+
+func (g *Game) showdownResp(pots []map[uint]uint64, lowPots []map[uint]uint64, boards [][]abstracts.Poker) *abstracts.ShowdownResp {
+	resp := &abstracts.ShowdownResp{ CommonPokers: toPokerScenes(g.commonPokers) }
+	if len(boards) > 1 {
+		for _, board := range boards {
+			resp.Boards = append(resp.Boards, toPokerScenes(board))
+		}
+	}
+	amounts := g.chipPool.potAmounts()
+	for i, pot := range pots {
+		result := &abstracts.PotResult{ Amount: amounts[i] }
+		for u := uint(0); u < g.playersLen; u++ {
+			if won, ok := pot[u]; ok {
+				result.Winners = append(result.Winners, &abstracts.PotWinner{ UserID: g.players[u].ID(), Amount: won })
+			}
+		}
+		if i < len(lowPots) {
+			for u := uint(0); u < g.playersLen; u++ {
+				if won, ok := lowPots[i][u]; ok {
+					result.Winners = append(result.Winners, &abstracts.PotWinner{ UserID: g.players[u].ID(), Amount: won, Low: true })
+				}
+			}
+		}
+		resp.Pots = append(resp.Pots, result)
+	}
+
+	for u := uint(0); u < g.playersLen; u++ {
+		p := g.players[u]
+		if p.Discarded() {
+			continue
+		}
+		sp := &abstracts.ShowdownPlayer{ UserID: p.ID() }
+		for i, pot := range pots {
+			won, ok := pot[u]
+			if i < len(lowPots) {
+				lowWon, lowOk := lowPots[i][u]
+				won, ok = won + lowWon, ok || lowOk
+			}
+			if ok {
+				sp.Won += won
+				sp.WonPots = append(sp.WonPots, i)
+			}
+		}
+		if !g.mustShow(u, sp.Won) {
+			sp.Mucked = true
+			resp.Players = append(resp.Players, sp)
+			continue
+		}
+		sp.Pokers = toPokerScenes(p.Pokers())
+		run, lzAs := g.showdownRun(p, g.commonPokers)
+		if lzAs != "" {
+			markLzAs(sp.Pokers, lzAs)
+		}
+		sp.BestFive, sp.HandType, sp.HandName, sp.CnHandName, sp.LowName = run.BestFive, run.HandType, run.HandName, run.CnHandName, run.LowName
+		if len(boards) > 1 {
+			for _, board := range boards {
+				run, _ := g.showdownRun(p, board)
+				sp.Runs = append(sp.Runs, run)
+			}
+		}
+		resp.Players = append(resp.Players, sp)
+	}
+	return resp
+}
+
+// 玩家用某组公共牌组成的牌型，lzAs是赖子当成了哪张牌，没有赖子时为空
+func (g *Game) showdownRun(p abstracts.Player, board []abstracts.Poker) (run *abstracts.ShowdownRun, lzAs string) {
+	hand := g.analystHandOfBoard(p, board)
+	run = &abstracts.ShowdownRun{}
+	for _, poker := range hand.GetSortedPokers() {
+		if poker != nil {
+			run.BestFive = append(run.BestFive, &abstracts.PokerScene{ Whole: poker.GetWhole() })
+		}
+	}
+	if as := hand.GetLzAs(); as != nil {
+		lzAs = as.GetWhole()
+		markLzAs(run.BestFive, lzAs)
+	}
+	run.HandType = int(hand.GetHandType())
+	run.HandName = hand.Description()
+	run.CnHandName = hand.CnDescription()
+	if g.variant.HiLo() {
+		if low, ok := g.lowOfBoard(p, board); ok {
+			run.LowName = low.String()
+		}
+	}
+	return
+}
+
+// 赢了筹码的人、最后一轮最后下注或加注的人必须亮牌，最后一轮都过牌时第一个说话的人亮牌，all in后发牌到最后时都亮牌，其他输家盖牌
+func (g *Game) mustShow(player uint, won uint64) bool {
+	if won > 0 || g.runout {
+		return true
+	}
+	if g.lastAggressor < 0 {
+		return g.startBetAt == player
+	}
+	return g.lastAggressor == int(player)
+}
+
+func toPokerScenes(pokers []abstracts.Poker) (result []*abstracts.PokerScene) {
+	for _, poker := range pokers {
+		result = append(result, &abstracts.PokerScene{ Whole: poker.GetWhole() })
+	}
+	return
+}
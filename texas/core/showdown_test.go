@@ -0,0 +1,197 @@
+package core
+
+import (
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/abstracts"
+)
+
+func (g *Game) setPokers(t *testing.T, board []string, holes ...[]string) {
+	for i, hole := range holes {
+		ps, err := stringsToAbsPokers(hole)
+		assert.NoError(t, err)
+		g.players[uint(i)].GotPokers(ps)
+	}
+	ps, err := stringsToAbsPokers(board)
+	assert.NoError(t, err)
+	g.commonPokers = ps
+}
+
+func TestGameShowdown(t *testing.T) {
+	g := NewGame(TableLevel{ Xm: 10 }, newFakePlayersHeadsUp(), &fakeMsgSender{}, make(chan *GameResult, 1))
+	g.setPokers(t, []string{ "Kd", "Kc", "7d", "9h", "2c" }, []string{ "As", "Ah" }, []string{ "Ks", "7h" })
+	assert.NoError(t, g.chipPool.bet(1, 0, 100, false))
+	assert.NoError(t, g.chipPool.bet(1, 1, 100, false))
+
+	// 河牌是1下注0跟注
+	g.lastAggressor = 1
+	resp := g.showdownResp(g.chipPool.finalizePots(g.rankPlayers()), nil, nil)
+	assert.Len(t, resp.CommonPokers, 5)
+	if assert.Len(t, resp.Pots, 1) {
+		assert.Equal(t, 200, int(resp.Pots[0].Amount))
+		assert.Equal(t, []*abstracts.PotWinner{ { UserID: g.players[1].ID(), Amount: 200 } }, resp.Pots[0].Winners)
+	}
+	// 输家不是最后加注的人，盖牌
+	assert.Equal(t, &abstracts.ShowdownPlayer{ UserID: g.players[0].ID(), Mucked: true }, resp.Players[0])
+	winner := resp.Players[1]
+	assert.False(t, winner.Mucked)
+	assert.Equal(t, 200, int(winner.Won))
+	assert.Equal(t, []int{ 0 }, winner.WonPots)
+	assert.Len(t, winner.Pokers, 2)
+	assert.Len(t, winner.BestFive, 5)
+	assert.Equal(t, "Full house, Kings full of Sevens", winner.HandName)
+	assert.Equal(t, "葫芦，三条K带一对7", winner.CnHandName)
+
+	// 最后加注的人输了也要亮牌
+	g.lastAggressor = 0
+	resp = g.showdownResp(g.chipPool.finalizePots(g.rankPlayers()), nil, nil)
+	assert.False(t, resp.Players[0].Mucked)
+	assert.Equal(t, "Two pair, Aces and Kings", resp.Players[0].HandName)
+	// 河牌都过牌，第一个说话的人输了也要亮牌
+	g.lastAggressor = -1
+	g.startBetAt = 0
+	resp = g.showdownResp(g.chipPool.finalizePots(g.rankPlayers()), nil, nil)
+	assert.False(t, resp.Players[0].Mucked)
+	g.startBetAt = 1
+	resp = g.showdownResp(g.chipPool.finalizePots(g.rankPlayers()), nil, nil)
+	assert.True(t, resp.Players[0].Mucked)
+	// all in后发牌到最后都亮牌
+	g.runout = true
+	resp = g.showdownResp(g.chipPool.finalizePots(g.rankPlayers()), nil, nil)
+	assert.False(t, resp.Players[0].Mucked)
+}
+
+// 河牌轮结束后进入亮牌，最后下注的人要留着
+func TestGameShowdownKeepsRiverAggressor(t *testing.T) {
+	g := NewGame(TableLevel{ Xm: 10 }, newFakePlayersHeadsUp(), &fakeMsgSender{}, make(chan *GameResult, 1))
+	g.curRound = g.lastRound()
+	g.lastAggressor = 0
+	g.setupNewRound()
+	assert.True(t, g.curRound > g.lastRound())
+	assert.Equal(t, 0, g.lastAggressor)
+}
+
+// 边池分别结算，每个人赢了哪几个池子
+func TestGameShowdownSidePots(t *testing.T) {
+	players := newFakePlayersInTable1()
+	g := NewGame(TableLevel{ Xm: 10 }, players, &fakeMsgSender{}, make(chan *GameResult, 1))
+	g.setPokers(t, []string{ "2c", "7d", "9h", "Jc", "3s" }, []string{ "Ks", "Kh" }, []string{ "Qs", "Qh" }, []string{ "As", "Ah" }, []string{ "4s", "5h" }, []string{ "4d", "5d" })
+	g.players[3].Discard()
+	g.players[4].Discard()
+	assert.NoError(t, g.chipPool.bet(1, 2, 50, true))
+	assert.NoError(t, g.chipPool.bet(1, 0, 100, false))
+	assert.NoError(t, g.chipPool.bet(1, 1, 100, false))
+
+	resp := g.showdownResp(g.chipPool.finalizePots(g.rankPlayers()), nil, nil)
+	if assert.Len(t, resp.Pots, 2) {
+		assert.Equal(t, 150, int(resp.Pots[0].Amount))
+		assert.Equal(t, g.players[2].ID(), resp.Pots[0].Winners[0].UserID)
+		assert.Equal(t, 100, int(resp.Pots[1].Amount))
+		assert.Equal(t, g.players[0].ID(), resp.Pots[1].Winners[0].UserID)
+	}
+	// 弃牌的人不在结果中
+	if assert.Len(t, resp.Players, 3) {
+		assert.Equal(t, []int{ 1 }, resp.Players[0].WonPots)
+		assert.Equal(t, "Pair of Kings", resp.Players[0].HandName)
+		assert.True(t, resp.Players[1].Mucked)
+		assert.Equal(t, []int{ 0 }, resp.Players[2].WonPots)
+		assert.Equal(t, 150, int(resp.Players[2].Won))
+	}
+}
+
+// 奥马哈高低牌：高牌和低牌各拿一半
+func TestGameShowdownHiLo(t *testing.T) {
+	g := NewGame(TableLevel{ Xm: 10, Variant: VariantOmahaHiLo }, newFakePlayersHeadsUp(), &fakeMsgSender{}, make(chan *GameResult, 1))
+	g.setPokers(t, []string{ "2c", "3d", "7h", "Kc", "Ks" }, []string{ "As", "4s", "Qd", "Qh" }, []string{ "Kd", "9s", "9h", "8d" })
+	assert.NoError(t, g.chipPool.bet(1, 0, 100, false))
+	assert.NoError(t, g.chipPool.bet(1, 1, 101, false))
+
+	// 只有一个人能用两张手牌成低牌
+	assert.Equal(t, [][]uint{ {0} }, g.rankLowPlayers())
+	pots, lowPots := g.chipPool.finalizeRunsPots([][][]uint{ g.rankPlayers() }, [][][]uint{ g.rankLowPlayers() })
+	resp := g.showdownResp(pots, lowPots, nil)
+	if assert.Len(t, resp.Pots, 1) {
+		assert.Equal(t, []*abstracts.PotWinner{
+			{ UserID: g.players[1].ID(), Amount: 101 },
+			{ UserID: g.players[0].ID(), Amount: 100, Low: true },
+		}, resp.Pots[0].Winners)
+	}
+	assert.Equal(t, "7-4-3-2-A", resp.Players[0].LowName)
+	assert.Equal(t, "Two pair, Kings and Queens", resp.Players[0].HandName)
+	assert.Equal(t, 100, int(resp.Players[0].Won))
+	assert.Equal(t, "", resp.Players[1].LowName)
+	assert.Equal(t, "Three of a kind, Kings", resp.Players[1].HandName)
+	assert.Equal(t, 101, int(resp.Players[1].Won))
+
+	// all in时的胜率也按高低牌算：河牌Ts时平分，5c时0号A2345顺子通吃
+	g.setPokers(t, []string{ "2c", "3d", "7h", "Kc" })
+	deck, err := stringsToPokers([]string{ "Ts", "5c" })
+	assert.NoError(t, err)
+	g.cardHeap = &PokerHeap{ Pokers: deck }
+	equity := g.allInEquity()
+	assert.InDelta(t, 0.75, equity[0], 0.0001)
+	assert.InDelta(t, 0.25, equity[1], 0.0001)
+}
+
+// 发了两次牌时每次的牌型分开报，CommonPokers和玩家的牌型是第一次的
+func TestGameShowdownRuns(t *testing.T) {
+	g := NewGame(TableLevel{ Xm: 10, RunTimes: 2 }, newFakePlayersHeadsUp(), &fakeMsgSender{}, make(chan *GameResult, 1))
+	g.setPokers(t, []string{ "2c", "7d", "9h", "Jc", "Kd" }, []string{ "As", "Ah" }, []string{ "Ks", "Kh" })
+	second := g.commonPokers
+	g.setPokers(t, []string{ "2c", "7d", "9h", "Jc", "3s" })
+	boards := [][]abstracts.Poker{ g.commonPokers, second }
+	assert.NoError(t, g.chipPool.bet(1, 0, 100, false))
+	assert.NoError(t, g.chipPool.bet(1, 1, 100, false))
+
+	var runs [][][]uint
+	for _, board := range boards {
+		g.commonPokers = board
+		runs = append(runs, g.rankPlayers())
+	}
+	g.commonPokers = boards[0]
+	pots, lowPots := g.chipPool.finalizeRunsPots(runs, nil)
+	resp := g.showdownResp(pots, lowPots, boards)
+	assert.Len(t, resp.Boards, 2)
+	assert.Equal(t, "3s", resp.CommonPokers[4].Whole)
+	assert.Equal(t, "Kd", resp.Boards[1][4].Whole)
+	if assert.Len(t, resp.Players, 2) {
+		assert.Equal(t, "Pair of Aces", resp.Players[0].HandName)
+		assert.Equal(t, "Pair of Kings", resp.Players[1].HandName)
+		if assert.Len(t, resp.Players[1].Runs, 2) {
+			assert.Equal(t, "Pair of Kings", resp.Players[1].Runs[0].HandName)
+			assert.Equal(t, "Three of a kind, Kings", resp.Players[1].Runs[1].HandName)
+			assert.Len(t, resp.Players[1].Runs[1].BestFive, 5)
+		}
+		assert.Equal(t, 100, int(resp.Players[0].Won))
+		assert.Equal(t, 100, int(resp.Players[1].Won))
+	}
+
+	// 只发一次时没有Runs
+	resp = g.showdownResp(g.chipPool.finalizePots(g.rankPlayers()), nil, nil)
+	assert.Empty(t, resp.Boards)
+	assert.Empty(t, resp.Players[0].Runs)
+}
+
+// 打到河牌结束时广播摊牌
+func TestGameShowdownBroadcast(t *testing.T) {
+	sender := &recordMsgSender{}
+	resultC := make(chan *GameResult)
+	g := NewGame(TableLevel{ Xm: 10 }, newFakePlayersHeadsUp(), sender, resultC)
+	go g.Run()
+	time.Sleep(100 * time.Millisecond)
+
+	onGames([]*Game{ g }, 0, abstracts.GameActionOfBet, 10)
+	onGames([]*Game{ g }, 1, abstracts.GameActionOfBet, 0)
+	for round := 2; round <= 4; round++ {
+		onGames([]*Game{ g }, 1, abstracts.GameActionOfBet, 0)
+		onGames([]*Game{ g }, 0, abstracts.GameActionOfBet, 0)
+	}
+	<- resultC
+	showdowns := sender.get(abstracts.MsgTypeShowdown)
+	if assert.Len(t, showdowns, 1) {
+		resp := showdowns[0].(abstracts.ShowdownResp)
+		assert.Len(t, resp.Players, 2)
+		assert.Equal(t, 40, int(resp.Pots[0].Amount))
+	}
+}
@@ -0,0 +1,416 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"go.uber.org/zap"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/abstracts"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/core/hand_processor"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/common/log"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/common/util"
+)
+
+/*
+
+崩溃恢复
+
+桌子定时把座位、筹码以及正在打的局的全部状态（牌堆、筹码池、每一步操作）存到SnapshotStore
+进程挂掉重启后，用快照恢复桌子：
+1. 座位上的人原样坐回去
+1. 正在打的局可以从快照的位置继续打，也可以直接作废。带入的筹码只在一局结束时结算，因此作废这局就等于把所有下注退回
+
+*/
+type SnapshotStore interface {
+	Save(s *TableSnapshot) error
+	// 没有快照时返回nil, nil
+	Load(tableID int) (*TableSnapshot, error)
+	Delete(tableID int) error
+}
+
+type TableSnapshot struct {
+	TableID int `json:"table_id"`
+	Level TableLevel `json:"level"`
+	CurD int `json:"cur_d"`
+	// 空座位为nil
+	Seats []*SeatSnapshot `json:"seats"`
+	// 没有在打的局时为nil
+	Game *GameSnapshot `json:"game"`
+	// 快照时间，UnixNano
+	Time int64 `json:"time"`
+}
+
+type SeatSnapshot struct {
+	UserID string `json:"user_id"`
+	// 快照时的余额，锦标赛中就是用户的筹码
+	Balance uint64 `json:"balance"`
+	// 本局中途离开，这局结束后离座
+	Left bool `json:"left"`
+}
+
+type GameSnapshot struct {
+	ID int64 `json:"id"`
+	Xm uint64 `json:"xm"`
+	Dm uint64 `json:"dm"`
+	Ante uint64 `json:"ante"`
+	ButtonBlind uint64 `json:"button_blind"`
+	Variant GameVariant `json:"variant"`
+	Betting BettingType `json:"betting"`
+	SmallBet uint64 `json:"small_bet"`
+	BigBet uint64 `json:"big_bet"`
+	RaiseCap int `json:"raise_cap"`
+	RunTimes int `json:"run_times"`
+	Players []*PlayerSnapshot `json:"players"`
+	// 牌堆中剩余的牌，按发牌顺序
+	Deck []string `json:"deck"`
+	CommonPokers []string `json:"common_pokers"`
+	ChipPool *TermChipPoolSnapshot `json:"chip_pool"`
+	CurBetPlayer uint `json:"cur_bet_player"`
+	CurRound uint `json:"cur_round"`
+	StartBetAt uint `json:"start_bet_at"`
+	DiscardedPlayerCount uint `json:"discarded_player_count"`
+	AllInnedPlayerCount uint `json:"all_inned_player_count"`
+	RaiseCount int `json:"raise_count"`
+	LastAggressor int `json:"last_aggressor"`
+	LastRaise uint64 `json:"last_raise"`
+	Actions []GameActionRecord `json:"actions"`
+}
+
+type PlayerSnapshot struct {
+	Index uint `json:"index"`
+	ID string `json:"id"`
+	BringIn uint64 `json:"bring_in"`
+	Remain uint64 `json:"remain"`
+	Win uint64 `json:"win"`
+	Discarded bool `json:"discarded"`
+	AllInned bool `json:"all_inned"`
+	Pokers []string `json:"pokers"`
+}
+
+type TermChipPoolSnapshot struct {
+	Rounds []*RoundBetSnapshot `json:"rounds"`
+	// 按pool链表的顺序
+	Pools []*ChipPoolSnapshot `json:"pools"`
+}
+
+// 某一轮的下注情况
+type RoundBetSnapshot struct {
+	Round uint `json:"round"`
+	MaxAmount uint64 `json:"max_amount"`
+	Bets []PlayerChipSnapshot `json:"bets"`
+}
+
+type ChipPoolSnapshot struct {
+	Round uint `json:"round"`
+	MaxAmount uint64 `json:"max_amount"`
+	HaveAllIn bool `json:"have_all_in"`
+	Total []PlayerChipSnapshot `json:"total"`
+}
+
+// 快照中不用map，按player排序后结果是确定的
+type PlayerChipSnapshot struct {
+	Player uint `json:"player"`
+	Amount uint64 `json:"amount"`
+}
+
+// 一局中的每一步操作，超时也记录
+type GameActionRecord struct {
+	Round uint `json:"round"`
+	Player uint `json:"player"`
+	ActionType abstracts.GameAction `json:"action_type"`
+	Amount uint64 `json:"amount"`
+	Timeout bool `json:"timeout"`
+}
+
+// 只有*Player能存快照，其他实现的玩家返回错误
+func toGameSnapshot(g *Game) (*GameSnapshot, error) {
+	s := &GameSnapshot{
+		ID: g.id, Xm: g.xmBet, Dm: g.dmBet, Ante: g.ante, ButtonBlind: g.buttonBlind, Variant: g.variant, RunTimes: g.runTimes,
+		CommonPokers: pokersToStrings(g.commonPokers),
+		ChipPool: toTermChipPoolSnapshot(g.chipPool),
+		CurBetPlayer: g.curBetPlayer,
+		CurRound: g.curRound,
+		StartBetAt: g.startBetAt,
+		DiscardedPlayerCount: g.discardedPlayerCount,
+		AllInnedPlayerCount: g.allInnedPlayerCount,
+		RaiseCount: g.raiseCount,
+		LastAggressor: g.lastAggressor,
+		LastRaise: g.lastRaise,
+		Actions: append([]GameActionRecord{}, g.actions...),
+	}
+	switch b := g.betting.(type) {
+	case *potLimitBetting:
+		s.Betting = BettingPotLimit
+	case *fixedLimitBetting:
+		s.Betting, s.SmallBet, s.BigBet, s.RaiseCap = BettingFixedLimit, b.smallBet, b.bigBet, b.raiseCap
+	default:
+		s.Betting = BettingNoLimit
+	}
+	if heap, ok := g.cardHeap.(*PokerHeap); ok {
+		for _, p := range heap.Pokers {
+			s.Deck = append(s.Deck, p.GetWhole())
+		}
+	}
+	for i := uint(0); i < g.playersLen; i++ {
+		p, ok := g.players[i].(*Player)
+		if !ok {
+			return nil, fmt.Errorf("player %v can't be snapshotted, type: %T", i, g.players[i])
+		}
+		s.Players = append(s.Players, &PlayerSnapshot{
+			Index: p.playerIndex, ID: p.id,
+			BringIn: p.bringIn, Remain: p.remain, Win: p.win,
+			Discarded: p.discarded, AllInned: p.allInned,
+			Pokers: pokersToStrings(p.pokers),
+		})
+	}
+	return s, nil
+}
+
+func toTermChipPoolSnapshot(tp *termChipPool) *TermChipPoolSnapshot {
+	s := &TermChipPoolSnapshot{}
+	var rounds []uint
+	for r := range tp.roundTotalBet {
+		rounds = append(rounds, r)
+	}
+	for r := range tp.roundMaxAmount {
+		if tp.roundTotalBet[r] == nil {
+			rounds = append(rounds, r)
+		}
+	}
+	sort.Slice(rounds, func(i, j int) bool { return rounds[i] < rounds[j] })
+	for _, r := range rounds {
+		s.Rounds = append(s.Rounds, &RoundBetSnapshot{ Round: r, MaxAmount: tp.roundMaxAmount[r], Bets: toPlayerChips(tp.roundTotalBet[r]) })
+	}
+	for next := tp.pool; next != nil; next = next.nextPool {
+		s.Pools = append(s.Pools, &ChipPoolSnapshot{ Round: next.round, MaxAmount: next.maxAmount, HaveAllIn: next.haveAllIn, Total: toPlayerChips(next.total) })
+	}
+	return s
+}
+
+func (s *TermChipPoolSnapshot) restore() (*termChipPool, error) {
+	if len(s.Pools) == 0 {
+		return nil, errors.New("chip pool snapshot has no pool")
+	}
+	tp := newTermChipPool()
+	for _, rs := range s.Rounds {
+		tp.roundMaxAmount[rs.Round] = rs.MaxAmount
+		tp.roundTotalBet[rs.Round] = fromPlayerChips(rs.Bets)
+	}
+	var last *chipPool
+	for _, ps := range s.Pools {
+		p := newChipPool(ps.Round)
+		p.maxAmount = ps.MaxAmount
+		p.haveAllIn = ps.HaveAllIn
+		p.total = fromPlayerChips(ps.Total)
+		if last == nil {
+			tp.pool = p
+		} else {
+			last.nextPool = p
+		}
+		last = p
+	}
+	return tp, nil
+}
+
+func toPlayerChips(m map[uint]uint64) []PlayerChipSnapshot {
+	var result []PlayerChipSnapshot
+	for player, amount := range m {
+		result = append(result, PlayerChipSnapshot{ Player: player, Amount: amount })
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Player < result[j].Player })
+	return result
+}
+
+func fromPlayerChips(chips []PlayerChipSnapshot) map[uint]uint64 {
+	result := map[uint]uint64{}
+	for _, c := range chips {
+		result[c.Player] = c.Amount
+	}
+	return result
+}
+
+func pokersToStrings(ps []abstracts.Poker) []string {
+	var result []string
+	for _, p := range ps {
+		result = append(result, p.GetWhole())
+	}
+	return result
+}
+
+func stringsToPokers(strs []string) ([]*hand_processor.Poker, error) {
+	var result []*hand_processor.Poker
+	for _, str := range strs {
+		if len(str) != 2 {
+			return nil, fmt.Errorf("invalid poker: %v", str)
+		}
+		p := hand_processor.PokerStrToPoker(str)
+		if p == nil {
+			return nil, fmt.Errorf("invalid poker: %v", str)
+		}
+		result = append(result, p)
+	}
+	return result, nil
+}
+
+func stringsToAbsPokers(strs []string) ([]abstracts.Poker, error) {
+	ps, err := stringsToPokers(strs)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]abstracts.Poker, len(ps))
+	util.InterfaceSliceCopy(result, ps)
+	return result, nil
+}
+
+/*
+
+用快照恢复一局游戏，随后调用Run会从快照的位置继续打（不会重新发牌和下盲注）
+牌堆也是快照中的，因此后续发出的牌和崩溃前会发的牌一样
+
+*/
+func restoreGame(s *GameSnapshot, sender gameMsgSender, resultChan chan *GameResult) (*Game, error) {
+	if len(s.Players) < 2 {
+		return nil, errors.New("game snapshot has less than 2 players")
+	}
+	players := map[uint]abstracts.Player{}
+	for _, ps := range s.Players {
+		if ps.Index >= uint(len(s.Players)) {
+			return nil, fmt.Errorf("invalid player index in game snapshot: %v", ps.Index)
+		}
+		pokers, err := stringsToAbsPokers(ps.Pokers)
+		if err != nil {
+			return nil, err
+		}
+		players[ps.Index] = &Player{
+			playerIndex: ps.Index, id: ps.ID,
+			bringIn: ps.BringIn, remain: ps.Remain, win: ps.Win,
+			discarded: ps.Discarded, allInned: ps.AllInned,
+			pokers: pokers,
+		}
+	}
+	if len(players) != len(s.Players) || s.CurBetPlayer >= uint(len(players)) {
+		return nil, errors.New("invalid player index in game snapshot")
+	}
+	deck, err := stringsToPokers(s.Deck)
+	if err != nil {
+		return nil, err
+	}
+	commonPokers, err := stringsToAbsPokers(s.CommonPokers)
+	if err != nil {
+		return nil, err
+	}
+	if s.ChipPool == nil {
+		return nil, errors.New("game snapshot has no chip pool")
+	}
+	chipPool, err := s.ChipPool.restore()
+	if err != nil {
+		return nil, err
+	}
+
+	g := NewGame(TableLevel{ Xm: s.Xm, Dm: s.Dm, Ante: s.Ante, ButtonBlind: s.ButtonBlind, Variant: s.Variant, Betting: s.Betting, SmallBet: s.SmallBet, BigBet: s.BigBet, RaiseCap: s.RaiseCap, RunTimes: s.RunTimes }, players, sender, resultChan)
+	g.id = s.ID
+	g.cardHeap = &PokerHeap{ Pokers: deck }
+	g.gameStatus = gameStatus{
+		chipPool: chipPool,
+		curBetPlayer: s.CurBetPlayer,
+		curRound: s.CurRound,
+		startBetAt: s.StartBetAt,
+		discardedPlayerCount: s.DiscardedPlayerCount,
+		allInnedPlayerCount: s.AllInnedPlayerCount,
+		raiseCount: s.RaiseCount,
+		lastAggressor: s.LastAggressor,
+		lastRaise: s.LastRaise,
+		commonPokers: commonPokers,
+	}
+	g.actions = append([]GameActionRecord{}, s.Actions...)
+	g.restored = true
+	return g, nil
+}
+
+func NewFileSnapshotStore(dir string) *FileSnapshotStore {
+	return &FileSnapshotStore{ dir: dir }
+}
+
+// 每张桌子一个json文件，先写临时文件并落盘再rename，写到一半挂掉也不会损坏上一个快照
+type FileSnapshotStore struct {
+	dir string
+}
+
+func (s *FileSnapshotStore) path(tableID int) string {
+	return filepath.Join(s.dir, fmt.Sprintf("table_%v.json", tableID))
+}
+
+func (s *FileSnapshotStore) Save(snapshot *TableSnapshot) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	path := s.path(snapshot.TableID)
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY | os.O_CREATE | os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	data, err := util.StringifyJsonToBytesWithErr(snapshot)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	return syncDir(s.dir)
+}
+
+// rename只改了目录项，目录也要落盘，否则掉电后可能还是旧文件或者没有文件
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	if err := d.Sync(); err != nil {
+		d.Close()
+		return err
+	}
+	return d.Close()
+}
+
+func (s *FileSnapshotStore) Load(tableID int) (*TableSnapshot, error) {
+	data, err := ioutil.ReadFile(s.path(tableID))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snapshot TableSnapshot
+	if err := util.ParseJsonFromBytes(data, &snapshot); err != nil {
+		return nil, err
+	}
+	if snapshot.TableID != tableID {
+		return nil, fmt.Errorf("snapshot table id not match, want: %v, got: %v", tableID, snapshot.TableID)
+	}
+	return &snapshot, nil
+}
+
+func (s *FileSnapshotStore) Delete(tableID int) error {
+	err := os.Remove(s.path(tableID))
+	if err != nil && !os.IsNotExist(err) {
+		log.L.Error("delete table snapshot failed", zap.Int("table id", tableID), zap.Error(err))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/abstracts"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/common/util"
+)
+
+// round从快照里取，快照在loop中生成，一定在上一条消息处理完之后
+func onGames(gs []*Game, player uint, action abstracts.GameAction, amount uint64) {
+	for _, g := range gs {
+		s, _ := g.Snapshot()
+		if s == nil {
+			continue
+		}
+		g.OnMsg(abstracts.PlayerActionMsg{
+			MsgID: time.Now().UnixNano(), UserID: strconv.Itoa(int(player)), Player: player,
+			GameID: g.id, Round: s.CurRound, ActionType: action, Amount: amount,
+		})
+	}
+	// 其他测试调用后直接读game里的状态，要等处理完
+	time.Sleep(10 * time.Millisecond)
+}
+
+// 打到有边池时做快照，用快照恢复的局和原来的局做同样的操作，结果一样
+func newSnapshotGame(t *testing.T) (*Game, chan *GameResult, *GameSnapshot) {
+	betTimeout = 10 * time.Second
+	resultC := make(chan *GameResult, 1)
+	g := NewGame(TableLevel{ Xm: 10 }, newFakePlayersInTable2(), &fakeMsgSender{}, resultC)
+	go g.Run()
+	time.Sleep(100 * time.Millisecond)
+
+	gs := []*Game{ g }
+	// 第一轮都跟注，第二轮2号位筹码少all in，其他人跟
+	onGames(gs, 3, abstracts.GameActionOfBet, 20)
+	onGames(gs, 4, abstracts.GameActionOfBet, 20)
+	onGames(gs, 0, abstracts.GameActionOfBet, 20)
+	onGames(gs, 1, abstracts.GameActionOfBet, 10)
+	onGames(gs, 1, abstracts.GameActionOfBet, 0)
+	onGames(gs, 2, abstracts.GameActionOfBet, 1480)
+	onGames(gs, 3, abstracts.GameActionOfBet, 1480)
+	onGames(gs, 4, abstracts.GameActionOfBet, 1480)
+	onGames(gs, 0, abstracts.GameActionOfBet, 1480)
+	onGames(gs, 1, abstracts.GameActionOfBet, 1480)
+	// 第三轮1号位下注，分出边池
+	onGames(gs, 1, abstracts.GameActionOfBet, 100)
+
+	s, err := g.Snapshot()
+	assert.NoError(t, err)
+	if !assert.NotNil(t, s) {
+		t.FailNow()
+	}
+	assert.Equal(t, 3, int(s.CurRound))
+	assert.Equal(t, 3, int(s.CurBetPlayer))
+	assert.Len(t, s.ChipPool.Pools, 2)
+	assert.Len(t, s.Actions, 11)
+	assert.Len(t, s.CommonPokers, 4)
+	assert.Len(t, s.Deck, 52 - 5 * 2 - 4)
+	return g, resultC, s
+}
+
+func TestGameSnapshot(t *testing.T) {
+	g, resultC, s := newSnapshotGame(t)
+
+	// 存储时是json，恢复后再做快照和原来的一样
+	var parsed GameSnapshot
+	assert.NoError(t, util.ParseJsonFromBytes(util.StringifyJsonToBytes(s), &parsed))
+	restoredC := make(chan *GameResult, 1)
+	restored, err := restoreGame(&parsed, &fakeMsgSender{}, restoredC)
+	assert.NoError(t, err)
+	restoredSnapshot, err := toGameSnapshot(restored)
+	assert.NoError(t, err)
+	assert.Equal(t, util.StringifyJson(s), util.StringifyJson(restoredSnapshot))
+	go restored.Run()
+	time.Sleep(100 * time.Millisecond)
+
+	both := []*Game{ g, restored }
+	onGames(both, 3, abstracts.GameActionOfBet, 100)
+	onGames(both, 4, abstracts.GameActionOfDiscard, 0)
+	onGames(both, 0, abstracts.GameActionOfBet, 100)
+	onGames(both, 1, abstracts.GameActionOfBet, 0)
+	onGames(both, 3, abstracts.GameActionOfBet, 0)
+	onGames(both, 0, abstracts.GameActionOfBet, 0)
+
+	result := <- resultC
+	restoredResult := <- restoredC
+	assert.Equal(t, result.id, restoredResult.id)
+	var total uint64
+	for i, p := range result.players {
+		rp := restoredResult.players[i]
+		assert.Equal(t, p.ID(), rp.ID())
+		change, isAdd := p.Result()
+		rChange, rIsAdd := rp.Result()
+		assert.Equal(t, change, rChange)
+		assert.Equal(t, isAdd, rIsAdd)
+		total += p.RemainChip() + p.(*Player).win
+	}
+	// 没有抽成时筹码总数不变
+	assert.True(t, total <= 2000 * 4 + 1500 && total >= 2000 * 4 + 1500 - 3)
+}
+
+// 短牌的D盲注也要存下来
+func TestGameSnapshot_ButtonBlind(t *testing.T) {
+	betTimeout = 10 * time.Second
+	g := NewGame(TableLevels[22], newFakePlayersInTable1(), &fakeMsgSender{}, make(chan *GameResult, 1))
+	go g.Run()
+	time.Sleep(100 * time.Millisecond)
+	s, err := g.Snapshot()
+	assert.NoError(t, err)
+	g.Cancel()
+	if !assert.NotNil(t, s) {
+		t.FailNow()
+	}
+	assert.Equal(t, 20, int(s.ButtonBlind))
+
+	var parsed GameSnapshot
+	assert.NoError(t, util.ParseJsonFromBytes(util.StringifyJsonToBytes(s), &parsed))
+	restored, err := restoreGame(&parsed, &fakeMsgSender{}, make(chan *GameResult, 1))
+	assert.NoError(t, err)
+	assert.Equal(t, 20, int(restored.buttonBlind))
+	restoredSnapshot, err := toGameSnapshot(restored)
+	assert.NoError(t, err)
+	assert.Equal(t, util.StringifyJson(s), util.StringifyJson(restoredSnapshot))
+}
+
+type otherPlayer struct {
+	*Player
+}
+
+// 不是*Player的玩家存不了快照
+func TestGameSnapshot_OtherPlayer(t *testing.T) {
+	g := NewGame(TableLevel{ Xm: 10 }, newFakePlayersHeadsUp(), &fakeMsgSender{}, make(chan *GameResult, 1))
+	g.players[1] = otherPlayer{ g.players[1].(*Player) }
+	s, err := toGameSnapshot(g)
+	assert.Error(t, err)
+	assert.Nil(t, s)
+}
+
+// 局中有人找不到或选择不接着打时，这局作废，每个人的余额都是这局开始前的
+func TestTableRestore(t *testing.T) {
+	g, _, s := newSnapshotGame(t)
+	g.Cancel()
+
+	users := map[string]*fakeUser{}
+	ts := &TableSnapshot{ TableID: 1, CurD: 0, Seats: make([]*SeatSnapshot, 6), Game: s }
+	for i := 0; i < 5; i++ {
+		id := strconv.Itoa(i)
+		users[id] = &fakeUser{ uid: id, balance: 10000 }
+		ts.Seats[i] = &SeatSnapshot{ UserID: id, Balance: 10000 }
+	}
+	ts.Seats[4].Left = true
+	getUser := func(uID string) abstracts.User {
+		u, ok := users[uID]
+		if !ok {
+			return nil
+		}
+		return u
+	}
+
+	table := NewTable(1, 6, TableLevel{ Xm: 10 }, &fakeTableMsgSender{})
+	assert.NoError(t, table.Restore(ts, getUser, true))
+	assert.NotNil(t, table.curGame)
+	assert.Equal(t, 5, table.seatedUserCount())
+	assert.Len(t, table.leavedUsers, 1)
+	assert.Error(t, NewTable(2, 6, TableLevel{ Xm: 10 }, &fakeTableMsgSender{}).Restore(ts, getUser, true))
+
+	table = NewTable(1, 6, TableLevel{ Xm: 10 }, &fakeTableMsgSender{})
+	assert.NoError(t, table.Restore(ts, getUser, false))
+	assert.Nil(t, table.curGame)
+	assert.Equal(t, 5, table.seatedUserCount())
+
+	delete(users, "2")
+	table = NewTable(1, 6, TableLevel{ Xm: 10 }, &fakeTableMsgSender{})
+	assert.NoError(t, table.Restore(ts, getUser, true))
+	assert.Nil(t, table.curGame)
+	assert.Equal(t, 4, table.seatedUserCount())
+	for _, u := range users {
+		assert.Equal(t, 10000, int(u.balance))
+	}
+}
+
+func TestFileSnapshotStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "table_snapshot")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	store := NewFileSnapshotStore(dir)
+
+	s, err := store.Load(1)
+	assert.NoError(t, err)
+	assert.Nil(t, s)
+	assert.NoError(t, store.Save(&TableSnapshot{ TableID: 1, Seats: []*SeatSnapshot{ nil, { UserID: "1", Balance: 100 } } }))
+	s, err = store.Load(1)
+	assert.NoError(t, err)
+	assert.Nil(t, s.Seats[0])
+	assert.Equal(t, "1", s.Seats[1].UserID)
+	assert.NoError(t, store.Delete(1))
+	assert.NoError(t, store.Delete(1))
+	s, err = store.Load(1)
+	assert.NoError(t, err)
+	assert.Nil(t, s)
+}
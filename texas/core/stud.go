@@ -0,0 +1,193 @@
+package core
+
+import (
+	"sort"
+	"go.uber.org/zap"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/abstracts"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/core/hand_processor"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/common/log"
+)
+
+/*
+
+七张梭哈
+
+第1轮是第三街：每人两张暗牌一张明牌（门牌），门牌最小的人下bring-in，由他左边的人第一个说话
+第2到4轮是第四到第六街：每人一张明牌，明牌最大的人先说话
+第5轮是第七街：每人一张暗牌，说话顺序同第六街
+前注在第0轮，下注结构默认固定限注，第三、四街小注，后三街大注
+人多牌不够时那一街只发一张公共牌，所有人共用，因此一桌最多8人
+
+*/
+const studLastRound = 5
+
+// 8人到第七街正好用完52张牌（第七街发公共牌）
+const studMaxPlayers = 8
+
+// 每人手牌中第几张是明牌，第1、2、7张是暗牌
+func studUpCard(i int) bool {
+	return i >= 2 && i < 6
+}
+
+func (g *Game) dealStudCards() {
+	switch g.curRound {
+	case 1:
+		for i := uint(0); i < g.playersLen; i++ {
+			g.players[i].GotPokers(g.cardHeap.DispatchPokers(3))
+		}
+	case 2, 3, 4, 5:
+		var live []uint
+		for i := uint(0); i < g.playersLen; i++ {
+			if !g.players[i].Discarded() {
+				live = append(live, i)
+			}
+		}
+		// 每一街都要检查，超过8人时第七街之前就可能不够
+		if heap, ok := g.cardHeap.(*PokerHeap); ok && len(heap.Pokers) < len(live) {
+			if len(heap.Pokers) == 0 {
+				log.L.Warn("stud heap empty", zap.Uint("cur round", g.curRound), zap.Int("live", len(live)))
+				return
+			}
+			log.L.Debug("stud heap not enough, deal a common poker", zap.Int("heap len", len(heap.Pokers)), zap.Int("live", len(live)))
+			g.commonPokers = append(g.commonPokers, g.cardHeap.DispatchPokers(1)...)
+			return
+		}
+		for _, i := range live {
+			g.players[i].GotPokers(g.cardHeap.DispatchPokers(1))
+		}
+	default:
+		log.L.Warn("invalid round for dealStudCards", zap.Uint("cur round", g.curRound))
+	}
+}
+
+// 门牌最小的人下bring-in，由他左边的人第一个说话
+func (g *Game) betBringIn() {
+	bringIn := g.studBringInPlayer()
+	g.betForced(1, bringIn, g.xmBet)
+	g.curBetPlayer = g.nextPlayer(bringIn)
+	g.startBetAt = g.curBetPlayer
+}
+
+/*
+
+门牌最小的人，A最大，点数一样时按花色比，梅花最小，然后是方块、红桃、黑桃
+
+*/
+func (g *Game) studBringInPlayer() (result uint) {
+	var lowest int
+	for i := uint(0); i < g.playersLen; i++ {
+		door := g.players[i].Pokers()[2]
+		card, err := hand_processor.CardOf(door.GetWhole())
+		if err != nil {
+			panic("parse door card failed: " + err.Error())
+		}
+		// Card的花色顺序是shdc，反过来梅花最小
+		value := card.Rank() * 4 + 3 - card.Suit()
+		if i == 0 || value < lowest {
+			result, lowest = i, value
+		}
+	}
+	return
+}
+
+/*
+
+第四街起明牌最大的人先说话，只看四条、三条、两对、对子和单张，不看顺子和同花
+一样大时座位靠前的先说，明牌最大的人已经all in时由他左边的人先说
+
+*/
+func (g *Game) studFirstToAct() uint {
+	var best uint
+	var bestValue int
+	found := false
+	for i := uint(0); i < g.playersLen; i++ {
+		p := g.players[i]
+		if p.Discarded() {
+			continue
+		}
+		if value := showingValue(studUpPokers(p)); !found || value > bestValue {
+			best, bestValue, found = i, value, true
+		}
+	}
+	if g.players[best].AllInned() {
+		return g.nextBetPlayer(best)
+	}
+	return best
+}
+
+func studUpPokers(p abstracts.Player) (result []abstracts.Poker) {
+	for i, poker := range p.Pokers() {
+		if studUpCard(i) {
+			result = append(result, poker)
+		}
+	}
+	return
+}
+
+/*
+
+明牌的大小，最高4位是牌型（四条4、三条3、两对2、对子1、单张0）
+后边每4位一个点数，张数多的在前，一样多的大的在前
+
+*/
+func showingValue(pokers []abstracts.Poker) int {
+	counts := map[int]int{}
+	var ranks []int
+	for _, poker := range pokers {
+		card, err := hand_processor.CardOf(poker.GetWhole())
+		if err != nil {
+			panic("parse up card failed: " + err.Error())
+		}
+		if counts[card.Rank()] == 0 {
+			ranks = append(ranks, card.Rank())
+		}
+		counts[card.Rank()]++
+	}
+	sort.Slice(ranks, func(i, j int) bool {
+		if counts[ranks[i]] != counts[ranks[j]] {
+			return counts[ranks[i]] > counts[ranks[j]]
+		}
+		return ranks[i] > ranks[j]
+	})
+
+	category := 0
+	if len(ranks) > 0 {
+		switch counts[ranks[0]] {
+		case 4:
+			category = 4
+		case 3:
+			category = 3
+		case 2:
+			category = 1
+			if len(ranks) > 1 && counts[ranks[1]] == 2 {
+				category = 2
+			}
+		}
+	}
+	value := category
+	for i := 0; i < 4; i++ {
+		value <<= 4
+		if i < len(ranks) {
+			// 点数加1，和没有牌区分开
+			value |= ranks[i] + 1
+		}
+	}
+	return value
+}
+
+// 梭哈的玩家场景：明牌所有人可见，暗牌只有自己可见，弃牌的人别人看不到他的牌
+func toStudPlayerScene(uid string, p abstracts.Player) *abstracts.PlayerScene {
+	rp := toPlayerScene(uid, p)
+	rp.Pokers = nil
+	self := p.ID() == uid
+	if p.Discarded() && !self {
+		return rp
+	}
+	for i, poker := range p.Pokers() {
+		up := studUpCard(i)
+		if up || self {
+			rp.Pokers = append(rp.Pokers, &abstracts.PokerScene{ Whole: poker.GetWhole(), Up: up })
+		}
+	}
+	return rp
+}
@@ -0,0 +1,179 @@
+package core
+
+import (
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/abstracts"
+)
+
+func TestShowingValue(t *testing.T) {
+	value := func(strs ...string) int {
+		ps, err := stringsToAbsPokers(strs)
+		assert.NoError(t, err)
+		return showingValue(ps)
+	}
+	// 对子比单张大，顺子同花不算
+	assert.True(t, value("2s", "2h") > value("As", "Kh"))
+	assert.True(t, value("As", "Kh") > value("4c", "5c", "6c", "7c"))
+	assert.True(t, value("As", "Kh") > value("As", "Qh"))
+	// 两对比对子大，三条比两对大，四条最大
+	assert.True(t, value("3s", "3h", "2d", "2c") > value("As", "Ah", "Kd", "Qc"))
+	assert.True(t, value("2s", "2h", "2d", "3c") > value("As", "Ah", "Kd", "Kc"))
+	assert.True(t, value("2s", "2h", "2d", "2c") > value("As", "Ah", "Ad", "Kc"))
+	// 对子一样比单张，花色不算
+	assert.True(t, value("Ks", "Kh", "9d") > value("Kd", "Kc", "8s"))
+	assert.Equal(t, value("Ks", "9h"), value("Kd", "9c"))
+}
+
+func newStudGame(t *testing.T, variant GameVariant, sender gameMsgSender, resultC chan *GameResult, deck ...string) *Game {
+	players := map[uint]abstracts.Player{
+		0: newPlayerWithFakeUser(0, 2000),
+		1: newPlayerWithFakeUser(1, 2000),
+		2: newPlayerWithFakeUser(2, 2000),
+	}
+	g := NewGame(TableLevel{ Xm: 5, Ante: 2, Variant: variant }, players, sender, resultC)
+	pokers, err := stringsToPokers(deck)
+	assert.NoError(t, err)
+	g.cardHeap = &PokerHeap{ Pokers: pokers }
+	return g
+}
+
+func TestGameStud(t *testing.T) {
+	betTimeout = 10 * time.Second
+	resultC := make(chan *GameResult, 1)
+	sender := &recordMsgSender{}
+	g := newStudGame(t, VariantStud, sender, resultC,
+		// 第三街：两张暗牌一张门牌
+		"As", "Ah", "Kd",
+		"2s", "3s", "4c",
+		"7h", "8h", "4d",
+		// 第四街
+		"Kh", "5c", "9h",
+		// 第五到第七街，2号已经弃牌
+		"2d", "6c",
+		"3d", "Qs",
+		"4h", "Tc",
+	)
+	go g.Run()
+	time.Sleep(100 * time.Millisecond)
+	gs := []*Game{ g }
+
+	// 门牌一样时梅花最小，1号下bring-in，2号先说话
+	g.betRight(t, 1, 2 + 5)
+	assert.Equal(t, 2, int(g.curBetPlayer))
+	assert.Equal(t, 0, g.raiseCount)
+
+	// 明牌所有人可见，暗牌只有自己可见
+	scene := g.GetScene("2")
+	if assert.Len(t, scene.Players["1"].Pokers, 1) {
+		assert.Equal(t, &abstracts.PokerScene{ Whole: "4c", Up: true }, scene.Players["1"].Pokers[0])
+	}
+	assert.Equal(t, []*abstracts.PokerScene{ { Whole: "7h" }, { Whole: "8h" }, { Whole: "4d", Up: true } }, scene.Players["2"].Pokers)
+
+	// bring-in后只能补齐到一整注
+	onGames(gs, 2, abstracts.GameActionOfBet, 15)
+	g.betRight(t, 2, 2)
+	onGames(gs, 2, abstracts.GameActionOfBet, 10)
+	assert.Equal(t, 1, g.raiseCount)
+	onGames(gs, 0, abstracts.GameActionOfBet, 10)
+	onGames(gs, 1, abstracts.GameActionOfBet, 5)
+	assert.Equal(t, 2, int(g.curRound))
+
+	// 第四街0号明牌有对子，先说话
+	assert.Equal(t, 0, int(g.curBetPlayer))
+	onGames(gs, 0, abstracts.GameActionOfBet, 10)
+	onGames(gs, 1, abstracts.GameActionOfBet, 10)
+	onGames(gs, 2, abstracts.GameActionOfDiscard, 0)
+	assert.Equal(t, 3, int(g.curRound))
+
+	// 弃牌的人别人看不到他的牌
+	scene = g.GetScene("0")
+	assert.Empty(t, scene.Players["2"].Pokers)
+	assert.Len(t, scene.Players["1"].Pokers, 3)
+
+	// 第五街后每轮都是0号先说话，大注20
+	for round := 3; round <= 5; round++ {
+		assert.Equal(t, round, int(g.curRound))
+		assert.Equal(t, 0, int(g.curBetPlayer))
+		onGames(gs, 0, abstracts.GameActionOfBet, 0)
+		onGames(gs, 1, abstracts.GameActionOfBet, 0)
+	}
+
+	result := <- resultC
+	assert.False(t, result.cancelled)
+	// 1号2到6的顺子赢了0号的两对
+	showdowns := sender.get(abstracts.MsgTypeShowdown)
+	if assert.Len(t, showdowns, 1) {
+		resp := showdowns[0].(abstracts.ShowdownResp)
+		assert.Empty(t, resp.CommonPokers)
+		if assert.Len(t, resp.Pots, 1) {
+			assert.Equal(t, 2 * 3 + 10 * 3 + 10 * 2, int(resp.Pots[0].Amount))
+			assert.Equal(t, []*abstracts.PotWinner{ { UserID: "1", Amount: 56 } }, resp.Pots[0].Winners)
+		}
+		if assert.Len(t, resp.Players, 2) {
+			assert.Len(t, resp.Players[1].Pokers, 7)
+			assert.Equal(t, "Straight, Six high", resp.Players[1].HandName)
+		}
+	}
+}
+
+// 牌不够每人一张时第七街发一张公共牌
+func TestGameStudCommonPoker(t *testing.T) {
+	g := newStudGame(t, VariantStud, &fakeMsgSender{}, make(chan *GameResult, 1), "Ac")
+	g.setPokers(t, nil, []string{ "As", "Ah", "Kd", "Kh", "2d", "3d" }, []string{ "2s", "3s", "4c", "5c", "6c", "Qs" }, []string{ "7h", "8h", "9d", "Tc", "Jd", "Js" })
+	g.curRound = studLastRound
+	g.dealStudCards()
+	assert.Len(t, g.commonPokers, 1)
+	assert.Len(t, g.players[0].Pokers(), 6)
+	// 公共牌和自己的6张牌组成牌型
+	assert.Equal(t, [][]uint{ { 0 }, { 2 }, { 1 } }, g.rankPlayers())
+}
+
+// 七张梭哈高低牌：用自己的七张牌成低牌，高牌和低牌各拿一半
+func TestGameStudHiLo(t *testing.T) {
+	g := newStudGame(t, VariantStudHiLo, &fakeMsgSender{}, make(chan *GameResult, 1))
+	assert.Equal(t, BettingFixedLimit, TableLevel{ Variant: VariantStudHiLo }.BettingType())
+	g.setPokers(t, nil, []string{ "As", "Ah", "Kd", "Kh", "2d", "3d", "Qh" }, []string{ "2s", "3s", "4c", "6c", "7d", "Js", "Jd" }, []string{ "8h", "8c", "9d", "Tc", "5d", "5h", "Qs" })
+	for i := uint(0); i < 3; i++ {
+		assert.NoError(t, g.chipPool.bet(1, i, 100, false))
+	}
+
+	assert.Equal(t, [][]uint{ {1} }, g.rankLowPlayers())
+	pots, lowPots := g.chipPool.finalizeRunsPots([][][]uint{ g.rankPlayers() }, [][][]uint{ g.rankLowPlayers() })
+	resp := g.showdownResp(pots, lowPots, nil)
+	if assert.Len(t, resp.Pots, 1) {
+		assert.Equal(t, []*abstracts.PotWinner{
+			{ UserID: g.players[0].ID(), Amount: 150 },
+			{ UserID: g.players[1].ID(), Amount: 150, Low: true },
+		}, resp.Pots[0].Winners)
+	}
+	assert.Equal(t, "Two pair, Aces and Kings", resp.Players[0].HandName)
+	assert.Equal(t, "", resp.Players[0].LowName)
+	assert.Equal(t, "7-6-4-3-2", resp.Players[1].LowName)
+}
+
+// 超过8人第六街牌就不够了，桌子不能超过8个座位；牌不够的那一街都发公共牌，不会越界
+func TestGameStudNinePlayers(t *testing.T) {
+	assert.Panics(t, func() { NewTable(1, 9, TableLevels[51], &fakeTableMsgSender{}) })
+	assert.Panics(t, func() { NewTournamentTable(1, 10, TableLevels[52], &fakeTableMsgSender{}, nil) })
+	assert.NotPanics(t, func() { NewTable(1, 8, TableLevels[51], &fakeTableMsgSender{}) })
+
+	for _, count := range []int{ 9, 10 } {
+		players := map[uint]abstracts.Player{}
+		for i := 0; i < count; i++ {
+			players[uint(i)] = newPlayerWithFakeUser(uint(i), 2000)
+		}
+		g := NewGame(TableLevel{ Xm: 5, Ante: 2, Variant: VariantStud }, players, &fakeMsgSender{}, make(chan *GameResult, 1))
+		assert.NotPanics(t, func() {
+			for g.curRound = 1; g.curRound <= studLastRound; g.curRound++ {
+				g.dealStudCards()
+			}
+		})
+		// 第六街和第七街各发一张公共牌，10人时正好发完
+		assert.Len(t, g.commonPokers, 2)
+		assert.Len(t, g.players[0].Pokers(), 5)
+		assert.Len(t, g.cardHeap.(*PokerHeap).Pokers, 52 - count * 5 - 2)
+		assert.NotEmpty(t, g.rankPlayers())
+	}
+}
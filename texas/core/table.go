@@ -3,20 +3,29 @@ package core
 import (
 	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 	"go.uber.org/zap"
 	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/abstracts"
 	"github.com/LeaguesOfHoleHoleShoes/HoleHole/common/log"
-	"github.com/LeaguesOfHoleHoleShoes/HoleHole/common/util"
 )
 
 type msgSender interface {
-	Send(id string, msgType int, mID int64, msg []byte)
+	// msg由sender按接收者的codec编码
+	Send(id string, msgType int, mID int64, msg interface{})
 }
 
 func NewTable(id int, seatCount int, level TableLevel, msgSender msgSender) *Table {
+	if level.Variant.Stud() && seatCount > studMaxPlayers {
+		panic(fmt.Sprintf("stud table has at most %v seats, got %v", studMaxPlayers, seatCount))
+	}
 	timer := time.NewTimer(time.Second)
 	timer.Stop()
+	drainTimer := time.NewTimer(time.Second)
+	drainTimer.Stop()
+	snapshotTimer := time.NewTimer(time.Second)
+	snapshotTimer.Stop()
 	return &Table{
 		id: id, level: level, seats: make([]abstracts.User, seatCount),
 		seatCount: seatCount, msgSender: msgSender,
@@ -26,12 +35,48 @@ func NewTable(id int, seatCount int, level TableLevel, msgSender msgSender) *Tab
 		enterChan: make(chan withErrMsg, 1),
 		leaveChan: make(chan withErrMsg, 1),
 		actionChan: make(chan actionMsg, 1),
+		levelChan: make(chan TableLevel, 1),
+		removeChan: make(chan withErrMsg, 1),
+		resumeChan: make(chan struct{}, 1),
+		drainChan: make(chan drainMsg, 1),
+		drainTimer: drainTimer,
+		snapshotTimer: snapshotTimer,
 		gameFinishedChan: make(chan *GameResult, 1),
+		stopChan: make(chan struct{}),
 	}
 }
 
 /*
 
+锦标赛用的桌子
+1. 用户不需要每局发ready，坐下的人每局都会参与，不操作就超时过牌或弃牌
+2. 用户的Balance就是他在锦标赛中的筹码，每局都全部带入
+3. 每局结束后筹码为0的用户被淘汰，并通过observer通知上层
+4. 有observer时，每局结束后桌子会挂起，等上层处理完淘汰、换桌等再调用Resume开始下一局
+
+*/
+func NewTournamentTable(id int, seatCount int, level TableLevel, msgSender msgSender, observer TableObserver) *Table {
+	t := NewTable(id, seatCount, level, msgSender)
+	t.tournament = true
+	t.observer = observer
+	return t
+}
+
+// 桌子每局结束后回调给上层（锦标赛等）。在table的loop中调用，实现方不能阻塞，也不能在里边同步调用table的方法
+// 回调后桌子处于挂起状态，上层必须调用Resume桌子才会开始下一局
+type TableObserver interface {
+	OnGameFinished(tableID int, busted []BustedUser)
+}
+
+// 本局被淘汰的用户
+type BustedUser struct {
+	User abstracts.User
+	// 本局开始时的筹码，同一局被淘汰的多个用户按它来排名
+	OriginChip uint64
+}
+
+/*
+
 用户加入桌子
 用户坐下
 检查是否要开始游戏
@@ -51,6 +96,15 @@ type Table struct {
 	seats []abstracts.User
 	seatCount int
 	msgSender msgSender
+	// 锦标赛模式，见NewTournamentTable
+	tournament bool
+	observer TableObserver
+	// 锦标赛桌子一局结束后挂起，直到调用Resume
+	held bool
+	// 停服中，不再接受Enter也不再开新局，当前这局结束后关闭桌子
+	draining bool
+	// 关闭完成后通知Drain的调用方
+	drainDoneChan chan error
 
 	// 记录最近一次准备开始时，准备好的用户。每次准备计时结束后，都要清空该数据
 	preparedUsers map[string]int
@@ -69,8 +123,21 @@ type Table struct {
 	enterChan chan withErrMsg
 	leaveChan chan withErrMsg
 	actionChan chan actionMsg
+	levelChan chan TableLevel
+	removeChan chan withErrMsg
+	resumeChan chan struct{}
+	drainChan chan drainMsg
+	// 当前这局超过该时间还没结束则取消
+	drainTimer *time.Timer
+	// 崩溃恢复用，为nil则不存快照
+	snapshotStore SnapshotStore
+	snapshotInterval time.Duration
+	snapshotTimer *time.Timer
 	gameFinishedChan chan *GameResult
+	// 只close不重新赋值，loop一直在读它
 	stopChan chan struct{}
+	stopOnce sync.Once
+	started int32
 }
 
 func (t *Table) loop() {
@@ -88,6 +155,21 @@ func (t *Table) loop() {
 			t.doLeave(msg)
 		case msg := <- t.actionChan:
 			t.doActionChan(msg)
+		case level := <- t.levelChan:
+			// 只影响下一局
+			t.level = level
+		case msg := <- t.removeChan:
+			t.doRemove(msg)
+		case <- t.resumeChan:
+			t.held = false
+			t.prepareStartCheck()
+		case msg := <- t.drainChan:
+			t.doDrain(msg)
+		case <- t.drainTimer.C:
+			t.cancelGame()
+		case <- t.snapshotTimer.C:
+			t.saveSnapshot()
+			t.snapshotTimer.Reset(t.snapshotInterval)
 		case result := <- t.gameFinishedChan:
 			t.doGameFinished(result)
 		case <- t.stopChan:
@@ -98,6 +180,14 @@ func (t *Table) loop() {
 
 // 检查是否可以开始游戏
 func (t *Table) startGameCheck() {
+	// 上一局还没结束，等结束后会重新准备。挂起中则等Resume后再准备。停服中不再开局
+	if t.curGame != nil || t.held || t.draining {
+		return
+	}
+	if t.tournament {
+		t.startTournamentGameCheck()
+		return
+	}
 	readyUser := 0
 	// 检查用户是否已提交准备，如果没有则将其移除该桌子
 	for i := 0; i < t.seatCount; i++ {
@@ -129,6 +219,23 @@ func (t *Table) startGameCheck() {
 	}
 }
 
+// 锦标赛不需要ready，有筹码的人都参与
+func (t *Table) startTournamentGameCheck() {
+	t.preparedUsers = nil
+	if t.seatedUserCount() > 1 {
+		t.startGame()
+	}
+}
+
+func (t *Table) seatedUserCount() (count int) {
+	for _, u := range t.seats {
+		if u != nil {
+			count++
+		}
+	}
+	return
+}
+
 func (t *Table) userInReadyMap(u abstracts.User) bool {
 	for uID, exist := range t.preparedUsers {
 		if uID == u.ID() && exist == 1 {
@@ -149,7 +256,7 @@ func (t *Table) startGame() {
 	}
 
 	t.leavedUsers = map[int]abstracts.User{}
-	t.curGame = NewGame(t.level.Xm, t.getPlayersFromSeats(), t, t.gameFinishedChan)
+	t.curGame = NewGame(t.level, t.getPlayersFromSeats(), t, t.gameFinishedChan)
 	go t.curGame.Run()
 }
 
@@ -163,19 +270,19 @@ func (t *Table) getPlayersFromSeats() map[uint]abstracts.Player {
 	// find cur d
 	dIndex, dUser := t.nextUser(t.curD)
 	t.curD = dIndex
-	result[0] = NewPlayer(0, dUser, t.level.BringIn)
+	result[0] = NewPlayer(0, dUser, t.bringInOf(dUser))
 	log.L.Info("find cur game d", zap.Int("cur d", t.curD), zap.String("cur d id", dUser.ID()))
 
-	i := -1
+	i := dIndex
 	var u abstracts.User = nil
 	playerIndex := uint(1)
 	count := 0
 	for {
-		i, u = t.nextUser(dIndex)
+		i, u = t.nextUser(i)
 		if i == dIndex {
 			break
 		}
-		result[playerIndex] = NewPlayer(playerIndex, u, t.level.BringIn)
+		result[playerIndex] = NewPlayer(playerIndex, u, t.bringInOf(u))
 		playerIndex++
 
 		count++
@@ -187,6 +294,14 @@ func (t *Table) getPlayersFromSeats() map[uint]abstracts.Player {
 	return result
 }
 
+// 锦标赛每局带入全部筹码
+func (t *Table) bringInOf(u abstracts.User) uint64 {
+	if t.tournament {
+		return u.Balance()
+	}
+	return t.level.BringIn
+}
+
 // 获取下一个座位
 func (t *Table) nextSeat(cur int) int {
 	next := cur + 1
@@ -224,6 +339,7 @@ func (t *Table) doGameFinished(result *GameResult) {
 	}
 
 	// 处理结果
+	var busted []BustedUser
 	for _, p := range result.players {
 		u := t.getUserByIDFromSeat(p.ID())
 		if u == nil {
@@ -232,6 +348,9 @@ func (t *Table) doGameFinished(result *GameResult) {
 		}
 		u.ChangeBalance(p.Result())
 		// todo 记录变化
+		if t.tournament && u.Balance() == 0 {
+			busted = append(busted, BustedUser{ User: u, OriginChip: p.OriginChip() })
+		}
 	}
 
 	// 移除离开的用户
@@ -240,11 +359,41 @@ func (t *Table) doGameFinished(result *GameResult) {
 	}
 	t.leavedUsers = nil
 	t.curGame = nil
+
+	if t.tournament {
+		t.removeBustedUsers(busted)
+	}
+	// 结算完马上存，否则崩溃后会用旧快照把结算过的局再打一遍
+	t.saveSnapshot()
+	if t.draining {
+		t.closeSeats(result.cancelled)
+		return
+	}
+	// 现金桌不自动开下一局，等有人进入桌子时再准备
+	if t.tournament {
+		if t.observer != nil {
+			t.held = true
+			t.observer.OnGameFinished(t.id, busted)
+			return
+		}
+		t.prepareStartCheck()
+	}
+}
+
+// 锦标赛中筹码输光的用户离开桌子
+func (t *Table) removeBustedUsers(busted []BustedUser) {
+	for _, b := range busted {
+		for i, u := range t.seats {
+			if u != nil && u.ID() == b.User.ID() {
+				t.seats[i] = nil
+			}
+		}
+	}
 }
 
 func (t *Table) getUserByIDFromSeat(id string) abstracts.User {
 	for _, u := range t.seats {
-		if u.ID() == id {
+		if u != nil && u.ID() == id {
 			return u
 		}
 	}
@@ -260,16 +409,29 @@ func (t *Table) getUserByIDFromSeat(id string) abstracts.User {
 
 */
 func (t *Table) doGetScene(msg getSceneMsg) {
-	// 组装每个seat的状态
-	gameScene := t.curGame.GetScene(msg.uID)
 	result := abstracts.TableScene{
 		CurD: t.curD,
 		Players: make([]*abstracts.PlayerScene, t.seatCount),
-		CommonPokers: gameScene.CommonPokers,
-		ChipPools: gameScene.ChipPools,
+	}
+	// 没有在打的局时只有座位上的人
+	var gameScene *abstracts.GameScene
+	if t.curGame != nil {
+		gameScene = t.curGame.GetScene(msg.uID)
+	}
+	if gameScene != nil {
+		result.CommonPokers = gameScene.CommonPokers
+		result.ChipPools = gameScene.ChipPools
 	}
 
+	// 组装每个seat的状态
 	for i, u := range t.seats {
+		if u == nil {
+			continue
+		}
+		if gameScene == nil {
+			result.Players[i] = &abstracts.PlayerScene{ UserID: u.ID(), RemainChip: t.bringInOf(u) }
+			continue
+		}
 		if u.ID() == gameScene.CurBet {
 			result.CurBet = i
 		}
@@ -291,6 +453,10 @@ func (t *Table) doReady(msg withErrMsg) {
 // 处理用户进入桌子
 // 判断是否要准备开始
 func (t *Table) doEnter(msg withErrMsg) {
+	if t.draining {
+		msg.resultChan <- errors.New("table is closing")
+		return
+	}
 	sitUser := 0
 	sit := false
 	for i := 0; i < t.seatCount; i++ {
@@ -309,15 +475,98 @@ func (t *Table) doEnter(msg withErrMsg) {
 		msg.resultChan <- errors.New("no more seat")
 	}
 
-	if sitUser > 1 {
-		// 准备开始游戏
-		t.latestPrepareMsgID = time.Now().UnixNano()
-		t.BroadcastMsg(abstracts.MsgTypePrepare, t.latestPrepareMsgID, nil)
-		t.prepareStartTimer.Reset(2 * time.Second)
-		t.preparedUsers = map[string]int{}
+	// 正在打或挂起中，结束后会再准备
+	if sitUser > 1 && t.curGame == nil && !t.held {
+		t.prepareStart()
+	}
+}
+
+// 锦标赛换桌、拆桌时把用户从桌子上移走，只能在两局之间做
+func (t *Table) doRemove(msg withErrMsg) {
+	if t.curGame != nil {
+		msg.resultChan <- errors.New("game is running, can't remove user")
+		return
+	}
+	for i, u := range t.seats {
+		if u != nil && u.ID() == msg.user.ID() {
+			t.seats[i] = nil
+			msg.resultChan <- nil
+			return
+		}
+	}
+	msg.resultChan <- errors.New("user not in table")
+}
+
+type drainMsg struct {
+	timeout time.Duration
+	resultChan chan error
+}
+
+/*
+
+停服
+1. 不再接受Enter，也不再准备开新局
+1. 没有在打的局则直接关闭
+1. 有在打的局则等它正常结束并结算，超过timeout还没结束则取消本局，所有下注退回后照常结算
+1. 关闭时通知桌上所有人，并清空座位
+
+*/
+func (t *Table) doDrain(msg drainMsg) {
+	if t.draining {
+		msg.resultChan <- errors.New("table already draining")
+		return
+	}
+	log.L.Info("table draining", zap.Int("table id", t.id), zap.Bool("have game", t.curGame != nil))
+	t.draining = true
+	t.prepareStartTimer.Stop()
+	t.preparedUsers = nil
+	t.drainDoneChan = msg.resultChan
+	if t.curGame == nil {
+		t.closeSeats(false)
+		return
+	}
+	t.drainTimer.Reset(msg.timeout)
+}
+
+func (t *Table) cancelGame() {
+	if t.curGame == nil {
+		return
+	}
+	log.L.Warn("table drain timeout, cancel game", zap.Int("table id", t.id), zap.Int64("game id", t.curGame.ID()))
+	t.curGame.Cancel()
+}
+
+// 结算都在doGameFinished中做完了，这里只需要通知并清空座位
+func (t *Table) closeSeats(gameCancelled bool) {
+	t.drainTimer.Stop()
+	t.BroadcastMsg(abstracts.MsgTypeTableClosed, time.Now().UnixNano(), abstracts.TableClosedResp{ TableID: t.id, GameCancelled: gameCancelled })
+	for i := range t.seats {
+		t.seats[i] = nil
+	}
+	if t.snapshotStore != nil {
+		// 正常关闭的桌子重启后不需要恢复
+		t.snapshotStore.Delete(t.id)
+	}
+	log.L.Info("table drained", zap.Int("table id", t.id))
+	t.drainDoneChan <- nil
+	t.drainDoneChan = nil
+}
+
+// 桌上有两个人以上则准备开始下一局
+func (t *Table) prepareStartCheck() {
+	if t.seatedUserCount() > 1 && !t.draining {
+		t.prepareStart()
 	}
 }
 
+// 准备开始游戏
+func (t *Table) prepareStart() {
+	t.latestPrepareMsgID = time.Now().UnixNano()
+	t.BroadcastMsg(abstracts.MsgTypePrepare, t.latestPrepareMsgID, nil)
+	t.prepareStartTimer.Reset(2 * time.Second)
+	t.preparedUsers = map[string]int{}
+}
+
 // 找到user，
 func (t *Table) doLeave(msg withErrMsg) {
 	if t.curGame == nil {
@@ -330,7 +579,7 @@ func (t *Table) doLeave(msg withErrMsg) {
 	}
 
 	for index, u := range t.seats {
-		if u.ID() == msg.user.ID() {
+		if u != nil && u.ID() == msg.user.ID() {
 			t.leavedUsers[index] = u
 		}
 	}
@@ -393,8 +642,135 @@ func (t *Table) Do(action abstracts.PlayerActionMsg) error {
 	return <- result
 }
 
+func (t *Table) Remove(u abstracts.User) error {
+	result := make(chan error)
+	t.removeChan <- withErrMsg{ user: u, resultChan: result }
+	return <- result
+}
+
+// 阻塞到桌子关闭完成，随后可以调用Stop
+func (t *Table) Drain(timeout time.Duration) error {
+	result := make(chan error, 1)
+	t.drainChan <- drainMsg{ timeout: timeout, resultChan: result }
+	return <- result
+}
+
+/*
+
+开启快照，每隔interval把桌子和正在打的局存一次，一局结算完也会马上存一次
+必须在Start之前调用
+
+*/
+func (t *Table) EnableSnapshot(store SnapshotStore, interval time.Duration) {
+	if interval <= 0 {
+		panic("snapshot interval must be greater than 0")
+	}
+	t.snapshotStore = store
+	t.snapshotInterval = interval
+}
+
+func (t *Table) saveSnapshot() {
+	if t.snapshotStore == nil {
+		return
+	}
+	s := &TableSnapshot{
+		TableID: t.id, Level: t.level, CurD: t.curD,
+		Seats: make([]*SeatSnapshot, t.seatCount),
+		Time: time.Now().UnixNano(),
+	}
+	for i, u := range t.seats {
+		if u != nil {
+			s.Seats[i] = &SeatSnapshot{ UserID: u.ID(), Balance: u.Balance(), Left: t.leavedUsers[i] != nil }
+		}
+	}
+	if t.curGame != nil {
+		g, ok := t.curGame.(*Game)
+		if !ok {
+			return
+		}
+		var err error
+		if s.Game, err = g.Snapshot(); err != nil {
+			log.L.Error("snapshot game failed", zap.Int("table id", t.id), zap.Error(err))
+			return
+		}
+		// 这局已经打完但还没结算，结算后会再存
+		if s.Game == nil {
+			return
+		}
+	}
+	if err := t.snapshotStore.Save(s); err != nil {
+		log.L.Error("save table snapshot failed", zap.Int("table id", t.id), zap.Error(err))
+	}
+}
+
+/*
+
+用快照恢复桌子，必须在Start之前调用
+1. 用getUser取到的用户坐回原来的座位，找不到的用户不再入座。锦标赛中getUser返回的用户筹码应该是快照中的Balance
+1. resume为true时，正在打的局从快照的位置继续打
+1. resume为false，或局中有人没能入座、快照损坏时，这局作废。局中的下注都还没有结算，因此作废就是原样退回，每个人的余额都是这局开始前的
+
+*/
+func (t *Table) Restore(s *TableSnapshot, getUser func(uID string) abstracts.User, resume bool) error {
+	if atomic.LoadInt32(&t.started) == 1 {
+		return errors.New("table already started")
+	}
+	if s.TableID != t.id || len(s.Seats) != t.seatCount {
+		return fmt.Errorf("snapshot not match table, table id: %v, snapshot table id: %v", t.id, s.TableID)
+	}
+	t.curD = s.CurD
+	for i, ss := range s.Seats {
+		if ss == nil {
+			continue
+		}
+		u := getUser(ss.UserID)
+		if u == nil {
+			log.L.Warn("restore table, but can't find user", zap.Int("table id", t.id), zap.String("uid", ss.UserID))
+			continue
+		}
+		t.seats[i] = u.Copy()
+	}
+	if s.Game == nil {
+		return nil
+	}
+	if !resume {
+		log.L.Info("restore table, refund game", zap.Int("table id", t.id), zap.Int64("game id", s.Game.ID))
+		return nil
+	}
+	for _, p := range s.Game.Players {
+		if t.getUserByIDFromSeat(p.ID) == nil {
+			log.L.Warn("restore table, player not seated, refund game", zap.Int("table id", t.id), zap.Int64("game id", s.Game.ID), zap.String("uid", p.ID))
+			return nil
+		}
+	}
+	g, err := restoreGame(s.Game, t, t.gameFinishedChan)
+	if err != nil {
+		log.L.Error("restore game failed, refund game", zap.Int("table id", t.id), zap.Int64("game id", s.Game.ID), zap.Error(err))
+		return nil
+	}
+	t.curGame = g
+	t.leavedUsers = map[int]abstracts.User{}
+	for i, ss := range s.Seats {
+		if ss != nil && ss.Left && t.seats[i] != nil {
+			t.leavedUsers[i] = t.seats[i]
+		}
+	}
+	log.L.Info("restore table, resume game", zap.Int("table id", t.id), zap.Int64("game id", g.ID()))
+	return nil
+}
+
+// 挂起的锦标赛桌子开始准备下一局
+func (t *Table) Resume() {
+	t.resumeChan <- struct{}{}
+}
+
+// 修改桌子的级别（涨盲），下一局开始生效
+func (t *Table) SetLevel(level TableLevel) {
+	t.levelChan <- level
+}
+
 func (t *Table) SendMsg(playerID string, msgType int, mID int64, msg interface{}) {
-	t.msgSender.Send(playerID, msgType, mID, util.StringifyJsonToBytes(msg))
+	t.msgSender.Send(playerID, msgType, mID, msg)
 }
 
 func (t *Table) BroadcastMsg(msgType int, msgID int64, msg interface{}) {
@@ -412,22 +788,35 @@ func (t *Table) BroadcastMsg(msgType int, msgID int64, msg interface{}) {
 }
 
 func (t *Table) Start() error {
-	if t.stopChan != nil {
+	if !atomic.CompareAndSwapInt32(&t.started, 0, 1) {
 		return errors.New("already started")
 	}
-	t.stopChan = make(chan struct{})
+	if t.snapshotStore != nil {
+		t.snapshotTimer.Reset(t.snapshotInterval)
+	}
+	// 从快照恢复的局接着打，没有则看座位上的人是否够开局
+	if t.curGame != nil {
+		go t.curGame.Run()
+	} else {
+		// 作废的局马上用新快照覆盖掉
+		t.saveSnapshot()
+		t.prepareStartCheck()
+	}
 	go t.loop()
 
 	return nil
 }
 
 func (t *Table) Stop() error {
-	if t.stopChan == nil {
+	if atomic.LoadInt32(&t.started) == 0 {
 		return errors.New("not started")
 	}
-	close(t.stopChan)
-	t.stopChan = nil
+	stopped := errors.New("already stopped")
+	t.stopOnce.Do(func() {
+		close(t.stopChan)
+		stopped = nil
+	})
 
-	return nil
+	return stopped
 }
 
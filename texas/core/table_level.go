@@ -4,13 +4,139 @@ var TableLevels = map[int]TableLevel {
 	1: { Xm: 10, BringIn: 4000, MinHave: 500 },
 	2: { Xm: 100, BringIn: 4000 * 10, MinHave: 500 * 10 },
 	3: { Xm: 1000, BringIn: 4000 * 100, MinHave: 500 * 100 },
+	// 奥马哈的级别从11开始
+	11: { Xm: 10, BringIn: 4000, MinHave: 500, Variant: VariantOmaha },
+	12: { Xm: 10, BringIn: 4000, MinHave: 500, Variant: VariantOmahaHiLo },
+	// 短牌的级别从21开始
+	21: { Xm: 10, BringIn: 4000, MinHave: 500, Variant: VariantShortDeck },
+	22: { Xm: 10, Ante: 10, ButtonBlind: 20, BringIn: 4000, MinHave: 500, Variant: VariantShortDeck },
+	// 限注德州的级别从31开始
+	31: { Xm: 10, BringIn: 4000, MinHave: 500, Betting: BettingFixedLimit },
+	32: { Xm: 10, BringIn: 4000, MinHave: 500, Betting: BettingPotLimit },
+	// 赖子德州的级别从41开始
+	41: { Xm: 10, BringIn: 4000, MinHave: 500, Variant: VariantWildcard },
+	// 七张梭哈的级别从51开始，Xm是bring-in
+	51: { Xm: 5, Ante: 2, BringIn: 4000, MinHave: 500, Variant: VariantStud },
+	52: { Xm: 5, Ante: 2, BringIn: 4000, MinHave: 500, Variant: VariantStudHiLo },
 }
 
 type TableLevel struct {
-	// 小盲下注多少
+	// 小盲下注多少，七张梭哈中是门牌最小的人下的bring-in
 	Xm uint64
+	// 大盲下注多少，为0则是小盲的两倍
+	Dm uint64
+	// 每人每局开始前下的前注，为0则没有前注
+	Ante uint64
 	// 用户每次必须带入多少筹码
 	BringIn uint64
 	// 至少有多少筹码才不会被踢出桌子
 	MinHave uint64
+	// 玩法，默认德州
+	Variant GameVariant
+	// D下的盲注（短牌常用的只下前注加D盲注），不为0时不再下大小盲，由D左边的人第一个说话
+	ButtonBlind uint64
+	// 下注结构，默认按玩法来：奥马哈底池限注，七张梭哈固定限注，其他无限注
+	Betting BettingType
+	// 固定限注时前两轮每次下注、加注多少，为0则是大盲
+	SmallBet uint64
+	// 固定限注时后两轮每次下注、加注多少，为0则是小注的两倍
+	BigBet uint64
+	// 固定限注时每轮最多下注加加注几次，为0则是4次
+	RaiseCap int
+	// 所有人all in后剩下的牌发几次，每个池子按次数平分，0和1都是发一次
+	RunTimes int
+}
+
+// 大盲下注多少
+func (l TableLevel) DmBet() uint64 {
+	if l.Dm == 0 {
+		return l.Xm * 2
+	}
+	return l.Dm
+}
+
+// 实际用的下注结构
+func (l TableLevel) BettingType() BettingType {
+	if l.Betting != BettingDefault {
+		return l.Betting
+	}
+	if l.Variant.Omaha() {
+		return BettingPotLimit
+	}
+	if l.Variant.Stud() {
+		return BettingFixedLimit
+	}
+	return BettingNoLimit
+}
+
+// 固定限注的小注和大注
+func (l TableLevel) LimitBets() (small uint64, big uint64) {
+	small, big = l.SmallBet, l.BigBet
+	if small == 0 {
+		small = l.DmBet()
+	}
+	if big == 0 {
+		big = small * 2
+	}
+	return
+}
+
+// 固定限注每轮最多下注加加注几次
+func (l TableLevel) RaiseLimit() int {
+	if l.RaiseCap == 0 {
+		return 4
+	}
+	return l.RaiseCap
+}
+
+type BettingType int
+
+const (
+	// 按玩法默认的下注结构
+	BettingDefault BettingType = iota
+	BettingNoLimit
+	BettingPotLimit
+	BettingFixedLimit
+)
+
+type GameVariant int
+
+const (
+	// 德州
+	VariantHoldem GameVariant = iota
+	// 奥马哈，四张手牌，必须用两张手牌加三张公共牌
+	VariantOmaha
+	// 短牌（6+）德州，去掉2到5，A6789是最小的顺子，同花比葫芦大
+	VariantShortDeck
+	// 赖子德州，牌堆中多一张赖子，可以当任意一张牌，四条加赖子是五条
+	VariantWildcard
+	// 奥马哈高低牌，每个池子高牌和低牌（8或更小）平分，没人成低牌时都给高牌
+	VariantOmahaHiLo
+	// 七张梭哈，没有公共牌，每人两张暗牌四张明牌一张暗牌，有前注和bring-in，每轮由明牌最大的人先说话
+	VariantStud
+	// 七张梭哈高低牌，发牌下注和七张梭哈一样，每个池子高牌和低牌（8或更小）平分
+	VariantStudHiLo
+)
+
+// 每人发几张手牌
+func (v GameVariant) HoleCards() int {
+	if v.Omaha() {
+		return 4
+	}
+	return 2
+}
+
+// 是否按奥马哈的规则：四张手牌，必须用两张手牌加三张公共牌
+func (v GameVariant) Omaha() bool {
+	return v == VariantOmaha || v == VariantOmahaHiLo
+}
+
+// 是否按七张梭哈的规则发牌和下注
+func (v GameVariant) Stud() bool {
+	return v == VariantStud || v == VariantStudHiLo
+}
+
+// 是否高低牌平分
+func (v GameVariant) HiLo() bool {
+	return v == VariantOmahaHiLo || v == VariantStudHiLo
 }
@@ -9,3 +9,20 @@ func TestTableLevel(t *testing.T) {
 	l := TableLevels[4]
 	assert.Equal(t, 0, int(l.Xm))
 }
+
+func TestTableLevel_Betting(t *testing.T) {
+	assert.Equal(t, BettingNoLimit, TableLevel{ Xm: 10 }.BettingType())
+	assert.Equal(t, BettingPotLimit, TableLevel{ Xm: 10, Variant: VariantOmaha }.BettingType())
+	assert.Equal(t, BettingPotLimit, TableLevel{ Xm: 10, Variant: VariantOmahaHiLo }.BettingType())
+	assert.Equal(t, 4, VariantOmahaHiLo.HoleCards())
+	assert.Equal(t, BettingNoLimit, TableLevel{ Xm: 10, Variant: VariantOmaha, Betting: BettingNoLimit }.BettingType())
+	assert.Equal(t, BettingFixedLimit, TableLevels[51].BettingType())
+
+	small, big := TableLevels[31].LimitBets()
+	assert.Equal(t, 20, int(small))
+	assert.Equal(t, 40, int(big))
+	assert.Equal(t, 4, TableLevels[31].RaiseLimit())
+	small, big = TableLevel{ Xm: 10, SmallBet: 50, BigBet: 80, RaiseCap: 3 }.LimitBets()
+	assert.Equal(t, 50, int(small))
+	assert.Equal(t, 80, int(big))
+}
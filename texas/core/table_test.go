@@ -2,11 +2,90 @@ package core
 
 import (
 	"testing"
+	"time"
 	"github.com/stretchr/testify/assert"
 	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/abstracts"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/common/util"
 )
 
 func TestNilSeat(t *testing.T) {
 	table := &Table{ seats: make([]abstracts.User, 5) }
 	assert.True(t, table.seats[2] == nil)
 }
+
+// 锦标赛桌子不需要ready就开局，输光的人离开桌子并通知observer
+func TestTournamentTable(t *testing.T) {
+	betTimeout = 200 * time.Millisecond
+	// D先拿72，另一个人拿AA，D输光
+	deck, err := stringsToPokers([]string{ "7c", "2d", "As", "Ah", "3s", "8h", "9d", "Jc", "Kd" })
+	assert.NoError(t, err)
+	newGameCardHeap = func(variant GameVariant) *PokerHeap { return &PokerHeap{ Pokers: deck } }
+	defer func() { newGameCardHeap = newVariantPokerHeap }()
+	observer := &fakeTableObserver{ finishedChan: make(chan []BustedUser, 1) }
+	table := NewTournamentTable(1, 3, TableLevel{ Xm: 10 }, &fakeTableMsgSender{}, observer)
+	assert.NoError(t, table.Start())
+
+	// 1号位是D，两个人时D是小盲，下盲注就all in了，直接发牌到最后比大小
+	bb := &fakeUser{ uid: "bb", balance: 1000 }
+	xm := &fakeUser{ uid: "xm", balance: 10 }
+	assert.NoError(t, table.Enter(bb))
+	assert.NoError(t, table.Enter(xm))
+
+	var busted []BustedUser
+	select {
+	case busted = <- observer.finishedChan:
+	case <- time.After(5 * time.Second):
+		t.Fatal("game not finished")
+	}
+
+	// 输光的人离开桌子
+	if assert.Len(t, busted, 1) {
+		assert.Equal(t, "xm", busted[0].User.ID())
+		assert.Equal(t, 10, int(busted[0].OriginChip))
+		assert.Equal(t, 0, int(busted[0].User.Balance()))
+	}
+	scene := table.GetScene("bb")
+	assert.Nil(t, scene.CommonPokers)
+	assert.Equal(t, "bb", scene.Players[0].UserID)
+	assert.Equal(t, 1010, int(scene.Players[0].RemainChip))
+	assert.Nil(t, scene.Players[1])
+
+	// 一局结束后桌子挂起，可以把人移走
+	assert.NoError(t, table.Remove(bb))
+	assert.Error(t, table.Remove(bb))
+	assert.NoError(t, table.Stop())
+}
+
+// 停服时不再接受Enter，当前这局超时后取消，通知桌上的人后清空座位
+func TestTableDrain(t *testing.T) {
+	betTimeout = 10 * time.Second
+	sender := &fakeTableMsgSender{}
+	table := NewTournamentTable(1, 3, TableLevel{ Xm: 10 }, sender, nil)
+	assert.NoError(t, table.Start())
+	assert.NoError(t, table.Enter(&fakeUser{ uid: "1", balance: 1000 }))
+	assert.NoError(t, table.Enter(&fakeUser{ uid: "2", balance: 1000 }))
+
+	// 等开局
+	time.Sleep(2100 * time.Millisecond)
+	start := time.Now()
+	assert.NoError(t, table.Drain(50 * time.Millisecond))
+	assert.True(t, time.Since(start) < time.Second)
+	assert.Nil(t, table.curGame)
+	assert.Equal(t, 0, table.seatedUserCount())
+
+	closed := sender.get(abstracts.MsgTypeTableClosed)
+	assert.Len(t, closed, 2)
+	var resp abstracts.TableClosedResp
+	assert.NoError(t, util.ParseJsonFromBytes(closed[0], &resp))
+	assert.True(t, resp.GameCancelled)
+
+	assert.Error(t, table.Enter(&fakeUser{ uid: "3", balance: 1000 }))
+	assert.Error(t, table.Drain(time.Second))
+	assert.NoError(t, table.Stop())
+
+	// 没有在打的局则直接关闭
+	table = NewTable(2, 3, TableLevel{ Xm: 10 }, sender)
+	assert.NoError(t, table.Start())
+	assert.NoError(t, table.Drain(time.Second))
+	assert.NoError(t, table.Stop())
+}
@@ -1,21 +1,58 @@
 package texas
 
 import (
+	"compress/flate"
+	"context"
+	"crypto/ed25519"
 	"errors"
 	"go.uber.org/zap"
 	"sync"
 	"sync/atomic"
 	"fmt"
+	"net/http"
+	"time"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/LeaguesOfHoleHoleShoes/HoleHole/common/msg_server"
 	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/abstracts"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/abstracts/pb"
 	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/core"
 	"github.com/LeaguesOfHoleHoleShoes/HoleHole/common/log"
-	"github.com/LeaguesOfHoleHoleShoes/HoleHole/common/util"
+)
+
+const (
+	// 停服时等待桌子上正在打的局结束的默认时间
+	defaultDrainTimeout = 30 * time.Second
+	// 停服时等待消息发完的时间
+	shutdownTimeout = 5 * time.Second
+	// 客户端要求批量时，广播事件在这个时间内合成一个frame
+	batchWindow = 5 * time.Millisecond
+)
+
+// 客户端的消息限流，正常打牌远到不了
+var (
+	userRateLimit = msg_server.RateLimit{ Rate: 20, Burst: 40 }
+	// 入座、离开、准备
+	seatRateLimit = msg_server.RateLimit{ Rate: 1, Burst: 5 }
 )
 
 func NewRoomServer(tableCount int, tableSeatCount int, tableLevel int, srvPort int) *RoomServer {
-	r := &RoomServer{ totalSeat: tableSeatCount * tableCount, userGetter: &rpcUserGetter{} }
-	r.wsServer = msg_server.NewWsServer(srvPort, r.userGetter, r)
+	return NewRoomServerWithOptions(tableCount, tableSeatCount, tableLevel, msg_server.DefaultWsOptions(srvPort))
+}
+
+// opts是websocket服务的配置，监听端口、TLS、origin等
+func NewRoomServerWithOptions(tableCount int, tableSeatCount int, tableLevel int, opts msg_server.WsOptions) *RoomServer {
+	return newRoomServer(tableCount, tableSeatCount, tableLevel, opts, &rpcUserGetter{})
+}
+
+func newRoomServer(tableCount int, tableSeatCount int, tableLevel int, opts msg_server.WsOptions, userGetter roomUserGetter) *RoomServer {
+	r := &RoomServer{ totalSeat: tableSeatCount * tableCount, userGetter: userGetter, drainTimeout: defaultDrainTimeout }
+	r.wsServer = msg_server.NewWsServerWithOptions(opts, r.userGetter, r)
+	r.wsServer.AddCodec(pb.NewCodec())
+	r.wsServer.EnableCompression(flate.BestSpeed)
+	r.wsServer.EnableBatching(batchWindow)
+	r.guardMsgs()
+	r.wsServer.Handle("/health", http.HandlerFunc(r.health))
+	r.wsServer.Handle("/metrics", promhttp.Handler())
 
 	tables := make([]abstracts.Table, tableCount)
 	for i := 0; i < tableCount; i++ {
@@ -29,6 +66,17 @@ func NewRoomServer(tableCount int, tableSeatCount int, tableLevel int, srvPort i
 	return r
 }
 
+type roomUserGetter interface {
+	GetUserByToken(token string) msg_server.AbsUser
+	GetUser(id string) abstracts.User
+}
+
+// 可以从快照恢复的桌子
+type recoverableTable interface {
+	EnableSnapshot(store core.SnapshotStore, interval time.Duration)
+	Restore(s *core.TableSnapshot, getUser func(uID string) abstracts.User, resume bool) error
+}
+
 /*
 
 完成game server
@@ -45,12 +93,44 @@ type RoomServer struct {
 	//users map[string]abstracts.Table
 	users sync.Map
 
-	userGetter *rpcUserGetter
+	userGetter roomUserGetter
 
 	started uint32
+	// 停服中，不再接受新用户入座
+	draining uint32
+	drainTimeout time.Duration
+
+	// 崩溃恢复，为nil则不存快照
+	snapshotStore core.SnapshotStore
+	snapshotInterval time.Duration
+	// 重启时正在打的局是接着打还是作废
+	resumeGames bool
+}
+
+// 停服时最多等多久让桌子上正在打的局结束，超时则取消并退回下注
+func (r *RoomServer) SetDrainTimeout(timeout time.Duration) {
+	r.drainTimeout = timeout
+}
+
+// 握手后加密会话，signKey是服务器长期的ed25519私钥，必须在Start之前调用
+func (r *RoomServer) EnableEncryption(signKey ed25519.PrivateKey, required bool) {
+	r.wsServer.EnableEncryption(signKey, required)
 }
 
-func (r *RoomServer) Handle(uID string, msgType int, mID int64, msg []byte) error  {
+/*
+
+开启崩溃恢复，必须在Start之前调用
+桌子每隔interval存一次快照，Start时用快照恢复桌子，坐回去的用户可以直接用quick start拿到桌子的场景
+resume为true时正在打的局接着打，否则作废并退回下注
+
+*/
+func (r *RoomServer) EnableRecovery(store core.SnapshotStore, interval time.Duration, resume bool) {
+	r.snapshotStore = store
+	r.snapshotInterval = interval
+	r.resumeGames = resume
+}
+
+func (r *RoomServer) Handle(uID string, msgType int, mID int64, msg []byte, codec msg_server.Codec) error  {
 	u := r.userGetter.GetUser(uID)
 	if u == nil {
 		log.L.Debug("call quick start, but can't find user", zap.String("uid", uID))
@@ -65,7 +145,7 @@ func (r *RoomServer) Handle(uID string, msgType int, mID int64, msg []byte) erro
 		r.ready(abstracts.CommonMsg{ MsgID: mID, User: u })
 	case abstracts.MsgTypeGameAction:
 		var gMsg abstracts.PlayerActionMsg
-		if err := util.ParseJsonFromBytes(msg, &gMsg); err != nil {
+		if err := codec.Unmarshal(msg, &gMsg); err != nil {
 			return err
 		}
 		gMsg.UserID = uID
@@ -75,9 +155,44 @@ func (r *RoomServer) Handle(uID string, msgType int, mID int64, msg []byte) erro
 	return nil
 }
 
+// 只接受客户端能发的消息，限流并校验格式
+func (r *RoomServer) guardMsgs() {
+	r.wsServer.SetRateLimit(userRateLimit)
+	for _, mt := range []int{ abstracts.MsgTypeQuickStart, abstracts.MsgTypeLeave, abstracts.MsgTypeReady } {
+		r.wsServer.SetMsgRateLimit(mt, seatRateLimit)
+		r.wsServer.AddMsgSchema(mt, msg_server.MsgSchema{ MaxSize: 64 })
+	}
+	r.wsServer.AddMsgSchema(abstracts.MsgTypeGameAction, msg_server.MsgSchema{
+		New: func() interface{} { return &abstracts.PlayerActionMsg{} },
+		Validate: validateGameAction,
+	})
+}
+
+func validateGameAction(v interface{}) error {
+	msg := v.(*abstracts.PlayerActionMsg)
+	if msg.ActionType != abstracts.GameActionOfBet && msg.ActionType != abstracts.GameActionOfDiscard {
+		return fmt.Errorf("unknown action type: %v", msg.ActionType)
+	}
+	return nil
+}
+
+// 负载均衡的健康检查，停服中返回503，不再分配新用户过来
+func (r *RoomServer) health(w http.ResponseWriter, req *http.Request) {
+	if atomic.LoadUint32(&r.started) == 0 || atomic.LoadUint32(&r.draining) == 1 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("unavailable"))
+		return
+	}
+	w.Write([]byte("ok"))
+}
+
 // 快速开始
 func (r *RoomServer) quickStart(msg abstracts.CommonMsg) {
 	user := msg.User
+	if atomic.LoadUint32(&r.draining) == 1 {
+		r.sendErr(msg, "room is closing")
+		return
+	}
 	// 如果他已经在某张桌子，则直接将该桌子的场景返回给客户端
 	tmp, ok := r.users.Load(user.ID())
 	if ok {
@@ -143,30 +258,30 @@ func (r *RoomServer) ready(msg abstracts.CommonMsg) {
 func (r *RoomServer) gameMsg(msg abstracts.PlayerActionMsg) {
 	tmp, ok := r.users.Load(msg.UserID)
 	if !ok {
-		r.wsServer.Send(msg.UserID, abstracts.MsgTypeErr, msg.MsgID, util.StringifyJsonToBytes(abstracts.ErrResp{ Info: "user not in any table" }))
+		r.wsServer.Send(msg.UserID, abstracts.MsgTypeErr, msg.MsgID, abstracts.ErrResp{ Info: "user not in any table" })
 		return
 	}
 	t := tmp.(abstracts.Table)
 
 	if err := t.Do(msg); err != nil {
-		r.wsServer.Send(msg.UserID, abstracts.MsgTypeErr, msg.MsgID, util.StringifyJsonToBytes(abstracts.ErrResp{ Info: err.Error() }))
+		r.wsServer.Send(msg.UserID, abstracts.MsgTypeErr, msg.MsgID, abstracts.ErrResp{ Info: err.Error() })
 		return
 	}
 }
 
 // send success
 func (r *RoomServer) sendSuccess(msg abstracts.CommonMsg, info string) {
-	r.wsServer.Send(msg.User.ID(), abstracts.MsgTypeSuccess, msg.MsgID, util.StringifyJsonToBytes(abstracts.SuccessResp{ Info: info }))
+	r.wsServer.Send(msg.User.ID(), abstracts.MsgTypeSuccess, msg.MsgID, abstracts.SuccessResp{ Info: info })
 }
 
 // send err
 func (r *RoomServer) sendErr(msg abstracts.CommonMsg, info string) {
-	r.wsServer.Send(msg.User.ID(), abstracts.MsgTypeErr, msg.MsgID, util.StringifyJsonToBytes(abstracts.ErrResp{ Info: info }))
+	r.wsServer.Send(msg.User.ID(), abstracts.MsgTypeErr, msg.MsgID, abstracts.ErrResp{ Info: info })
 }
 
 // send msg
 func (r *RoomServer) sendMsg(msg abstracts.CommonMsg, mt int, data interface{}) {
-	r.wsServer.Send(msg.User.ID(), mt, msg.MsgID, util.StringifyJsonToBytes(data))
+	r.wsServer.Send(msg.User.ID(), mt, msg.MsgID, data)
 }
 
 func (r *RoomServer) startServer() error {
@@ -174,18 +289,80 @@ func (r *RoomServer) startServer() error {
 	return nil
 }
 
-func (r *RoomServer) stopServer() error { return nil }
+// 桌子都关闭后再停，保证关闭通知都能发出去
+func (r *RoomServer) stopServer() error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return r.wsServer.Shutdown(ctx)
+}
 
 func (r *RoomServer) startTables() error {
+	if err := r.restoreTables(); err != nil {
+		return err
+	}
 	for _, t := range r.tables {
 		if err := t.Start(); err != nil {
 			return err
 		}
+		if r.snapshotStore != nil {
+			r.storeTableUsers(t)
+		}
 	}
 	return nil
 }
 
+// 用快照恢复桌子，没有快照的桌子不用处理
+func (r *RoomServer) restoreTables() error {
+	if r.snapshotStore == nil {
+		return nil
+	}
+	for i, t := range r.tables {
+		rt, ok := t.(recoverableTable)
+		if !ok {
+			return fmt.Errorf("table %v can't be recovered", i)
+		}
+		rt.EnableSnapshot(r.snapshotStore, r.snapshotInterval)
+		s, err := r.snapshotStore.Load(i)
+		if err != nil {
+			return err
+		}
+		if s == nil {
+			continue
+		}
+		if err := rt.Restore(s, r.userGetter.GetUser, r.resumeGames); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 恢复后坐在桌子上的用户
+func (r *RoomServer) storeTableUsers(t abstracts.Table) {
+	for _, p := range t.GetScene("").Players {
+		if p != nil {
+			r.users.Store(p.UserID, t)
+		}
+	}
+}
+
+// 所有桌子同时drain，都打完（或超时取消）并结算后再停
 func (r *RoomServer) stopTables() error {
+	var wg sync.WaitGroup
+	for _, t := range r.tables {
+		wg.Add(1)
+		go func(t abstracts.Table) {
+			defer wg.Done()
+			if err := t.Drain(r.drainTimeout); err != nil {
+				log.L.Error("drain table failed", zap.Error(err))
+			}
+		}(t)
+	}
+	wg.Wait()
+	r.users.Range(func(key, value interface{}) bool {
+		r.users.Delete(key)
+		return true
+	})
+
 	for _, t := range r.tables {
 		if err := t.Stop(); err != nil {
 			return err
@@ -201,7 +378,9 @@ func (r *RoomServer) Start() error {
 
 	if atomic.CompareAndSwapUint32(&r.started, 0, 1) {
 		// start tables
-		r.startTables()
+		if err := r.startTables(); err != nil {
+			return err
+		}
 		// start server
 		r.startServer()
 	} else {
@@ -217,8 +396,13 @@ func (r *RoomServer) Stop() error {
 	}
 
 	if atomic.CompareAndSwapUint32(&r.started, 1, 0) {
-		r.stopTables()
-		r.stopServer()
+		atomic.StoreUint32(&r.draining, 1)
+		if err := r.stopTables(); err != nil {
+			return err
+		}
+		if err := r.stopServer(); err != nil {
+			return err
+		}
 	} else {
 		log.L.Warn("stop room atomic.CompareAndSwapUint32(&r.started... is false")
 	}
@@ -1,7 +1,171 @@
 package texas
 
-import "testing"
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/common/msg_server"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/abstracts/pb"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/abstracts"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/core"
+)
 
 func TestNewRoomServer(t *testing.T) {
-	
+
+}
+
+type fakeUser struct {
+	uid string
+	balance uint64
+}
+
+func (u *fakeUser) ID() string { return u.uid }
+
+// 和rpc用户一样，所有副本的余额都是同一份
+func (u *fakeUser) Copy() abstracts.User { return u }
+
+func (u *fakeUser) Balance() uint64 { return u.balance }
+
+func (u *fakeUser) ChangeBalance(dis uint64, isAdd bool) {
+	if isAdd {
+		u.balance += dis
+	} else {
+		u.balance -= dis
+	}
+}
+
+type fakeUserGetter struct {
+	users map[string]*fakeUser
+}
+
+func (g *fakeUserGetter) GetUserByToken(token string) msg_server.AbsUser {
+	return g.users[token]
+}
+
+func (g *fakeUserGetter) GetUser(id string) abstracts.User {
+	u, ok := g.users[id]
+	if !ok {
+		return nil
+	}
+	return u
+}
+
+// 模拟进程挂掉：桌子不drain直接停，快照留在store中
+func killRoom(r *RoomServer) {
+	for _, t := range r.tables {
+		t.Stop()
+	}
+	r.wsServer.Shutdown(context.Background())
+	time.Sleep(50 * time.Millisecond)
+}
+
+func doAction(r *RoomServer, uID string, player uint, round uint, amount uint64) {
+	// 用protobuf编码，和json一样能解出来
+	codec := pb.NewCodec()
+	msg, err := codec.Marshal(abstracts.PlayerActionMsg{ Player: player, Round: round, ActionType: abstracts.GameActionOfBet, Amount: amount })
+	if err != nil {
+		panic(err)
+	}
+	r.Handle(uID, abstracts.MsgTypeGameAction, time.Now().UnixNano(), msg, codec)
+	time.Sleep(20 * time.Millisecond)
+}
+
+// 打到一半挂掉，重启后用快照接着打完，发出的牌和崩溃前牌堆中的一样
+func TestRoomServer_Recovery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "room_snapshot")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	store := core.NewFileSnapshotStore(dir)
+	getter := &fakeUserGetter{ users: map[string]*fakeUser{
+		"1": { uid: "1", balance: 5000 },
+		"2": { uid: "2", balance: 5000 },
+	} }
+
+	r := newRoomServer(1, 3, 1, msg_server.DefaultWsOptions(3335), getter)
+	r.EnableRecovery(store, 20 * time.Millisecond, true)
+	assert.NoError(t, r.Start())
+	r.Handle("1", abstracts.MsgTypeQuickStart, 1, nil, msg_server.JsonCodec)
+	r.Handle("2", abstracts.MsgTypeQuickStart, 2, nil, msg_server.JsonCodec)
+	r.Handle("1", abstracts.MsgTypeReady, 3, nil, msg_server.JsonCodec)
+	r.Handle("2", abstracts.MsgTypeReady, 4, nil, msg_server.JsonCodec)
+	time.Sleep(2100 * time.Millisecond)
+
+	// 1号座位的"2"是D（两个人时也是小盲）先说话，跟注到20后进入第二轮
+	doAction(r, "2", 0, 1, 10)
+	time.Sleep(50 * time.Millisecond)
+	killRoom(r)
+
+	s, err := store.Load(0)
+	assert.NoError(t, err)
+	if !assert.NotNil(t, s) || !assert.NotNil(t, s.Game) {
+		return
+	}
+	assert.Equal(t, 2, int(s.Game.CurRound))
+	assert.Equal(t, 1, int(s.Game.CurBetPlayer))
+	assert.Len(t, s.Game.Actions, 1)
+	assert.Len(t, s.Game.CommonPokers, 3)
+	turn := s.Game.Deck[0]
+
+	// 重启，用户还在原来的桌子上，这局接着打
+	r = newRoomServer(1, 3, 1, msg_server.DefaultWsOptions(3335), getter)
+	r.EnableRecovery(store, 20 * time.Millisecond, true)
+	assert.NoError(t, r.Start())
+	time.Sleep(50 * time.Millisecond)
+	_, ok := r.users.Load("1")
+	assert.True(t, ok)
+	table := r.tables[0]
+	scene := table.GetScene("1")
+	assert.Equal(t, 0, scene.CurBet)
+	assert.Equal(t, 40, int(scene.ChipPools[0].Chips))
+	assert.Len(t, scene.Players[0].Pokers, 2)
+	assert.Len(t, scene.CommonPokers, 3)
+
+	// 都过牌，发出的转牌就是快照中牌堆最上边的一张
+	doAction(r, "1", 1, 2, 0)
+	doAction(r, "2", 0, 2, 0)
+	scene = table.GetScene("1")
+	if assert.Len(t, scene.CommonPokers, 4) {
+		assert.Equal(t, turn, scene.CommonPokers[3].Whole)
+	}
+	// 后边都过牌到结束
+	for round := uint(3); round <= 4; round++ {
+		doAction(r, "1", 1, round, 0)
+		doAction(r, "2", 0, round, 0)
+	}
+	scene = table.GetScene("1")
+	assert.Len(t, scene.ChipPools, 0)
+	assert.Equal(t, 10000, int(getter.users["1"].balance + getter.users["2"].balance))
+	assert.Contains(t, []uint64{ 4980, 5000, 5020 }, getter.users["1"].balance)
+
+	// 正常停服后快照被删除
+	assert.NoError(t, r.Stop())
+	s, err = store.Load(0)
+	assert.NoError(t, err)
+	assert.Nil(t, s)
+}
+
+func TestValidateGameAction(t *testing.T) {
+	assert.NoError(t, validateGameAction(&abstracts.PlayerActionMsg{ ActionType: abstracts.GameActionOfDiscard }))
+	assert.Error(t, validateGameAction(&abstracts.PlayerActionMsg{ ActionType: 7 }))
+}
+
+// 没启动和停服中都不健康
+func TestRoomServer_Health(t *testing.T) {
+	r := newRoomServer(1, 3, 1, msg_server.DefaultWsOptions(3335), &fakeUserGetter{})
+	check := func() int {
+		w := httptest.NewRecorder()
+		r.health(w, httptest.NewRequest("GET", "/health", nil))
+		return w.Code
+	}
+	assert.Equal(t, http.StatusServiceUnavailable, check())
+	atomic.StoreUint32(&r.started, 1)
+	assert.Equal(t, http.StatusOK, check())
+	atomic.StoreUint32(&r.draining, 1)
+	assert.Equal(t, http.StatusServiceUnavailable, check())
 }
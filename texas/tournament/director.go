@@ -0,0 +1,724 @@
+package tournament
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+	"go.uber.org/zap"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/abstracts"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/core"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/common/log"
+)
+
+// 一场比赛最多开多少张桌子
+const maxTables = 1024
+
+type MTTConfig struct {
+	// 开赛时报名人数少于该值则不能开赛
+	MinPlayers int
+	// 每张桌子的座位数
+	SeatCount int
+	// 报名费，从User.Balance中扣除，全部进入奖池
+	BuyIn uint64
+	// 每人的起始筹码，与User.Balance无关
+	StartingStack uint64
+	// 盲注级别，打到最后一级后不再涨
+	Levels []BlindLevel
+	// 每个级别默认持续多久，为0则不涨盲
+	LevelDuration time.Duration
+	// 各名次奖金比例（百分比，总和应为100），0号位为第一名。为空则按参赛人次使用DefaultPayouts
+	Payouts []uint64
+	// 开赛后前多少个级别可以迟到报名（含重入），为0则开赛后不能报名
+	LateRegLevels int
+	// 每人最多参赛几次（含第一次），小于2则不能重入
+	MaxEntries int
+	// 为nil则不能加买
+	AddOn *AddOnRule
+}
+
+/*
+
+多桌锦标赛
+
+报名阶段只扣报名费，调用StartTournament后把所有人随机分到若干张桌子上，每张桌子人数最多相差一个
+每张桌子打完一局都会挂起并通知director，director在两局之间处理：
+1. 淘汰输光的人并按名次发奖
+1. 剩下的人能坐进更少的桌子时，拆掉这张桌子，把人分到人数最少的桌子上
+1. 这张桌子比人数最少的桌子多两个人以上时，把多出来的人移到人数最少的桌子上
+1. 离钱圈只差一个人时进入手对手：每张桌子打完一局后都要等其他桌子也打完，一起结算淘汰，有人淘汰则钱圈破裂，恢复各打各的
+1. 只剩一张桌子时为决赛桌
+1. 只剩一个人时比赛结束
+每次变化都会给所有报名的用户广播大厅消息
+
+迟到报名期间可以继续报名，被淘汰的人也可以重入，新的参赛人次坐到人数最少的桌子上，坐满了则开新桌
+迟到报名结束后奖池和奖金才确定，因此迟到报名期间被淘汰的人没有奖金，也不会进入手对手
+休息级别开始后，每张桌子打完当前这手就挂起，休息结束后一起继续
+
+*/
+func NewDirector(id int, config MTTConfig, sender msgSender) *Director {
+	if config.SeatCount < 2 {
+		panic("tournament table need at least 2 seats")
+	}
+	if config.MinPlayers < 2 {
+		config.MinPlayers = 2
+	}
+	if err := validateLevels(config.Levels, config.LevelDuration); err != nil {
+		panic(err)
+	}
+	if err := validatePayouts(config.Payouts); err != nil {
+		panic(err)
+	}
+	return &Director{
+		MTTConfig: config,
+		id: id, msgSender: sender,
+		newTable: newCoreTable,
+		tables: map[int]*mttTable{},
+		levelTimer: newLevelTimer(),
+		entries: map[string]int{},
+		addOns: map[*stackUser]bool{},
+		registerChan: make(chan userMsg, 1),
+		unregisterChan: make(chan userMsg, 1),
+		addOnChan: make(chan userMsg, 1),
+		startChan: make(chan chan error, 1),
+		lobbyChan: make(chan chan abstracts.TournamentLobbyResp),
+		gameFinishedChan: make(chan tableFinishedMsg, maxTables),
+		stopChan: make(chan struct{}),
+	}
+}
+
+// director记录的一张桌子
+type mttTable struct {
+	id int
+	table tournamentTable
+	players []*stackUser
+	// 打完一局挂起，等待director处理
+	held bool
+}
+
+type tableFinishedMsg struct {
+	tableID int
+	busted []core.BustedUser
+}
+
+type Director struct {
+	MTTConfig
+	id int
+	msgSender msgSender
+	newTable tableFactory
+	// 奖池，报名费和加买费用都进入奖池
+	prizePool uint64
+	// 各名次的奖金，0号位为第一名
+	prizes []uint64
+
+	// 每个参赛人次，按报名顺序。重入的用户会有多个，只有最后一个可能还没被淘汰
+	players []*stackUser
+	// 每个用户参赛了几次
+	entries map[string]int
+	// 已经加买过的参赛人次
+	addOns map[*stackUser]bool
+	// 被淘汰的用户，先淘汰的在前
+	busted []*stackUser
+	tables map[int]*mttTable
+	// 上一张新开的桌子id
+	lastTableID int
+	// 用户当前所在的桌子，uid -> tournamentTable，其他goroutine转发游戏消息时用
+	userTables sync.Map
+	// 手对手时已经打完但还没结算的淘汰
+	pendingBusted []core.BustedUser
+	curLevel int
+	status int
+	handForHand bool
+	finalTable bool
+
+	levelTimer levelTimer
+	registerChan chan userMsg
+	unregisterChan chan userMsg
+	addOnChan chan userMsg
+	startChan chan chan error
+	// 不缓冲，否则Stop后请求还能放进去，Lobby会一直等结果
+	lobbyChan chan chan abstracts.TournamentLobbyResp
+	// 每张桌子挂起后才会再回调，桌子数不超过maxTables，所以不会写满
+	gameFinishedChan chan tableFinishedMsg
+	// 只close不重新赋值，loop一直在读它
+	stopChan chan struct{}
+	stopOnce sync.Once
+	started int32
+}
+
+func (d *Director) loop() {
+	for {
+		select {
+		case msg := <- d.registerChan:
+			d.doRegister(msg)
+		case msg := <- d.unregisterChan:
+			d.doUnregister(msg)
+		case msg := <- d.addOnChan:
+			d.doAddOn(msg)
+		case result := <- d.startChan:
+			result <- d.startTournament()
+		case result := <- d.lobbyChan:
+			result <- d.lobby()
+		case msg := <- d.gameFinishedChan:
+			d.doGameFinished(msg)
+		case <- d.levelTimer.C():
+			d.levelUp()
+		case <- d.stopChan:
+			d.levelTimer.Stop()
+			return
+		}
+	}
+}
+
+// 开赛前报名，或者迟到报名期间报名、重入
+func (d *Director) doRegister(msg userMsg) {
+	if d.status == statusFinished || (d.status == statusRunning && !d.lateRegOpen()) {
+		msg.resultChan <- errors.New("registration closed")
+		return
+	}
+	if p := d.findPlayer(msg.user.ID()); p != nil {
+		if !d.isBusted(p) {
+			msg.resultChan <- errors.New("already registered")
+			return
+		}
+		if d.entries[p.ID()] >= d.MaxEntries {
+			msg.resultChan <- errors.New("no more re-entry")
+			return
+		}
+	}
+	if msg.user.Balance() < d.BuyIn {
+		msg.resultChan <- errors.New("not enough balance")
+		return
+	}
+	msg.user.ChangeBalance(d.BuyIn, false)
+	p := newStackUser(msg.user, d.StartingStack)
+	d.players = append(d.players, p)
+	d.entries[p.ID()]++
+	d.prizePool += d.BuyIn
+	msg.resultChan <- nil
+
+	if d.status == statusRunning {
+		d.seatLateEntry(p)
+		d.calcPrizes()
+		d.broadcastLobby()
+	}
+}
+
+// 开赛前可以退赛，退还报名费
+func (d *Director) doUnregister(msg userMsg) {
+	if d.status != statusRegistering {
+		msg.resultChan <- errors.New("tournament already started")
+		return
+	}
+	for i, p := range d.players {
+		if p.ID() == msg.user.ID() {
+			p.u.ChangeBalance(d.BuyIn, true)
+			d.players = append(d.players[:i], d.players[i+1:]...)
+			delete(d.entries, p.ID())
+			d.prizePool -= d.BuyIn
+			msg.resultChan <- nil
+			return
+		}
+	}
+	msg.resultChan <- errors.New("not registered")
+}
+
+// 在加买的级别中，每个还没被淘汰的参赛人次可以加买一次，筹码直接加到身上，当前这手结束后按新筹码结算
+func (d *Director) doAddOn(msg userMsg) {
+	if d.AddOn == nil || d.status != statusRunning || d.curLevel != d.AddOn.Level {
+		msg.resultChan <- errors.New("add on not available")
+		return
+	}
+	p := d.findPlayer(msg.user.ID())
+	if p == nil || d.isBusted(p) {
+		msg.resultChan <- errors.New("not in tournament")
+		return
+	}
+	if d.addOns[p] {
+		msg.resultChan <- errors.New("already added on")
+		return
+	}
+	if msg.user.Balance() < d.AddOn.Cost {
+		msg.resultChan <- errors.New("not enough balance")
+		return
+	}
+	msg.user.ChangeBalance(d.AddOn.Cost, false)
+	p.ChangeBalance(d.AddOn.Chips, true)
+	d.addOns[p] = true
+	d.prizePool += d.AddOn.Cost
+	d.calcPrizes()
+	msg.resultChan <- nil
+}
+
+// 迟到报名期间奖池一直在变，每次变化都重新计算奖金
+func (d *Director) calcPrizes() {
+	payouts := d.Payouts
+	if payouts == nil {
+		payouts = DefaultPayouts(len(d.players))
+	}
+	d.prizes = calcPrizes(d.prizePool, payouts)
+}
+
+func (d *Director) lateRegOpen() bool {
+	return d.status == statusRunning && d.curLevel < d.LateRegLevels
+}
+
+// 迟到报名的人坐到人数最少的桌子上，都坐满了则开一张新桌子
+func (d *Director) seatLateEntry(p *stackUser) {
+	t := d.smallestTable(nil)
+	if t == nil {
+		t = d.addTable()
+		if err := t.table.Start(); err != nil {
+			panic(err)
+		}
+	}
+	d.seat(p, t)
+}
+
+func (d *Director) addTable() *mttTable {
+	if len(d.tables) >= maxTables {
+		panic("too many tournament tables")
+	}
+	d.lastTableID++
+	t := &mttTable{ id: d.lastTableID, table: d.newTable(d.lastTableID, d.SeatCount, d.tableLevel(), d.msgSender, d) }
+	d.tables[t.id] = t
+	return t
+}
+
+func (d *Director) seat(p *stackUser, t *mttTable) {
+	if err := t.table.Enter(p); err != nil {
+		log.L.Error("tournament player enter table failed", zap.String("uid", p.ID()), zap.Error(err))
+		return
+	}
+	t.players = append(t.players, p)
+	d.userTables.Store(p.ID(), t.table)
+	d.sendMsg(p.ID(), abstracts.MsgTypeTournamentStart, abstracts.TournamentStartResp{ TournamentID: d.id, TableID: t.id, StartingStack: d.StartingStack })
+}
+
+// 随机分桌，桌子数量尽量少，各桌人数最多相差一个
+func (d *Director) startTournament() error {
+	if d.status != statusRegistering {
+		return errors.New("tournament already started")
+	}
+	if len(d.players) < d.MinPlayers {
+		return errors.New("not enough players")
+	}
+	log.L.Info("tournament start", zap.Int("id", d.id), zap.Int("player count", len(d.players)))
+	d.status = statusRunning
+	d.calcPrizes()
+
+	tableCount := (len(d.players) + d.SeatCount - 1) / d.SeatCount
+	ts := make([]*mttTable, tableCount)
+	for i := range ts {
+		ts[i] = d.addTable()
+	}
+	seats := rand.Perm(len(d.players))
+	for i, seat := range seats {
+		ts[i % tableCount].players = append(ts[i % tableCount].players, d.players[seat])
+	}
+	for _, t := range ts {
+		if err := t.table.Start(); err != nil {
+			panic(err)
+		}
+		players := t.players
+		t.players = nil
+		for _, p := range players {
+			d.seat(p, t)
+		}
+	}
+	if len(d.tables) == 1 && !d.lateRegOpen() {
+		d.toFinalTable()
+	}
+	d.resetLevelTimer()
+	d.broadcastLobby()
+	return nil
+}
+
+func (d *Director) tableLevel() core.TableLevel {
+	return tableLevelAt(d.Levels, d.curLevel)
+}
+
+func (d *Director) resetLevelTimer() {
+	if duration := levelDurationAt(d.Levels, d.curLevel, d.LevelDuration); duration > 0 {
+		d.levelTimer.Reset(duration)
+	}
+}
+
+func (d *Director) onBreak() bool {
+	return d.Levels[d.curLevel].Break
+}
+
+// 桌子每局结束后回调，在桌子的loop中执行，因此只能转到自己的loop中处理
+func (d *Director) OnGameFinished(tableID int, busted []core.BustedUser) {
+	d.gameFinishedChan <- tableFinishedMsg{ tableID: tableID, busted: busted }
+}
+
+func (d *Director) doGameFinished(msg tableFinishedMsg) {
+	if d.status != statusRunning {
+		return
+	}
+	t := d.tables[msg.tableID]
+	if t == nil {
+		log.L.Error("finished table not in tournament", zap.Int("table id", msg.tableID))
+		return
+	}
+	t.held = true
+	for _, b := range msg.busted {
+		t.removePlayer(b.User.ID())
+		d.userTables.Delete(b.User.ID())
+	}
+	d.pendingBusted = append(d.pendingBusted, msg.busted...)
+
+	// 手对手时要等所有桌子都打完这一手
+	if d.handForHand && !d.allHeld() {
+		return
+	}
+	d.settleBusted()
+	if d.remainCount() <= 1 {
+		d.finish()
+		return
+	}
+
+	if d.handForHand && d.remainCount() <= len(d.prizes) {
+		log.L.Info("tournament bubble burst", zap.Int("id", d.id))
+		d.handForHand = false
+	} else if !d.handForHand && !d.lateRegOpen() && d.remainCount() == len(d.prizes) + 1 && len(d.tables) > 1 {
+		log.L.Info("tournament hand for hand", zap.Int("id", d.id))
+		d.handForHand = true
+		d.broadcast(abstracts.MsgTypeTournamentHandForHand, d.lobby())
+		if !d.allHeld() {
+			return
+		}
+	}
+
+	d.rearrange()
+	if len(d.tables) == 1 && !d.finalTable && !d.lateRegOpen() {
+		d.toFinalTable()
+	}
+	d.resumeTables()
+	d.broadcastLobby()
+}
+
+// 挂起的桌子继续下一局。休息时不继续，手对手时要等所有桌子都打完
+func (d *Director) resumeTables() {
+	if d.onBreak() || (d.handForHand && !d.allHeld()) {
+		return
+	}
+	for _, t := range d.tables {
+		if t.held {
+			t.held = false
+			t.table.Resume()
+		}
+	}
+}
+
+// 同时结算的淘汰，开局时筹码少的名次靠后，因此先淘汰
+func (d *Director) settleBusted() {
+	sort.SliceStable(d.pendingBusted, func(i, j int) bool {
+		return d.pendingBusted[i].OriginChip < d.pendingBusted[j].OriginChip
+	})
+	for _, b := range d.pendingBusted {
+		p := d.findPlayer(b.User.ID())
+		if p == nil {
+			log.L.Error("busted user not in tournament", zap.String("uid", b.User.ID()))
+			continue
+		}
+		d.pay(p, d.remainCount())
+		d.busted = append(d.busted, p)
+	}
+	d.pendingBusted = nil
+}
+
+// 挂起的桌子按需拆桌或者平衡人数，只有挂起的桌子能把人移走
+func (d *Director) rearrange() {
+	for _, t := range d.sortedTables() {
+		if !t.held {
+			continue
+		}
+		needTables := (d.remainCount() + d.SeatCount - 1) / d.SeatCount
+		if len(d.tables) > needTables {
+			d.breakTable(t)
+			continue
+		}
+		for {
+			target := d.smallestTable(t)
+			if target == nil || len(t.players) <= len(target.players) + 1 {
+				break
+			}
+			d.movePlayer(t.players[len(t.players) - 1], t, target)
+		}
+	}
+}
+
+// 拆桌，桌上的人依次坐到人数最少的桌子上
+func (d *Director) breakTable(t *mttTable) {
+	log.L.Info("tournament break table", zap.Int("id", d.id), zap.Int("table id", t.id))
+	delete(d.tables, t.id)
+	for len(t.players) > 0 {
+		target := d.smallestTable(t)
+		if target == nil {
+			panic("no table to move to")
+		}
+		d.movePlayer(t.players[0], t, target)
+	}
+	if err := t.table.Stop(); err != nil {
+		log.L.Error("stop broken table failed", zap.Error(err))
+	}
+}
+
+func (d *Director) movePlayer(p *stackUser, from *mttTable, to *mttTable) {
+	if err := from.table.Remove(p); err != nil {
+		log.L.Error("remove user from table failed", zap.String("uid", p.ID()), zap.Error(err))
+		return
+	}
+	from.removePlayer(p.ID())
+	if err := to.table.Enter(p); err != nil {
+		log.L.Error("move user to table failed", zap.String("uid", p.ID()), zap.Error(err))
+		return
+	}
+	to.players = append(to.players, p)
+	d.userTables.Store(p.ID(), to.table)
+	d.sendMsg(p.ID(), abstracts.MsgTypeTournamentMoveTable, abstracts.TournamentMoveResp{ TournamentID: d.id, FromTableID: from.id, ToTableID: to.id })
+}
+
+// 除exclude外人数最少且还有空位的桌子，人数相同取id小的。exclude可以为nil
+func (d *Director) smallestTable(exclude *mttTable) *mttTable {
+	var result *mttTable
+	for _, t := range d.sortedTables() {
+		if t == exclude || len(t.players) >= d.SeatCount {
+			continue
+		}
+		if result == nil || len(t.players) < len(result.players) {
+			result = t
+		}
+	}
+	return result
+}
+
+// 按桌子id排序，保证每次处理的顺序一致
+func (d *Director) sortedTables() []*mttTable {
+	result := make([]*mttTable, 0, len(d.tables))
+	for _, t := range d.tables {
+		result = append(result, t)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].id < result[j].id
+	})
+	return result
+}
+
+// 有人的桌子是否都打完了，只剩一个人的桌子不会开局，不用等
+func (d *Director) allHeld() bool {
+	for _, t := range d.tables {
+		if !t.held && len(t.players) > 1 {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *Director) toFinalTable() {
+	log.L.Info("tournament final table", zap.Int("id", d.id))
+	d.finalTable = true
+	d.broadcast(abstracts.MsgTypeTournamentFinalTable, d.lobby())
+}
+
+// 还没被淘汰的人数
+func (d *Director) remainCount() int {
+	return len(d.players) - len(d.busted)
+}
+
+func (d *Director) finish() {
+	for _, p := range d.players {
+		if !d.isBusted(p) {
+			d.pay(p, 1)
+		}
+	}
+	log.L.Info("tournament finished", zap.Int("id", d.id))
+	d.status = statusFinished
+	d.levelTimer.Stop()
+	for _, t := range d.tables {
+		if err := t.table.Stop(); err != nil {
+			log.L.Error("stop tournament table failed", zap.Error(err))
+		}
+	}
+	d.broadcastLobby()
+}
+
+// 按名次发放奖金，并通知用户。迟到报名期间奖金还没确定，被淘汰的人没有奖金
+func (d *Director) pay(p *stackUser, place int) {
+	var prize uint64
+	if place <= len(d.prizes) && !d.lateRegOpen() {
+		prize = d.prizes[place - 1]
+	}
+	if prize > 0 {
+		p.u.ChangeBalance(prize, true)
+	}
+	log.L.Info("tournament player finished", zap.Int("id", d.id), zap.String("uid", p.ID()), zap.Int("place", place), zap.Uint64("prize", prize))
+	d.sendMsg(p.ID(), abstracts.MsgTypeTournamentResult, abstracts.TournamentResultResp{ TournamentID: d.id, Place: place, Prize: prize })
+}
+
+func (d *Director) levelUp() {
+	if d.status != statusRunning {
+		return
+	}
+	if d.curLevel < len(d.Levels) - 1 {
+		d.curLevel++
+		for _, t := range d.tables {
+			t.table.SetLevel(d.tableLevel())
+		}
+		d.broadcast(abstracts.MsgTypeTournamentLevelUp, levelResp(d.id, d.Levels, d.curLevel, d.LevelDuration))
+		if d.curLevel == d.LateRegLevels {
+			log.L.Info("tournament late registration closed", zap.Int("id", d.id), zap.Int("entrants", len(d.players)), zap.Uint64("prize pool", d.prizePool))
+			if len(d.tables) == 1 && !d.finalTable {
+				d.toFinalTable()
+			}
+		}
+		// 休息结束后继续
+		d.resumeTables()
+		d.broadcastLobby()
+	}
+	d.resetLevelTimer()
+}
+
+func (d *Director) lobby() abstracts.TournamentLobbyResp {
+	result := abstracts.TournamentLobbyResp{
+		TournamentID: d.id,
+		Level: d.curLevel,
+		Xm: d.tableLevel().Xm,
+		Entrants: len(d.players),
+		PrizePool: d.prizePool,
+		Prizes: append([]uint64{}, d.prizes...),
+		LateRegistration: d.lateRegOpen(),
+		Remain: d.remainCount(),
+		HandForHand: d.handForHand,
+		FinalTable: d.finalTable,
+		Break: d.status == statusRunning && d.onBreak(),
+		Finished: d.status == statusFinished,
+	}
+	if result.Remain > 0 {
+		var total uint64
+		for _, p := range d.players {
+			if !d.isBusted(p) {
+				total += p.Balance()
+			}
+		}
+		result.AvgStack = total / uint64(result.Remain)
+	}
+	for _, t := range d.sortedTables() {
+		result.Tables = append(result.Tables, &abstracts.TournamentTableScene{ TableID: t.id, PlayerCount: len(t.players) })
+	}
+	return result
+}
+
+func (d *Director) broadcastLobby() {
+	d.broadcast(abstracts.MsgTypeTournamentLobby, d.lobby())
+}
+
+// 发给所有报名的用户，包括已经淘汰的
+func (d *Director) broadcast(msgType int, msg interface{}) {
+	for _, p := range d.players {
+		d.sendMsg(p.ID(), msgType, msg)
+	}
+}
+
+// 用户最后一次参赛
+func (d *Director) findPlayer(uID string) *stackUser {
+	for i := len(d.players) - 1; i >= 0; i-- {
+		if d.players[i].ID() == uID {
+			return d.players[i]
+		}
+	}
+	return nil
+}
+
+func (d *Director) isBusted(p *stackUser) bool {
+	for _, b := range d.busted {
+		if b == p {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Director) sendMsg(uID string, msgType int, msg interface{}) {
+	d.msgSender.Send(uID, msgType, time.Now().UnixNano(), msg)
+}
+
+func (t *mttTable) removePlayer(uID string) {
+	for i, p := range t.players {
+		if p.ID() == uID {
+			t.players = append(t.players[:i], t.players[i+1:]...)
+			return
+		}
+	}
+}
+
+func (d *Director) Register(u abstracts.User) error {
+	result := make(chan error)
+	d.registerChan <- userMsg{ user: u, resultChan: result }
+	return <- result
+}
+
+func (d *Director) Unregister(u abstracts.User) error {
+	result := make(chan error)
+	d.unregisterChan <- userMsg{ user: u, resultChan: result }
+	return <- result
+}
+
+// 加买
+func (d *Director) AddOnChips(u abstracts.User) error {
+	result := make(chan error)
+	d.addOnChan <- userMsg{ user: u, resultChan: result }
+	return <- result
+}
+
+// 分桌开赛，有迟到报名时开赛后还可以报名
+func (d *Director) StartTournament() error {
+	result := make(chan error)
+	d.startChan <- result
+	return <- result
+}
+
+// 比赛的当前情况，在loop中生成。比赛已经Stop时返回空的结果
+func (d *Director) Lobby() abstracts.TournamentLobbyResp {
+	result := make(chan abstracts.TournamentLobbyResp, 1)
+	select {
+	case d.lobbyChan <- result:
+		return <- result
+	case <- d.stopChan:
+		return abstracts.TournamentLobbyResp{}
+	}
+}
+
+// 用户当前所在的桌子，用户的游戏消息都转给该桌子。没在比赛中则返回nil
+func (d *Director) TableOf(uID string) abstracts.Table {
+	if t, ok := d.userTables.Load(uID); ok {
+		return t.(abstracts.Table)
+	}
+	return nil
+}
+
+func (d *Director) Start() error {
+	if !atomic.CompareAndSwapInt32(&d.started, 0, 1) {
+		return errors.New("already started")
+	}
+	go d.loop()
+
+	return nil
+}
+
+func (d *Director) Stop() error {
+	if atomic.LoadInt32(&d.started) == 0 {
+		return errors.New("not started")
+	}
+	stopped := errors.New("already stopped")
+	d.stopOnce.Do(func() {
+		close(d.stopChan)
+		stopped = nil
+	})
+
+	return stopped
+}
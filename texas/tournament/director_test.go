@@ -0,0 +1,292 @@
+package tournament
+
+import (
+	"strconv"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/abstracts"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/core"
+)
+
+func newTestDirector(tables *[]*fakeTable, sender *fakeMsgSender, playerCount int, payouts []uint64) (*Director, []*fakeUser) {
+	d, users, _ := newTestDirectorWithConfig(tables, sender, playerCount, MTTConfig{
+		MinPlayers: 4,
+		SeatCount: 6,
+		BuyIn: 100,
+		StartingStack: 1500,
+		Levels: []BlindLevel{ { Xm: 10 }, { Xm: 20 } },
+		Payouts: payouts,
+	})
+	return d, users
+}
+
+// 涨盲计时器手动触发
+// 桌子回调不缓冲，OnGameFinished返回时已经开始处理，之后的Lobby一定在处理完之后才返回
+func newTestDirectorWithConfig(tables *[]*fakeTable, sender *fakeMsgSender, playerCount int, config MTTConfig) (*Director, []*fakeUser, *fakeLevelTimer) {
+	d := NewDirector(1, config, sender)
+	d.newTable = newFakeTableFactory(tables)
+	timer := newFakeLevelTimer()
+	d.levelTimer = timer
+	d.gameFinishedChan = make(chan tableFinishedMsg)
+	if err := d.Start(); err != nil {
+		panic(err)
+	}
+	var users []*fakeUser
+	for i := 0; i < playerCount; i++ {
+		u := &fakeUser{ uid: strconv.Itoa(i), balance: 1000 }
+		if err := d.Register(u); err != nil {
+			panic(err)
+		}
+		users = append(users, u)
+	}
+	return d, users, timer
+}
+
+// 模拟桌子打完一局，count个人被淘汰，返回director处理完后的大厅
+func finishHand(d *Director, table *fakeTable, count int) abstracts.TournamentLobbyResp {
+	var busted []core.BustedUser
+	for _, u := range table.users[:count] {
+		busted = append(busted, core.BustedUser{ User: u, OriginChip: u.Balance() })
+	}
+	for _, b := range busted {
+		b.User.ChangeBalance(b.User.Balance(), false)
+		table.Leave(b.User)
+	}
+	d.OnGameFinished(table.id, busted)
+	return d.Lobby()
+}
+
+// 随机分桌，各桌人数最多相差一个
+func TestDirector_Start(t *testing.T) {
+	var tables []*fakeTable
+	d, users := newTestDirector(&tables, &fakeMsgSender{}, 3, nil)
+	assert.Error(t, d.StartTournament())
+	assert.NoError(t, d.Unregister(users[0]))
+	assert.Equal(t, 1000, int(users[0].balance))
+
+	for i := 3; i < 21; i++ {
+		assert.NoError(t, d.Register(&fakeUser{ uid: strconv.Itoa(i), balance: 1000 }))
+	}
+	assert.NoError(t, d.StartTournament())
+	assert.Error(t, d.StartTournament())
+	assert.Error(t, d.Register(users[0]))
+
+	// 20个人，每桌6个座位，分4桌每桌5个人
+	assert.Len(t, tables, 4)
+	for _, table := range tables {
+		assert.True(t, table.started)
+		assert.Len(t, table.users, 5)
+		for _, u := range table.users {
+			assert.Equal(t, table, d.TableOf(u.ID()))
+		}
+	}
+	assert.Nil(t, d.TableOf(users[0].ID()))
+	lobby := d.Lobby()
+	assert.Len(t, lobby.Prizes, 5)
+	assert.Len(t, lobby.Tables, 4)
+	assert.False(t, lobby.FinalTable)
+
+	assert.NoError(t, d.Stop())
+}
+
+// 两局之间平衡人数，人少了拆桌，只剩一张桌子时为决赛桌
+func TestDirector_BalanceAndBreak(t *testing.T) {
+	var tables []*fakeTable
+	sender := &fakeMsgSender{}
+	d, users := newTestDirector(&tables, sender, 12, []uint64{ 100 })
+	assert.NoError(t, d.StartTournament())
+	t1, t2 := tables[0], tables[1]
+
+	// 桌1淘汰3个人，只有挂起的桌子能移人，桌1人少不需要移
+	finishHand(d, t1, 3)
+	assert.Len(t, t1.users, 3)
+	assert.Equal(t, 1, t1.resumeCount)
+
+	// 桌2打完后比桌1多3个人，移一个到桌1
+	finishHand(d, t2, 0)
+	assert.Len(t, t1.users, 4)
+	assert.Len(t, t2.users, 5)
+	assert.Equal(t, 1, t2.resumeCount)
+	moved := t1.users[3]
+	assert.Equal(t, t1, d.TableOf(moved.ID()))
+	assert.Equal(t, 1, sender.count(moved.ID(), abstracts.MsgTypeTournamentMoveTable))
+
+	// 剩6个人一张桌子坐得下，拆掉桌1
+	lobby := finishHand(d, t1, 3)
+	assert.Len(t, lobby.Tables, 1)
+	assert.False(t, t1.started)
+	assert.Len(t, t2.users, 6)
+	assert.True(t, lobby.FinalTable)
+	assert.Equal(t, 1, sender.count(users[0].ID(), abstracts.MsgTypeTournamentFinalTable))
+
+	// 冠军拿走全部奖池
+	lobby = finishHand(d, t2, 5)
+	assert.True(t, lobby.Finished)
+	assert.False(t, t2.started)
+	winner := t2.users[0].(*stackUser).u.(*fakeUser)
+	assert.Equal(t, 900 + 1200, int(winner.balance))
+
+	assert.NoError(t, d.Stop())
+}
+
+// 离钱圈差一个人时手对手，每张桌子要等其他桌子打完
+func TestDirector_HandForHand(t *testing.T) {
+	var tables []*fakeTable
+	sender := &fakeMsgSender{}
+	d, users := newTestDirector(&tables, sender, 12, []uint64{ 50, 30, 20 })
+	assert.NoError(t, d.StartTournament())
+	t1, t2 := tables[0], tables[1]
+
+	lobby := finishHand(d, t2, 4)
+	assert.Equal(t, 1, t2.resumeCount)
+	assert.False(t, lobby.HandForHand)
+
+	// 剩4个人，奖励前3名，进入手对手，桌1要等桌2打完
+	lobby = finishHand(d, t1, 4)
+	assert.True(t, lobby.HandForHand)
+	assert.Equal(t, 0, t1.resumeCount)
+	assert.Equal(t, 1, sender.count(users[0].ID(), abstracts.MsgTypeTournamentHandForHand))
+
+	// 桌2打完，没人淘汰，4个人拆成一桌继续手对手
+	lobby = finishHand(d, t2, 0)
+	assert.True(t, lobby.HandForHand)
+	assert.Len(t, lobby.Tables, 1)
+	assert.Len(t, t2.users, 4)
+	assert.Equal(t, 2, t2.resumeCount)
+
+	// 同一手淘汰两个人，开局筹码多的拿到第3名，钱圈破裂
+	big, small := t2.users[0], t2.users[1]
+	big.ChangeBalance(500, true)
+	lobby = finishHand(d, t2, 2)
+	assert.False(t, lobby.HandForHand)
+	assert.Equal(t, 2, lobby.Remain)
+	bigUser := big.(*stackUser).u.(*fakeUser)
+	smallUser := small.(*stackUser).u.(*fakeUser)
+	assert.Equal(t, 900 + 1200 * 20 / 100, int(bigUser.balance))
+	assert.Equal(t, 900, int(smallUser.balance))
+
+	assert.NoError(t, d.Stop())
+}
+
+// 迟到报名期间可以报名和重入，桌子坐满了开新桌，迟到报名结束后奖金才确定
+func TestDirector_LateRegistration(t *testing.T) {
+	var tables []*fakeTable
+	d, users, timer := newTestDirectorWithConfig(&tables, &fakeMsgSender{}, 6, MTTConfig{
+		MinPlayers: 2,
+		SeatCount: 6,
+		BuyIn: 100,
+		StartingStack: 1500,
+		Levels: []BlindLevel{ { Xm: 10, Duration: 100 * time.Millisecond }, { Xm: 20 } },
+		Payouts: []uint64{ 100 },
+		LateRegLevels: 1,
+		MaxEntries: 2,
+	})
+	assert.NoError(t, d.StartTournament())
+	assert.Len(t, tables, 1)
+	// 迟到报名还没结束，不算决赛桌
+	lobby := d.Lobby()
+	assert.True(t, lobby.LateRegistration)
+	assert.False(t, lobby.FinalTable)
+
+	late := &fakeUser{ uid: "late", balance: 1000 }
+	assert.NoError(t, d.Register(late))
+	assert.Error(t, d.Register(late))
+	assert.Len(t, tables, 2)
+	assert.Len(t, tables[1].users, 1)
+	assert.Equal(t, tables[1], d.TableOf(late.ID()))
+
+	// 淘汰后重入，迟到报名期间被淘汰没有奖金
+	table := d.TableOf(users[0].ID()).(*fakeTable)
+	table.users = append([]abstracts.User{ findUser(table.users, users[0].ID()) }, removeUser(table.users, users[0].ID())...)
+	finishHand(d, table, 1)
+	assert.Equal(t, 900, int(users[0].balance))
+	assert.Nil(t, d.TableOf(users[0].ID()))
+	assert.NoError(t, d.Register(users[0]))
+	assert.Equal(t, 800, int(users[0].balance))
+	assert.NotNil(t, d.TableOf(users[0].ID()))
+	lobby = d.Lobby()
+	assert.Equal(t, 8, lobby.Entrants)
+	assert.Equal(t, 800, int(lobby.PrizePool))
+	assert.Equal(t, []uint64{ 800 }, lobby.Prizes)
+
+	// 最多参赛两次
+	table = d.TableOf(users[0].ID()).(*fakeTable)
+	table.users = append([]abstracts.User{ findUser(table.users, users[0].ID()) }, removeUser(table.users, users[0].ID())...)
+	finishHand(d, table, 1)
+	assert.Error(t, d.Register(users[0]))
+
+	// 迟到报名结束
+	assert.Equal(t, []time.Duration{ 100 * time.Millisecond }, timer.durations)
+	timer.fire()
+	lobby = d.Lobby()
+	assert.Equal(t, 1, lobby.Level)
+	assert.False(t, lobby.LateRegistration)
+	assert.Error(t, d.Register(&fakeUser{ uid: "too late", balance: 1000 }))
+
+	assert.NoError(t, d.Stop())
+}
+
+// 休息时桌子打完这手后挂起，休息结束一起继续。加买只能在指定级别
+func TestDirector_BreakAndAddOn(t *testing.T) {
+	var tables []*fakeTable
+	d, users, timer := newTestDirectorWithConfig(&tables, &fakeMsgSender{}, 12, MTTConfig{
+		MinPlayers: 2,
+		SeatCount: 6,
+		BuyIn: 100,
+		StartingStack: 1500,
+		Levels: []BlindLevel{
+			{ Xm: 10, Ante: 5, Duration: 50 * time.Millisecond },
+			{ Break: true, Duration: 100 * time.Millisecond },
+			{ Xm: 20, Dm: 50 },
+		},
+		AddOn: &AddOnRule{ Level: 1, Cost: 50, Chips: 1000 },
+	})
+	assert.NoError(t, d.StartTournament())
+	assert.Equal(t, 5, int(tables[0].levels[0].Ante))
+	assert.Error(t, d.AddOnChips(users[0]))
+
+	timer.fire()
+	assert.True(t, d.Lobby().Break)
+	// 休息时沿用上一个级别的盲注
+	assert.Equal(t, 10, int(tables[0].levels[1].Xm))
+	finishHand(d, tables[0], 0)
+	assert.Equal(t, 0, tables[0].resumeCount)
+
+	assert.NoError(t, d.AddOnChips(users[0]))
+	assert.Error(t, d.AddOnChips(users[0]))
+	assert.Equal(t, 850, int(users[0].balance))
+	assert.Equal(t, 2500, int(findUser(d.TableOf(users[0].ID()).(*fakeTable).users, users[0].ID()).Balance()))
+	assert.Equal(t, 1250, int(d.Lobby().PrizePool))
+
+	// 休息结束
+	assert.Equal(t, []time.Duration{ 50 * time.Millisecond, 100 * time.Millisecond }, timer.durations)
+	timer.fire()
+	lobby := d.Lobby()
+	assert.Equal(t, 2, lobby.Level)
+	assert.False(t, lobby.Break)
+	assert.Equal(t, 1, tables[0].resumeCount)
+	assert.Equal(t, 0, tables[1].resumeCount)
+	assert.Equal(t, 50, int(tables[0].levels[2].Dm))
+	assert.Error(t, d.AddOnChips(users[1]))
+
+	assert.NoError(t, d.Stop())
+}
+
+func findUser(users []abstracts.User, uID string) abstracts.User {
+	for _, u := range users {
+		if u.ID() == uID {
+			return u
+		}
+	}
+	return nil
+}
+
+func removeUser(users []abstracts.User, uID string) (result []abstracts.User) {
+	for _, u := range users {
+		if u.ID() != uID {
+			result = append(result, u)
+		}
+	}
+	return
+}
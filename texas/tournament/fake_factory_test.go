@@ -0,0 +1,126 @@
+package tournament
+
+import (
+	"sync"
+	"time"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/abstracts"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/core"
+)
+
+// 记录发给每个用户的消息类型
+type fakeMsgSender struct {
+	lock sync.Mutex
+	msgTypes map[string][]int
+}
+
+func (s *fakeMsgSender) Send(id string, msgType int, mID int64, msg interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.msgTypes == nil {
+		s.msgTypes = map[string][]int{}
+	}
+	s.msgTypes[id] = append(s.msgTypes[id], msgType)
+}
+
+func (s *fakeMsgSender) count(id string, msgType int) (count int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, t := range s.msgTypes[id] {
+		if t == msgType {
+			count++
+		}
+	}
+	return
+}
+
+type fakeUser struct {
+	uid string
+	balance uint64
+}
+
+func (u *fakeUser) ID() string { return u.uid }
+
+func (u *fakeUser) Copy() abstracts.User { return &fakeUser{ uid: u.uid, balance: u.balance } }
+
+func (u *fakeUser) Balance() uint64 { return u.balance }
+
+func (u *fakeUser) ChangeBalance(dis uint64, isAdd bool) {
+	if isAdd {
+		u.balance += dis
+	} else {
+		u.balance -= dis
+	}
+}
+
+// 只记录锦标赛对桌子做了什么
+type fakeTable struct {
+	id int
+	started bool
+	users []abstracts.User
+	levels []core.TableLevel
+	resumeCount int
+}
+
+func newFakeTableFactory(tables *[]*fakeTable) tableFactory {
+	return func(id int, seatCount int, level core.TableLevel, sender msgSender, observer core.TableObserver) tournamentTable {
+		t := &fakeTable{ id: id, levels: []core.TableLevel{ level } }
+		*tables = append(*tables, t)
+		return t
+	}
+}
+
+func (t *fakeTable) Start() error { t.started = true; return nil }
+
+func (t *fakeTable) Stop() error { t.started = false; return nil }
+
+func (t *fakeTable) Enter(u abstracts.User) error {
+	t.users = append(t.users, u)
+	return nil
+}
+
+func (t *fakeTable) Remove(u abstracts.User) error {
+	return t.Leave(u)
+}
+
+func (t *fakeTable) Resume() { t.resumeCount++ }
+
+func (t *fakeTable) Drain(timeout time.Duration) error { return nil }
+
+func (t *fakeTable) Leave(u abstracts.User) error {
+	for i, tu := range t.users {
+		if tu.ID() == u.ID() {
+			t.users = append(t.users[:i], t.users[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (t *fakeTable) Ready(u abstracts.User) error { return nil }
+
+func (t *fakeTable) Do(action abstracts.PlayerActionMsg) error { return nil }
+
+func (t *fakeTable) GetScene(uID string) abstracts.TableScene { return abstracts.TableScene{} }
+
+func (t *fakeTable) SetLevel(level core.TableLevel) {
+	t.levels = append(t.levels, level)
+}
+
+// 手动触发的涨盲计时器，记录每次设置的时长
+type fakeLevelTimer struct {
+	c chan time.Time
+	durations []time.Duration
+}
+
+func newFakeLevelTimer() *fakeLevelTimer {
+	return &fakeLevelTimer{ c: make(chan time.Time) }
+}
+
+func (t *fakeLevelTimer) C() <-chan time.Time { return t.c }
+
+func (t *fakeLevelTimer) Reset(d time.Duration) { t.durations = append(t.durations, d) }
+
+func (t *fakeLevelTimer) Stop() {}
+
+// 时间到，loop收到后才返回
+func (t *fakeLevelTimer) fire() { t.c <- time.Now() }
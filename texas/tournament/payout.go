@@ -0,0 +1,52 @@
+package tournament
+
+import "fmt"
+
+// 根据参赛人数获取默认的奖金比例（百分比），0号位为第一名
+func DefaultPayouts(playerCount int) []uint64 {
+	switch {
+	case playerCount <= 3:
+		return []uint64{ 100 }
+	case playerCount <= 6:
+		return []uint64{ 65, 35 }
+	case playerCount <= 10:
+		return []uint64{ 50, 30, 20 }
+	default:
+		return []uint64{ 40, 25, 15, 12, 8 }
+	}
+}
+
+// 奖金比例的总和必须是100，否则奖池发不完或者不够发。为nil则使用默认比例，不用检查
+func validatePayouts(payouts []uint64) error {
+	if payouts == nil {
+		return nil
+	}
+	var total uint64
+	for _, p := range payouts {
+		total += p
+	}
+	if total != 100 {
+		return fmt.Errorf("payouts sum to %v, not 100", total)
+	}
+	return nil
+}
+
+/*
+
+按比例计算各名次的奖金，0号位为第一名，payouts必须先通过validatePayouts
+除不尽的余数都给第一名，保证奖池全部发完
+奖池很大时prizePool * p会溢出，因此先除100再乘，余数部分单独算
+
+*/
+func calcPrizes(prizePool uint64, payouts []uint64) []uint64 {
+	result := make([]uint64, len(payouts))
+	var total uint64
+	for i, p := range payouts {
+		result[i] = prizePool / 100 * p + prizePool % 100 * p / 100
+		total += result[i]
+	}
+	if len(result) > 0 && total < prizePool {
+		result[0] += prizePool - total
+	}
+	return result
+}
@@ -0,0 +1,418 @@
+package tournament
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+	"go.uber.org/zap"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/abstracts"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/core"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/common/log"
+)
+
+type msgSender interface {
+	Send(id string, msgType int, mID int64, msg interface{})
+}
+
+// 锦标赛用到的桌子功能，测试时可以替换成假的桌子
+type tournamentTable interface {
+	abstracts.Table
+	SetLevel(level core.TableLevel)
+	// 两局之间把用户移走
+	Remove(u abstracts.User) error
+	// 每局结束后桌子会挂起，处理完后继续下一局
+	Resume()
+}
+
+type tableFactory func(id int, seatCount int, level core.TableLevel, sender msgSender, observer core.TableObserver) tournamentTable
+
+func newCoreTable(id int, seatCount int, level core.TableLevel, sender msgSender, observer core.TableObserver) tournamentTable {
+	return core.NewTournamentTable(id, seatCount, level, sender, observer)
+}
+
+// 涨盲计时器，只在锦标赛的loop中使用，测试时可以替换成手动触发的
+type levelTimer interface {
+	C() <-chan time.Time
+	Reset(d time.Duration)
+	Stop()
+}
+
+func newLevelTimer() levelTimer {
+	t := time.NewTimer(time.Second)
+	t.Stop()
+	return &timeLevelTimer{ t: t }
+}
+
+type timeLevelTimer struct {
+	t *time.Timer
+}
+
+func (t *timeLevelTimer) C() <-chan time.Time { return t.t.C }
+
+func (t *timeLevelTimer) Reset(d time.Duration) { t.t.Reset(d) }
+
+func (t *timeLevelTimer) Stop() { t.t.Stop() }
+
+const (
+	statusRegistering = iota
+	statusRunning
+	statusFinished
+)
+
+type SitAndGoConfig struct {
+	// 报满该人数后开赛，也是桌子的座位数
+	PlayerCount int
+	// 报名费，从User.Balance中扣除，全部进入奖池
+	BuyIn uint64
+	// 每人的起始筹码，与User.Balance无关
+	StartingStack uint64
+	// 盲注级别，打到最后一级后不再涨
+	Levels []BlindLevel
+	// 每个级别默认持续多久，为0则不按时间涨盲（休息级别必须有时间）
+	LevelDuration time.Duration
+	// 每个级别打多少手牌，为0则不按手数涨盲
+	LevelHands int
+	// 各名次奖金比例（百分比，总和应为100），0号位为第一名。为空则使用DefaultPayouts
+	Payouts []uint64
+}
+
+/*
+
+单桌锦标赛（坐满即玩）
+
+报名时扣除报名费，人满后开一张锦标赛桌子，所有人带着相同的起始筹码坐下
+按时间或手数涨盲，休息时打完当前这手后暂停，每局结束后筹码输光的人被淘汰，淘汰时就按名次发放奖金（走User.ChangeBalance）
+只剩一个人时比赛结束
+
+*/
+func NewSitAndGo(id int, config SitAndGoConfig, sender msgSender) *SitAndGo {
+	if config.PlayerCount < 2 {
+		panic("sit and go need at least 2 players")
+	}
+	if err := validateLevels(config.Levels, config.LevelDuration); err != nil {
+		panic(err)
+	}
+	if err := validatePayouts(config.Payouts); err != nil {
+		panic(err)
+	}
+	if config.Payouts == nil {
+		config.Payouts = DefaultPayouts(config.PlayerCount)
+	}
+	return &SitAndGo{
+		SitAndGoConfig: config,
+		id: id, msgSender: sender,
+		newTable: newCoreTable,
+		prizes: calcPrizes(config.BuyIn * uint64(config.PlayerCount), config.Payouts),
+		levelTimer: newLevelTimer(),
+		registerChan: make(chan userMsg, 1),
+		unregisterChan: make(chan userMsg, 1),
+		lobbyChan: make(chan chan abstracts.TournamentLobbyResp),
+		gameFinishedChan: make(chan []core.BustedUser, 10),
+		stopChan: make(chan struct{}),
+	}
+}
+
+type SitAndGo struct {
+	SitAndGoConfig
+	id int
+	msgSender msgSender
+	newTable tableFactory
+	table tournamentTable
+	// 各名次的奖金，0号位为第一名
+	prizes []uint64
+
+	// 报名的用户，按报名顺序
+	players []*stackUser
+	// 被淘汰的用户，先淘汰的在前
+	busted []*stackUser
+	curLevel int
+	handCount int
+	status int
+	// 休息时桌子打完一局后挂起，休息结束再继续
+	held bool
+
+	levelTimer levelTimer
+	registerChan chan userMsg
+	unregisterChan chan userMsg
+	// 不缓冲，否则Stop后请求还能放进去，Lobby会一直等结果
+	lobbyChan chan chan abstracts.TournamentLobbyResp
+	gameFinishedChan chan []core.BustedUser
+	// 只close不重新赋值，loop一直在读它
+	stopChan chan struct{}
+	stopOnce sync.Once
+	started int32
+}
+
+type userMsg struct {
+	user abstracts.User
+	resultChan chan error
+}
+
+func (s *SitAndGo) loop() {
+	for {
+		select {
+		case msg := <- s.registerChan:
+			s.doRegister(msg)
+		case msg := <- s.unregisterChan:
+			s.doUnregister(msg)
+		case result := <- s.lobbyChan:
+			result <- s.lobby()
+		case busted := <- s.gameFinishedChan:
+			s.doGameFinished(busted)
+		case <- s.levelTimer.C():
+			s.levelUp()
+		case <- s.stopChan:
+			s.levelTimer.Stop()
+			return
+		}
+	}
+}
+
+func (s *SitAndGo) doRegister(msg userMsg) {
+	if s.status != statusRegistering {
+		msg.resultChan <- errors.New("registration closed")
+		return
+	}
+	if s.findPlayer(msg.user.ID()) != nil {
+		msg.resultChan <- errors.New("already registered")
+		return
+	}
+	if msg.user.Balance() < s.BuyIn {
+		msg.resultChan <- errors.New("not enough balance")
+		return
+	}
+	msg.user.ChangeBalance(s.BuyIn, false)
+	s.players = append(s.players, newStackUser(msg.user, s.StartingStack))
+	// 满员时开完赛再返回，报名返回后桌子已经建好
+	if len(s.players) == s.PlayerCount {
+		s.startTournament()
+	}
+	msg.resultChan <- nil
+}
+
+// 开赛前可以退赛，退还报名费
+func (s *SitAndGo) doUnregister(msg userMsg) {
+	if s.status != statusRegistering {
+		msg.resultChan <- errors.New("tournament already started")
+		return
+	}
+	for i, p := range s.players {
+		if p.ID() == msg.user.ID() {
+			p.u.ChangeBalance(s.BuyIn, true)
+			s.players = append(s.players[:i], s.players[i+1:]...)
+			msg.resultChan <- nil
+			return
+		}
+	}
+	msg.resultChan <- errors.New("not registered")
+}
+
+func (s *SitAndGo) startTournament() {
+	log.L.Info("sit and go start", zap.Int("id", s.id), zap.Int("player count", len(s.players)))
+	s.status = statusRunning
+	s.table = s.newTable(s.id, s.PlayerCount, s.tableLevel(), s.msgSender, s)
+	if err := s.table.Start(); err != nil {
+		panic(err)
+	}
+	for _, p := range s.players {
+		if err := s.table.Enter(p); err != nil {
+			log.L.Error("sit and go player enter table failed", zap.String("uid", p.ID()), zap.Error(err))
+			continue
+		}
+		s.sendMsg(p.ID(), abstracts.MsgTypeTournamentStart, abstracts.TournamentStartResp{ TournamentID: s.id, TableID: s.id, StartingStack: s.StartingStack })
+	}
+	s.resetLevelTimer()
+}
+
+func (s *SitAndGo) tableLevel() core.TableLevel {
+	return tableLevelAt(s.Levels, s.curLevel)
+}
+
+func (s *SitAndGo) resetLevelTimer() {
+	if d := levelDurationAt(s.Levels, s.curLevel, s.LevelDuration); d > 0 {
+		s.levelTimer.Reset(d)
+	}
+}
+
+// 桌子每局结束后回调，在桌子的loop中执行，因此只能转到自己的loop中处理
+func (s *SitAndGo) OnGameFinished(tableID int, busted []core.BustedUser) {
+	s.gameFinishedChan <- busted
+}
+
+func (s *SitAndGo) doGameFinished(busted []core.BustedUser) {
+	if s.status != statusRunning {
+		return
+	}
+	s.handCount++
+
+	// 同一局被淘汰的人，开局时筹码少的名次靠后，因此先淘汰
+	sort.SliceStable(busted, func(i, j int) bool {
+		return busted[i].OriginChip < busted[j].OriginChip
+	})
+	for _, b := range busted {
+		p := s.findPlayer(b.User.ID())
+		if p == nil {
+			log.L.Error("busted user not in sit and go", zap.String("uid", b.User.ID()))
+			continue
+		}
+		s.pay(p, s.remainCount())
+		s.busted = append(s.busted, p)
+	}
+
+	if s.remainCount() <= 1 {
+		s.finish()
+		return
+	}
+	if s.LevelHands > 0 && s.handCount % s.LevelHands == 0 && !s.Levels[s.curLevel].Break {
+		s.levelUp()
+	}
+	if s.Levels[s.curLevel].Break {
+		s.held = true
+		return
+	}
+	s.table.Resume()
+}
+
+// 还没被淘汰的人数
+func (s *SitAndGo) remainCount() int {
+	return len(s.players) - len(s.busted)
+}
+
+func (s *SitAndGo) finish() {
+	for _, p := range s.players {
+		if !s.isBusted(p) {
+			s.pay(p, 1)
+		}
+	}
+	log.L.Info("sit and go finished", zap.Int("id", s.id))
+	s.status = statusFinished
+	s.levelTimer.Stop()
+	if err := s.table.Stop(); err != nil {
+		log.L.Error("stop sit and go table failed", zap.Error(err))
+	}
+}
+
+// 按名次发放奖金，并通知用户
+func (s *SitAndGo) pay(p *stackUser, place int) {
+	var prize uint64
+	if place <= len(s.prizes) {
+		prize = s.prizes[place - 1]
+	}
+	if prize > 0 {
+		p.u.ChangeBalance(prize, true)
+	}
+	log.L.Info("sit and go player finished", zap.Int("id", s.id), zap.String("uid", p.ID()), zap.Int("place", place), zap.Uint64("prize", prize))
+	s.sendMsg(p.ID(), abstracts.MsgTypeTournamentResult, abstracts.TournamentResultResp{ TournamentID: s.id, Place: place, Prize: prize })
+}
+
+func (s *SitAndGo) levelUp() {
+	if s.status != statusRunning {
+		return
+	}
+	if s.curLevel < len(s.Levels) - 1 {
+		s.curLevel++
+		s.table.SetLevel(s.tableLevel())
+		resp := levelResp(s.id, s.Levels, s.curLevel, s.LevelDuration)
+		for _, p := range s.players {
+			if !s.isBusted(p) {
+				s.sendMsg(p.ID(), abstracts.MsgTypeTournamentLevelUp, resp)
+			}
+		}
+		// 休息结束
+		if s.held && !s.Levels[s.curLevel].Break {
+			s.held = false
+			s.table.Resume()
+		}
+	}
+	s.resetLevelTimer()
+}
+
+func (s *SitAndGo) lobby() abstracts.TournamentLobbyResp {
+	return abstracts.TournamentLobbyResp{
+		TournamentID: s.id,
+		Level: s.curLevel,
+		Xm: s.tableLevel().Xm,
+		Entrants: len(s.players),
+		PrizePool: s.BuyIn * uint64(s.PlayerCount),
+		Prizes: s.prizes,
+		Remain: s.remainCount(),
+		Break: s.status == statusRunning && s.Levels[s.curLevel].Break,
+		FinalTable: s.status == statusRunning,
+		Finished: s.status == statusFinished,
+	}
+}
+
+func (s *SitAndGo) findPlayer(uID string) *stackUser {
+	for _, p := range s.players {
+		if p.ID() == uID {
+			return p
+		}
+	}
+	return nil
+}
+
+func (s *SitAndGo) isBusted(p *stackUser) bool {
+	for _, b := range s.busted {
+		if b == p {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *SitAndGo) sendMsg(uID string, msgType int, msg interface{}) {
+	s.msgSender.Send(uID, msgType, time.Now().UnixNano(), msg)
+}
+
+// 报名，人满后自动开赛
+func (s *SitAndGo) Register(u abstracts.User) error {
+	result := make(chan error)
+	s.registerChan <- userMsg{ user: u, resultChan: result }
+	return <- result
+}
+
+func (s *SitAndGo) Unregister(u abstracts.User) error {
+	result := make(chan error)
+	s.unregisterChan <- userMsg{ user: u, resultChan: result }
+	return <- result
+}
+
+// 比赛的当前情况，在loop中生成。比赛已经Stop时返回空的结果
+func (s *SitAndGo) Lobby() abstracts.TournamentLobbyResp {
+	result := make(chan abstracts.TournamentLobbyResp, 1)
+	select {
+	case s.lobbyChan <- result:
+		return <- result
+	case <- s.stopChan:
+		return abstracts.TournamentLobbyResp{}
+	}
+}
+
+// 开赛后用户的游戏消息都转给该桌子
+func (s *SitAndGo) Table() abstracts.Table {
+	return s.table
+}
+
+func (s *SitAndGo) Start() error {
+	if !atomic.CompareAndSwapInt32(&s.started, 0, 1) {
+		return errors.New("already started")
+	}
+	go s.loop()
+
+	return nil
+}
+
+func (s *SitAndGo) Stop() error {
+	if atomic.LoadInt32(&s.started) == 0 {
+		return errors.New("not started")
+	}
+	stopped := errors.New("already stopped")
+	s.stopOnce.Do(func() {
+		close(s.stopChan)
+		stopped = nil
+	})
+
+	return stopped
+}
@@ -0,0 +1,187 @@
+package tournament
+
+import (
+	"math"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/core"
+)
+
+// 涨盲计时器手动触发
+// 桌子回调不缓冲，OnGameFinished返回时已经开始处理，之后的Lobby一定在处理完之后才返回
+func newTestSitAndGo(tables *[]*fakeTable) (*SitAndGo, *fakeLevelTimer) {
+	s := NewSitAndGo(1, SitAndGoConfig{
+		PlayerCount: 3,
+		BuyIn: 100,
+		StartingStack: 1500,
+		Levels: []BlindLevel{ { Xm: 10 }, { Xm: 20 }, { Xm: 40 } },
+		LevelHands: 1,
+		Payouts: []uint64{ 70, 30 },
+	}, &fakeMsgSender{})
+	s.newTable = newFakeTableFactory(tables)
+	timer := newFakeLevelTimer()
+	s.levelTimer = timer
+	s.gameFinishedChan = make(chan []core.BustedUser)
+	return s, timer
+}
+
+// 报名、退赛、满员开赛
+func TestSitAndGo_Register(t *testing.T) {
+	var tables []*fakeTable
+	s, _ := newTestSitAndGo(&tables)
+	assert.NoError(t, s.Start())
+
+	u1 := &fakeUser{ uid: "1", balance: 1000 }
+	u2 := &fakeUser{ uid: "2", balance: 1000 }
+	u3 := &fakeUser{ uid: "3", balance: 50 }
+	u4 := &fakeUser{ uid: "4", balance: 1000 }
+
+	assert.NoError(t, s.Register(u1))
+	assert.Error(t, s.Register(u1))
+	assert.Equal(t, 900, int(u1.balance))
+	// 余额不够报名费
+	assert.Error(t, s.Register(u3))
+	assert.Equal(t, 50, int(u3.balance))
+
+	// 退赛退还报名费
+	assert.NoError(t, s.Register(u2))
+	assert.NoError(t, s.Unregister(u2))
+	assert.Equal(t, 1000, int(u2.balance))
+	assert.Error(t, s.Unregister(u2))
+	assert.Len(t, tables, 0)
+
+	assert.NoError(t, s.Register(u2))
+	assert.NoError(t, s.Register(u4))
+	// 满员后开赛
+	assert.Len(t, tables, 1)
+	assert.True(t, tables[0].started)
+	assert.Len(t, tables[0].users, 3)
+	for _, u := range tables[0].users {
+		// 桌上的筹码与用户余额无关
+		assert.Equal(t, 1500, int(u.Balance()))
+	}
+	assert.Error(t, s.Register(&fakeUser{ uid: "5", balance: 1000 }))
+	assert.Error(t, s.Unregister(u1))
+
+	assert.NoError(t, s.Stop())
+}
+
+// 涨盲、淘汰、按名次发奖
+func TestSitAndGo_Finish(t *testing.T) {
+	var tables []*fakeTable
+	s, _ := newTestSitAndGo(&tables)
+	assert.NoError(t, s.Start())
+
+	users := []*fakeUser{ { uid: "1", balance: 1000 }, { uid: "2", balance: 1000 }, { uid: "3", balance: 1000 } }
+	for _, u := range users {
+		assert.NoError(t, s.Register(u))
+	}
+	table := tables[0]
+	p1, p2, p3 := table.users[0], table.users[1], table.users[2]
+
+	// 第一手没人淘汰，每手涨一级
+	s.OnGameFinished(table.id, nil)
+	lobby := s.Lobby()
+	assert.Equal(t, 1, lobby.Level)
+	assert.Equal(t, 3, lobby.Remain)
+	assert.Len(t, table.levels, 2)
+	assert.Equal(t, 20, int(table.levels[1].Xm))
+	assert.Equal(t, 1, table.resumeCount)
+
+	// 同一手淘汰两个人，开局筹码多的名次更好
+	p1.ChangeBalance(3000, true)
+	p2.ChangeBalance(1500, false)
+	p3.ChangeBalance(1500, false)
+	s.OnGameFinished(table.id, []core.BustedUser{ { User: p2, OriginChip: 1000 }, { User: p3, OriginChip: 500 } })
+	lobby = s.Lobby()
+
+	// 奖池300，第一名70%，第二名30%，第三名没有奖金
+	assert.Equal(t, []uint64{ 210, 90 }, lobby.Prizes)
+	assert.Equal(t, 900 + 210, int(users[0].balance))
+	assert.Equal(t, 900 + 90, int(users[1].balance))
+	assert.Equal(t, 900, int(users[2].balance))
+	assert.True(t, lobby.Finished)
+	assert.Equal(t, 1, lobby.Remain)
+	assert.False(t, table.started)
+	// 比赛结束后不再涨盲
+	assert.Len(t, table.levels, 2)
+
+	assert.NoError(t, s.Stop())
+	assert.Error(t, s.Stop())
+}
+
+// 按时间涨盲，打到最后一级后不再涨
+func TestSitAndGo_LevelDuration(t *testing.T) {
+	var tables []*fakeTable
+	s, timer := newTestSitAndGo(&tables)
+	s.LevelHands = 0
+	s.LevelDuration = 20 * time.Millisecond
+	assert.NoError(t, s.Start())
+	for _, uid := range []string{ "1", "2", "3" } {
+		assert.NoError(t, s.Register(&fakeUser{ uid: uid, balance: 1000 }))
+	}
+	// 开赛时开始计时
+	assert.Equal(t, []time.Duration{ 20 * time.Millisecond }, timer.durations)
+
+	timer.fire()
+	timer.fire()
+	assert.Equal(t, 2, s.Lobby().Level)
+	assert.Len(t, tables[0].levels, 3)
+	assert.Equal(t, 40, int(tables[0].levels[2].Xm))
+
+	timer.fire()
+	assert.Equal(t, 2, s.Lobby().Level)
+	assert.Len(t, tables[0].levels, 3)
+
+	assert.NoError(t, s.Stop())
+	// 停了之后不会卡住
+	assert.Equal(t, 0, s.Lobby().TournamentID)
+}
+
+// 休息时桌子打完这手后挂起，休息结束后继续
+func TestSitAndGo_Break(t *testing.T) {
+	var tables []*fakeTable
+	s, timer := newTestSitAndGo(&tables)
+	s.Levels = []BlindLevel{ { Xm: 10 }, { Break: true, Duration: 100 * time.Millisecond }, { Xm: 20 } }
+	assert.NoError(t, s.Start())
+	for _, uid := range []string{ "1", "2", "3" } {
+		assert.NoError(t, s.Register(&fakeUser{ uid: uid, balance: 1000 }))
+	}
+	table := tables[0]
+
+	s.OnGameFinished(table.id, nil)
+	lobby := s.Lobby()
+	assert.Equal(t, 1, lobby.Level)
+	assert.True(t, lobby.Break)
+	assert.Equal(t, 0, table.resumeCount)
+	// 第一个级别不按时间涨盲，休息按自己的时长计时
+	assert.Equal(t, []time.Duration{ 100 * time.Millisecond }, timer.durations)
+
+	timer.fire()
+	lobby = s.Lobby()
+	assert.Equal(t, 2, lobby.Level)
+	assert.False(t, lobby.Break)
+	assert.Equal(t, 1, table.resumeCount)
+	assert.Equal(t, 20, int(table.levels[2].Xm))
+
+	assert.NoError(t, s.Stop())
+}
+
+func TestCalcPrizes(t *testing.T) {
+	assert.Equal(t, []uint64{ 70, 30 }, calcPrizes(100, []uint64{ 70, 30 }))
+	// 余数给第一名
+	assert.Equal(t, []uint64{ 35, 33, 33 }, calcPrizes(101, []uint64{ 34, 33, 33 }))
+	assert.Equal(t, []uint64{ 53, 30, 20 }, calcPrizes(103, []uint64{ 50, 30, 20 }))
+	// 奖池很大时不能溢出
+	// MaxUint64除100余15
+	second := uint64(math.MaxUint64 / 100 * 30 + 15 * 30 / 100)
+	assert.Equal(t, []uint64{ math.MaxUint64 - second, second }, calcPrizes(math.MaxUint64, []uint64{ 70, 30 }))
+	assert.NoError(t, validatePayouts(nil))
+	assert.NoError(t, validatePayouts([]uint64{ 50, 30, 20 }))
+	assert.Error(t, validatePayouts([]uint64{ 50, 30 }))
+	assert.Error(t, validatePayouts([]uint64{}))
+	assert.Panics(t, func() { NewSitAndGo(1, SitAndGoConfig{ PlayerCount: 2, Levels: []BlindLevel{ { Xm: 10 } }, Payouts: []uint64{ 70, 40 } }, nil) })
+	assert.Len(t, DefaultPayouts(2), 1)
+	assert.Len(t, DefaultPayouts(9), 3)
+}
@@ -0,0 +1,43 @@
+package tournament
+
+import (
+	"sync/atomic"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/abstracts"
+)
+
+func newStackUser(u abstracts.User, stack uint64) *stackUser {
+	return &stackUser{ u: u, stack: stack }
+}
+
+/*
+
+锦标赛中的用户
+Balance为该用户在锦标赛中的筹码，与真实的User.Balance无关，桌子每局结算时改的也是这个筹码
+桌子坐下时会Copy，这里返回自己，保证桌子和锦标赛看到的是同一份筹码
+
+*/
+type stackUser struct {
+	u abstracts.User
+	// 桌子loop和锦标赛loop都会读写，用atomic
+	stack uint64
+}
+
+func (su *stackUser) ID() string {
+	return su.u.ID()
+}
+
+func (su *stackUser) Copy() abstracts.User {
+	return su
+}
+
+func (su *stackUser) Balance() uint64 {
+	return atomic.LoadUint64(&su.stack)
+}
+
+func (su *stackUser) ChangeBalance(dis uint64, isAdd bool) {
+	if isAdd {
+		atomic.AddUint64(&su.stack, dis)
+	} else {
+		atomic.AddUint64(&su.stack, ^(dis - 1))
+	}
+}
@@ -0,0 +1,211 @@
+package tournament
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"time"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/abstracts"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/texas/core"
+	"github.com/LeaguesOfHoleHoleShoes/HoleHole/common/util"
+)
+
+// 目前支持的赛事结构格式版本，格式有不兼容的改动时加一，并在ParseStructure中兼容旧版本
+const StructureVersion = 1
+
+// 一个盲注级别
+type BlindLevel struct {
+	// 小盲下注多少
+	Xm uint64
+	// 大盲下注多少，为0则是小盲的两倍
+	Dm uint64
+	// 每人的前注，为0则没有前注
+	Ante uint64
+	// 该级别持续多久，为0则使用配置中的LevelDuration
+	Duration time.Duration
+	// 休息，所有桌子打完当前这手后暂停，直到该级别结束。休息级别的盲注不生效
+	Break bool
+}
+
+// 加买规则，在某个级别期间（通常是迟到报名结束时的休息），每个还没被淘汰的人可以加买一次
+type AddOnRule struct {
+	// 在哪个级别期间可以加买，0号位为第一个级别
+	Level int `json:"level"`
+	// 加买的费用，从User.Balance中扣除，全部进入奖池
+	Cost uint64 `json:"cost"`
+	// 加买得到的筹码
+	Chips uint64 `json:"chips"`
+}
+
+/*
+
+赛事结构，由运营用json定义，开新的比赛不需要改代码
+{
+	"version": 1,
+	"name": "daily",
+	"buy_in": 100,
+	"starting_stack": 5000,
+	"level_seconds": 600,
+	"levels": [
+		{ "sb": 25, "bb": 50 },
+		{ "sb": 50, "bb": 100, "ante": 10 },
+		{ "break": true, "seconds": 300 },
+		{ "sb": 100, "bb": 200, "ante": 25 }
+	],
+	"late_reg_levels": 3,
+	"max_entries": 2,
+	"add_on": { "level": 2, "cost": 100, "chips": 5000 }
+}
+
+*/
+type Structure struct {
+	Version int `json:"version"`
+	Name string `json:"name"`
+	BuyIn uint64 `json:"buy_in"`
+	StartingStack uint64 `json:"starting_stack"`
+	// 每个级别默认持续多少秒
+	LevelSeconds int64 `json:"level_seconds"`
+	Levels []StructureLevel `json:"levels"`
+	// 各名次奖金比例（百分比），为空则按人数使用DefaultPayouts
+	Payouts []uint64 `json:"payouts"`
+	// 开赛后前多少个级别可以迟到报名（含重入），为0则开赛后不能报名
+	LateRegLevels int `json:"late_reg_levels"`
+	// 每人最多参赛几次（含第一次），小于2则不能重入
+	MaxEntries int `json:"max_entries"`
+	AddOn *AddOnRule `json:"add_on"`
+}
+
+type StructureLevel struct {
+	Sb uint64 `json:"sb"`
+	// 为0则是小盲的两倍
+	Bb uint64 `json:"bb"`
+	Ante uint64 `json:"ante"`
+	// 该级别持续多少秒，为0则使用level_seconds
+	Seconds int64 `json:"seconds"`
+	Break bool `json:"break"`
+}
+
+// 解析并检查赛事结构
+func ParseStructure(data []byte) (*Structure, error) {
+	var s Structure
+	if err := util.ParseJsonFromBytes(data, &s); err != nil {
+		return nil, err
+	}
+	if s.Version != StructureVersion {
+		return nil, fmt.Errorf("unsupported structure version: %v", s.Version)
+	}
+	if s.StartingStack == 0 {
+		return nil, errors.New("starting stack is 0")
+	}
+	if err := validateLevels(s.BlindLevels(), time.Duration(s.LevelSeconds) * time.Second); err != nil {
+		return nil, err
+	}
+	if err := validatePayouts(s.Payouts); err != nil {
+		return nil, err
+	}
+	if s.LateRegLevels < 0 || s.LateRegLevels > len(s.Levels) {
+		return nil, errors.New("invalid late registration levels")
+	}
+	if s.AddOn != nil && (s.AddOn.Level < 0 || s.AddOn.Level >= len(s.Levels) || s.AddOn.Chips == 0) {
+		return nil, errors.New("invalid add on rule")
+	}
+	return &s, nil
+}
+
+func LoadStructure(path string) (*Structure, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseStructure(data)
+}
+
+func (s *Structure) BlindLevels() []BlindLevel {
+	result := make([]BlindLevel, len(s.Levels))
+	for i, l := range s.Levels {
+		result[i] = BlindLevel{ Xm: l.Sb, Dm: l.Bb, Ante: l.Ante, Duration: time.Duration(l.Seconds) * time.Second, Break: l.Break }
+	}
+	return result
+}
+
+// 多桌锦标赛的配置
+func (s *Structure) MTTConfig(seatCount int, minPlayers int) MTTConfig {
+	return MTTConfig{
+		MinPlayers: minPlayers,
+		SeatCount: seatCount,
+		BuyIn: s.BuyIn,
+		StartingStack: s.StartingStack,
+		Levels: s.BlindLevels(),
+		LevelDuration: time.Duration(s.LevelSeconds) * time.Second,
+		Payouts: s.Payouts,
+		LateRegLevels: s.LateRegLevels,
+		MaxEntries: s.MaxEntries,
+		AddOn: s.AddOn,
+	}
+}
+
+// 单桌锦标赛的配置，单桌锦标赛人满开赛，没有迟到报名、重入和加买
+func (s *Structure) SitAndGoConfig(playerCount int) SitAndGoConfig {
+	return SitAndGoConfig{
+		PlayerCount: playerCount,
+		BuyIn: s.BuyIn,
+		StartingStack: s.StartingStack,
+		Levels: s.BlindLevels(),
+		LevelDuration: time.Duration(s.LevelSeconds) * time.Second,
+		Payouts: s.Payouts,
+	}
+}
+
+// 第一个级别不能是休息，休息级别必须有持续时间，否则永远结束不了
+func validateLevels(levels []BlindLevel, defaultDuration time.Duration) error {
+	if len(levels) == 0 {
+		return errors.New("no blind levels")
+	}
+	if levels[0].Break {
+		return errors.New("first level can't be a break")
+	}
+	for i, l := range levels {
+		if l.Break {
+			if l.Duration == 0 && defaultDuration == 0 {
+				return fmt.Errorf("break level %v has no duration", i)
+			}
+			continue
+		}
+		if l.Xm == 0 {
+			return fmt.Errorf("level %v has no small blind", i)
+		}
+		if l.Dm != 0 && l.Dm < l.Xm {
+			return fmt.Errorf("level %v big blind less than small blind", i)
+		}
+	}
+	return nil
+}
+
+// 某个级别对应的桌子级别，休息级别沿用上一个级别的盲注
+// 锦标赛桌子带入的是用户的全部筹码，也不会因为筹码少而被踢
+func tableLevelAt(levels []BlindLevel, cur int) core.TableLevel {
+	for cur > 0 && levels[cur].Break {
+		cur--
+	}
+	l := levels[cur]
+	return core.TableLevel{ Xm: l.Xm, Dm: l.Dm, Ante: l.Ante, BringIn: math.MaxUint64 }
+}
+
+// 某个级别持续多久，为0则不按时间涨盲
+func levelDurationAt(levels []BlindLevel, cur int, defaultDuration time.Duration) time.Duration {
+	if levels[cur].Duration > 0 {
+		return levels[cur].Duration
+	}
+	return defaultDuration
+}
+
+func levelResp(tournamentID int, levels []BlindLevel, cur int, defaultDuration time.Duration) abstracts.TournamentLevelResp {
+	tl := tableLevelAt(levels, cur)
+	return abstracts.TournamentLevelResp{
+		TournamentID: tournamentID, Level: cur,
+		Xm: tl.Xm, Dm: tl.DmBet(), Ante: tl.Ante,
+		Break: levels[cur].Break,
+		Seconds: int64(levelDurationAt(levels, cur, defaultDuration) / time.Second),
+	}
+}
@@ -0,0 +1,66 @@
+package tournament
+
+import (
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+)
+
+const testStructure = `{
+	"version": 1,
+	"name": "daily",
+	"buy_in": 100,
+	"starting_stack": 5000,
+	"level_seconds": 600,
+	"levels": [
+		{ "sb": 25, "bb": 50 },
+		{ "sb": 50, "bb": 100, "ante": 10 },
+		{ "break": true, "seconds": 300 },
+		{ "sb": 100, "ante": 25 }
+	],
+	"late_reg_levels": 3,
+	"max_entries": 2,
+	"add_on": { "level": 2, "cost": 100, "chips": 5000 }
+}`
+
+func TestParseStructure(t *testing.T) {
+	s, err := ParseStructure([]byte(testStructure))
+	assert.NoError(t, err)
+	assert.Equal(t, "daily", s.Name)
+
+	levels := s.BlindLevels()
+	assert.Len(t, levels, 4)
+	assert.Equal(t, 300 * time.Second, levels[2].Duration)
+	assert.Equal(t, 600 * time.Second, levelDurationAt(levels, 0, 600 * time.Second))
+
+	// 休息沿用上一个级别的盲注，大盲为0则是小盲的两倍
+	assert.Equal(t, 100, int(tableLevelAt(levels, 2).DmBet()))
+	assert.Equal(t, 10, int(tableLevelAt(levels, 2).Ante))
+	assert.Equal(t, 200, int(tableLevelAt(levels, 3).DmBet()))
+
+	config := s.MTTConfig(9, 10)
+	assert.Equal(t, 3, config.LateRegLevels)
+	assert.Equal(t, 5000, int(config.AddOn.Chips))
+	assert.Equal(t, 600 * time.Second, config.LevelDuration)
+	assert.Equal(t, 6, s.SitAndGoConfig(6).PlayerCount)
+}
+
+func TestParseStructure_Invalid(t *testing.T) {
+	for _, data := range []string{
+		`{ "version": 2, "starting_stack": 100, "levels": [ { "sb": 10 } ] }`,
+		`{ "version": 1, "levels": [ { "sb": 10 } ] }`,
+		`{ "version": 1, "starting_stack": 100, "levels": [] }`,
+		`{ "version": 1, "starting_stack": 100, "levels": [ { "break": true, "seconds": 60 }, { "sb": 10 } ] }`,
+		`{ "version": 1, "starting_stack": 100, "levels": [ { "sb": 10 }, { "break": true } ] }`,
+		`{ "version": 1, "starting_stack": 100, "levels": [ { "sb": 10, "bb": 5 } ] }`,
+		`{ "version": 1, "starting_stack": 100, "levels": [ { "sb": 10 } ], "late_reg_levels": 2 }`,
+		`{ "version": 1, "starting_stack": 100, "levels": [ { "sb": 10 } ], "add_on": { "level": 1, "chips": 100 } }`,
+		`{ "version": 1, "starting_stack": 100, "levels": [ { "sb": 10 } ], "payouts": [ 60, 30 ] }`,
+		`not json`,
+	} {
+		_, err := ParseStructure([]byte(data))
+		assert.Error(t, err, data)
+	}
+	_, err := LoadStructure("not_exist.json")
+	assert.Error(t, err)
+}